@@ -0,0 +1,129 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrConsistencyViolation is matched by the errors of reads served by a replica behind their required LSN
+var ErrConsistencyViolation = errors.New("dbresolver: replica read violates its required LSN")
+
+// ConsistencyViolation is reported when consistency assertions catch a replica read behind its required LSN
+type ConsistencyViolation struct {
+	Replica     string // Name of the replica, see WithNamedReplicaDBs
+	RequiredLSN LSN
+	ReplayLSN   LSN // Replay LSN of the replica on the connection of the read, right before it
+}
+
+func (v *ConsistencyViolation) Error() string {
+	return fmt.Sprintf("dbresolver: read routed to %s at replay LSN %s, behind its required LSN %s",
+		v.Replica, v.ReplayLSN, v.RequiredLSN)
+}
+
+// Is makes violations match ErrConsistencyViolation
+func (v *ConsistencyViolation) Is(target error) bool {
+	return target == ErrConsistencyViolation
+}
+
+// AssertMode sets how consistency violations are surfaced
+type AssertMode int
+
+const (
+	// AssertError fails the read with a *ConsistencyViolation
+	AssertError AssertMode = iota
+	// AssertPanic panics with a *ConsistencyViolation, failing the test even if the error is ignored
+	AssertPanic
+)
+
+// consistencyAssertions verifies the routing of reads with an LSN requirement. Methods are nil-receiver safe.
+type consistencyAssertions struct {
+	mode AssertMode
+}
+
+// WithConsistencyAssertions turns the read-your-writes contract into runtime checks, meant for tests and CI.
+// Every replica read carrying a required LSN runs on a dedicated connection, which first queries the replay
+// LSN of its backend again, bypassing the router's cache; since replay only moves forward, the read observes
+// at least that LSN. A replica behind the required LSN is a routing bug, reported according to mode.
+// The check and the read share a session, not a transaction: behind a pooler in transaction mode they may
+// reach different backends of the replica. This doubles the queries of such reads and shouldn't be enabled
+// in production.
+func WithConsistencyAssertions(mode AssertMode) OptionFunc {
+	return func(opt *Option) {
+		opt.ConsistencyAssertions = &consistencyAssertions{mode: mode}
+	}
+}
+
+// querier runs the queries of a read, on a database or on one of its connections
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// applies reports whether the read of ctx on target is checked
+func (a *consistencyAssertions) applies(ctx context.Context, db *DB, target *sql.DB) bool {
+	if a == nil || slices.Contains(db.allPrimaries(), target) {
+		return false
+	}
+	lsnCtx := GetLSNContext(ctx)
+	return lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero()
+}
+
+// readOn runs read on target. A checked read runs on a connection whose replay LSN is verified first, a read
+// with a search_path on a connection the search_path is set on; the connection returns to the pool once the rows
+// of the read are closed.
+func (db *DB) readOn(ctx context.Context, target *sql.DB, read func(querier) error) error {
+	checked := db.assertions.applies(ctx, db, target)
+	if !checked && !db.usesSearchPath(ctx, target) {
+		return read(target)
+	}
+	conn, err := target.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := db.applySearchPath(ctx, target, conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !checked {
+		err = read(conn)
+		go conn.Close() //nolint:errcheck // blocks until the rows of the read are closed
+		return err
+	}
+	if err := db.assertions.check(ctx, db, target, conn); err != nil {
+		_ = conn.Close()
+		if violation := (*ConsistencyViolation)(nil); db.assertions.mode == AssertPanic && errors.As(err, &violation) {
+			panic(violation)
+		}
+		return err
+	}
+	err = read(conn)
+	go conn.Close() //nolint:errcheck // blocks until the rows of the read are closed
+	return err
+}
+
+// check verifies that the backend of conn, on replica, has replayed the LSN required by the read
+func (a *consistencyAssertions) check(ctx context.Context, db *DB, replica *sql.DB, conn *sql.Conn) error {
+	lsnCtx := GetLSNContext(ctx)
+
+	var replayLSN sql.NullString
+	if err := conn.QueryRowContext(ctx, "SELECT "+PGLastWalReplayLSN+"::text").Scan(&replayLSN); err != nil {
+		return fmt.Errorf("consistency assertion: failed to query replay LSN: %w", err)
+	}
+	lsn, err := ParseLSN(replayLSN.String)
+	if err != nil {
+		return fmt.Errorf("consistency assertion: %w", err)
+	}
+	if !lsn.LessThan(lsnCtx.RequiredLSN) {
+		return nil
+	}
+
+	return &ConsistencyViolation{
+		Replica:     physicalDBName(db, replica),
+		RequiredLSN: lsnCtx.RequiredLSN,
+		ReplayLSN:   lsn,
+	}
+}
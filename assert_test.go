@@ -0,0 +1,89 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConsistencyAssertions(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	// without causal consistency routing, LSN requirements are ignored and the contract is broken
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithConsistencyAssertions(AssertError))
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x5000}})
+	expectReplayLSN(replicaMock, "0/4000")
+	_, err := db.QueryContext(ctx, "SELECT id FROM orders")
+	var violation *ConsistencyViolation
+	if !errors.As(err, &violation) || !errors.Is(err, ErrConsistencyViolation) {
+		t.Fatalf("want a consistency violation, got %v", err)
+	}
+	if violation.Replica != "replica-0" || violation.ReplayLSN != (LSN{Lower: 0x4000}) {
+		t.Errorf("unexpected violation %+v", violation)
+	}
+
+	// a caught up replica serves the read
+	expectReplayLSN(replicaMock, "0/5000")
+	replicaMock.ExpectQuery("SELECT id FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err := db.QueryContext(ctx, "SELECT id FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	// reads without a requirement aren't checked
+	replicaMock.ExpectQuery("SELECT id FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err = db.QueryContext(context.Background(), "SELECT id FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsistencyAssertionsPanic(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithConsistencyAssertions(AssertPanic))
+
+	defer func() {
+		if _, ok := recover().(*ConsistencyViolation); !ok {
+			t.Error("expected a panic with the consistency violation")
+		}
+	}()
+	expectReplayLSN(replicaMock, "0/4000")
+	var id int
+	_ = db.QueryRowContext(WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x5000}}),
+		"SELECT id FROM orders").Scan(&id)
+}
+
+func TestConsistencyAssertionsReleaseConnection(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	replica.SetMaxOpenConns(1) // the next read blocks until the checked connection is released
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithConsistencyAssertions(AssertError))
+	ctx, cancel := context.WithTimeout(WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x5000}}),
+		time.Second)
+	defer cancel()
+
+	for range 2 {
+		expectReplayLSN(replicaMock, "0/5000")
+		replicaMock.ExpectQuery("SELECT id FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		var id int
+		if err := db.QueryRowContext(ctx, "SELECT id FROM orders").Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
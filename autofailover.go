@@ -0,0 +1,109 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// FailoverEvent is sent to the failover callback when a replica is promoted to the primary role
+type FailoverEvent struct {
+	Primary  string    // Name of the promoted replica before its promotion
+	Replaced []*sql.DB // Primaries replaced by the promoted replica, left open for the caller to close
+	Err      error     // Error of the check of the replaced primaries
+}
+
+// autoFailover promotes the replica that left recovery when the primaries are lost
+type autoFailover struct {
+	interval   time.Duration
+	onFailover func(FailoverEvent)
+}
+
+// WithAutoFailover checks the primaries every interval and, when none is reachable and read/write while
+// exactly one replica reports pg_is_in_recovery() = false, i.e. a standby was promoted, sets that replica
+// as the primary for the writes, see SetPrimary. onFailover, which may be nil, is called after each
+// failover. Nothing is done when several replicas left recovery, which needs a human decision.
+func WithAutoFailover(interval time.Duration, onFailover func(FailoverEvent)) OptionFunc {
+	return func(opt *Option) {
+		opt.AutoFailover = &autoFailover{interval: interval, onFailover: onFailover}
+	}
+}
+
+// runAutoFailover checks the primaries every interval
+func (db *DB) runAutoFailover(stop <-chan struct{}) {
+	ticker := time.NewTicker(db.failover.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			db.checkFailover(context.Background())
+		}
+	}
+}
+
+// checkFailover promotes the replica that left recovery if every primary is lost
+func (db *DB) checkFailover(ctx context.Context) {
+	primaries := db.allPrimaries()
+	errs := make([]error, len(primaries))
+	_ = doParallely(len(primaries), func(i int) error {
+		errs[i] = checkRole(ctx, primaries[i], true)
+		return nil
+	})
+	primaryErr := multierr.Combine(errs...)
+	for _, err := range errs {
+		if err == nil {
+			return
+		}
+	}
+
+	replicas := db.allReplicas()
+	promoted := make([]bool, len(replicas))
+	_ = doParallely(len(replicas), func(i int) error {
+		promoted[i] = errors.Is(checkRole(ctx, replicas[i], false), ErrRoleMismatch)
+		return nil
+	})
+	var candidates []*sql.DB
+	for i, replica := range replicas {
+		if promoted[i] {
+			candidates = append(candidates, replica)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		db.log().Warn("auto failover: primary lost and no replica promoted", "error", primaryErr)
+		return
+	case 1:
+	default:
+		db.log().Warn("auto failover: several replicas left recovery, not failing over", "replicas", len(candidates))
+		return
+	}
+
+	name := physicalDBName(db, candidates[0])
+	if err := db.promoteReplica(candidates[0]); err != nil {
+		db.log().Warn("auto failover: failed to promote replica", "db", name, "error", err)
+		return
+	}
+	if db.failover.onFailover != nil {
+		db.failover.onFailover(FailoverEvent{Primary: name, Replaced: primaries, Err: primaryErr})
+	}
+}
+
+// promoteReplica moves replica from the read pool to the primary role, putting it back on failure
+func (db *DB) promoteReplica(replica *sql.DB) error {
+	if err := db.RemoveReplica(replica); err != nil {
+		return err
+	}
+	if err := db.SetPrimary(replica); err != nil {
+		if addErr := db.AddReplica(replica); addErr != nil {
+			db.log().Warn("auto failover: failed to restore replica", "db", physicalDBName(db, replica), "error", addErr)
+		}
+		return err
+	}
+	return nil
+}
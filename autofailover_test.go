@@ -0,0 +1,54 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAutoFailoverPromotesReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	standby, standbyMock := newMockDB(t)
+	promoted, promotedMock := newMockDB(t)
+
+	var events []FailoverEvent
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(standby, promoted))
+	db.failover = &autoFailover{onFailover: func(e FailoverEvent) { events = append(events, e) }}
+
+	// the primary is reachable: the replicas aren't checked
+	expectInRecovery(primaryMock, false)
+	db.checkFailover(context.Background())
+
+	primaryMock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnError(errConnReset)
+	expectInRecovery(standbyMock, true)
+	expectInRecovery(promotedMock, false)
+	db.checkFailover(context.Background())
+
+	if db.ReadWrite() != promoted {
+		t.Fatal("want the promoted replica set as the primary")
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != standby {
+		t.Errorf("want the promoted replica removed from the read pool, got %v", replicas)
+	}
+	if len(events) != 1 || events[0].Primary != "replica-1" || len(events[0].Replaced) != 1 ||
+		events[0].Replaced[0] != primary || events[0].Err == nil {
+		t.Errorf("want a failover event from the replaced primary to replica-1, got %+v", events)
+	}
+}
+
+func TestAutoFailoverSplitBrain(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	first, firstMock := newMockDB(t)
+	second, secondMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(first, second))
+	db.failover = &autoFailover{}
+
+	expectInRecovery(primaryMock, true) // demoted
+	expectInRecovery(firstMock, false)
+	expectInRecovery(secondMock, false)
+	db.checkFailover(context.Background())
+
+	if db.ReadWrite() != primary || len(db.ReplicaDBs()) != 2 {
+		t.Error("want no failover when several replicas left recovery")
+	}
+}
@@ -0,0 +1,98 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// backendParamsQuery reads the settings of the backend and the TLS status of its connection. The join keeps
+// a row when pg_stat_ssl has none for the backend.
+const backendParamsQuery = `SELECT current_setting('server_version'), current_setting('hot_standby'),
+current_setting('max_standby_streaming_delay'), current_setting('synchronous_commit'),
+COALESCE(s.ssl, false), COALESCE(s.version, ''), COALESCE(s.cipher, '')
+FROM (SELECT pg_backend_pid() AS pid) b LEFT JOIN pg_stat_ssl s ON s.pid = b.pid`
+
+// BackendParams are the settings a backend reported to the health monitor, see WithBackendParams
+type BackendParams struct {
+	ServerVersion            string
+	HotStandby               string
+	MaxStandbyStreamingDelay string
+	SynchronousCommit        string
+	SSL                      bool   // Whether the connection of the check is encrypted
+	TLSVersion               string // e.g. "TLSv1.3", empty without SSL
+	TLSCipher                string
+	CheckedAt                time.Time
+}
+
+// PrimaryStatus is the status of a primary maintained by the health monitor
+type PrimaryStatus struct {
+	Name      string // Name of the primary, see WithNamedPrimaryDBs
+	IsHealthy bool
+	LastCheck time.Time
+	LastError error
+	Params    *BackendParams // Reported with WithBackendParams
+}
+
+// WithBackendParams makes the health monitor also collect, on every successful check, the server version,
+// the negotiated TLS status and the settings hot_standby, max_standby_streaming_delay and synchronous_commit
+// of each database, reported in ReplicaStatus.Params and PrimaryStatus.Params so misconfigured backends
+// stand out of the health snapshot. Requires WithHealthCheck.
+func WithBackendParams() OptionFunc {
+	return func(opt *Option) {
+		opt.BackendParams = true
+	}
+}
+
+// queryBackendParams collects the settings of target, bounded by the health check timeout
+func (db *DB) queryBackendParams(target *sql.DB) (*BackendParams, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), db.health.timeout)
+	defer cancel()
+
+	p := BackendParams{CheckedAt: time.Now()}
+	err := target.QueryRowContext(ctx, backendParamsQuery).Scan(
+		&p.ServerVersion, &p.HotStandby, &p.MaxStandbyStreamingDelay, &p.SynchronousCommit,
+		&p.SSL, &p.TLSVersion, &p.TLSCipher,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query backend params: %w", err)
+	}
+	return &p, nil
+}
+
+// checkBackendParams collects the settings of target when enabled, nil when disabled or failed
+func (db *DB) checkBackendParams(target *sql.DB) *BackendParams {
+	if !db.health.backendParams {
+		return nil
+	}
+	params, err := db.queryBackendParams(target)
+	if err != nil {
+		db.log().Debug("health check: backend params unavailable", "db", physicalDBName(db, target), "error", err)
+	}
+	return params
+}
+
+// GetPrimaryStatus returns the status of every primary maintained by the health monitor, in the order
+// they were passed to New or set with SetPrimary. It returns nil when the health monitor isn't enabled
+// with WithHealthCheck.
+func (db *DB) GetPrimaryStatus() []*PrimaryStatus {
+	if db.health == nil {
+		return nil
+	}
+	primaries := db.allPrimaries()
+	// named before locking the monitor, see GetReplicaStatus
+	statuses := make([]*PrimaryStatus, len(primaries))
+	for i, primary := range primaries {
+		statuses[i] = &PrimaryStatus{Name: physicalDBName(db, primary)}
+	}
+
+	db.health.mu.RLock()
+	defer db.health.mu.RUnlock()
+	for i, primary := range primaries {
+		if s, ok := db.health.primaries[primary]; ok {
+			*statuses[i] = *s
+		}
+	}
+	return statuses
+}
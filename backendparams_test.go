@@ -0,0 +1,84 @@
+package dbresolver
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectBackendParams(mock sqlmock.Sqlmock, version, hotStandby string, ssl bool) {
+	tlsVersion := ""
+	if ssl {
+		tlsVersion = "TLSv1.3"
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("current_setting('server_version')")).
+		WillReturnRows(sqlmock.NewRows([]string{"server_version", "hot_standby", "max_standby_streaming_delay",
+			"synchronous_commit", "ssl", "version", "cipher"}).
+			AddRow(version, hotStandby, "30s", "on", ssl, tlsVersion, ""))
+}
+
+func TestHealthCheckBackendParams(t *testing.T) {
+	db, primaryMock, replicaMock, _ := newHealthDB(t, WithBackendParams())
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectBackendParams(primaryMock, "16.4", "off", true)
+	expectReplayLSN(replicaMock, "0/3000000")
+	expectBackendParams(replicaMock, "15.8", "on", false)
+
+	db.checkHealth()
+
+	primary := db.GetPrimaryStatus()[0]
+	if !primary.IsHealthy || primary.Name != "primary-0" || primary.Params == nil {
+		t.Fatalf("want a healthy primary with its params, got %+v", primary)
+	}
+	if p := primary.Params; p.ServerVersion != "16.4" || !p.SSL || p.TLSVersion != "TLSv1.3" || p.SynchronousCommit != "on" {
+		t.Errorf("unexpected primary params %+v", p)
+	}
+	replica := db.GetReplicaStatus()[0]
+	if p := replica.Params; p == nil || p.ServerVersion != "15.8" || p.HotStandby != "on" || p.SSL ||
+		p.MaxStandbyStreamingDelay != "30s" {
+		t.Errorf("unexpected replica params %+v", p)
+	}
+
+	// params failing to be collected keep the previous ones, without failing the check
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectBackendParams(primaryMock, "16.4", "off", true)
+	expectReplayLSN(replicaMock, "0/3000000")
+	replicaMock.ExpectQuery(regexp.QuoteMeta("current_setting('server_version')")).
+		WillReturnError(errors.New("permission denied"))
+
+	db.checkHealth()
+
+	if replica := db.GetReplicaStatus()[0]; !replica.IsHealthy || replica.Params == nil || replica.Params.ServerVersion != "15.8" {
+		t.Errorf("want the previous params of the healthy replica, got %+v", replica)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPrimaryStatusWithoutBackendParams(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t)
+	primaryErr := errors.New("connection refused")
+	primaryMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(primaryErr)
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+
+	primary := db.GetPrimaryStatus()[0]
+	if primary.IsHealthy || !errors.Is(primary.LastError, primaryErr) || primary.Params != nil {
+		t.Errorf("want an unhealthy primary without params, got %+v", primary)
+	}
+	if len(*events) != 1 || (*events)[0].Type != HealthEventPrimaryDown {
+		t.Errorf("want a primary down event, got %+v", *events)
+	}
+	if db.GetReplicaStatus()[0].Params != nil {
+		t.Error("params should not be collected without WithBackendParams")
+	}
+	if New(WithPrimaryDBs(db.primaries...)).GetPrimaryStatus() != nil {
+		t.Error("want no primary status without the health monitor")
+	}
+}
@@ -0,0 +1,182 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBoostMinDuration = 30 * time.Second
+	// boostMinSamples is the number of primary reads averaged before the boost can start
+	boostMinSamples = 10
+	// boostSmoothing is the weight of the latest primary read in the average latency
+	boostSmoothing = 0.1
+)
+
+const boostableReadsKey contextKey = "boostable_reads"
+
+// ReplicaBoostConfig configures WithReplicaBoost
+type ReplicaBoostConfig struct {
+	LatencyThreshold  time.Duration      // Average primary read latency above which the boost starts
+	RecoveryThreshold time.Duration      // Average latency under which the boost ends, defaults to half LatencyThreshold
+	MinDuration       time.Duration      // Shortest boost, defaults to 30s
+	MaxLagBytes       uint64             // Replicas lagging more aren't boosted to, 0 to not check the lag
+	OnChange          func(boosted bool) // Called when a primary read starts or ends the boost
+}
+
+// WithReplicaBoost offloads the reads marked with WithBoostableReads from a slow primary: while the
+// exponential moving average of the primary read latency exceeds config.LatencyThreshold, they are routed to the
+// available replicas instead, even when the consistency level or a fallback sends them to the primary.
+// The boost lasts at least config.MinDuration and ends once the average falls under config.RecoveryThreshold,
+// or when no primary read was measured for config.MinDuration.
+func WithReplicaBoost(config ReplicaBoostConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.RecoveryThreshold <= 0 || config.RecoveryThreshold > config.LatencyThreshold {
+			config.RecoveryThreshold = config.LatencyThreshold / 2
+		}
+		if config.MinDuration <= 0 {
+			config.MinDuration = defaultBoostMinDuration
+		}
+		opt.ReplicaBoost = &replicaBoost{config: config}
+	}
+}
+
+// WithBoostableReads returns a context whose reads tolerate being served by a replica while the
+// primary is boosted, see WithReplicaBoost. The reads following a write of the same LSN context stay on the primary.
+func WithBoostableReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, boostableReadsKey, true)
+}
+
+// IsReplicaBoosted reports whether the boostable reads are currently offloaded to the replicas
+func (db *DB) IsReplicaBoosted() bool {
+	return db.boost != nil && db.boost.isActive(time.Now())
+}
+
+// replicaBoost tracks the primary read latency and the boost state with hysteresis
+type replicaBoost struct {
+	config ReplicaBoostConfig
+
+	mu          sync.Mutex
+	average     float64 // nanoseconds
+	samples     int
+	lastSample  time.Time
+	activeSince time.Time // zero while not boosted
+}
+
+// observe records the latency of a primary read, returning whether the boost started or ended
+func (b *replicaBoost) observe(now time.Time, latency time.Duration) (changed, boosted bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasActive := b.expireLocked(now)
+	if b.samples == 0 {
+		b.average = float64(latency)
+	} else {
+		b.average += boostSmoothing * (float64(latency) - b.average)
+	}
+	b.samples++
+	b.lastSample = now
+
+	switch {
+	case !wasActive && b.samples >= boostMinSamples && b.average > float64(b.config.LatencyThreshold):
+		b.activeSince = now
+	case wasActive && now.Sub(b.activeSince) >= b.config.MinDuration && b.average < float64(b.config.RecoveryThreshold):
+		b.activeSince = time.Time{}
+	}
+	boosted = !b.activeSince.IsZero()
+	return boosted != wasActive, boosted
+}
+
+// isActive reports whether the primary is boosted at now
+func (b *replicaBoost) isActive(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.expireLocked(now)
+}
+
+// expireLocked ends a boost without primary reads measured for MinDuration, whose latency is unknown,
+// and reports whether the primary is boosted
+func (b *replicaBoost) expireLocked(now time.Time) bool {
+	if b.activeSince.IsZero() {
+		return false
+	}
+	if now.Sub(b.activeSince) >= b.config.MinDuration && now.Sub(b.lastSample) >= b.config.MinDuration {
+		b.activeSince = time.Time{}
+		b.samples = 0
+		return false
+	}
+	return true
+}
+
+// observePrimaryRead records the latency of a successful read on curDB if it is a primary
+func (db *DB) observePrimaryRead(curDB *sql.DB, latency time.Duration) {
+	if db.boost == nil || !containsDB(db.allPrimaries(), curDB) {
+		return
+	}
+	changed, boosted := db.boost.observe(time.Now(), latency)
+	if !changed {
+		return
+	}
+	if boosted {
+		db.log().Warn("dbresolver: primary reads are slow, boosting the replicas", "latency_threshold", db.boost.config.LatencyThreshold)
+	} else {
+		db.log().Debug("dbresolver: primary reads recovered, replica boost ended")
+	}
+	if db.boost.config.OnChange != nil {
+		db.boost.config.OnChange(boosted)
+	}
+}
+
+// boostRead returns a replica for a boostable read routed to the primary curDB while the primary is boosted,
+// or curDB
+func (db *DB) boostRead(ctx context.Context, queryType QueryType, curDB *sql.DB) *sql.DB {
+	if boostable, _ := ctx.Value(boostableReadsKey).(bool); !boostable || !db.IsReplicaBoosted() {
+		return curDB
+	}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil && lsnCtx.HasWriteOperation {
+		return curDB
+	}
+	if !containsDB(db.allPrimaries(), curDB) {
+		return curDB
+	}
+	replicas := db.boostReplicas()
+	if len(replicas) == 0 {
+		return curDB
+	}
+	replica := db.loadBalancer.Resolve(replicas)
+	db.hooks.route(RouteDecision{QueryType: queryType, DB: replica, Target: physicalDBName(db, replica), Reason: ReasonReplicaBoost})
+	return replica
+}
+
+// boostReplicas returns the available replicas within the lag allowed while the primary is boosted
+func (db *DB) boostReplicas() []*sql.DB {
+	replicas := db.ReplicaDBs()
+	if db.boost.config.MaxLagBytes == 0 {
+		return replicas
+	}
+
+	var masterLSN LSN
+	for _, primary := range db.allPrimaries() {
+		if checker := lookupChecker(primary); checker != nil {
+			if lsn, _, ok := checker.cachedWALLSN(); ok && lsn.GreaterThan(masterLSN) {
+				masterLSN = lsn
+			}
+		}
+	}
+	if masterLSN.IsZero() {
+		return nil
+	}
+	caughtUp := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		checker := lookupChecker(replica)
+		if checker == nil {
+			continue
+		}
+		if lsn, _, ok := checker.CachedReplayLSN(); ok && masterLSN.Subtract(lsn) <= db.boost.config.MaxLagBytes {
+			caughtUp = append(caughtUp, replica)
+		}
+	}
+	return caughtUp
+}
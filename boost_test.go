@@ -0,0 +1,94 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplicaBoostHysteresis(t *testing.T) {
+	opt := defaultOption()
+	WithReplicaBoost(ReplicaBoostConfig{LatencyThreshold: 100 * time.Millisecond, MinDuration: time.Minute})(opt)
+	b := opt.ReplicaBoost
+	start := time.Now()
+
+	var changes []bool
+	observe := func(at time.Duration, latency time.Duration) {
+		if changed, boosted := b.observe(start.Add(at), latency); changed {
+			changes = append(changes, boosted)
+		}
+	}
+	for i := range boostMinSamples {
+		observe(time.Duration(i)*time.Second, 200*time.Millisecond)
+	}
+	if !b.isActive(start.Add(10 * time.Second)) {
+		t.Fatal("want the replicas boosted once the primary reads are slow")
+	}
+
+	// fast reads don't end the boost before MinDuration
+	for i := range 30 {
+		observe(10*time.Second+time.Duration(i)*time.Second, time.Millisecond)
+	}
+	if !b.isActive(start.Add(40 * time.Second)) {
+		t.Fatal("want the boost to last MinDuration")
+	}
+	observe(70*time.Second, time.Millisecond)
+	if b.isActive(start.Add(70 * time.Second)) {
+		t.Fatal("want the boost ended once the primary recovered")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("want a boost start then end, got %v", changes)
+	}
+}
+
+func TestReplicaBoostExpiresWithoutSamples(t *testing.T) {
+	b := &replicaBoost{config: ReplicaBoostConfig{LatencyThreshold: time.Millisecond, MinDuration: time.Second}}
+	now := time.Now()
+	for range boostMinSamples {
+		b.observe(now, time.Second)
+	}
+	if !b.isActive(now) {
+		t.Fatal("want the replicas boosted")
+	}
+	if b.isActive(now.Add(2 * time.Second)) {
+		t.Error("want the boost ended without primary reads to measure")
+	}
+}
+
+func TestReplicaBoostRouting(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	var decisions []RouteDecision
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyLevel(StrongConsistency),
+		WithReplicaBoost(ReplicaBoostConfig{LatencyThreshold: time.Millisecond}),
+		WithRoutingHooks(func(d RouteDecision) { decisions = append(decisions, d) }, nil, nil),
+	)
+	boostable := WithBoostableReads(context.Background())
+
+	if got, _ := db.selectDB(boostable, QueryTypeRead, "SELECT 1"); got != primary {
+		t.Fatal("want reads on the primary while it isn't boosted")
+	}
+	for range boostMinSamples {
+		db.observePrimaryRead(primary, time.Second)
+	}
+	if !db.IsReplicaBoosted() {
+		t.Fatal("want the replicas boosted")
+	}
+
+	if got, _ := db.selectDB(boostable, QueryTypeRead, "SELECT 1"); got != replica {
+		t.Error("want the boostable read offloaded to the replica")
+	}
+	if last := decisions[len(decisions)-1]; last.Reason != ReasonReplicaBoost {
+		t.Errorf("want a replica boost decision, got %+v", last)
+	}
+	if got, _ := db.selectDB(context.Background(), QueryTypeRead, "SELECT 1"); got != primary {
+		t.Error("want the other reads to keep their consistency level")
+	}
+	afterWrite := WithLSNContext(boostable, &LSNContext{HasWriteOperation: true})
+	if got, _ := db.selectDB(afterWrite, QueryTypeRead, "SELECT 1"); got != primary {
+		t.Error("want the reads following a write kept on the primary")
+	}
+}
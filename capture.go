@@ -0,0 +1,115 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const defaultCaptureSampleRate = 0.01
+
+var (
+	captureCommentRegex = regexp.MustCompile(`--[^\n]*|/\*[\s\S]*?\*/`)
+	captureStringRegex  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	captureNumberRegex  = regexp.MustCompile(`(^|[^\w$])\d+(?:\.\d+)?\b`)
+)
+
+// CapturedRead is a read sampled by WithReadCapture, replayed by pgroutertest.Replay
+type CapturedRead struct {
+	Time     time.Time     `json:"time"`
+	Query    string        `json:"query"`  // Query anonymized by ReadCaptureConfig.Anonymize, args aren't captured
+	Target   string        `json:"target"` // Name of the database which served the read, see WithNamedReplicaDBs
+	Primary  bool          `json:"primary,omitempty"`
+	Causal   bool          `json:"causal,omitempty"` // Whether the read required an LSN
+	Duration time.Duration `json:"duration"`         // Including the retries of the read
+	Failed   bool          `json:"failed,omitempty"`
+}
+
+// ReadCaptureSink receives the reads sampled by WithReadCapture. CaptureRead is called on the path of the reads
+// and must not block.
+type ReadCaptureSink interface {
+	CaptureRead(read CapturedRead)
+}
+
+// ReadCaptureConfig configures WithReadCapture
+type ReadCaptureConfig struct {
+	Sink       ReadCaptureSink
+	SampleRate float64 // Share of the reads captured, in (0, 1], defaults to 1%
+	// Anonymize strips the sensitive parts of a query, defaults to AnonymizeQuery
+	Anonymize func(query string) string
+}
+
+// WithReadCapture samples the reads run with QueryContext and QueryRowContext, with their timing and the database
+// which served them, into config.Sink, so routing changes can be load tested with production-shaped traffic by
+// replaying them against a staging resolver with pgroutertest.Replay. Reads of statements and transactions aren't
+// captured.
+func WithReadCapture(config ReadCaptureConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.SampleRate <= 0 || config.SampleRate > 1 {
+			config.SampleRate = defaultCaptureSampleRate
+		}
+		if config.Anonymize == nil {
+			config.Anonymize = AnonymizeQuery
+		}
+		opt.ReadCapture = &config
+	}
+}
+
+// AnonymizeQuery strips the comments of query and replaces its string literals by empty strings and its numeric literals by 0,
+// keeping it runnable for a replay with the same shape. Placeholders such as $1 are kept.
+func AnonymizeQuery(query string) string {
+	query = captureCommentRegex.ReplaceAllString(query, "")
+	query = captureStringRegex.ReplaceAllString(query, "''")
+	return captureNumberRegex.ReplaceAllString(query, "${1}0")
+}
+
+// captureRead samples a read served by curDB which started at start
+func (db *DB) captureRead(ctx context.Context, query string, curDB *sql.DB, start time.Time, err error) {
+	if db.capture == nil || rand.Float64() >= db.capture.SampleRate { //nolint:gosec // sampling, not security
+		return
+	}
+	lsnCtx := GetLSNContext(ctx)
+	db.capture.Sink.CaptureRead(CapturedRead{
+		Time:     start,
+		Query:    db.capture.Anonymize(query),
+		Target:   physicalDBName(db, curDB),
+		Primary:  containsDB(db.allPrimaries(), curDB),
+		Causal:   lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero(),
+		Duration: time.Since(start),
+		Failed:   err != nil,
+	})
+}
+
+// JSONReadCaptureSink writes the captured reads to a writer as JSON lines, the format read by
+// pgroutertest.Replay. Writes are serialized, wrap files in a bufio.Writer flushed on shutdown.
+type JSONReadCaptureSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewJSONReadCaptureSink writes the captured reads to w
+func NewJSONReadCaptureSink(w io.Writer) *JSONReadCaptureSink {
+	return &JSONReadCaptureSink{encoder: json.NewEncoder(w)}
+}
+
+// CaptureRead writes read as a JSON line, dropping it after a write error
+func (s *JSONReadCaptureSink) CaptureRead(read CapturedRead) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = s.encoder.Encode(read)
+	}
+}
+
+// Err returns the first write error, after which the reads are dropped
+func (s *JSONReadCaptureSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,51 @@
+package dbresolver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAnonymizeQuery(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT * FROM users WHERE email = 'bob@example.com' AND age > 42": "SELECT * FROM users WHERE email = '' AND age > 0",
+		"SELECT * FROM t1 WHERE id = $1 LIMIT 10":                          "SELECT * FROM t1 WHERE id = $1 LIMIT 0",
+		"SELECT name FROM users -- for ticket 1234\nWHERE note = 'it''s'":  "SELECT name FROM users \nWHERE note = ''",
+		"SELECT /* tenant 7 */ price * 1.5 FROM items":                     "SELECT  price * 0 FROM items",
+	} {
+		if got := AnonymizeQuery(query); got != want {
+			t.Errorf("AnonymizeQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestReadCapture(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	var captured bytes.Buffer
+	sink := NewJSONReadCaptureSink(&captured)
+	db := New(WithPrimaryDBs(primary), WithNamedReplicaDBs(map[string]*sql.DB{"east": replica}),
+		WithReadCapture(ReadCaptureConfig{Sink: sink, SampleRate: 1}))
+
+	replicaMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	rows, err := db.QueryContext(context.Background(), "SELECT name FROM users WHERE id = 42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	var read CapturedRead
+	if err := json.Unmarshal(captured.Bytes(), &read); err != nil || sink.Err() != nil {
+		t.Fatalf("want a JSON line captured, got %q, %v", captured.String(), err)
+	}
+	if read.Query != "SELECT name FROM users WHERE id = 0" || read.Target != "east" || read.Primary || read.Failed {
+		t.Errorf("want the anonymized read served by the replica, got %+v", read)
+	}
+	if read.Time.IsZero() {
+		t.Error("want the start of the read captured")
+	}
+}
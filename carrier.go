@@ -0,0 +1,59 @@
+package dbresolver
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ConsistencyCarrier stores the LSN context of a request. The default carrier, ContextValueCarrier, keeps it as a
+// value of the context.Context; frameworks with their own request-scoped storage, e.g. gin.Keys or
+// fasthttp.UserValue, can keep it there instead when their request type is passed as the context of the queries.
+type ConsistencyCarrier interface {
+	// LSNContext returns the LSN context carried by ctx, nil if none
+	LSNContext(ctx context.Context) *LSNContext
+	// WithLSNContext returns a context carrying lsnCtx. Carriers storing it in the request of ctx may return ctx itself.
+	WithLSNContext(ctx context.Context, lsnCtx *LSNContext) context.Context
+}
+
+// ContextValueCarrier is the default ConsistencyCarrier, keeping the LSN context as a value of the context.Context.
+// Custom carriers can fall back to it for the contexts that aren't requests of their framework.
+type ContextValueCarrier struct{}
+
+// LSNContext returns the LSN context stored as a value of ctx
+func (ContextValueCarrier) LSNContext(ctx context.Context) *LSNContext {
+	if lsnCtx, ok := ctx.Value(lsnContextKey).(*LSNContext); ok {
+		return lsnCtx
+	}
+	return nil
+}
+
+// WithLSNContext returns a context derived from ctx with lsnCtx as a value
+func (ContextValueCarrier) WithLSNContext(ctx context.Context, lsnCtx *LSNContext) context.Context {
+	return contextWithLSN(ctx, lsnCtx)
+}
+
+var consistencyCarrier atomic.Pointer[ConsistencyCarrier]
+
+// SetConsistencyCarrier sets the carrier of the LSN contexts used by WithLSNContext, GetLSNContext and everything
+// built on them, e.g. HTTPMiddleware and the causal router. It should be called once at startup, before serving
+// queries; nil restores ContextValueCarrier.
+func SetConsistencyCarrier(carrier ConsistencyCarrier) {
+	if carrier == nil {
+		consistencyCarrier.Store(nil)
+		return
+	}
+	consistencyCarrier.Store(&carrier)
+}
+
+func currentCarrier() ConsistencyCarrier {
+	if carrier := consistencyCarrier.Load(); carrier != nil {
+		return *carrier
+	}
+	return ContextValueCarrier{}
+}
+
+// contextWithLSN derives a context carrying lsnCtx as a value, which takes precedence over the carrier.
+// It's used for the copies of an LSN context that must not replace the one of the request.
+func contextWithLSN(ctx context.Context, lsnCtx *LSNContext) context.Context {
+	return context.WithValue(ctx, lsnContextKey, lsnCtx)
+}
@@ -0,0 +1,61 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+)
+
+// requestContext is a framework request used as the context of the queries, with its own storage
+type requestContext struct {
+	context.Context
+	keys map[string]any
+}
+
+// keysCarrier keeps the LSN context in the storage of requestContext
+type keysCarrier struct{}
+
+func (keysCarrier) LSNContext(ctx context.Context) *LSNContext {
+	if req, ok := ctx.(*requestContext); ok {
+		lsnCtx, _ := req.keys["lsn"].(*LSNContext)
+		return lsnCtx
+	}
+	return ContextValueCarrier{}.LSNContext(ctx)
+}
+
+func (keysCarrier) WithLSNContext(ctx context.Context, lsnCtx *LSNContext) context.Context {
+	if req, ok := ctx.(*requestContext); ok {
+		req.keys["lsn"] = lsnCtx
+		return req
+	}
+	return ContextValueCarrier{}.WithLSNContext(ctx, lsnCtx)
+}
+
+func TestConsistencyCarrier(t *testing.T) {
+	SetConsistencyCarrier(keysCarrier{})
+	t.Cleanup(func() { SetConsistencyCarrier(nil) })
+
+	req := &requestContext{Context: context.Background(), keys: map[string]any{}}
+	lsnCtx := &LSNContext{Level: ReadYourWrites}
+	if ctx := WithLSNContext(req, lsnCtx); ctx != req || req.keys["lsn"] != lsnCtx {
+		t.Fatal("want the LSN context stored in the request")
+	}
+	if got := GetLSNContext(req); got != lsnCtx {
+		t.Errorf("want the LSN context of the request, got %+v", got)
+	}
+
+	// internal copies take precedence over the carrier without replacing the request's LSN context
+	explained := &LSNContext{}
+	if got := GetLSNContext(contextWithLSN(req, explained)); got != explained || req.keys["lsn"] != lsnCtx {
+		t.Errorf("want the copy for the derived context only, got %+v", got)
+	}
+
+	other := WithLSNContext(context.Background(), lsnCtx)
+	if got := GetLSNContext(other); got != lsnCtx {
+		t.Errorf("want the fallback to context values, got %+v", got)
+	}
+
+	SetConsistencyCarrier(nil)
+	if got := GetLSNContext(req); got != nil {
+		t.Errorf("want no LSN context with the default carrier, got %+v", got)
+	}
+}
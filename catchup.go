@@ -0,0 +1,162 @@
+package dbresolver
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// catchUpBuckets are the upper bounds, in seconds, of the replica catch-up time histogram
+var catchUpBuckets = [...]float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// maxPendingCatchUps bounds the writes waiting to be replayed, the following ones aren't timed
+const maxPendingCatchUps = 256
+
+// catchUpTracker times how long the writes captured by the router take to be replayed by a replica.
+// A write is resolved by the first replay LSN observed at or past it, by the routing of a read or by
+// a health check, so the times are upper bounds whose precision depends on how often replicas are checked.
+type catchUpTracker struct {
+	mu      sync.Mutex
+	pending []pendingCatchUp // by increasing LSN
+	counts  [len(catchUpBuckets) + 1]uint64
+	count   uint64
+	sum     float64
+}
+
+// pendingCatchUp is a write not replayed by any replica yet
+type pendingCatchUp struct {
+	lsn LSN
+	at  time.Time
+}
+
+// write records the LSN captured after a write
+func (t *catchUpTracker) write(lsn LSN, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingCatchUps {
+		return
+	}
+	if n := len(t.pending); n > 0 && !lsn.GreaterThan(t.pending[n-1].lsn) {
+		// replayed no later than the pending write at or past it
+		return
+	}
+	t.pending = append(t.pending, pendingCatchUp{lsn: lsn, at: at})
+}
+
+// replayed resolves the pending writes a replica replayed by at
+func (t *catchUpTracker) replayed(lsn LSN, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resolved := 0
+	for resolved < len(t.pending) && !lsn.LessThan(t.pending[resolved].lsn) {
+		t.observe(at.Sub(t.pending[resolved].at))
+		resolved++
+	}
+	t.pending = append(t.pending[:0], t.pending[resolved:]...)
+}
+
+// observe records a catch-up time, t.mu being held
+func (t *catchUpTracker) observe(d time.Duration) {
+	seconds := max(d.Seconds(), 0)
+	i := 0
+	for i < len(catchUpBuckets) && seconds > catchUpBuckets[i] {
+		i++
+	}
+	t.counts[i]++
+	t.count++
+	t.sum += seconds
+}
+
+// snapshot returns the histogram of the catch-up times with cumulative bucket counts
+func (t *catchUpTracker) snapshot() HistogramSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := HistogramSnapshot{Buckets: make(map[float64]uint64, len(catchUpBuckets)), Count: t.count, Sum: t.sum}
+	var cumulative uint64
+	for i, bound := range catchUpBuckets {
+		cumulative += t.counts[i]
+		s.Buckets[bound] = cumulative
+	}
+	return s
+}
+
+// quantile returns the upper bound of the bucket holding the q quantile of the catch-up times,
+// false before any observation or when it lies beyond the last bucket
+func (t *catchUpTracker) quantile(q float64) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.count == 0 {
+		return 0, false
+	}
+	rank := uint64(math.Ceil(q * float64(t.count)))
+	var cumulative uint64
+	for i, bound := range catchUpBuckets {
+		cumulative += t.counts[i]
+		if cumulative >= rank {
+			return time.Duration(bound * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+// CookieMaxAgeAdvisor is implemented by components recommending the LSN cookie MaxAge from the observed
+// replica catch-up times, such as *DB and *CausalRouter
+type CookieMaxAgeAdvisor interface {
+	// RecommendedCookieMaxAge returns the p99 replica catch-up time, false until one was observed
+	RecommendedCookieMaxAge() (time.Duration, bool)
+}
+
+// recommendedMaxAgeQuantile is the quantile of the catch-up times recommended as cookie MaxAge
+const recommendedMaxAgeQuantile = 0.99
+
+// RecommendedCookieMaxAge returns the p99 of the time replicas took to replay the writes captured by the
+// router, rounded up to a histogram bucket, false until one was observed or beyond the last bucket
+func (r *CausalRouter) RecommendedCookieMaxAge() (time.Duration, bool) {
+	return r.metrics.catchUps.quantile(recommendedMaxAgeQuantile)
+}
+
+// RecommendedCookieMaxAge returns the recommendation of the causal router, see CausalRouter
+func (db *DB) RecommendedCookieMaxAge() (time.Duration, bool) {
+	if router, ok := db.queryRouter.(*CausalRouter); ok {
+		return router.RecommendedCookieMaxAge()
+	}
+	return 0, false
+}
+
+// observeReplay resolves the writes of the causal router replayed by a replica at lsn
+func (db *DB) observeReplay(lsn LSN) {
+	if router, ok := db.queryRouter.(*CausalRouter); ok {
+		router.metrics.catchUps.replayed(lsn, time.Now())
+	}
+}
+
+// WithAdaptiveCookieMaxAge sets the MaxAge of the LSN cookies, and the TTL of the session store entries,
+// to the recommendation of the CookieMaxAgeAdvisor router within [minAge, maxAge], so the cookie lifetime
+// follows the observed replica catch-up times. The maxAge given to NewHTTPMiddleware applies until a
+// recommendation is available, or when the router isn't an advisor.
+func WithAdaptiveCookieMaxAge(minAge, maxAge time.Duration) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.adaptiveMaxAge = &adaptiveMaxAge{minAge: minAge, maxAge: maxAge}
+	}
+}
+
+// adaptiveMaxAge bounds the recommended cookie MaxAge
+type adaptiveMaxAge struct {
+	minAge, maxAge time.Duration
+}
+
+// tokenMaxAge returns the MaxAge of the LSN cookies and TTL of the session store entries
+func (m *HTTPMiddleware) tokenMaxAge() time.Duration {
+	if m.adaptiveMaxAge == nil {
+		return m.cookie.MaxAge
+	}
+	advisor, ok := m.router.(CookieMaxAgeAdvisor)
+	if !ok {
+		return m.cookie.MaxAge
+	}
+	recommended, ok := advisor.RecommendedCookieMaxAge()
+	if !ok {
+		return m.cookie.MaxAge
+	}
+	return min(max(recommended, m.adaptiveMaxAge.minAge), m.adaptiveMaxAge.maxAge)
+}
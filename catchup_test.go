@@ -0,0 +1,104 @@
+package dbresolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCatchUpTracker(t *testing.T) {
+	var tracker catchUpTracker
+	start := time.Now()
+	tracker.write(LSN{Lower: 0x100}, start)
+	tracker.write(LSN{Lower: 0x100}, start.Add(time.Second)) // already pending
+	tracker.write(LSN{Lower: 0x200}, start.Add(time.Second))
+
+	tracker.replayed(LSN{Lower: 0x150}, start.Add(40*time.Millisecond))
+	tracker.replayed(LSN{Lower: 0x150}, start.Add(time.Minute)) // nothing left to resolve below it
+	if s := tracker.snapshot(); s.Count != 1 || s.Buckets[0.05] != 1 || s.Buckets[0.025] != 0 {
+		t.Fatalf("expected one catch-up of 40ms, got %+v", s)
+	}
+	tracker.replayed(LSN{Lower: 0x200}, start.Add(3*time.Second))
+
+	if len(tracker.pending) != 0 {
+		t.Errorf("expected every write resolved, got %d pending", len(tracker.pending))
+	}
+	if got, ok := tracker.quantile(0.5); !ok || got != 50*time.Millisecond {
+		t.Errorf("expected a p50 of 50ms, got %s (%v)", got, ok)
+	}
+	if got, ok := tracker.quantile(0.99); !ok || got != 2500*time.Millisecond {
+		t.Errorf("expected a p99 of 2.5s, got %s (%v)", got, ok)
+	}
+}
+
+func TestRecommendedCookieMaxAge(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	if _, ok := db.RecommendedCookieMaxAge(); ok {
+		t.Fatal("expected no recommendation before any catch-up")
+	}
+
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+	db.DbSelector(ctx, QueryTypeWrite)
+	primaryMock.ExpectQuery("pg_current_wal_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	if _, err := db.queryRouter.UpdateLSNAfterWrite(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lsnCtx.ForceMaster = false
+	expectReplayLSN(replicaMock, "0/5000")
+	db.DbSelector(ctx, QueryTypeRead)
+
+	m := db.Metrics()
+	if m.ReplicaCatchUp.Count != 1 || m.RecommendedCookieMaxAge != 10*time.Millisecond {
+		t.Errorf("expected one catch-up recommending 10ms, got %+v and %s", m.ReplicaCatchUp, m.RecommendedCookieMaxAge)
+	}
+}
+
+// advisingRouter recommends a fixed cookie MaxAge
+type advisingRouter struct {
+	fixedLSNRouter
+	maxAge time.Duration
+}
+
+func (r *advisingRouter) RecommendedCookieMaxAge() (time.Duration, bool) {
+	return r.maxAge, r.maxAge > 0
+}
+
+func TestHTTPMiddlewareAdaptiveCookieMaxAge(t *testing.T) {
+	tests := []struct {
+		name        string
+		recommended time.Duration
+		wantMaxAge  int
+	}{
+		{name: "no recommendation keeps the configured MaxAge", wantMaxAge: 300},
+		{name: "recommendation within bounds", recommended: 10 * time.Second, wantMaxAge: 10},
+		{name: "raised to the lower bound", recommended: 250 * time.Millisecond, wantMaxAge: 2},
+		{name: "capped to the upper bound", recommended: 5 * time.Minute, wantMaxAge: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := &advisingRouter{fixedLSNRouter: fixedLSNRouter{lsn: LSN{Lower: 0x1000}}, maxAge: tt.recommended}
+			middleware := NewHTTPMiddleware(router, "test_lsn", 5*time.Minute, false,
+				WithAdaptiveCookieMaxAge(2*time.Second, time.Minute))
+			handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				GetLSNContext(r.Context()).HasWriteOperation = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", http.NoBody))
+			cookies := rec.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].MaxAge != tt.wantMaxAge {
+				t.Fatalf("expected a cookie with MaxAge %d, got %+v", tt.wantMaxAge, cookies)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package dbresolver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"time"
 )
@@ -80,8 +82,32 @@ type CausalConsistencyConfig struct {
 	RequireCookie    bool                   // Require LSN cookie for read-your-writes
 	CookieName       string                 // HTTP cookie name for LSN tracking
 	CookieMaxAge     time.Duration          // Maximum age for LSN cookie
-	FallbackToMaster bool                   // Fallback to master when LSN requirements can't be met
+	FallbackToMaster bool                   // Fallback to master when LSN requirements can't be met, else refuse with ErrStaleReadRefused
 	Timeout          time.Duration          // Timeout for LSN queries
+	MaxReplicaWait   time.Duration          // Maximum time to wait for a replica to catch up before falling back (0 disables waiting)
+	ReplicaWaitPoll  time.Duration          // Interval between replica LSN checks while waiting (defaults to 10ms)
+	PinAfterFallback bool                   // Keep the reads of a request on the primary after its first fallback
+	ThrottleTime     time.Duration          // Query the LSN of a database at most once per ThrottleTime (0 disables)
+}
+
+const defaultReplicaWaitPoll = 10 * time.Millisecond
+
+// ErrStaleReadRefused is matched by the errors of reads refused because no replica has caught up to their required LSN
+// and FallbackToMaster is disabled
+var ErrStaleReadRefused = errors.New("dbresolver: no replica has caught up to the required LSN")
+
+// StaleReadRefused is returned for a read requiring an LSN no replica has replayed when FallbackToMaster is disabled
+type StaleReadRefused struct {
+	RequiredLSN LSN
+}
+
+func (e *StaleReadRefused) Error() string {
+	return fmt.Sprintf("dbresolver: read refused, no replica has caught up to its required LSN %s", e.RequiredLSN)
+}
+
+// Is makes refusals match ErrStaleReadRefused
+func (e *StaleReadRefused) Is(target error) bool {
+	return target == ErrStaleReadRefused
 }
 
 // DefaultCausalConsistencyConfig returns default configuration for causal consistency
@@ -97,24 +123,40 @@ func DefaultCausalConsistencyConfig() *CausalConsistencyConfig {
 	}
 }
 
-// LSNContext holds LSN-related context information
+// LSNContext holds LSN-related context information.
+// It belongs to a single request and must not be shared by concurrent queries.
 type LSNContext struct {
 	RequiredLSN       LSN
-	Level             CausalConsistencyLevel
+	Level             CausalConsistencyLevel // Consistency level of the request, applied with OverrideLevel
+	OverrideLevel     bool                   // Routes the reads of the request by Level instead of CausalConsistencyConfig.Level
 	ForceMaster       bool
-	HasWriteOperation bool // Track if this request performed a write operation
+	HasWriteOperation bool          // Track if this request performed a write operation
+	ReadPin           ReadPinPolicy // Overrides CausalConsistencyConfig.PinAfterFallback for the request
+	// SkipCheckRate is the share of the reads of the request, in [0, 1], served by any replica without checking
+	// that it caught up to RequiredLSN, for endpoints where possibly stale data is acceptable
+	SkipCheckRate float64
 
 	masterDB *sql.DB
+	// highest LSN returned by the write forwarder, see WithWriteForwarder
+	forwardedLSN LSN
+	// primary serving the reads after a fallback, see ReadPinPolicy
+	pinnedPrimary *sql.DB
 }
 
 // ReplicaStatus represents the health and replication status of a replica
 type ReplicaStatus struct {
+	Name       string // Name of the replica, see WithNamedReplicaDBs
 	IsHealthy  bool
 	LastCheck  time.Time
 	ErrorCount int
 	LastError  error
 	LastLSN    *LSN
 	LagBytes   int64
+	Evicted    bool           // Whether the replica is removed from the read pool, see WithUnhealthyReplicaEviction
+	Params     *BackendParams // Reported with WithBackendParams
+	Pooler     *PoolerStats   // Saturation of the pool fronting the replica, reported with WithPoolerStats
+	Delayed    bool           // Whether the replica delays its replication and never serves reads, see WithDelayedReplicas
+	ApplyDelay time.Duration  // recovery_min_apply_delay of a delayed replica, reported with WithDelayedReplicaDetection
 }
 
 // Context keys for storing LSN information in context
@@ -124,16 +166,19 @@ const (
 	lsnContextKey contextKey = "lsn_context"
 )
 
-// WithLSNContext adds LSN requirements to the context
+// WithLSNContext adds LSN requirements to the context, through the carrier set with SetConsistencyCarrier
 func WithLSNContext(ctx context.Context, lsnCtx *LSNContext) context.Context {
-	return context.WithValue(ctx, lsnContextKey, lsnCtx)
+	return currentCarrier().WithLSNContext(ctx, lsnCtx)
 }
 
-// GetLSNContext retrieves LSN context from the request context
+// GetLSNContext retrieves LSN context from the request context, through the carrier set with SetConsistencyCarrier
 func GetLSNContext(ctx context.Context) *LSNContext {
 	if lsnCtx, ok := ctx.Value(lsnContextKey).(*LSNContext); ok {
 		return lsnCtx
 	}
+	if carrier := consistencyCarrier.Load(); carrier != nil {
+		return (*carrier).LSNContext(ctx)
+	}
 	return nil
 }
 
@@ -144,42 +189,97 @@ type CausalRouter struct {
 
 	// Configuration for on-demand checkers
 	queryTimeout time.Duration
+
+	metrics routerMetrics
+	tracer  Tracer
+	logger  *slog.Logger
+	hooks   *routingHooks
+	// called with the failed LSN captures after writes, see WithLSNTrackingErrorHandler
+	onTrackingError func(ctx context.Context, err error)
 }
 
 // NewCausalRouter creates a new LSN-aware router
 func NewCausalRouter(dbProvider DBProvider, config *CausalConsistencyConfig) *CausalRouter {
-	if config == nil {
-		config = DefaultCausalConsistencyConfig()
+	cfg := DefaultCausalConsistencyConfig()
+	if config != nil {
+		*cfg = *config
 	}
 
 	return &CausalRouter{
-		config:       config,
+		config:       cfg,
 		dbProvider:   dbProvider,
 		queryTimeout: 3 * time.Second, // Default timeout
 	}
 }
 
+// log returns the logger of the router
+func (r *CausalRouter) log() *slog.Logger {
+	return loggerOrDefault(r.logger)
+}
+
+// IsCausalConsistencyEnabled reports whether the router performs LSN-based routing
+func (r *CausalRouter) IsCausalConsistencyEnabled() bool {
+	return r.config.Enabled && r.dbProvider != nil
+}
+
+// ConsistencyConfig returns a copy of the configuration of the router
+func (r *CausalRouter) ConsistencyConfig() (CausalConsistencyConfig, bool) {
+	return *r.config, true
+}
+
 // RouteQuery routes a query to the appropriate database based on LSN requirements
 // Optimized version: Cookie-first approach with simplified logic
+func (r *CausalRouter) RouteQuery(ctx context.Context, queryType QueryType) (*sql.DB, error) {
+	ctx, span := startSpan(contextWithTracer(ctx, r.tracer), SpanRouteQuery)
+	defer span.End()
+
+	db, reason, err := r.route(ctx, queryType)
+	span.SetAttributes(SpanAttribute{Key: AttrQueryType, Value: queryTypeName(queryType)})
+	var requiredLSN LSN
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero() {
+		requiredLSN = lsnCtx.RequiredLSN
+		span.SetAttributes(SpanAttribute{Key: AttrRequiredLSN, Value: requiredLSN.String()})
+	}
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	switch reason {
+	case ReasonReplicaLagging:
+		r.metrics.lsnFallbacks.Add(1)
+	case ReasonLSNCheckSkipped:
+		r.metrics.skippedLSNChecks.Add(1)
+	}
+	target := physicalDBName(r.dbProvider, db)
+	span.SetAttributes(
+		SpanAttribute{Key: AttrTargetDB, Value: target},
+		SpanAttribute{Key: AttrRouteReason, Value: reason},
+	)
+	r.hooks.route(RouteDecision{QueryType: queryType, DB: db, Target: target, Reason: reason, RequiredLSN: requiredLSN})
+	return db, nil
+}
+
+// route selects the database for RouteQuery and reports the reason of the decision
 //
 //nolint:gocyclo,funlen // Complex routing logic with multiple consistency levels
-func (r *CausalRouter) RouteQuery(ctx context.Context, queryType QueryType) (*sql.DB, error) {
-	slog.Debug("RouteQuery", "queryType", queryType, "enabled", r.config.Enabled)
+func (r *CausalRouter) route(ctx context.Context, queryType QueryType) (*sql.DB, string, error) {
+	r.log().Debug("RouteQuery", "queryType", queryType, "enabled", r.config.Enabled)
 
 	if !r.config.Enabled || r.dbProvider == nil {
-		slog.Debug("RouteQuery: causal consistency not enabled or no db provider")
-		return nil, fmt.Errorf("causal consistency not enabled")
+		r.log().Debug("RouteQuery: causal consistency not enabled or no db provider")
+		return nil, "", fmt.Errorf("causal consistency not enabled")
 	}
 
 	lsnCtx := GetLSNContext(ctx)
 	primaries := r.dbProvider.PrimaryDBs()
 	replicas := r.dbProvider.ReplicaDBs()
 
-	slog.Debug("RouteQuery", "primaries", len(primaries), "replicas", len(replicas), "hasLSNContext", lsnCtx != nil)
+	r.log().Debug("RouteQuery", "primaries", len(primaries), "replicas", len(replicas), "hasLSNContext", lsnCtx != nil)
 
 	if len(primaries) == 0 {
-		slog.Debug("RouteQuery: no primary databases available")
-		return nil, fmt.Errorf("no primary databases available")
+		r.log().Debug("RouteQuery: no primary databases available")
+		return nil, "", fmt.Errorf("no primary databases available")
 	}
 
 	// If master is explicitly forced, use master or
@@ -190,7 +290,7 @@ func (r *CausalRouter) RouteQuery(ctx context.Context, queryType QueryType) (*sq
 		if lsnCtx != nil {
 			forceMaster = lsnCtx.ForceMaster
 		}
-		slog.Debug("RouteQuery: write operation/master forced, using primary",
+		r.log().Debug("RouteQuery: write operation/master forced, using primary",
 			slog.Int("query_type", int(queryType)),
 			slog.Bool("force_master", forceMaster))
 		if lsnCtx != nil {
@@ -198,61 +298,79 @@ func (r *CausalRouter) RouteQuery(ctx context.Context, queryType QueryType) (*sq
 			lsnCtx.HasWriteOperation = true
 			lsnCtx.masterDB = masterDB
 		}
-		return masterDB, nil
+		if queryType == QueryTypeWrite {
+			return masterDB, ReasonWrite, nil
+		}
+		return masterDB, ReasonForceMaster, nil
+	}
+
+	if pinned := r.pinnedPrimary(lsnCtx, primaries); pinned != nil {
+		r.log().Debug("RouteQuery: reads pinned to primary after fallback")
+		return pinned, ReasonPinnedAfterFallback, nil
 	}
 
 	// For read operations: check cookie first
-	switch r.config.Level {
+	switch r.level(lsnCtx) {
 	case ReadYourWrites:
-		slog.Debug("RouteQuery: ReadYourWrites consistency level")
+		r.log().Debug("RouteQuery: ReadYourWrites consistency level")
 		// Check if we have LSN cookie requirements
 		if lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero() {
-			slog.Debug("RouteQuery: checking replica status", "requiredLSN", lsnCtx.RequiredLSN)
+			if len(replicas) > 0 && lsnCtx.SkipCheckRate > 0 && rand.Float64() < lsnCtx.SkipCheckRate { //nolint:gosec // sampling
+				r.log().Debug("RouteQuery: skipping the replica check", "requiredLSN", lsnCtx.RequiredLSN)
+				return r.dbProvider.LoadBalancer().Resolve(replicas), ReasonLSNCheckSkipped, nil
+			}
+			r.log().Debug("RouteQuery: checking replica status", "requiredLSN", lsnCtx.RequiredLSN)
 			// Has LSN requirement - check if replica has caught up
 			useReplica, db := r.shouldUseReplica(ctx, lsnCtx.RequiredLSN)
+			if !useReplica && r.config.MaxReplicaWait > 0 {
+				useReplica, db = r.waitForReplica(ctx, lsnCtx.RequiredLSN)
+			}
 			if useReplica {
-				slog.Debug("RouteQuery: using replica", "requiredLSN", lsnCtx.RequiredLSN)
-				return db, nil
+				r.log().Debug("RouteQuery: using replica", "requiredLSN", lsnCtx.RequiredLSN)
+				return db, ReasonReplicaCaughtUp, nil
 			}
 			// Replica hasn't caught up yet, fall back to master
 			if r.config.FallbackToMaster {
-				slog.Debug("RouteQuery: replica not ready, falling back to master")
-				return r.dbProvider.LoadBalancer().Resolve(primaries), nil
+				r.log().Debug("RouteQuery: replica not ready, falling back to master")
+				return r.fallBackToPrimary(lsnCtx, primaries), ReasonReplicaLagging, nil
 			}
-			slog.Debug("RouteQuery: no replica has caught up to required LSN")
-			return nil, fmt.Errorf("no replica has caught up to required LSN")
+			r.log().Debug("RouteQuery: no replica has caught up to required LSN")
+			return nil, "", &StaleReadRefused{RequiredLSN: lsnCtx.RequiredLSN}
 		}
 		// No LSN cookie - use simple read/write routing (ignore LSN checking)
-		slog.Debug("RouteQuery: no LSN cookie, falling through to simple routing")
+		r.log().Debug("RouteQuery: no LSN cookie, falling through to simple routing")
 		fallthrough
 
 	case NoneCausalConsistency:
-		slog.Debug("RouteQuery: NoneCausalConsistency level")
+		r.log().Debug("RouteQuery: NoneCausalConsistency level")
 		// No LSN requirements, use any replica
 		if len(replicas) > 0 {
-			slog.Debug("RouteQuery: using replica", "replicaCount", len(replicas))
-			return r.dbProvider.LoadBalancer().Resolve(replicas), nil
+			r.log().Debug("RouteQuery: using replica", "replicaCount", len(replicas))
+			return r.dbProvider.LoadBalancer().Resolve(replicas), ReasonNoLSNRequirement, nil
 		}
-		slog.Debug("RouteQuery: no replicas available, using primary")
-		return r.dbProvider.LoadBalancer().Resolve(primaries), nil
+		r.log().Debug("RouteQuery: no replicas available, using primary")
+		return r.dbProvider.LoadBalancer().Resolve(primaries), ReasonNoReplicas, nil
 
 	case StrongConsistency:
-		slog.Debug("RouteQuery: StrongConsistency level, using primary")
+		r.log().Debug("RouteQuery: StrongConsistency level, using primary")
 		// Always use master for strong consistency or when no LSN cookie
-		return r.dbProvider.LoadBalancer().Resolve(primaries), nil
+		return r.dbProvider.LoadBalancer().Resolve(primaries), ReasonStrongConsistent, nil
 	}
 
 	// Default fallback to master
 	if r.config.FallbackToMaster {
-		slog.Debug("RouteQuery: default fallback to master")
-		return r.dbProvider.LoadBalancer().Resolve(primaries), nil
+		r.log().Debug("RouteQuery: default fallback to master")
+		return r.dbProvider.LoadBalancer().Resolve(primaries), ReasonDefaultFallback, nil
 	}
-	slog.Debug("RouteQuery: unable to route query")
-	return nil, fmt.Errorf("unable to route query: no suitable database found")
+	r.log().Debug("RouteQuery: unable to route query")
+	return nil, "", fmt.Errorf("unable to route query: no suitable database found")
 }
 
-// shouldUseReplica determines if a replica should be used based on LSN requirements
-func (r *CausalRouter) shouldUseReplica(_ context.Context, requiredLSN LSN) (bool, *sql.DB) {
+// shouldUseReplica determines if a replica should be used based on LSN requirements.
+// The load balancer selected replica is checked first; if it lags behind the required LSN
+// the remaining replicas are tried in order before giving up on replicas entirely.
+// With a region topology, the tiers are tried in order of preference.
+func (r *CausalRouter) shouldUseReplica(ctx context.Context, requiredLSN LSN) (bool, *sql.DB) {
 	replicas := r.dbProvider.ReplicaDBs()
 	if len(replicas) == 0 {
 		return false, nil
@@ -264,27 +382,100 @@ func (r *CausalRouter) shouldUseReplica(_ context.Context, requiredLSN LSN) (boo
 		return true, selected
 	}
 
-	// Try the load balancer selected replica first
+	tiered, ok := r.dbProvider.(tieredDBProvider)
+	if !ok {
+		return r.caughtUpReplica(ctx, replicas, requiredLSN)
+	}
+	// Prefer a caught-up replica of a less preferred tier over the primary
+	for _, tier := range tiered.replicaTiers() {
+		if len(tier) == 0 {
+			continue
+		}
+		if caughtUp, replica := r.caughtUpReplica(ctx, tier, requiredLSN); caughtUp {
+			return true, replica
+		}
+	}
+	return false, nil
+}
+
+// caughtUpReplica returns a replica that has replayed the required LSN, starting with the
+// load balancer selected one
+func (r *CausalRouter) caughtUpReplica(ctx context.Context, replicas []*sql.DB, requiredLSN LSN) (bool, *sql.DB) {
+	// Try the load balancer selected replica first, then the others
 	selected := r.dbProvider.LoadBalancer().Resolve(replicas)
+	start := indexOfDB(replicas, selected)
+
+	for i := range replicas {
+		candidate := replicas[(start+i)%len(replicas)]
+
+		// Check if this replica has caught up to the required LSN
+		checker := getOrCreateChecker(candidate, r.queryTimeout)
+		replicaLSN, cached := checker.cachedReplayLSNWithin(r.config.ThrottleTime)
+		if !cached {
+			checkStart := time.Now()
+			var err error
+			replicaLSN, err = checker.GetLastReplayLSN(ctx)
+			r.metrics.lsnChecks.observe(time.Since(checkStart))
+			if err != nil {
+				r.log().Debug("shouldUseReplica: failed to get replica LSN", "error", err)
+				continue
+			}
+			r.metrics.catchUps.replayed(replicaLSN, time.Now())
+		}
+		if !replicaLSN.LessThan(requiredLSN) {
+			return true, candidate
+		}
+		r.log().Debug("shouldUseReplica: replica lagging", "replicaLSN", replicaLSN, "requiredLSN", requiredLSN)
+	}
 
-	// Check if this replica has caught up to the required LSN
-	checker := getOrCreateChecker(selected, r.queryTimeout)
+	// Every replica is lagged or errored, fall back to master
+	return false, nil
+}
 
-	replicaLSN, err := checker.GetLastReplayLSN(context.Background())
-	if err == nil && !replicaLSN.LessThan(requiredLSN) {
-		// Selected replica is ready to use
-		return true, selected
+// waitForReplica polls the replicas until one catches up to the required LSN,
+// giving up after MaxReplicaWait or when the context is done
+func (r *CausalRouter) waitForReplica(ctx context.Context, requiredLSN LSN) (bool, *sql.DB) {
+	poll := r.config.ReplicaWaitPoll
+	if poll <= 0 {
+		poll = defaultReplicaWaitPoll
 	}
 
-	// Selected replica is lagged or error occurred, fall back to master
-	return false, nil
+	deadline := time.NewTimer(r.config.MaxReplicaWait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-deadline.C:
+			r.log().Debug("waitForReplica: no replica caught up in time", "maxWait", r.config.MaxReplicaWait)
+			return false, nil
+		case <-ticker.C:
+			if useReplica, db := r.shouldUseReplica(ctx, requiredLSN); useReplica {
+				return true, db
+			}
+		}
+	}
 }
 
-// GetLSNFromCookie extracts LSN from HTTP request cookies
+// indexOfDB returns the position of target in dbs, or 0 if it is not present
+func indexOfDB(dbs []*sql.DB, target *sql.DB) int {
+	for i, db := range dbs {
+		if db == target {
+			return i
+		}
+	}
+	return 0
+}
+
+// GetLSNFromCookie extracts LSN from HTTP request cookies.
+// Both legacy and versioned consistency tokens are accepted.
 func GetLSNFromCookie(r *http.Request, cookieName string) (LSN, bool) {
 	if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
-		if lsn, err := ParseLSN(cookie.Value); err == nil {
-			return lsn, true
+		if token, err := ParseConsistencyToken(cookie.Value); err == nil {
+			return token.LSN, true
 		}
 	}
 	return LSN{}, false
@@ -293,35 +484,64 @@ func GetLSNFromCookie(r *http.Request, cookieName string) (LSN, bool) {
 // UpdateLSNAfterWrite updates the LSN context after a write operation using the specific DB
 // Optimized version: Event-driven, queries the specific DB that performed the write
 func (r *CausalRouter) UpdateLSNAfterWrite(ctx context.Context) (LSN, error) {
-	slog.Debug("UpdateLSNAfterWrite", "enabled", r.config.Enabled)
+	r.log().Debug("UpdateLSNAfterWrite", "enabled", r.config.Enabled)
+
+	ctx, span := startSpan(contextWithTracer(ctx, r.tracer), SpanUpdateLSNAfterWrite)
+	defer span.End()
 
 	if !r.config.Enabled {
-		slog.Debug("UpdateLSNAfterWrite: LSN tracking not enabled, returning zero LSN")
+		r.log().Debug("UpdateLSNAfterWrite: LSN tracking not enabled, returning zero LSN")
 		return LSN{}, nil
 	}
 
 	lsnCtx := GetLSNContext(ctx)
+	if lsnCtx != nil && lsnCtx.masterDB == nil && !lsnCtx.forwardedLSN.IsZero() {
+		// Only forwarded writes, whose LSN was captured by the forwarder
+		r.log().Debug("UpdateLSNAfterWrite: using the LSN of forwarded writes", "forwardedLSN", lsnCtx.forwardedLSN)
+		r.hooks.lsnUpdate(lsnCtx.forwardedLSN)
+		return lsnCtx.forwardedLSN, nil
+	}
 	if lsnCtx == nil || lsnCtx.masterDB == nil {
-		slog.Debug("UpdateLSNAfterWrite: no LSN context or masterDB available, returning zero LSN")
+		r.log().Debug("UpdateLSNAfterWrite: no LSN context or masterDB available, returning zero LSN")
 		return LSN{}, nil
 	}
 
 	// Create checker on-demand for the specific DB using router's configuration
 	db := lsnCtx.masterDB
+	span.SetAttributes(SpanAttribute{Key: AttrTargetDB, Value: physicalDBName(r.dbProvider, db)})
 	checker := getOrCreateChecker(db, r.queryTimeout)
-	slog.Debug("UpdateLSNAfterWrite: created/updated checker", "queryTimeout", r.queryTimeout)
-
-	masterLSN, err := checker.GetCurrentWALLSN(ctx)
+	r.log().Debug("UpdateLSNAfterWrite: created/updated checker", "queryTimeout", r.queryTimeout)
+
+	var (
+		masterLSN LSN
+		err       error
+	)
+	if r.config.ThrottleTime > 0 {
+		masterLSN, err = checker.throttledWALLSN(ctx, r.config.ThrottleTime)
+	} else {
+		masterLSN, err = checker.GetCurrentWALLSN(ctx)
+	}
 	if err != nil {
-		slog.Debug("UpdateLSNAfterWrite: failed to get master LSN", "error", err)
-		return LSN{}, fmt.Errorf("failed to get master LSN after write: %w", err)
+		r.log().Debug("UpdateLSNAfterWrite: failed to get master LSN", "error", err)
+		span.RecordError(err)
+		err = fmt.Errorf("failed to get master LSN after write: %w", err)
+		r.metrics.lsnTrackingFailures.Add(1)
+		r.hooks.fallback(FallbackEvent{QueryType: QueryTypeWrite, Reason: ReasonLSNTrackingFailed, Err: err})
+		if r.onTrackingError != nil {
+			r.onTrackingError(ctx, err)
+		}
+		return LSN{}, err
 	}
 
-	slog.Debug("UpdateLSNAfterWrite: got master LSN", "masterLSN", masterLSN)
+	r.log().Debug("UpdateLSNAfterWrite: got master LSN", "masterLSN", masterLSN)
+
+	r.metrics.catchUps.write(masterLSN, time.Now())
 
 	// Update context with new LSN requirement
 	lsnCtx.RequiredLSN = masterLSN
-	slog.Debug("UpdateLSNAfterWrite: updated LSN context with new required LSN", "requiredLSN", masterLSN)
+	span.SetAttributes(SpanAttribute{Key: AttrObservedLSN, Value: masterLSN.String()})
+	r.log().Debug("UpdateLSNAfterWrite: updated LSN context with new required LSN", "requiredLSN", masterLSN)
+	r.hooks.lsnUpdate(masterLSN)
 
 	return masterLSN, nil
 }
@@ -0,0 +1,253 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// firstLoadBalancer always resolves to the first element, making routing order deterministic in tests
+type firstLoadBalancer[T DBConnection] struct{}
+
+func (firstLoadBalancer[T]) Resolve(dbs []T) T        { return dbs[0] }
+func (firstLoadBalancer[T]) Name() LoadBalancerPolicy { return "FIRST" }
+
+// staticProvider is a DBProvider over fixed database slices
+type staticProvider struct {
+	primaries []*sql.DB
+	replicas  []*sql.DB
+}
+
+func (p *staticProvider) PrimaryDBs() []*sql.DB               { return p.primaries }
+func (p *staticProvider) ReplicaDBs() []*sql.DB               { return p.replicas }
+func (p *staticProvider) LoadBalancer() LoadBalancer[*sql.DB] { return firstLoadBalancer[*sql.DB]{} }
+
+// newMockDB creates a sqlmock database or fails the test
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database failed: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, mock
+}
+
+func expectReplayLSN(mock sqlmock.Sqlmock, lsn string) {
+	mock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+}
+
+func TestCausalRouterTriesNextReplica(t *testing.T) {
+	primary, _ := newMockDB(t)
+	lagging, laggingMock := newMockDB(t)
+	caughtUp, caughtUpMock := newMockDB(t)
+
+	expectReplayLSN(laggingMock, "0/100")
+	expectReplayLSN(caughtUpMock, "0/3000060")
+
+	router := NewCausalRouter(&staticProvider{
+		primaries: []*sql.DB{primary},
+		replicas:  []*sql.DB{lagging, caughtUp},
+	}, &CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true})
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	selected, err := router.RouteQuery(ctx, QueryTypeRead)
+	if err != nil {
+		t.Fatalf("unexpected routing error: %s", err)
+	}
+	if selected != caughtUp {
+		t.Error("expected the caught up replica to be selected")
+	}
+
+	if err := laggingMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := caughtUpMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCausalRouterFallsBackWhenAllReplicasLag(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica1, mock1 := newMockDB(t)
+	replica2, mock2 := newMockDB(t)
+
+	expectReplayLSN(mock1, "0/100")
+	expectReplayLSN(mock2, "0/200")
+
+	router := NewCausalRouter(&staticProvider{
+		primaries: []*sql.DB{primary},
+		replicas:  []*sql.DB{replica1, replica2},
+	}, &CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true})
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	selected, err := router.RouteQuery(ctx, QueryTypeRead)
+	if err != nil {
+		t.Fatalf("unexpected routing error: %s", err)
+	}
+	if selected != primary {
+		t.Error("expected fallback to the primary when every replica lags")
+	}
+}
+
+func TestCausalRouterWaitsForLaggingReplica(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	expectReplayLSN(replicaMock, "0/100")
+	expectReplayLSN(replicaMock, "0/500")
+	expectReplayLSN(replicaMock, "0/3000060")
+
+	router := NewCausalRouter(&staticProvider{
+		primaries: []*sql.DB{primary},
+		replicas:  []*sql.DB{replica},
+	}, &CausalConsistencyConfig{
+		Enabled:          true,
+		Level:            ReadYourWrites,
+		FallbackToMaster: true,
+		MaxReplicaWait:   time.Second,
+		ReplicaWaitPoll:  time.Millisecond,
+	})
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	selected, err := router.RouteQuery(ctx, QueryTypeRead)
+	if err != nil {
+		t.Fatalf("unexpected routing error: %s", err)
+	}
+	if selected != replica {
+		t.Error("expected the replica once it caught up within MaxReplicaWait")
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCausalRouterWaitTimesOut(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.MatchExpectationsInOrder(false)
+	for i := 0; i < 100; i++ {
+		expectReplayLSN(replicaMock, "0/100")
+	}
+
+	router := NewCausalRouter(&staticProvider{
+		primaries: []*sql.DB{primary},
+		replicas:  []*sql.DB{replica},
+	}, &CausalConsistencyConfig{
+		Enabled:          true,
+		Level:            ReadYourWrites,
+		FallbackToMaster: true,
+		MaxReplicaWait:   20 * time.Millisecond,
+		ReplicaWaitPoll:  5 * time.Millisecond,
+	})
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	start := time.Now()
+	selected, err := router.RouteQuery(ctx, QueryTypeRead)
+	if err != nil {
+		t.Fatalf("unexpected routing error: %s", err)
+	}
+	if selected != primary {
+		t.Error("expected fallback to the primary after MaxReplicaWait")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the router to wait at least MaxReplicaWait, waited %v", elapsed)
+	}
+}
+
+func TestStaleReadRefusedWithoutFallback(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: false}))
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+
+	expectReplayLSN(replicaMock, "0/100")
+	_, err := db.QueryContext(ctx, "SELECT name FROM users")
+	var refused *StaleReadRefused
+	if !errors.Is(err, ErrStaleReadRefused) || !errors.As(err, &refused) || refused.RequiredLSN != (LSN{Lower: 0x1000}) {
+		t.Fatalf("want the read refused with its required LSN, got %v", err)
+	}
+
+	expectReplayLSN(replicaMock, "0/100")
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); !errors.Is(err, ErrStaleReadRefused) {
+		t.Errorf("want the row read refused, got %v", err)
+	}
+
+	// the read ran nowhere, neither the primary nor the replica served it
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	expectReplayLSN(replicaMock, "0/2000")
+	replicaMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("replica"))
+	if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil || name != "replica" {
+		t.Errorf("want the caught-up replica to serve the read, got %q, %v", name, err)
+	}
+}
+
+func TestSelectorsRefuseStaleReads(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: false}))
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+
+	expectReplayLSN(replicaMock, "0/100")
+	if selected, err := db.SelectDB(ctx, QueryTypeRead); !errors.Is(err, ErrStaleReadRefused) || selected != nil {
+		t.Errorf("want the read refused, got %v, %v", selected, err)
+	}
+	// DbSelector can't report the refusal, the primary serves the read rather than the lagging replica
+	expectReplayLSN(replicaMock, "0/100")
+	if selected := db.DbSelector(ctx, QueryTypeRead); selected != primary {
+		t.Error("want the primary selected for the refused read")
+	}
+
+	expectReplayLSN(replicaMock, "0/2000")
+	if selected, err := db.SelectDB(ctx, QueryTypeRead); err != nil || selected != replica {
+		t.Errorf("want the caught-up replica selected, got %v", err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCausalRouterSkipsLSNChecks(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	lsnCtx := &LSNContext{RequiredLSN: LSN{Lower: 0x1000}, SkipCheckRate: 1}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	// the lagging replica serves the read, its LSN isn't queried
+	replicaMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("replica"))
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil || name != "replica" {
+		t.Fatalf("want the replica check skipped, got %q, %v", name, err)
+	}
+
+	lsnCtx.SkipCheckRate = 0
+	expectReplayLSN(replicaMock, "0/100")
+	if selected := db.DbSelector(ctx, QueryTypeRead); selected != primary {
+		t.Error("want the replica checked without skip rate")
+	}
+	if m := db.Metrics(); m.SkippedLSNChecks != 1 || m.LSNFallbacks != 1 {
+		t.Errorf("want 1 skipped check and 1 fallback, got %d and %d", m.SkippedLSNChecks, m.LSNFallbacks)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
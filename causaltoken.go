@@ -0,0 +1,125 @@
+package dbresolver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Causal token errors
+var (
+	// ErrUnsignedCausalToken is returned when a signing key is configured but the token carries no signature
+	ErrUnsignedCausalToken = errors.New("dbresolver: causal token is not signed")
+	// ErrInvalidCausalTokenSignature is returned when the token signature doesn't match any configured key
+	ErrInvalidCausalTokenSignature = errors.New("dbresolver: causal token signature is invalid")
+)
+
+// causalTokenSignatureSize is the number of HMAC-SHA256 bytes kept in the token
+const causalTokenSignatureSize = 16
+
+// SignConsistencyToken encodes the LSN as a v2 token signed with the given HMAC key
+func SignConsistencyToken(lsn LSN, key []byte) string {
+	token := ConsistencyToken{Version: TokenVersion2, LSN: lsn}
+	token.Extensions = []string{tokenSignature(token.Version, lsn, key)}
+	return token.String()
+}
+
+// VerifyConsistencyToken parses the token and verifies its signature against the given keys.
+// The first key is the current signing key; further keys are accepted for key rotation.
+// Without keys, signatures aren't checked and unsigned tokens are accepted.
+func VerifyConsistencyToken(token string, keys ...[]byte) (ConsistencyToken, error) {
+	parsed, err := ParseConsistencyToken(token)
+	if err != nil {
+		return ConsistencyToken{}, err
+	}
+	if len(keys) == 0 {
+		return parsed, nil
+	}
+
+	if parsed.Version < TokenVersion2 || len(parsed.Extensions) == 0 {
+		return ConsistencyToken{}, ErrUnsignedCausalToken
+	}
+
+	signature := parsed.Extensions[0]
+	for _, key := range keys {
+		if hmac.Equal([]byte(signature), []byte(tokenSignature(parsed.Version, parsed.LSN, key))) {
+			return parsed, nil
+		}
+	}
+	return ConsistencyToken{}, ErrInvalidCausalTokenSignature
+}
+
+// tokenSignature computes the signature over the "v<N>.<LSN>" token prefix
+func tokenSignature(version TokenVersion, lsn LSN, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("v" + strconv.Itoa(int(version)) + tokenFieldSeparator + lsn.String()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:causalTokenSignatureSize])
+}
+
+// CausalToken returns an opaque token encoding the LSN the context's reads must observe,
+// so another service can honor this request's writes via WithCausalToken.
+// If the context performed a write, the current master LSN is captured first.
+// An empty token is returned when the context carries no LSN requirement.
+//
+// Tokens are signed when WithCausalTokenKey is configured.
+func (db *DB) CausalToken(ctx context.Context) (string, error) {
+	lsn, err := db.observedLSN(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to capture LSN for causal token: %w", err)
+	}
+	if lsn.IsZero() {
+		return "", nil
+	}
+
+	if len(db.tokenKeys) == 0 {
+		return EncodeConsistencyToken(lsn, TokenVersion1), nil
+	}
+	return SignConsistencyToken(lsn, db.tokenKeys[0]), nil
+}
+
+// observedLSN returns the LSN the reads of ctx must observe, capturing the master LSN first if the context
+// performed a write. It is zero when the context carries no LSN requirement.
+func (db *DB) observedLSN(ctx context.Context) (LSN, error) {
+	lsnCtx := GetLSNContext(ctx)
+	if lsnCtx == nil {
+		return LSN{}, nil
+	}
+
+	lsn := lsnCtx.RequiredLSN
+	if lsnCtx.HasWriteOperation && db.queryRouter != nil {
+		writeLSN, err := db.queryRouter.UpdateLSNAfterWrite(ctx)
+		if err != nil {
+			return LSN{}, err
+		}
+		if writeLSN.GreaterThan(lsn) {
+			lsn = writeLSN
+		}
+	}
+	return lsn, nil
+}
+
+// WithCausalToken returns a context whose reads honor the LSN encoded in a token produced by CausalToken,
+// possibly in another service. Invalid tokens, or unsigned ones when a key is configured, are ignored.
+// An LSN context already present in ctx is raised to the token's LSN, never lowered.
+func (db *DB) WithCausalToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+
+	parsed, err := VerifyConsistencyToken(token, db.tokenKeys...)
+	if err != nil || parsed.LSN.IsZero() {
+		return ctx
+	}
+
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		if parsed.LSN.GreaterThan(lsnCtx.RequiredLSN) {
+			lsnCtx.RequiredLSN = parsed.LSN
+		}
+		return ctx
+	}
+	return WithLSNContext(ctx, &LSNContext{RequiredLSN: parsed.LSN})
+}
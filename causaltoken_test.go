@@ -0,0 +1,88 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerifyConsistencyToken(t *testing.T) {
+	lsn := LSN{Upper: 1, Lower: 0xABCDEF}
+	key := []byte("current-key")
+
+	token := SignConsistencyToken(lsn, key)
+	if !strings.HasPrefix(token, "v2.1/ABCDEF.") {
+		t.Fatalf("unexpected signed token format: %s", token)
+	}
+
+	parsed, err := VerifyConsistencyToken(token, key)
+	if err != nil {
+		t.Fatalf("unexpected verification error: %v", err)
+	}
+	if parsed.LSN != lsn {
+		t.Errorf("want %s, got %s", lsn, parsed.LSN)
+	}
+
+	// older releases that don't verify signatures still read the LSN
+	if legacy, err := ParseConsistencyToken(token); err != nil || legacy.LSN != lsn {
+		t.Errorf("signed token should remain parseable without a key, got %v (%v)", legacy.LSN, err)
+	}
+
+	if _, err := VerifyConsistencyToken(token, []byte("new-key"), key); err != nil {
+		t.Errorf("previous keys should be accepted during rotation, got %v", err)
+	}
+
+	tampered := strings.Replace(token, "1/ABCDEF", "9/ABCDEF", 1)
+	if _, err := VerifyConsistencyToken(tampered, key); !errors.Is(err, ErrInvalidCausalTokenSignature) {
+		t.Errorf("want ErrInvalidCausalTokenSignature for a tampered token, got %v", err)
+	}
+
+	if _, err := VerifyConsistencyToken("v1.1/ABCDEF", key); !errors.Is(err, ErrUnsignedCausalToken) {
+		t.Errorf("want ErrUnsignedCausalToken, got %v", err)
+	}
+}
+
+func TestDBCausalTokenRoundTrip(t *testing.T) {
+	primary, _ := newMockDB(t)
+	writeLSN := LSN{Upper: 2, Lower: 0x100}
+
+	serviceA := New(WithPrimaryDBs(primary), WithCausalTokenKey([]byte("shared")))
+	serviceA.queryRouter = &fixedLSNRouter{lsn: writeLSN}
+	serviceB := New(WithPrimaryDBs(primary), WithCausalTokenKey([]byte("shared")))
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{HasWriteOperation: true})
+	token, err := serviceA.CausalToken(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := serviceB.WithCausalToken(context.Background(), token)
+	lsnCtx := GetLSNContext(received)
+	if lsnCtx == nil || lsnCtx.RequiredLSN != writeLSN {
+		t.Fatalf("expected required LSN %s in service B, got %+v", writeLSN, lsnCtx)
+	}
+
+	// an existing, higher requirement is never lowered
+	higher := LSN{Upper: 3}
+	existing := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: higher})
+	if got := GetLSNContext(serviceB.WithCausalToken(existing, token)).RequiredLSN; got != higher {
+		t.Errorf("want %s, got %s", higher, got)
+	}
+
+	// tokens signed with another key are ignored
+	forged := SignConsistencyToken(LSN{Upper: 9}, []byte("other"))
+	if GetLSNContext(serviceB.WithCausalToken(context.Background(), forged)) != nil {
+		t.Error("forged tokens must not set an LSN requirement")
+	}
+}
+
+func TestDBCausalTokenWithoutRequirement(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	token, err := db.CausalToken(context.Background())
+	if err != nil || token != "" {
+		t.Errorf("want empty token without LSN context, got %q (%v)", token, err)
+	}
+}
@@ -0,0 +1,37 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// ErrResolverClosed is returned by the queries, transactions, statements and pings issued after Close
+var ErrResolverClosed = errors.New("dbresolver: resolver is closed")
+
+// closedDB builds the rows of the queries issued after Close, it is never closed itself
+var closedDB = sql.OpenDB(closedConnector{})
+
+// closedConnector fails every connection with ErrResolverClosed
+type closedConnector struct{}
+
+func (closedConnector) Connect(context.Context) (driver.Conn, error) { return nil, ErrResolverClosed }
+func (closedConnector) Driver() driver.Driver                        { return closedDriver{} }
+
+type closedDriver struct{}
+
+func (closedDriver) Open(string) (driver.Conn, error) { return nil, ErrResolverClosed }
+
+// checkOpen returns ErrResolverClosed once Close is called
+func (db *DB) checkOpen() error {
+	if db.closed.Load() {
+		return ErrResolverClosed
+	}
+	return nil
+}
+
+// closedRow returns a *sql.Row whose Scan reports ErrResolverClosed
+func closedRow(ctx context.Context) *sql.Row {
+	return errorRow(ctx, closedDB, ErrResolverClosed)
+}
@@ -0,0 +1,67 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQueriesAfterClose(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+
+	primaryMock.ExpectPrepare("SELECT 1")
+	replicaMock.ExpectPrepare("SELECT 1")
+	st, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primaryMock.ExpectClose()
+	replicaMock.ExpectClose()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if err := db.Close(); err != nil {
+				t.Errorf("close: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if err := db.Close(); err != nil {
+		t.Errorf("closing again: %v", err)
+	}
+
+	ctx := context.Background()
+	calls := map[string]func() error{
+		"Exec":  func() error { _, err := db.ExecContext(ctx, "UPDATE t SET n = 1"); return err },
+		"Query": func() error { _, err := db.QueryContext(ctx, "SELECT 1"); return err },
+		"QueryRow": func() error {
+			var n int
+			return db.QueryRowContext(ctx, "SELECT 1").Scan(&n)
+		},
+		"Prepare":   func() error { _, err := db.PrepareContext(ctx, "SELECT 1"); return err },
+		"BeginTx":   func() error { _, err := db.BeginTx(ctx, nil); return err },
+		"Ping":      func() error { return db.PingContext(ctx) },
+		"Conn":      func() error { _, err := db.Conn(ctx); return err },
+		"Stmt.Exec": func() error { _, err := st.ExecContext(ctx); return err },
+		"Stmt.QueryRow": func() error {
+			var n int
+			return st.QueryRowContext(ctx).Scan(&n)
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrResolverClosed) {
+			t.Errorf("%s: want ErrResolverClosed, got %v", name, err)
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/alfari16/go-pgrouter/cmd/pgrouter-soak
+
+go 1.25.5
+
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/alfari16/go-pgrouter v0.0.0-20261014132500-77a25c75cdef
+	github.com/lib/pq v1.10.9
+)
+
+require go.uber.org/multierr v1.11.0 // indirect
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// resumeTimeout bounds resuming replay, which also runs after the soak context is done
+const resumeTimeout = 5 * time.Second
+
+// induceLag pauses WAL replay on every replica for pauseFor, every pauseEvery, until ctx is done.
+// Replay is always resumed before returning. Pausing requires superuser or EXECUTE on
+// pg_wal_replay_pause and pg_wal_replay_resume.
+func induceLag(ctx context.Context, replicas []*sql.DB, pauseEvery, pauseFor time.Duration, s *stats) {
+	ticker := time.NewTicker(pauseEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for i, replica := range replicas {
+			if _, err := replica.ExecContext(ctx, "SELECT pg_wal_replay_pause()"); err != nil {
+				log.Printf("replica %d: pausing replay failed: %v", i, err)
+			}
+		}
+		s.pauses.Add(1)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(pauseFor):
+		}
+		resumeReplay(replicas)
+	}
+}
+
+// resumeReplay resumes WAL replay on every replica
+func resumeReplay(replicas []*sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), resumeTimeout)
+	defer cancel()
+
+	for i, replica := range replicas {
+		if _, err := replica.ExecContext(ctx, "SELECT pg_wal_replay_resume()"); err != nil {
+			log.Printf("replica %d: resuming replay failed: %v", i, err)
+		}
+	}
+}
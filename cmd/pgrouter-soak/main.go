@@ -0,0 +1,145 @@
+// Command pgrouter-soak verifies the read-your-writes guarantee of dbresolver against a real cluster.
+//
+// Workers insert a row on the primary and read it back with the causal token of the write while
+// WAL replay is periodically paused on the replicas to induce lag. Every read that doesn't see its
+// row is reported as a violation, and the command exits with status 1 if any occurred.
+//
+// Usage:
+//
+//	pgrouter-soak -primary "postgres://..." -replicas "postgres://...,postgres://..." -duration 5m
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func main() {
+	var (
+		primaryDSN  = flag.String("primary", "", "primary DSN (required)")
+		replicaDSNs = flag.String("replicas", "", "comma-separated replica DSNs (required)")
+		duration    = flag.Duration("duration", time.Minute, "how long to run the workload")
+		workers     = flag.Int("workers", 8, "number of concurrent write-then-read workers")
+		pauseEvery  = flag.Duration("pause-every", 10*time.Second, "interval between replay pauses on the replicas, 0 disables them")
+		pauseFor    = flag.Duration("pause-for", 3*time.Second, "how long replay stays paused")
+		maxWait     = flag.Duration("max-replica-wait", 0, "MaxReplicaWait of the causal consistency config")
+		noFallback  = flag.Bool("no-fallback", false, "fail reads instead of falling back to the primary")
+		table       = flag.String("table", "pgrouter_soak", "table written by the workload, dropped afterwards")
+		keepTable   = flag.Bool("keep-table", false, "don't drop the table after the run")
+	)
+	flag.Parse()
+
+	if *primaryDSN == "" || *replicaDSNs == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	violations, err := run(config{
+		primaryDSN:  *primaryDSN,
+		replicaDSNs: strings.Split(*replicaDSNs, ","),
+		duration:    *duration,
+		workers:     *workers,
+		pauseEvery:  *pauseEvery,
+		pauseFor:    *pauseFor,
+		maxWait:     *maxWait,
+		fallback:    !*noFallback,
+		table:       *table,
+		keepTable:   *keepTable,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if violations > 0 {
+		os.Exit(1)
+	}
+}
+
+// config holds the command flags
+type config struct {
+	primaryDSN  string
+	replicaDSNs []string
+	duration    time.Duration
+	workers     int
+	pauseEvery  time.Duration
+	pauseFor    time.Duration
+	maxWait     time.Duration
+	fallback    bool
+	table       string
+	keepTable   bool
+}
+
+// run executes the soak test and returns the number of read-your-writes violations
+func run(cfg config) (int64, error) {
+	primary, err := sql.Open("postgres", cfg.primaryDSN)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open primary: %w", err)
+	}
+	replicas := make([]*sql.DB, 0, len(cfg.replicaDSNs))
+	for _, dsn := range cfg.replicaDSNs {
+		replica, err := sql.Open("postgres", strings.TrimSpace(dsn))
+		if err != nil {
+			return 0, fmt.Errorf("failed to open replica: %w", err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	s := &stats{}
+	db := dbresolver.New(
+		dbresolver.WithPrimaryDBs(primary),
+		dbresolver.WithReplicaDBs(replicas...),
+		dbresolver.WithCausalConsistencyConfig(&dbresolver.CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            dbresolver.ReadYourWrites,
+			FallbackToMaster: cfg.fallback,
+			Timeout:          time.Second,
+			MaxReplicaWait:   cfg.maxWait,
+		}),
+		dbresolver.WithRoutingHooks(nil, s.fallback, nil),
+	)
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := db.PingContext(ctx); err != nil {
+		return 0, fmt.Errorf("failed to reach the cluster: %w", err)
+	}
+
+	w := &soak{db: db, table: cfg.table, stats: s}
+	if err := w.setup(ctx); err != nil {
+		return 0, err
+	}
+	if !cfg.keepTable {
+		defer func() {
+			if err := w.teardown(context.Background()); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+	if cfg.pauseEvery > 0 {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			induceLag(ctx, replicas, cfg.pauseEvery, cfg.pauseFor, s)
+		}()
+		defer func() { <-done }()
+	}
+
+	start := time.Now()
+	w.run(ctx, cfg.workers)
+	s.report(time.Since(start), db.Metrics())
+	return s.violations.Load(), nil
+}
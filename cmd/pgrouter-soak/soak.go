@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// stats are the counters reported at the end of a soak run
+type stats struct {
+	writes       atomic.Int64
+	reads        atomic.Int64
+	violations   atomic.Int64
+	errors       atomic.Int64
+	replicaReads atomic.Int64
+	primaryReads atomic.Int64
+	pauses       atomic.Int64
+
+	mu        sync.Mutex
+	fallbacks map[string]int64
+}
+
+// fallback counts a fallback by reason, it is registered as the OnFallback routing hook
+func (s *stats) fallback(e dbresolver.FallbackEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fallbacks == nil {
+		s.fallbacks = make(map[string]int64)
+	}
+	s.fallbacks[e.Reason]++
+}
+
+// report prints the counters
+func (s *stats) report(elapsed time.Duration, metrics dbresolver.Metrics) {
+	fmt.Printf("duration:             %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("writes:               %d\n", s.writes.Load())
+	fmt.Printf("reads:                %d (replica %d, primary %d)\n", s.reads.Load(), s.replicaReads.Load(), s.primaryReads.Load())
+	fmt.Printf("recovery pauses:      %d\n", s.pauses.Load())
+	fmt.Printf("errors:               %d\n", s.errors.Load())
+	fmt.Printf("lsn checks:           %d\n", metrics.LSNCheckLatency.Count)
+
+	s.mu.Lock()
+	reasons := make([]string, 0, len(s.fallbacks))
+	for reason := range s.fallbacks {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("fallback %s: %d\n", reason, s.fallbacks[reason])
+	}
+	s.mu.Unlock()
+
+	fmt.Printf("ryw violations:       %d\n", s.violations.Load())
+}
+
+// soak runs the write-then-read workload
+type soak struct {
+	db    *dbresolver.DB
+	table string
+	stats *stats
+}
+
+// setup creates the table written by the workload
+func (s *soak) setup(ctx context.Context) error {
+	_, err := s.db.ReadWrite().ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+s.table+
+		" (id BIGSERIAL PRIMARY KEY, worker INT NOT NULL, seq BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now())")
+	if err != nil {
+		return fmt.Errorf("failed to create table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+// teardown drops the table written by the workload
+func (s *soak) teardown(ctx context.Context) error {
+	if _, err := s.db.ReadWrite().ExecContext(ctx, "DROP TABLE IF EXISTS "+s.table); err != nil {
+		return fmt.Errorf("failed to drop table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+// run starts the workers and waits for them until ctx is done
+func (s *soak) run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := int64(0); ctx.Err() == nil; seq++ {
+				if err := s.iteration(ctx, worker, seq); err != nil && ctx.Err() == nil {
+					s.stats.errors.Add(1)
+					log.Printf("worker %d: %v", worker, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// iteration inserts a row, then reads it back from a new context carrying the causal token of
+// the write, like the request following a POST would. A missing row is a read-your-writes violation.
+func (s *soak) iteration(ctx context.Context, worker int, seq int64) error {
+	writeCtx := dbresolver.WithLSNContext(ctx, &dbresolver.LSNContext{})
+	var id int64
+	err := s.db.QueryRowContext(writeCtx,
+		"INSERT INTO "+s.table+" (worker, seq) VALUES ($1, $2) RETURNING id", worker, seq).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("insert failed: %w", err)
+	}
+	s.stats.writes.Add(1)
+
+	token, err := s.db.CausalToken(writeCtx)
+	if err != nil {
+		return fmt.Errorf("capturing the write LSN failed: %w", err)
+	}
+
+	readCtx := dbresolver.WithReadAccumulator(s.db.WithCausalToken(ctx, token))
+	var got int64
+	err = s.db.QueryRowContext(readCtx, "SELECT id FROM "+s.table+" WHERE id = $1", id).Scan(&got)
+
+	acc := dbresolver.GetReadAccumulator(readCtx)
+	s.stats.primaryReads.Add(acc.PrimaryReads())
+	s.stats.replicaReads.Add(acc.ReplicaReads())
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		s.stats.violations.Add(1)
+		log.Printf("worker %d: read-your-writes violation, row %d not visible (token %s, replica reads %d)",
+			worker, id, token, acc.ReplicaReads())
+		return nil
+	case err != nil:
+		return fmt.Errorf("read failed: %w", err)
+	}
+	s.stats.reads.Add(1)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func newTestSoak(t *testing.T) (*soak, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	t.Helper()
+
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stats{}
+	db := dbresolver.New(
+		dbresolver.WithPrimaryDBs(primary),
+		dbresolver.WithReplicaDBs(replica),
+		dbresolver.WithCausalConsistencyConfig(&dbresolver.CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            dbresolver.ReadYourWrites,
+			FallbackToMaster: true,
+		}),
+		dbresolver.WithRoutingHooks(nil, s.fallback, nil),
+	)
+	t.Cleanup(func() { _ = db.Close() })
+	return &soak{db: db, table: "soak", stats: s}, primaryMock, replicaMock
+}
+
+func TestIterationReadsFromCaughtUpReplica(t *testing.T) {
+	w, primaryMock, replicaMock := newTestSoak(t)
+
+	primaryMock.ExpectQuery("INSERT INTO soak").WithArgs(1, int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	primaryMock.ExpectQuery("pg_current_wal_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	replicaMock.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	replicaMock.ExpectQuery("SELECT id FROM soak").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	if err := w.iteration(context.Background(), 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if w.stats.reads.Load() != 1 || w.stats.replicaReads.Load() != 1 || w.stats.violations.Load() != 0 {
+		t.Errorf("expected one replica read without violation, got reads=%d replica=%d violations=%d",
+			w.stats.reads.Load(), w.stats.replicaReads.Load(), w.stats.violations.Load())
+	}
+}
+
+func TestIterationCountsViolationsAndFallbacks(t *testing.T) {
+	w, primaryMock, replicaMock := newTestSoak(t)
+
+	primaryMock.ExpectQuery("INSERT INTO soak").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	primaryMock.ExpectQuery("pg_current_wal_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	replicaMock.ExpectQuery("pg_last_wal_replay_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/4000"))
+	// the lagging replica is skipped; a broken primary not returning the row is a violation
+	primaryMock.ExpectQuery("SELECT id FROM soak").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if err := w.iteration(context.Background(), 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if w.stats.violations.Load() != 1 || w.stats.primaryReads.Load() != 1 {
+		t.Errorf("expected a violation on a primary read, got violations=%d primary=%d",
+			w.stats.violations.Load(), w.stats.primaryReads.Load())
+	}
+	if w.stats.fallbacks[dbresolver.ReasonReplicaLagging] != 1 {
+		t.Errorf("expected a replica lagging fallback, got %v", w.stats.fallbacks)
+	}
+}
@@ -19,12 +19,15 @@ type Conn interface {
 }
 
 type conn struct {
+	db               *DB
 	sourceDB         *sql.DB
 	conn             *sql.Conn
 	queryTypeChecker QueryTypeChecker
+	handle           *leakHandle
 }
 
 func (c *conn) Close() error {
+	c.handle.release()
 	return c.conn.Close()
 }
 
@@ -35,9 +38,13 @@ func (c *conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
 	}
 
 	return &tx{
+		db:               c.db,
 		sourceDB:         c.sourceDB,
 		tx:               stx,
 		queryTypeChecker: c.queryTypeChecker,
+		handle:           c.handle.child(HandleTx),
+		ctx:              ctx,
+		readOnly:         opts != nil && opts.ReadOnly,
 	}, nil
 }
 
@@ -0,0 +1,217 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// Connector returns a driver.Connector routing every statement through db, so that sql.OpenDB(db.Connector())
+// is a *sql.DB usable by the libraries requiring one, such as ent and the code generated by sqlc:
+//
+//	// ent
+//	client := ent.NewClient(ent.Driver(entsql.OpenDB(dialect.Postgres, sql.OpenDB(db.Connector()))))
+//
+//	// sqlc
+//	queries := sqlcdb.New(sql.OpenDB(db.Connector()))
+//
+// Reads and writes are routed as by db.QueryContext and db.ExecContext, honoring the LSN context of the
+// query context. Transactions are started with db.BeginTx and run every statement. Statements prepared
+// through the returned connector are routed again at each execution, use db.PrepareContext to prepare them
+// on every database. Closing the *sql.DB built on the connector doesn't close db.
+func (db *DB) Connector() driver.Connector {
+	return resolverConnector{db: db}
+}
+
+// errConnectorOnly is returned by the driver of the connector, it can only open connections through the connector
+var errConnectorOnly = errors.New("dbresolver: the resolver driver only opens connections through DB.Connector")
+
+type resolverConnector struct {
+	db *DB
+}
+
+func (c resolverConnector) Connect(context.Context) (driver.Conn, error) {
+	if err := c.db.checkOpen(); err != nil {
+		return nil, err
+	}
+	return &resolverConn{db: c.db}, nil
+}
+
+func (c resolverConnector) Driver() driver.Driver { return resolverDriver{} }
+
+type resolverDriver struct{}
+
+func (resolverDriver) Open(string) (driver.Conn, error) { return nil, errConnectorOnly }
+
+// resolverConn is a virtual connection: its statements run on the resolver, or on the transaction it began
+type resolverConn struct {
+	db *DB
+	tx Tx
+}
+
+// querier returns the transaction of the connection, or the resolver
+func (c *resolverConn) querier() interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+} {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db
+}
+
+func (c *resolverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.querier().ExecContext(ctx, query, namedArgs(args)...)
+}
+
+func (c *resolverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.querier().QueryContext(ctx, query, namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	return &resolverRows{rows: rows, columns: columns}, nil
+}
+
+func (c *resolverConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *resolverConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &resolverStmt{conn: c, query: query}, nil
+}
+
+func (c *resolverConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *resolverConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.IsolationLevel(opts.Isolation), ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	c.tx = tx
+	return resolverTx{conn: c}, nil
+}
+
+func (c *resolverConn) Ping(ctx context.Context) error { return c.db.PingContext(ctx) }
+
+// CheckNamedValue passes the arguments through unchanged, they are converted by the physical databases
+func (c *resolverConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+// ResetSession discards the connection if a transaction was left open on it
+func (c *resolverConn) ResetSession(context.Context) error {
+	if c.tx != nil {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *resolverConn) Close() error {
+	if c.tx != nil {
+		_ = c.tx.Rollback()
+		c.tx = nil
+	}
+	return nil
+}
+
+type resolverTx struct {
+	conn *resolverConn
+}
+
+func (t resolverTx) Commit() error {
+	defer func() { t.conn.tx = nil }()
+	return t.conn.tx.Commit()
+}
+
+func (t resolverTx) Rollback() error {
+	defer func() { t.conn.tx = nil }()
+	return t.conn.tx.Rollback()
+}
+
+// resolverStmt runs its query on the connection at each execution
+type resolverStmt struct {
+	conn  *resolverConn
+	query string
+}
+
+func (s *resolverStmt) Close() error  { return nil }
+func (s *resolverStmt) NumInput() int { return -1 }
+
+func (s *resolverStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *resolverStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *resolverStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valueArgs(args))
+}
+
+func (s *resolverStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valueArgs(args))
+}
+
+// resolverRows reads the rows of a physical database
+type resolverRows struct {
+	rows    *sql.Rows
+	columns []string
+	values  []any
+	dest    []any
+}
+
+func (r *resolverRows) Columns() []string { return r.columns }
+func (r *resolverRows) Close() error      { return r.rows.Close() }
+
+func (r *resolverRows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	if r.dest == nil {
+		r.values = make([]any, len(r.columns))
+		r.dest = make([]any, len(r.columns))
+		for i := range r.values {
+			r.dest[i] = &r.values[i]
+		}
+	}
+	if err := r.rows.Scan(r.dest...); err != nil {
+		return err
+	}
+	for i, value := range r.values {
+		dest[i] = value
+	}
+	return nil
+}
+
+// namedArgs converts the arguments of a driver statement back to the arguments of a query
+func namedArgs(args []driver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			values[i] = sql.Named(arg.Name, arg.Value)
+			continue
+		}
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// valueArgs converts positional driver values to named values
+func valueArgs(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
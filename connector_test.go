@@ -0,0 +1,90 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// sqlcDBTX is the interface of the code generated by sqlc
+type sqlcDBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+func TestConnectorRoutesStatements(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	var compat sqlcDBTX = sql.OpenDB(db.Connector())
+	ctx := context.Background()
+
+	replicaMock.ExpectQuery("SELECT name FROM users WHERE id = \\$1").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	var name string
+	if err := compat.QueryRowContext(ctx, "SELECT name FROM users WHERE id = $1", 7).Scan(&name); err != nil || name != "alice" {
+		t.Fatalf("want the read served by the replica, got %q, %v", name, err)
+	}
+
+	primaryMock.ExpectExec("UPDATE users").WithArgs("bob", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	result, err := compat.ExecContext(ctx, "UPDATE users SET name = $1 WHERE id = $2", "bob", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("want 1 row affected, got %d", n)
+	}
+
+	replicaMock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	st, err := compat.PrepareContext(ctx, "SELECT count(*) FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := st.QueryRowContext(ctx).Scan(&count); err != nil || count != 2 {
+		t.Errorf("want the prepared read served by the replica, got %d, %v", count, err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConnectorTransactions(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	compat := sql.OpenDB(db.Connector())
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT balance").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(10))
+	primaryMock.ExpectExec("UPDATE accounts").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectCommit()
+
+	tx, err := compat.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var balance int
+	if err := tx.QueryRow("SELECT balance FROM accounts WHERE id = 1").Scan(&balance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("UPDATE accounts SET balance = 0 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrCopyToUnsupported is returned by CopyTo without CopyConfig.To, database/sql having no COPY TO STDOUT
+var ErrCopyToUnsupported = errors.New("dbresolver: COPY TO needs a driver-specific CopyConfig.To")
+
+// CopyFromSource is the source of the rows of CopyFrom, with the method set of pgx.CopyFromSource
+type CopyFromSource interface {
+	Next() bool
+	Values() ([]any, error)
+	Err() error
+}
+
+// CopyFromRows returns a CopyFromSource over rows
+func CopyFromRows(rows [][]any) CopyFromSource {
+	return &copyFromRows{rows: rows, i: -1}
+}
+
+type copyFromRows struct {
+	rows [][]any
+	i    int
+}
+
+func (r *copyFromRows) Next() bool {
+	r.i++
+	return r.i < len(r.rows)
+}
+
+func (r *copyFromRows) Values() ([]any, error) { return r.rows[r.i], nil }
+func (r *copyFromRows) Err() error             { return nil }
+
+// CopyFromFunc bulk loads the rows of src into the columns of table on conn, a connection of the primary, and
+// returns the number of rows loaded
+type CopyFromFunc func(ctx context.Context, conn *sql.Conn, table string, columns []string, src CopyFromSource) (int64, error)
+
+// CopyToFunc runs statement, a COPY ... TO STDOUT, on conn and writes its output to w, returning the number of
+// rows copied
+type CopyToFunc func(ctx context.Context, conn *sql.Conn, w io.Writer, statement string) (int64, error)
+
+// CopyConfig configures WithCopy
+type CopyConfig struct {
+	// From runs CopyFrom, defaults to COPY FROM STDIN through database/sql, supported by lib/pq. Drivers with a
+	// native COPY are faster, e.g. pgxresolver.CopyFromStdlib for the pgx stdlib driver.
+	From CopyFromFunc
+	// To runs CopyTo, which fails with ErrCopyToUnsupported without it, e.g. pgxresolver.CopyToStdlib
+	To CopyToFunc
+	// ToReplicas routes CopyTo like a read, e.g. to a replica which caught up to the LSN required by the context,
+	// instead of the primary
+	ToReplicas bool
+}
+
+// WithCopy configures the driver-specific fast paths and the routing of CopyFrom and CopyTo
+func WithCopy(config CopyConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.From == nil {
+			config.From = CopyFromStdin
+		}
+		opt.Copy = &config
+	}
+}
+
+// CopyFrom bulk loads the rows of src into the columns of table, possibly schema-qualified, on the primary with
+// COPY FROM, see WithCopy. The LSN of the load is captured on the same connection for the LSN context of ctx,
+// so the next reads observe the rows loaded.
+func (db *DB) CopyFrom(ctx context.Context, table string, columns []string, src CopyFromSource) (int64, error) {
+	if err := db.checkOpen(); err != nil {
+		return 0, err
+	}
+	copyFrom := CopyFromFunc(CopyFromStdin)
+	if db.copy != nil {
+		copyFrom = db.copy.From
+	}
+	primary, err := db.selectDB(ctx, QueryTypeWrite, "COPY "+table+" FROM STDIN")
+	if err != nil {
+		return 0, err
+	}
+	conn, err := primary.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := db.applySearchPath(ctx, primary, conn); err != nil {
+		return 0, err
+	}
+
+	n, err := copyFrom(ctx, conn, table, columns, src)
+	if err != nil {
+		return n, fmt.Errorf("failed to copy rows into %s: %w", table, err)
+	}
+	db.captureConnLSN(ctx, conn)
+	return n, nil
+}
+
+// CopyTo runs statement, a COPY ... TO STDOUT, with CopyConfig.To and writes its output to w. It runs on the
+// primary, or with CopyConfig.ToReplicas on the replica selected like the reads of QueryContext, e.g. one of the
+// resource class of ctx.
+func (db *DB) CopyTo(ctx context.Context, w io.Writer, statement string) (int64, error) {
+	if err := db.checkOpen(); err != nil {
+		return 0, err
+	}
+	if db.copy == nil || db.copy.To == nil {
+		return 0, ErrCopyToUnsupported
+	}
+	target := db.ReadWrite()
+	if db.copy.ToReplicas {
+		var err error
+		if target, err = db.selectDB(ctx, QueryTypeRead, statement); err != nil {
+			return 0, err
+		}
+	}
+	conn, err := target.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := db.applySearchPath(ctx, target, conn); err != nil {
+		return 0, err
+	}
+	return db.copy.To(ctx, conn, w, statement)
+}
+
+// CopyFromStdin is the CopyFromFunc of the drivers running COPY FROM STDIN as a prepared statement in a
+// transaction, executed once per row then once without args to complete it, like lib/pq
+func CopyFromStdin(ctx context.Context, conn *sql.Conn, table string, columns []string, src CopyFromSource) (int64, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, copyFromStatement(table, columns))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var n int64
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	if err := src.Err(); err != nil {
+		return 0, err
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// copyFromStatement returns the COPY FROM STDIN statement of the columns of table, every identifier quoted
+func copyFromStatement(table string, columns []string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = quoteIdentifier(part)
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return "COPY " + strings.Join(parts, ".") + " (" + strings.Join(quoted, ", ") + ") FROM STDIN"
+}
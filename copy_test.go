@@ -0,0 +1,104 @@
+package dbresolver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCopyFrom(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	// COPY FROM STDIN the way of lib/pq, then the LSN of the load on the same connection
+	primaryMock.ExpectBegin()
+	copyStmt := primaryMock.ExpectPrepare(`COPY "public"."users" \("id", "name"\) FROM STDIN`)
+	copyStmt.ExpectExec().WithArgs(1, "alice").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt.ExpectExec().WithArgs(2, "bob").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	primaryMock.ExpectCommit()
+	expectCurrentWALLSN(primaryMock, "0/6000")
+
+	n, err := db.CopyFrom(ctx, "public.users", []string{"id", "name"}, CopyFromRows([][]any{{1, "alice"}, {2, "bob"}}))
+	if err != nil || n != 2 {
+		t.Fatalf("want 2 rows loaded, got %d, %v", n, err)
+	}
+	if !lsnCtx.HasWriteOperation || lsnCtx.RequiredLSN != (LSN{Lower: 0x6000}) {
+		t.Errorf("want the LSN of the load required, got %+v", lsnCtx)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	ctx := context.Background()
+	// a driver-specific COPY TO, reading a row instead
+	copyTo := func(ctx context.Context, conn *sql.Conn, w io.Writer, statement string) (int64, error) {
+		var csv string
+		if err := conn.QueryRowContext(ctx, statement).Scan(&csv); err != nil {
+			return 0, err
+		}
+		_, err := io.WriteString(w, csv)
+		return 1, err
+	}
+
+	if _, err := New(WithPrimaryDBs(primary)).CopyTo(ctx, io.Discard, "COPY users TO STDOUT"); !errors.Is(err, ErrCopyToUnsupported) {
+		t.Errorf("want COPY TO unsupported by default, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		toReplicas bool
+		mock       sqlmock.Sqlmock
+	}{{false, primaryMock}, {true, replicaMock}} {
+		db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithCopy(CopyConfig{To: copyTo, ToReplicas: tt.toReplicas}))
+		tt.mock.ExpectQuery("COPY users TO STDOUT").WillReturnRows(sqlmock.NewRows([]string{"csv"}).AddRow("1,alice\n"))
+		var out bytes.Buffer
+		if n, err := db.CopyTo(ctx, &out, "COPY users TO STDOUT (FORMAT csv)"); err != nil || n != 1 || out.String() != "1,alice\n" {
+			t.Errorf("want the table copied, got %d %q, %v", n, out.String(), err)
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCopyToReplicasHonorsResourceClass(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	analytics, analyticsMock := newMockDB(t)
+	copyTo := func(ctx context.Context, conn *sql.Conn, w io.Writer, statement string) (int64, error) {
+		_, err := conn.ExecContext(ctx, statement)
+		return 0, err
+	}
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithResourceClassReplicas("analytics", analytics),
+		WithCopy(CopyConfig{To: copyTo, ToReplicas: true}))
+
+	// the export of an analytics request runs on its pool, like its queries
+	analyticsMock.ExpectExec("COPY events TO STDOUT").WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := db.CopyTo(WithResourceClass(context.Background(), "analytics"), io.Discard, "COPY events TO STDOUT"); err != nil {
+		t.Fatal(err)
+	}
+	if m := db.Metrics(); m.ReplicaReads != 1 {
+		t.Errorf("want the export counted as a read, got %d", m.ReplicaReads)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock, analyticsMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/multierr"
@@ -15,6 +19,12 @@ import (
 type QueryRouter interface {
 	// RouteQuery routes a query to the appropriate database based on query type and context
 	RouteQuery(ctx context.Context, queryType QueryType) (*sql.DB, error)
+	LSNTracker
+}
+
+// LSNTracker captures the LSN of the writes of a request, it is all the HTTP middleware needs from a router
+// and lets resolvers of other backends, such as pgxresolver, use it
+type LSNTracker interface {
 	// UpdateLSNAfterWrite updates LSN tracking after a write operation (optional)
 	// Implementations can return zero LSN and nil error if LSN tracking is not supported
 	UpdateLSNAfterWrite(ctx context.Context) (LSN, error)
@@ -32,22 +42,138 @@ type StmtLoadBalancer LoadBalancer[*sql.Stmt]
 // with optional LSN-based causal consistency support.
 
 type DB struct {
-	primaries        []*sql.DB
-	replicas         []*sql.DB
+	primaries        []*sql.DB // guarded by replicasMu, see allPrimaries
+	replicas         []*sql.DB // guarded by replicasMu, see allReplicas
 	loadBalancer     DBLoadBalancer
 	stmtLoadBalancer StmtLoadBalancer
 	queryTypeChecker QueryTypeChecker
 	queryRouter      QueryRouter
+	outage           *replicaOutage
+	tokenKeys        [][]byte
+	metrics          routingMetrics
+	readGuard        *primaryReadGuard
+	logger           *slog.Logger
+	hooks            *routingHooks
+	names            map[*sql.DB]string // guarded by replicasMu, see dbNames
+	assertions       *consistencyAssertions
+	health           *healthMonitor
+
+	// replicas grouped by read preference, nil without a region topology. Guarded by replicasMu.
+	tiers [][]*sql.DB
+	// primaries, replicas, tiers and names are replaced, never modified, by SetPrimary, AddReplica, RemoveReplica
+	// and Swap
+	replicasMu sync.RWMutex
+	// held for writing by SetPrimary, AddReplica, RemoveReplica and Swap, for reading while preparing statements
+	membershipMu sync.RWMutex
+	// statements prepared on every database, kept in sync with the replicas
+	stmts sync.Map // *stmt -> struct{}
+	// replicas taken out of rotation with DrainReplica
+	drain replicaDrain
+	// replicas with delayed replication, see WithDelayedReplicas
+	delay replicaDelay
+	// executes the writes of ExecContext instead of the primaries, nil to execute them locally
+	forwarder WriteForwarder
+	// tracks checked out Conn and Tx handles, nil without leak detection
+	leaks *leakDetector
+	// retries the reads failing with transient errors, nil to never retry
+	retry *retryPolicy
+	// lets Prepare and Ping succeed when only replicas fail, nil to fail them wholesale
+	partialFailures *partialFailureTolerance
+	// rechecks the role of every database, nil without role verification
+	roles *roleVerifier
+	// promotes the replica that left recovery when the primaries are lost, nil without auto failover
+	failover *autoFailover
+	// demotes the replicas whose queries fail too often, nil to never demote
+	errorRatio *errorRatioTracker
+	classifier ErrorClassifier
+	// last routing decisions, nil without a decision log
+	decisions *decisionLog
+	// reports the ejected replicas, nil without ejection diagnosis
+	diagnosis *DiagnosisConfig
+	// offloads the boostable reads from a slow primary, nil without replica boost
+	boost *replicaBoost
+	// averages the ratio of reads falling back to a primary, nil without WithFallbackRatio
+	fallbackRatio *fallbackRatio
+	// counts the reads per table and database, nil without WithReadDistribution
+	distribution *readDistribution
+	// replays the writes on the target of a migration, nil without WithDualWrite
+	dualWrite *dualWriter
+	// samples the reads, nil without WithReadCapture
+	capture *ReadCaptureConfig
+	// keeps the replicas joining the read pool out of it while they warm up, nil without WithReplicaWarmUp
+	warmup *replicaWarmUp
+	// captures the commit LSN of the transactions on their connection, see WithTxCommitLSN
+	txCommitLSN bool
+	// called with the failed LSN captures after writes, see WithLSNTrackingErrorHandler
+	onLSNTrackingError func(ctx context.Context, err error)
+	// runs CopyFrom and CopyTo, nil without WithCopy
+	copy *CopyConfig
+	// runs Listen, nil without WithNotifications
+	notifications *NotificationConfig
+	// timeouts of the transactions of Maintenance
+	maintenance MaintenanceConfig
+	// databases whose pooled connections may be left on the search_path of a request, see WithSearchPath
+	searchPaths sync.Map // *sql.DB -> struct{}
+
+	// replica pools dedicated to resource classes
+	classReplicas map[ResourceClass][]*sql.DB
+	queryClasses  []queryResourceClass
+
+	// replica keepalive
+	activity          replicaActivity
+	keepaliveInterval time.Duration
+	keepaliveTimeout  time.Duration
+
+	// writes suspended by EnterReadOnlyMode
+	readOnly atomic.Bool
+
+	// set by Close, see ErrResolverClosed
+	closed    atomic.Bool
+	closeOnce sync.Once
+	closeErr  error
+
+	// background workers, stopped on Close. bgMu orders starting a worker with stopping them.
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	bgMu     sync.Mutex
+	wg       sync.WaitGroup
 }
 
 // PrimaryDBs return all the active primary DB
 func (db *DB) PrimaryDBs() []*sql.DB {
-	return db.primaries
+	return db.allPrimaries()
 }
 
-// ReplicaDBs return all the active replica DB
+// ReplicaDBs return all the active replica DB.
+// Delayed replicas, replicas drained with DrainReplica, considered down by the replica outage tracking, evicted by the
+// health monitor or demoted for their error ratio are excluded.
+// With a region topology, only the most preferred tier with an available replica is returned.
 func (db *DB) ReplicaDBs() []*sql.DB {
-	return db.replicas
+	tiers := db.readTiers()
+	if tiers == nil {
+		return db.availableReplicas(db.allReplicas())
+	}
+	for _, tier := range tiers {
+		if replicas := db.availableReplicas(tier); len(replicas) > 0 {
+			return replicas
+		}
+	}
+	return nil
+}
+
+// availableReplicas filters out the replicas considered down, evicted or demoted
+func (db *DB) availableReplicas(replicas []*sql.DB) []*sql.DB {
+	replicas = db.warmup.available(db.delay.available(db.drain.available(replicas)))
+	if db.health != nil {
+		replicas = db.health.available(replicas)
+	}
+	if db.errorRatio != nil {
+		replicas = db.errorRatio.available(replicas)
+	}
+	if db.outage == nil {
+		return replicas
+	}
+	return db.outage.available(replicas)
 }
 
 // LoadBalancer returns the database load balancer
@@ -62,15 +188,36 @@ func (db *DB) IsCausalConsistencyEnabled() bool {
 }
 
 // Close closes all physical databases concurrently, releasing any open resources.
+// The queries in flight complete, the ones issued afterwards fail with ErrResolverClosed.
+// Close can be called several times and concurrently, every call returns the outcome of the first.
 func (db *DB) Close() error {
+	db.closeOnce.Do(func() {
+		db.closed.Store(true)
+		db.stopBackground()
+		db.closeErr = db.closeDatabases()
+	})
+	return db.closeErr
+}
+
+// closeDatabases closes all physical databases concurrently
+func (db *DB) closeDatabases() error {
 	var errors []error
 
-	errPrimaries := doParallely(len(db.primaries), func(i int) error {
-		return db.primaries[i].Close()
+	primaries := db.allPrimaries()
+	errPrimaries := doParallely(len(primaries), func(i int) error {
+		return primaries[i].Close()
+	})
+	replicas := db.allReplicas()
+	errReplicas := doParallely(len(replicas), func(i int) error {
+		return replicas[i].Close()
 	})
-	errReplicas := doParallely(len(db.replicas), func(i int) error {
-		return db.replicas[i].Close()
+	classReplicas := db.resourceClassReplicaDBs()
+	errClassReplicas := doParallely(len(classReplicas), func(i int) error {
+		return classReplicas[i].Close()
 	})
+	for _, closed := range slices.Concat(primaries, replicas, classReplicas) {
+		PurgeChecker(closed)
+	}
 
 	// Combine all errors
 	if errPrimaries != nil {
@@ -79,6 +226,9 @@ func (db *DB) Close() error {
 	if errReplicas != nil {
 		errors = append(errors, errReplicas)
 	}
+	if errClassReplicas != nil {
+		errors = append(errors, errClassReplicas)
+	}
 
 	if len(errors) > 0 {
 		return multierr.Combine(errors...)
@@ -101,21 +251,67 @@ func (db *DB) Begin() (Tx, error) {
 // The provided TxOptions is optional and may be nil if defaults should be used.
 // If a non-default isolation level is used that the driver doesn't support,
 // an error will be returned.
+// Read-only transactions are routed like reads, to a replica which caught up to the LSN required by ctx or to
+// the primary, and count as a single read in the metrics.
+// In read-only mode, only read-only transactions are started, on a node serving reads.
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
 	sourceDB := db.ReadWrite()
+	switch {
+	case db.readOnly.Load():
+		if opts == nil || !opts.ReadOnly {
+			return nil, ErrReadOnlyMode
+		}
+		sourceDB = db.readOnlyModeDB(ctx, QueryTypeRead)
+	case opts != nil && opts.ReadOnly:
+		var err error
+		if sourceDB, err = db.routeDB(ctx, QueryTypeRead); err != nil {
+			return nil, err
+		}
+		if err := db.recordRead(ctx, sourceDB); err != nil {
+			return nil, err
+		}
+	}
+
+	if db.txCommitLSN && (opts == nil || !opts.ReadOnly) {
+		return db.beginCommitLSNTx(ctx, sourceDB, opts)
+	}
 
 	stx, err := sourceDB.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
+	if err := db.applyTxSearchPath(ctx, sourceDB, stx); err != nil {
+		_ = stx.Rollback()
+		return nil, err
+	}
 
 	return &tx{
+		db:               db,
 		sourceDB:         sourceDB,
 		tx:               stx,
 		queryTypeChecker: db.queryTypeChecker,
+		handle:           db.trackHandle(HandleTx, sourceDB),
+		ctx:              ctx,
+		readOnly:         opts != nil && opts.ReadOnly,
 	}, nil
 }
 
+// BeginReplicaTx starts a read-only REPEATABLE READ transaction for consistent multi-query reads, whose queries
+// all observe the same snapshot of the replica selected like a read: one which caught up to the LSN required by
+// ctx, else the primary, or ErrStaleReadRefused without FallbackToMaster. The isolation level of opts, if any,
+// overrides REPEATABLE READ; standbys don't support SERIALIZABLE. Like the other read-only transactions, its
+// statements, e.g. SET LOCAL, aren't tracked as writes: committing it captures no LSN.
+func (db *DB) BeginReplicaTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	replicaOpts := sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	if opts != nil && opts.Isolation != sql.LevelDefault {
+		replicaOpts.Isolation = opts.Isolation
+	}
+	return db.BeginTx(ctx, &replicaOpts)
+}
+
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 // Exec uses the RW-database as the underlying db connection
@@ -128,8 +324,26 @@ func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 // Exec uses the RW-database as the underlying db connection
 // Optimized version: Uses single responsibility function for LSN tracking
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	curDB := db.DbSelector(ctx, db.queryTypeChecker.Check(query))
-	result, err := curDB.ExecContext(ctx, query, args...)
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	queryType := db.queryTypeChecker.Check(query)
+	if db.forwarder != nil && queryType == QueryTypeWrite && !db.readOnly.Load() {
+		return db.forwardExec(ctx, query, args...)
+	}
+	curDB, err := db.selectDB(ctx, queryType, query)
+	if err != nil {
+		return nil, err
+	}
+	var result sql.Result
+	err = db.readOn(ctx, curDB, func(target querier) (err error) {
+		result, err = target.ExecContext(ctx, query, args...)
+		return err
+	})
+	db.observeReplica(curDB, err)
+	if db.dualWrite != nil && containsDB(db.allPrimaries(), curDB) {
+		db.replayDualWrite(ctx, result, err, query, args...)
+	}
 
 	return result, err
 }
@@ -142,14 +356,34 @@ func (db *DB) Ping() error {
 
 // PingContext verifies if a connection to each physical database is still
 // alive, establishing a connection if necessary.
+// With WithPartialFailureTolerance, only the primaries failing fail the ping.
 func (db *DB) PingContext(ctx context.Context) error {
-	errPrimaries := doParallely(len(db.primaries), func(i int) error {
-		return db.primaries[i].PingContext(ctx)
+	if err := db.checkOpen(); err != nil {
+		return err
+	}
+	primaries := db.allPrimaries()
+	errPrimaries := doParallely(len(primaries), func(i int) error {
+		return primaries[i].PingContext(ctx)
 	})
-	errReplicas := doParallely(len(db.replicas), func(i int) error {
-		return db.replicas[i].PingContext(ctx)
+	if db.partialFailures != nil {
+		replicas := append(append([]*sql.DB(nil), db.allReplicas()...), db.resourceClassReplicaDBs()...)
+		errs := make([]error, len(replicas))
+		_ = doParallely(len(replicas), func(i int) error {
+			errs[i] = replicas[i].PingContext(ctx)
+			return nil
+		})
+		db.tolerateReplicaFailures(PartialFailurePing, replicas, errs)
+		return errPrimaries
+	}
+	replicas := db.allReplicas()
+	errReplicas := doParallely(len(replicas), func(i int) error {
+		return replicas[i].PingContext(ctx)
+	})
+	classReplicas := db.resourceClassReplicaDBs()
+	errClassReplicas := doParallely(len(classReplicas), func(i int) error {
+		return classReplicas[i].PingContext(ctx)
 	})
-	return multierr.Combine(errPrimaries, errReplicas)
+	return multierr.Combine(errPrimaries, errReplicas, errClassReplicas)
 }
 
 // Prepare creates a prepared statement for later queries or executions
@@ -163,29 +397,46 @@ func (db *DB) Prepare(query string) (_stmt Stmt, err error) {
 //
 // The provided context is used for the preparation of the statement, not for
 // the execution of the statement.
+// With WithPartialFailureTolerance, the replicas failing to prepare it are served by the primary.
 func (db *DB) PrepareContext(ctx context.Context, query string) (_stmt Stmt, err error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	// statements prepared meanwhile would miss a replica being added or removed
+	db.membershipMu.RLock()
+	defer db.membershipMu.RUnlock()
+
+	primaries, replicas := db.allPrimaries(), db.allReplicas()
 	dbStmt := map[*sql.DB]*sql.Stmt{}
 	var dbStmtLock sync.Mutex
-	roStmts := make([]*sql.Stmt, len(db.replicas))
-	primaryStmts := make([]*sql.Stmt, len(db.primaries))
-	errPrimaries := doParallely(len(db.primaries), func(i int) (err error) {
-		primaryStmts[i], err = db.primaries[i].PrepareContext(ctx, query)
+	roStmts := make([]*sql.Stmt, len(replicas))
+	replicaErrs := make([]error, len(replicas))
+	primaryStmts := make([]*sql.Stmt, len(primaries))
+	errPrimaries := doParallely(len(primaries), func(i int) (err error) {
+		primaryStmts[i], err = primaries[i].PrepareContext(ctx, query)
+		if err != nil {
+			db.metrics.prepareFailed(primaries[i])
+		}
 		dbStmtLock.Lock()
-		dbStmt[db.primaries[i]] = primaryStmts[i]
+		dbStmt[primaries[i]] = primaryStmts[i]
 		dbStmtLock.Unlock()
 		return
 	})
 
-	errReplicas := doParallely(len(db.replicas), func(i int) (err error) {
-		roStmts[i], err = db.replicas[i].PrepareContext(ctx, query)
+	errReplicas := doParallely(len(replicas), func(i int) (err error) {
+		roStmts[i], err = replicas[i].PrepareContext(ctx, query)
+		if err != nil {
+			db.metrics.prepareFailed(replicas[i])
+		}
 		dbStmtLock.Lock()
-		dbStmt[db.replicas[i]] = roStmts[i]
+		dbStmt[replicas[i]] = roStmts[i]
 		dbStmtLock.Unlock()
 
 		// if connection error happens on RO connection,
 		// ignore and fallback to RW connection
-		if isDBConnectionError(err) {
+		if isConnectionError(db.classifier, err) || (err != nil && db.partialFailures != nil) {
 			roStmts[i] = primaryStmts[0]
+			replicaErrs[i] = err
 			return nil
 		}
 		return err
@@ -195,17 +446,25 @@ func (db *DB) PrepareContext(ctx context.Context, query string) (_stmt Stmt, err
 	if err != nil {
 		return //nolint: nakedret
 	}
+	if db.partialFailures != nil {
+		db.tolerateReplicaFailures(PartialFailurePrepare, replicas, replicaErrs)
+	}
 
 	writeFlag := db.queryTypeChecker.Check(query)
 
-	_stmt = &stmt{
+	s := &stmt{
+		classifier:   db.classifier,
 		loadBalancer: db.stmtLoadBalancer,
 		primaryStmts: primaryStmts,
 		replicaStmts: roStmts,
+		replicaDBs:   slices.Clone(replicas), // updated in place, not shared with the resolver
 		dbStmt:       dbStmt,
 		writeFlag:    writeFlag == QueryTypeWrite,
+		query:        query,
+		resolver:     db,
 	}
-	return _stmt, nil
+	db.stmts.Store(s, struct{}{})
+	return s, nil
 }
 
 // Query executes a query that returns rows, typically a SELECT.
@@ -217,11 +476,27 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 // QueryContext executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
 	queryType := db.queryTypeChecker.Check(query)
-	curDB := db.DbSelector(ctx, queryType)
-
-	rows, err = curDB.QueryContext(ctx, query, args...)
+	curDB, err := db.selectDB(ctx, queryType, query)
+	if err != nil {
+		return nil, err
+	}
 
+	if queryType == QueryTypeWrite {
+		err = db.readOn(ctx, curDB, func(target querier) (err error) {
+			rows, err = target.QueryContext(ctx, query, args...)
+			return err
+		})
+		db.observeReplica(curDB, err)
+		return
+	}
+	err = db.runRead(ctx, query, curDB, func(target querier) (err error) {
+		rows, err = target.QueryContext(ctx, query, args...)
+		return err
+	})
 	return
 }
 
@@ -236,11 +511,34 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 // QueryRowContext always return a non-nil value.
 // Errors are deferred until Row's Scan method is called.
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if db.closed.Load() {
+		return closedRow(ctx)
+	}
 	queryType := db.queryTypeChecker.Check(query)
-	curDB := db.DbSelector(ctx, queryType)
-
-	row := curDB.QueryRowContext(ctx, query, args...)
+	curDB, err := db.selectDB(ctx, queryType, query)
+	if err != nil {
+		return errorRow(ctx, db.ReadWrite(), err)
+	}
 
+	var row *sql.Row
+	if queryType == QueryTypeWrite {
+		err = db.readOn(ctx, curDB, func(target querier) error {
+			row = target.QueryRowContext(ctx, query, args...)
+			return row.Err()
+		})
+		db.observeReplica(curDB, err)
+		if err != nil && row == nil {
+			return errorRow(ctx, curDB, err)
+		}
+		return row
+	}
+	err = db.runRead(ctx, query, curDB, func(target querier) error {
+		row = target.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	if err != nil && row.Err() != err {
+		return errorRow(ctx, db.ReadWrite(), err)
+	}
 	return row
 }
 
@@ -250,12 +548,12 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interfa
 // new MaxIdleConns will be reduced to match the MaxOpenConns limit
 // If n <= 0, no idle connections are retained.
 func (db *DB) SetMaxIdleConns(n int) {
-	for i := range db.primaries {
-		db.primaries[i].SetMaxIdleConns(n)
+	for _, primary := range db.allPrimaries() {
+		primary.SetMaxIdleConns(n)
 	}
 
-	for i := range db.replicas {
-		db.replicas[i].SetMaxIdleConns(n)
+	for _, replica := range db.allReplicas() {
+		replica.SetMaxIdleConns(n)
 	}
 }
 
@@ -266,11 +564,11 @@ func (db *DB) SetMaxIdleConns(n int) {
 // the new MaxOpenConns limit. If n <= 0, then there is no limit on the number
 // of open connections. The default is 0 (unlimited).
 func (db *DB) SetMaxOpenConns(n int) {
-	for i := range db.primaries {
-		db.primaries[i].SetMaxOpenConns(n)
+	for _, primary := range db.allPrimaries() {
+		primary.SetMaxOpenConns(n)
 	}
-	for i := range db.replicas {
-		db.replicas[i].SetMaxOpenConns(n)
+	for _, replica := range db.allReplicas() {
+		replica.SetMaxOpenConns(n)
 	}
 }
 
@@ -278,11 +576,11 @@ func (db *DB) SetMaxOpenConns(n int) {
 // Expired connections may be closed lazily before reuse.
 // If d <= 0, connections are reused forever.
 func (db *DB) SetConnMaxLifetime(d time.Duration) {
-	for i := range db.primaries {
-		db.primaries[i].SetConnMaxLifetime(d)
+	for _, primary := range db.allPrimaries() {
+		primary.SetConnMaxLifetime(d)
 	}
-	for i := range db.replicas {
-		db.replicas[i].SetConnMaxLifetime(d)
+	for _, replica := range db.allReplicas() {
+		replica.SetConnMaxLifetime(d)
 	}
 }
 
@@ -290,29 +588,81 @@ func (db *DB) SetConnMaxLifetime(d time.Duration) {
 // Expired connections may be closed lazily before reuse.
 // If d <= 0, connections are not closed due to a connection's idle time.
 func (db *DB) SetConnMaxIdleTime(d time.Duration) {
-	for i := range db.primaries {
-		db.primaries[i].SetConnMaxIdleTime(d)
+	for _, primary := range db.allPrimaries() {
+		primary.SetConnMaxIdleTime(d)
+	}
+
+	for _, replica := range db.allReplicas() {
+		replica.SetConnMaxIdleTime(d)
+	}
+}
+
+// UpdateLSNAfterWrite captures the LSN of the writes of ctx with the query router, so the DB can be given to
+// NewHTTPMiddleware. It returns a zero LSN without query router.
+func (db *DB) UpdateLSNAfterWrite(ctx context.Context) (LSN, error) {
+	if db.queryRouter == nil {
+		return LSN{}, nil
 	}
+	return db.queryRouter.UpdateLSNAfterWrite(ctx)
+}
 
-	for i := range db.replicas {
-		db.replicas[i].SetConnMaxIdleTime(d)
+// ConsistencyConfig returns the configuration of the causal router, false when causal consistency isn't configured
+func (db *DB) ConsistencyConfig() (CausalConsistencyConfig, bool) {
+	if router, ok := db.queryRouter.(*CausalRouter); ok {
+		return router.ConsistencyConfig()
 	}
+	return CausalConsistencyConfig{}, false
 }
 
-// DbSelector returns a readonly database considering query router requirements
+// DbSelector returns a readonly database considering query router requirements.
+// Reads refused with ErrStaleReadRefused are served by the primary rather than a stale replica, as DbSelector
+// can't report the refusal; SelectDB returns it.
 func (db *DB) DbSelector(ctx context.Context, queryType QueryType) *sql.DB {
+	selectedDB, err := db.dbSelector(ctx, queryType)
+	if err != nil {
+		selectedDB = db.ReadWrite()
+		db.recordDecision(ctx, queryType, selectedDB, ReasonReplicaLagging)
+		db.activity.touch(selectedDB)
+	}
+	return selectedDB
+}
+
+// SelectDB returns the database of a query of queryType like DbSelector, or the *StaleReadRefused error of a read
+// no replica can serve without FallbackToMaster.
+func (db *DB) SelectDB(ctx context.Context, queryType QueryType) (*sql.DB, error) {
+	return db.dbSelector(ctx, queryType)
+}
+
+// dbSelector selects the database for DbSelector, returning the ErrStaleReadRefused errors of the query router
+func (db *DB) dbSelector(ctx context.Context, queryType QueryType) (*sql.DB, error) {
 	// Use query router for routing
 	if db.queryRouter != nil {
 		selectedDB, err := db.queryRouter.RouteQuery(ctx, queryType)
+		if errors.Is(err, ErrStaleReadRefused) {
+			db.log().Debug("read refused, no replica has caught up to its required LSN", "error", err)
+			return nil, err
+		}
 		if err != nil {
 			// Fallback to standard routing if routing fails
-			return db.readWithoutLSN(queryType)
+			selectedDB = db.readWithoutLSN(queryType)
+			db.recordDecision(ctx, queryType, selectedDB, ReasonRouterError)
+			return selectedDB, nil
+		}
+		if _, ok := db.queryRouter.(*CausalRouter); !ok {
+			// the causal router records its decisions through the routing hooks
+			db.recordDecision(ctx, queryType, selectedDB, "")
 		}
 
-		return selectedDB
+		db.activity.touch(selectedDB)
+		return selectedDB, nil
 	}
 
-	return db.readWithoutLSN(queryType)
+	selectedDB := db.readWithoutLSN(queryType)
+	if db.decisions != nil {
+		db.recordDecision(ctx, queryType, selectedDB, db.withoutLSNReason(queryType))
+	}
+	db.activity.touch(selectedDB)
+	return selectedDB, nil
 }
 
 func (db *DB) readWithoutLSN(queryType QueryType) *sql.DB {
@@ -322,35 +672,92 @@ func (db *DB) readWithoutLSN(queryType QueryType) *sql.DB {
 	return db.ReadOnly()
 }
 
+// withoutLSNReason returns the reason of the routing decisions of readWithoutLSN
+func (db *DB) withoutLSNReason(queryType QueryType) string {
+	switch {
+	case queryType == QueryTypeWrite:
+		return ReasonWrite
+	case len(db.ReplicaDBs()) == 0:
+		return ReasonNoReplicas
+	default:
+		return ReasonNoLSNRequirement
+	}
+}
+
 // ReadOnly returns the readonly database
 func (db *DB) ReadOnly() *sql.DB {
-	if len(db.replicas) == 0 {
-		return db.loadBalancer.Resolve(db.primaries)
+	replicas := db.ReplicaDBs()
+	if len(replicas) == 0 {
+		return db.loadBalancer.Resolve(db.allPrimaries())
 	}
-	return db.loadBalancer.Resolve(db.replicas)
+	return db.loadBalancer.Resolve(replicas)
 }
 
 // ReadWrite returns the primary database
 func (db *DB) ReadWrite() *sql.DB {
-	return db.loadBalancer.Resolve(db.primaries)
+	return db.loadBalancer.Resolve(db.allPrimaries())
 }
 
 // Conn returns a single connection by either opening a new connection or returning an existing connection from the
-// connection pool of the first primary db.
+// connection pool of the first primary db, or of a node serving reads in read-only mode.
 func (db *DB) Conn(ctx context.Context) (Conn, error) {
-	c, err := db.primaries[0].Conn(ctx)
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	primary := db.allPrimaries()[0]
+	if db.readOnly.Load() {
+		primary = db.readOnlyModeDB(ctx, QueryTypeRead)
+	}
+	c, err := primary.Conn(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if err := db.applySearchPath(ctx, primary, c); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
 
 	return &conn{
-		sourceDB:         db.primaries[0],
+		db:               db,
+		sourceDB:         primary,
 		conn:             c,
 		queryTypeChecker: db.queryTypeChecker,
+		handle:           db.trackHandle(HandleConn, primary),
 	}, nil
 }
 
+// goBackground starts a background worker which must return once stop is closed.
+// All background workers are stopped and waited for when the DB is closed. It reports whether the worker started.
+func (db *DB) goBackground(fn func(stop <-chan struct{})) bool {
+	db.bgMu.Lock()
+	defer db.bgMu.Unlock()
+	select {
+	case <-db.stopCh:
+		return false // stopped, e.g. a diagnosis of a query completing after Close
+	default:
+	}
+	db.wg.Add(1)
+	go func() {
+		defer db.wg.Done()
+		fn(db.stopCh)
+	}()
+	return true
+}
+
+// stopBackground signals all background workers to stop and waits for them to exit
+func (db *DB) stopBackground() {
+	if db.stopCh == nil {
+		return
+	}
+	db.bgMu.Lock()
+	db.stopOnce.Do(func() {
+		close(db.stopCh)
+	})
+	db.bgMu.Unlock()
+	db.wg.Wait()
+}
+
 // Stats returns database statistics for the first primary db
 func (db *DB) Stats() sql.DBStats {
-	return db.primaries[0].Stats()
+	return db.allPrimaries()[0].Stats()
 }
@@ -0,0 +1,120 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DecisionRecord is a routing decision kept by the decision log
+type DecisionRecord struct {
+	Time        time.Time `json:"time"`
+	QueryType   string    `json:"query_type"` // "read", "write" or "unknown"
+	Target      string    `json:"target"`     // Name of the selected database, see WithNamedReplicaDBs
+	Reason      string    `json:"reason"`     // See the Reason constants; empty when decided by a custom QueryRouter
+	RequiredLSN string    `json:"required_lsn,omitempty"`
+}
+
+// WithDecisionLog keeps the last size routing decisions in memory, so an incident can be reconstructed
+// after the fact without verbose logging. Decisions are dumped by DB.RoutingDecisions and
+// NewDecisionLogHandler. Every query records its decision, including the retries of the retry policy.
+func WithDecisionLog(size int) OptionFunc {
+	return func(opt *Option) {
+		if size > 0 {
+			opt.DecisionLog = &decisionLog{records: make([]DecisionRecord, size)}
+		}
+	}
+}
+
+// decisionLog is a ring buffer of routing decisions. Methods are nil-receiver safe.
+type decisionLog struct {
+	mu      sync.Mutex
+	records []DecisionRecord
+	next    int
+	full    bool
+}
+
+func (l *decisionLog) record(decision RouteDecision) {
+	if l == nil {
+		return
+	}
+	record := DecisionRecord{
+		Time:      time.Now(),
+		QueryType: queryTypeName(decision.QueryType),
+		Target:    decision.Target,
+		Reason:    decision.Reason,
+	}
+	if !decision.RequiredLSN.IsZero() {
+		record.RequiredLSN = decision.RequiredLSN.String()
+	}
+
+	l.mu.Lock()
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	l.full = l.full || l.next == 0
+	l.mu.Unlock()
+}
+
+// snapshot returns the records made at or after since, oldest first
+func (l *decisionLog) snapshot(since time.Time) []DecisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ordered := l.records[:l.next]
+	if l.full {
+		ordered = append(append([]DecisionRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+	}
+	records := make([]DecisionRecord, 0, len(ordered))
+	for _, record := range ordered {
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// recordDecision records a routing decision made outside of the routing hooks
+func (db *DB) recordDecision(ctx context.Context, queryType QueryType, curDB *sql.DB, reason string) {
+	if db.decisions == nil {
+		return
+	}
+	decision := RouteDecision{QueryType: queryType, DB: curDB, Target: physicalDBName(db, curDB), Reason: reason}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		decision.RequiredLSN = lsnCtx.RequiredLSN
+	}
+	db.decisions.record(decision)
+}
+
+// RoutingDecisions returns the routing decisions kept by the decision log, oldest first.
+// It returns nil when the decision log isn't enabled with WithDecisionLog.
+func (db *DB) RoutingDecisions() []DecisionRecord {
+	if db.decisions == nil {
+		return nil
+	}
+	return db.decisions.snapshot(time.Time{})
+}
+
+// NewDecisionLogHandler returns a debug handler dumping the decision log of db as JSON, oldest first.
+// The since query parameter, an RFC 3339 time, only dumps the decisions made from that time on.
+func NewDecisionLogHandler(db *DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if db.decisions == nil {
+			http.Error(w, "decision log is not enabled", http.StatusNotFound)
+			return
+		}
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(db.decisions.snapshot(since))
+	})
+}
@@ -0,0 +1,143 @@
+package dbresolver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDecisionLogKeepsLastDecisions(t *testing.T) {
+	l := &decisionLog{records: make([]DecisionRecord, 2)}
+	for _, reason := range []string{ReasonWrite, ReasonNoReplicas, ReasonNoLSNRequirement} {
+		l.record(RouteDecision{QueryType: QueryTypeRead, Target: "replica-0", Reason: reason})
+	}
+
+	records := l.snapshot(time.Time{})
+	if len(records) != 2 || records[0].Reason != ReasonNoReplicas || records[1].Reason != ReasonNoLSNRequirement {
+		t.Fatalf("want the last 2 decisions oldest first, got %+v", records)
+	}
+	if records[0].QueryType != "read" || records[0].Time.IsZero() || records[0].RequiredLSN != "" {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if records := l.snapshot(time.Now().Add(time.Minute)); len(records) != 0 {
+		t.Errorf("want no decision after since, got %+v", records)
+	}
+
+	var disabled *decisionLog
+	disabled.record(RouteDecision{})
+}
+
+func TestDecisionLogRecordsRouting(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyLevel(ReadYourWrites),
+		WithDecisionLog(10),
+	)
+
+	primaryMock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(0, 1))
+	replicaMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	expectReplayLSN(replicaMock, "0/3000060")
+	primaryMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	if _, err := db.ExecContext(context.Background(), "INSERT INTO users (name) VALUES ('alice')"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(context.Background(), "SELECT name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	lsn, _ := ParseLSN("0/3000100")
+	ctx := WithLSNContext(context.Background(), &LSNContext{Level: ReadYourWrites, RequiredLSN: lsn})
+	if rows, err = db.QueryContext(ctx, "SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	records := db.RoutingDecisions()
+	want := []DecisionRecord{
+		{QueryType: "write", Target: "primary-0", Reason: ReasonWrite},
+		{QueryType: "unknown", Target: "replica-0", Reason: ReasonNoLSNRequirement},
+		{QueryType: "unknown", Target: "primary-0", Reason: ReasonReplicaLagging, RequiredLSN: "0/3000100"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("want %d decisions, got %+v", len(want), records)
+	}
+	for i, record := range records {
+		record.Time = time.Time{}
+		if record != want[i] {
+			t.Errorf("decision %d: want %+v, got %+v", i, want[i], record)
+		}
+	}
+}
+
+func TestDecisionLogWithoutCausalRouter(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+
+	var routed int
+	db := New(
+		WithPrimaryDBs(primary),
+		WithRoutingHooks(func(RouteDecision) { routed++ }, nil, nil),
+		WithDecisionLog(10),
+	)
+
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	records := db.RoutingDecisions()
+	if len(records) != 1 || records[0].Target != "primary-0" || records[0].Reason != ReasonNoReplicas {
+		t.Errorf("want the read routed to the primary without replicas, got %+v", records)
+	}
+	if routed != 0 {
+		t.Errorf("onRoute should only be called for the causal router decisions, got %d calls", routed)
+	}
+}
+
+func TestDecisionLogHandler(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithDecisionLog(10))
+	db.decisions.record(RouteDecision{QueryType: QueryTypeWrite, Target: "primary-0", Reason: ReasonWrite})
+
+	rec := httptest.NewRecorder()
+	NewDecisionLogHandler(db).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/decisions", nil))
+	var records []DecisionRecord
+	if err := json.NewDecoder(rec.Body).Decode(&records); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(records) != 1 || records[0].Reason != ReasonWrite {
+		t.Errorf("want the recorded decision, got %d %+v", rec.Code, records)
+	}
+
+	since := url.QueryEscape(time.Now().Add(time.Minute).Format(time.RFC3339))
+	rec = httptest.NewRecorder()
+	NewDecisionLogHandler(db).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/decisions?since="+since, nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || body != "[]\n" {
+		t.Errorf("want no decision after since, got %d %q", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	NewDecisionLogHandler(db).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/decisions?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("want bad request for an invalid since, got %d", rec.Code)
+	}
+
+	disabled := New(WithPrimaryDBs(primary))
+	rec = httptest.NewRecorder()
+	NewDecisionLogHandler(disabled).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/decisions", nil))
+	if rec.Code != http.StatusNotFound || disabled.RoutingDecisions() != nil {
+		t.Errorf("want not found without decision log, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// replicaDelay keeps the replicas with deliberately delayed replication, e.g. with recovery_min_apply_delay
+// for point-in-time protection: they're still monitored but never serve reads. The zero value is ready to use.
+type replicaDelay struct {
+	mu      sync.RWMutex
+	delayed map[*sql.DB]delayedReplica
+}
+
+// delayedReplica is the delay of a replica excluded from routing
+type delayedReplica struct {
+	applyDelay time.Duration // recovery_min_apply_delay, zero until detected
+	detected   bool          // flagged by WithDelayedReplicaDetection rather than configured
+}
+
+func (d *replicaDelay) set(db *sql.DB, replica delayedReplica) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.delayed == nil {
+		d.delayed = make(map[*sql.DB]delayedReplica)
+	}
+	d.delayed[db] = replica
+}
+
+func (d *replicaDelay) clear(db *sql.DB) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.delayed, db)
+}
+
+func (d *replicaDelay) get(db *sql.DB) (delayedReplica, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	replica, ok := d.delayed[db]
+	return replica, ok
+}
+
+// available returns the replicas that aren't delayed
+func (d *replicaDelay) available(replicas []*sql.DB) []*sql.DB {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.delayed) == 0 {
+		return replicas
+	}
+	serving := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if _, delayed := d.delayed[replica]; !delayed {
+			serving = append(serving, replica)
+		}
+	}
+	return serving
+}
+
+// WithDelayedReplicas adds replicas with deliberately delayed replication to the resolver: they're health
+// checked, role verified, pinged and closed like the other replicas, and reported by GetReplicaStatus and
+// Metrics, but never serve reads or prepared statements.
+func WithDelayedReplicas(replicaDBs ...*sql.DB) OptionFunc {
+	return func(opt *Option) {
+		opt.DelayedReplicaDBs = replicaDBs
+	}
+}
+
+// WithDelayedReplicaDetection makes the health checks read the recovery_min_apply_delay of every replica, and
+// exclude the replicas where it is set from routing, as if configured with WithDelayedReplicas, until it is
+// reset. The setting is used rather than the lag of the replica, which is also large on a regular replica
+// of an idle primary. Transitions are sent to the health callback as HealthEventReplicaDelayed and
+// HealthEventReplicaDelayCleared. Requires WithHealthCheck.
+func WithDelayedReplicaDetection() OptionFunc {
+	return func(opt *Option) {
+		opt.DetectDelayedReplicas = true
+	}
+}
+
+// SetReplicaDelayed flags a replica as delayed at runtime, excluding it from routing like WithDelayedReplicas,
+// or puts it back into rotation
+func (db *DB) SetReplicaDelayed(replica *sql.DB, delayed bool) error {
+	if !db.isReplica(replica) {
+		return fmt.Errorf("database is not a replica of the resolver")
+	}
+	if !delayed {
+		db.delay.clear(replica)
+		db.log().Debug("replica pool: replica no longer delayed", "db", physicalDBName(db, replica))
+		return nil
+	}
+	current, _ := db.delay.get(replica)
+	db.delay.set(replica, delayedReplica{applyDelay: current.applyDelay})
+	db.log().Debug("replica pool: replica delayed", "db", physicalDBName(db, replica))
+	return nil
+}
+
+// applyDelay reads the recovery_min_apply_delay of a replica
+func (db *DB) applyDelay(replica *sql.DB) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), db.health.timeout)
+	defer cancel()
+
+	var delayMillis int64
+	err := replica.QueryRowContext(ctx,
+		"SELECT setting::bigint FROM pg_settings WHERE name = 'recovery_min_apply_delay'").Scan(&delayMillis)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read recovery_min_apply_delay: %w", err)
+	}
+	return time.Duration(delayMillis) * time.Millisecond, nil
+}
+
+// detectDelay flags a replica whose recovery_min_apply_delay is set as delayed, and clears the flag it set
+// once the setting is reset. The replicas flagged explicitly keep their flag.
+func (db *DB) detectDelay(replica *sql.DB) {
+	delay, err := db.applyDelay(replica)
+	if err != nil {
+		db.log().Debug("health check: failed to detect replication delay", "db", physicalDBName(db, replica), "error", err)
+		return
+	}
+	current, delayed := db.delay.get(replica)
+	name := physicalDBName(db, replica)
+	switch {
+	case delay > 0 && !delayed:
+		db.delay.set(replica, delayedReplica{applyDelay: delay, detected: true})
+		db.log().Warn("health check: delayed replica excluded from routing", "db", name, "applyDelay", delay)
+		db.health.notify(HealthEvent{Type: HealthEventReplicaDelayed, DB: name})
+	case delay > 0:
+		current.applyDelay = delay
+		db.delay.set(replica, current)
+	case delayed && current.detected:
+		db.delay.clear(replica)
+		db.health.notify(HealthEvent{Type: HealthEventReplicaDelayCleared, DB: name})
+	}
+}
@@ -0,0 +1,96 @@
+package dbresolver
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectApplyDelay(mock sqlmock.Sqlmock, millis int64) {
+	mock.ExpectQuery("recovery_min_apply_delay").
+		WillReturnRows(sqlmock.NewRows([]string{"setting"}).AddRow(millis))
+}
+
+func TestDelayedReplicasNeverServeReads(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	delayed, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithDelayedReplicas(delayed))
+
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != replica {
+		t.Fatalf("expected only the regular replica to serve reads, got %d replicas", len(replicas))
+	}
+	if !containsDB(db.allReplicas(), delayed) {
+		t.Error("expected the delayed replica to be part of the resolver")
+	}
+	if m := db.Metrics(); len(m.Replicas) != 2 || m.Replicas[0].Delayed || !m.Replicas[1].Delayed {
+		t.Errorf("expected the delayed replica flagged in the metrics, got %+v", m.Replicas)
+	}
+
+	if err := db.SetReplicaDelayed(delayed, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.ReplicaDBs()) != 2 {
+		t.Error("expected the replica back into rotation")
+	}
+	if err := db.SetReplicaDelayed(replica, true); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != delayed {
+		t.Error("expected the replica flagged at runtime excluded from routing")
+	}
+	if err := db.SetReplicaDelayed(primary, true); err == nil {
+		t.Error("expected an error for a database that isn't a replica")
+	}
+}
+
+func TestDelayedReplicaDetection(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t, WithDelayedReplicaDetection())
+	replica := db.allReplicas()[0]
+
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/1000000")
+	expectApplyDelay(replicaMock, 3600000)
+	db.checkHealth()
+
+	if len(db.ReplicaDBs()) != 0 {
+		t.Error("expected the detected delayed replica excluded from routing")
+	}
+	status := db.GetReplicaStatus()[0]
+	if !status.Delayed || status.ApplyDelay.Hours() != 1 || !status.IsHealthy {
+		t.Errorf("expected a healthy replica delayed by 1h, got %+v", status)
+	}
+	if len(*events) != 1 || (*events)[0].Type != HealthEventReplicaDelayed {
+		t.Fatalf("expected a delayed event, got %+v", *events)
+	}
+
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+	expectApplyDelay(replicaMock, 0)
+	db.checkHealth()
+
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != replica {
+		t.Error("expected the replica back into rotation once the delay is reset")
+	}
+	if len(*events) != 2 || (*events)[1].Type != HealthEventReplicaDelayCleared {
+		t.Errorf("expected a delay cleared event, got %+v", *events)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDelayedReplicaDetectionKeepsConfiguredFlag(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t, WithDelayedReplicaDetection())
+	replica := db.allReplicas()[0]
+	db.delay.set(replica, delayedReplica{})
+
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+	expectApplyDelay(replicaMock, 0)
+	db.checkHealth()
+
+	if _, delayed := db.delay.get(replica); !delayed || len(*events) != 0 {
+		t.Errorf("expected the configured delayed replica to stay excluded, got events %+v", *events)
+	}
+}
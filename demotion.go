@@ -0,0 +1,173 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errorRatioBuckets is the number of buckets of the sliding window of an error ratio
+const errorRatioBuckets = 10
+
+// WithErrorRatioDemotion demotes replicas whose queries fail too often, even if they accept connections,
+// e.g. because of recovery conflicts or statement timeouts. Once a replica served at least minQueries
+// queries over the sliding window, it's removed from the read pool for a window when the ratio of failed
+// queries exceeds threshold, from 0 to 1, then re-admitted with a fresh window. Queries canceled by their
+// context aren't counted. Demotions are reported to the health callback, see WithHealthCallback.
+func WithErrorRatioDemotion(window time.Duration, threshold float64, minQueries int) OptionFunc {
+	return func(opt *Option) {
+		opt.ErrorRatioDemotion = &errorRatioConfig{window: window, threshold: threshold, minQueries: minQueries}
+	}
+}
+
+type errorRatioConfig struct {
+	window     time.Duration
+	threshold  float64
+	minQueries int
+}
+
+// errorRatioTracker demotes the replicas exceeding the error ratio. Methods are nil-receiver safe.
+type errorRatioTracker struct {
+	config  errorRatioConfig
+	onEvent func(HealthEvent)
+	name    func(*sql.DB) string
+	// called when a replica is demoted, with the error of the query exceeding the ratio
+	onDemote func(db *sql.DB, demotedAt time.Time, err error)
+
+	mu       sync.Mutex
+	replicas map[*sql.DB]*errorWindow
+	demoted  int
+}
+
+// errorWindow counts the outcome of the queries of a replica over the sliding window
+type errorWindow struct {
+	buckets      [errorRatioBuckets]errorBucket
+	demotedUntil time.Time
+}
+
+type errorBucket struct {
+	epoch     int64 // index of the bucket duration the counts belong to
+	succeeded int
+	failed    int
+}
+
+func newErrorRatioTracker(config errorRatioConfig, replicas []*sql.DB) *errorRatioTracker {
+	t := &errorRatioTracker{config: config, replicas: make(map[*sql.DB]*errorWindow, len(replicas))}
+	for _, replica := range replicas {
+		t.replicas[replica] = &errorWindow{}
+	}
+	return t
+}
+
+// observe records the outcome of a query on db, which is ignored if it isn't a replica
+func (t *errorRatioTracker) observe(db *sql.DB, err error) {
+	if t == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	w, ok := t.replicas[db]
+	if !ok || now.Before(w.demotedUntil) {
+		t.mu.Unlock()
+		return
+	}
+	epoch := now.UnixNano() / int64(t.bucketDuration())
+	bucket := &w.buckets[epoch%errorRatioBuckets]
+	if bucket.epoch != epoch {
+		*bucket = errorBucket{epoch: epoch}
+	}
+	if err != nil {
+		bucket.failed++
+	} else {
+		bucket.succeeded++
+	}
+	ratio, demote := t.shouldDemoteLocked(w, epoch)
+	if demote {
+		if w.demotedUntil.IsZero() {
+			t.demoted++
+		}
+		w.demotedUntil = now.Add(t.config.window)
+		w.buckets = [errorRatioBuckets]errorBucket{}
+	}
+	t.mu.Unlock()
+
+	if demote && t.onEvent != nil {
+		t.onEvent(HealthEvent{Type: HealthEventReplicaDemoted, DB: t.name(db), Err: err, ErrorRatio: ratio})
+	}
+	if demote && t.onDemote != nil {
+		t.onDemote(db, now, err)
+	}
+}
+
+// shouldDemoteLocked returns the error ratio of the window ending at epoch and whether it's exceeded
+func (t *errorRatioTracker) shouldDemoteLocked(w *errorWindow, epoch int64) (float64, bool) {
+	var succeeded, failed int
+	for _, bucket := range w.buckets {
+		if epoch-bucket.epoch < errorRatioBuckets {
+			succeeded += bucket.succeeded
+			failed += bucket.failed
+		}
+	}
+	total := succeeded + failed
+	if total == 0 || total < t.config.minQueries {
+		return 0, false
+	}
+	ratio := float64(failed) / float64(total)
+	return ratio, ratio > t.config.threshold
+}
+
+func (t *errorRatioTracker) bucketDuration() time.Duration {
+	return max(t.config.window/errorRatioBuckets, time.Millisecond)
+}
+
+// track starts tracking a replica added to the resolver
+func (t *errorRatioTracker) track(db *sql.DB) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.replicas[db]; !ok {
+		t.replicas[db] = &errorWindow{}
+	}
+}
+
+// forget stops tracking a replica removed from the resolver
+func (t *errorRatioTracker) forget(db *sql.DB) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if w, ok := t.replicas[db]; ok && !w.demotedUntil.IsZero() {
+		t.demoted--
+	}
+	delete(t.replicas, db)
+}
+
+// available returns the replicas that aren't demoted
+func (t *errorRatioTracker) available(replicas []*sql.DB) []*sql.DB {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.demoted == 0 {
+		return replicas
+	}
+	now := time.Now()
+	healthy := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if w, ok := t.replicas[replica]; ok && !w.demotedUntil.IsZero() {
+			if now.Before(w.demotedUntil) {
+				continue
+			}
+			// re-admitted with the fresh window started on demotion
+			w.demotedUntil = time.Time{}
+			t.demoted--
+		}
+		healthy = append(healthy, replica)
+	}
+	return healthy
+}
@@ -0,0 +1,69 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrorRatioDemotion(t *testing.T) {
+	primary, _ := newMockDB(t)
+	flaky, _ := newMockDB(t)
+	healthy, _ := newMockDB(t)
+
+	var events []HealthEvent
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(flaky, healthy),
+		WithErrorRatioDemotion(time.Minute, 0.5, 4),
+		WithHealthCallback(func(e HealthEvent) { events = append(events, e) }),
+	)
+	conflict := errors.New("canceling statement due to conflict with recovery")
+
+	db.observeReplica(flaky, nil)
+	db.observeReplica(flaky, conflict)
+	db.observeReplica(flaky, conflict)
+	if len(db.ReplicaDBs()) != 2 {
+		t.Fatal("replica should not be demoted before serving the minimum number of queries")
+	}
+	db.observeReplica(flaky, conflict)
+
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != healthy {
+		t.Fatalf("want the flaky replica demoted, got %v", replicas)
+	}
+	if len(events) != 1 || events[0].Type != HealthEventReplicaDemoted || events[0].DB != "replica-0" || events[0].ErrorRatio != 0.75 {
+		t.Errorf("want a demotion event for replica-0 at 0.75, got %+v", events)
+	}
+}
+
+func TestErrorRatioDemotionIgnoresCanceledQueries(t *testing.T) {
+	replica := &sql.DB{}
+	tracker := newErrorRatioTracker(errorRatioConfig{window: time.Minute, threshold: 0.1, minQueries: 1}, []*sql.DB{replica})
+
+	tracker.observe(replica, context.Canceled)
+	tracker.observe(&sql.DB{}, errors.New("not a replica"))
+	if tracker.demoted != 0 {
+		t.Error("canceled queries and other databases should not be counted")
+	}
+}
+
+func TestErrorRatioDemotionReadmits(t *testing.T) {
+	replica := &sql.DB{}
+	tracker := newErrorRatioTracker(errorRatioConfig{window: 20 * time.Millisecond, threshold: 0.5, minQueries: 1}, []*sql.DB{replica})
+
+	tracker.observe(replica, errors.New("statement timeout"))
+	if len(tracker.available([]*sql.DB{replica})) != 0 {
+		t.Fatal("want the replica demoted")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(tracker.available([]*sql.DB{replica})) != 1 {
+		t.Fatal("want the replica re-admitted after the window")
+	}
+
+	tracker.observe(replica, nil)
+	if tracker.demoted != 0 {
+		t.Error("re-admitted replica should start with a fresh window")
+	}
+}
@@ -0,0 +1,63 @@
+package dbresolver
+
+import (
+	"errors"
+	"go/build"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const modulePath = "github.com/alfari16/go-pgrouter"
+
+// coreDependencies are the only modules besides the standard library the packages of the root module may import.
+// Integrations requiring others live in nested modules with their own go.mod, such as redisstore.
+var coreDependencies = []string{modulePath, "go.uber.org/multierr"}
+
+func TestRootModuleDependencies(t *testing.T) {
+	err := filepath.WalkDir(".", func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if dir != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); dir != "." && err == nil {
+			return filepath.SkipDir // nested module
+		}
+
+		pkg, err := build.ImportDir(dir, 0)
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, path := range pkg.Imports {
+			if !allowedRootImport(path) {
+				t.Errorf("%s imports %s: move the integration to a nested module or allow it in coreDependencies",
+					dir, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// allowedRootImport reports whether path is in the standard library or the coreDependencies
+func allowedRootImport(path string) bool {
+	if first, _, _ := strings.Cut(path, "/"); !strings.Contains(first, ".") {
+		return true
+	}
+	for _, dependency := range coreDependencies {
+		if path == dependency || strings.HasPrefix(path, dependency+"/") {
+			return true
+		}
+	}
+	return false
+}
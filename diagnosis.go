@@ -0,0 +1,167 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// maxRecentReplicaErrors bounds the errors kept per replica for diagnosis reports
+const maxRecentReplicaErrors = 5
+
+const defaultDiagnosisTimeout = 5 * time.Second
+
+// ReplicaError is an error observed on a replica
+type ReplicaError struct {
+	At  time.Time
+	Err error
+}
+
+// EjectionCause identifies the mechanism that ejected a replica from the read pool
+type EjectionCause string
+
+const (
+	EjectedByOutage      EjectionCause = "outage"       // A query failed with a connection error, see WithReplicaOutagePolicy
+	EjectedByHealthCheck EjectionCause = "health_check" // Failed health checks, see WithUnhealthyReplicaEviction
+	EjectedByErrorRatio  EjectionCause = "error_ratio"  // Too many failed queries, see WithErrorRatioDemotion
+)
+
+// DiagnosisConfig configures the reports collected when a replica is ejected, see WithEjectionDiagnosis
+type DiagnosisConfig struct {
+	OnDiagnosis func(ReplicaDiagnosis) // Receives the diagnosis reports; they are logged when nil
+	Timeout     time.Duration          // Bound on collecting a report (defaults to 5s)
+}
+
+// WithEjectionDiagnosis collects a ReplicaDiagnosis in the background whenever a replica is ejected from the
+// read pool: on its first connection error with WithReplicaOutagePolicy, on eviction with
+// WithUnhealthyReplicaEviction and on demotion with WithErrorRatioDemotion.
+func WithEjectionDiagnosis(config DiagnosisConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.Timeout <= 0 {
+			config.Timeout = defaultDiagnosisTimeout
+		}
+		opt.Diagnosis = &config
+	}
+}
+
+// ReplicaDiagnosis is the diagnostic report collected when a replica is ejected.
+// Fields that couldn't be collected are nil; the reason is listed in CollectionErrors.
+type ReplicaDiagnosis struct {
+	Replica          string         // "replica-<index>", the position passed to WithReplicaDBs
+	Cause            EjectionCause  // Mechanism that ejected the replica
+	EjectedAt        time.Time      // When the replica was ejected
+	RecentErrors     []ReplicaError // Last errors observed on the replica, oldest first
+	InRecovery       *bool          // pg_is_in_recovery(); false means the node was promoted
+	ReplayLSN        *LSN           // pg_last_wal_replay_lsn()
+	ReplayTimestamp  *time.Time     // pg_last_xact_replay_timestamp()
+	Conflicts        map[string]int64
+	PoolStats        sql.DBStats // Connection pool statistics of the replica
+	CollectionErrors []error
+}
+
+// LogValue logs the report as a group of attributes
+func (d ReplicaDiagnosis) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("replica", d.Replica),
+		slog.String("cause", string(d.Cause)),
+		slog.Time("ejected_at", d.EjectedAt),
+		slog.Int("open_connections", d.PoolStats.OpenConnections),
+		slog.Int("in_use", d.PoolStats.InUse),
+		slog.Int64("wait_count", d.PoolStats.WaitCount),
+	}
+	if len(d.RecentErrors) > 0 {
+		attrs = append(attrs, slog.Any("last_error", d.RecentErrors[len(d.RecentErrors)-1].Err))
+	}
+	if d.InRecovery != nil {
+		attrs = append(attrs, slog.Bool("in_recovery", *d.InRecovery))
+	}
+	if d.ReplayLSN != nil {
+		attrs = append(attrs, slog.String("replay_lsn", d.ReplayLSN.String()))
+	}
+	if d.ReplayTimestamp != nil {
+		attrs = append(attrs, slog.Time("replay_timestamp", *d.ReplayTimestamp))
+	}
+	if d.Conflicts != nil {
+		attrs = append(attrs, slog.Any("conflicts", d.Conflicts))
+	}
+	if len(d.CollectionErrors) > 0 {
+		attrs = append(attrs, slog.Any("collection_errors", d.CollectionErrors))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// diagnoseReplica collects the diagnosis report of an ejected replica in the background,
+// if WithEjectionDiagnosis is enabled
+func (db *DB) diagnoseReplica(replica *sql.DB, cause EjectionCause, ejectedAt time.Time, recentErrors []ReplicaError) {
+	if db.diagnosis == nil {
+		return
+	}
+	db.goBackground(func(stop <-chan struct{}) {
+		ctx, cancel := context.WithTimeout(context.Background(), db.diagnosis.Timeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		diagnosis := collectReplicaDiagnosis(ctx, replica)
+		diagnosis.Replica = physicalDBName(db, replica)
+		diagnosis.Cause = cause
+		diagnosis.EjectedAt = ejectedAt
+		diagnosis.RecentErrors = recentErrors
+
+		if db.diagnosis.OnDiagnosis != nil {
+			db.diagnosis.OnDiagnosis(diagnosis)
+			return
+		}
+		db.log().Warn("dbresolver: replica ejected", "diagnosis", diagnosis)
+	})
+}
+
+// collectReplicaDiagnosis queries the replica state; each probe fails independently
+func collectReplicaDiagnosis(ctx context.Context, replica *sql.DB) ReplicaDiagnosis {
+	d := ReplicaDiagnosis{PoolStats: replica.Stats()}
+
+	var inRecovery bool
+	if err := replica.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery); err != nil {
+		d.CollectionErrors = append(d.CollectionErrors, fmt.Errorf("pg_is_in_recovery: %w", err))
+	} else {
+		d.InRecovery = &inRecovery
+	}
+
+	var replayLSN, replayTimestamp sql.NullString
+	err := replica.QueryRowContext(ctx,
+		"SELECT "+PGLastWalReplayLSN+"::text, pg_last_xact_replay_timestamp()::text").Scan(&replayLSN, &replayTimestamp)
+	if err != nil {
+		d.CollectionErrors = append(d.CollectionErrors, fmt.Errorf("replay position: %w", err))
+	} else {
+		if lsn, err := ParseLSN(replayLSN.String); replayLSN.Valid && err == nil {
+			d.ReplayLSN = &lsn
+		}
+		if ts, err := time.Parse("2006-01-02 15:04:05.999999-07", replayTimestamp.String); replayTimestamp.Valid && err == nil {
+			d.ReplayTimestamp = &ts
+		}
+	}
+
+	var tablespace, lock, snapshot, bufferpin, deadlock int64
+	err = replica.QueryRowContext(ctx, "SELECT confl_tablespace, confl_lock, confl_snapshot, confl_bufferpin, confl_deadlock "+
+		"FROM pg_stat_database_conflicts WHERE datname = current_database()").
+		Scan(&tablespace, &lock, &snapshot, &bufferpin, &deadlock)
+	if err != nil {
+		d.CollectionErrors = append(d.CollectionErrors, fmt.Errorf("recovery conflicts: %w", err))
+	} else {
+		d.Conflicts = map[string]int64{
+			"tablespace": tablespace,
+			"lock":       lock,
+			"snapshot":   snapshot,
+			"bufferpin":  bufferpin,
+			"deadlock":   deadlock,
+		}
+	}
+	return d
+}
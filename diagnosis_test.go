@@ -0,0 +1,118 @@
+package dbresolver
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaEjectionDiagnosis(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	reports := make(chan ReplicaDiagnosis, 2)
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{Policy: OutageDegradeSilently, RetryInterval: time.Hour}),
+		WithEjectionDiagnosis(DiagnosisConfig{OnDiagnosis: func(d ReplicaDiagnosis) { reports <- d }}),
+	)
+
+	connErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(connErr)
+	replicaMock.ExpectQuery("pg_is_in_recovery").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(false))
+	replicaMock.ExpectQuery("pg_last_wal_replay_lsn").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn", "ts"}).AddRow("0/3000060", "2026-01-02 03:04:05.123456+00"))
+	replicaMock.ExpectQuery("pg_stat_database_conflicts").WillReturnError(errors.New("permission denied"))
+
+	_, _ = db.Query("SELECT 1")
+
+	var d ReplicaDiagnosis
+	select {
+	case d = <-reports:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a diagnosis report for the ejected replica")
+	}
+
+	if d.Replica != "replica-0" || d.Cause != EjectedByOutage || d.EjectedAt.IsZero() {
+		t.Errorf("unexpected replica identification: %q by %q at %v", d.Replica, d.Cause, d.EjectedAt)
+	}
+	if len(d.RecentErrors) != 1 || !errors.Is(d.RecentErrors[0].Err, connErr) {
+		t.Errorf("expected the ejecting error in the report, got %+v", d.RecentErrors)
+	}
+	if d.InRecovery == nil || *d.InRecovery {
+		t.Error("expected the report to show the node is no longer in recovery")
+	}
+	if d.ReplayLSN == nil || *d.ReplayLSN != (LSN{Lower: 0x3000060}) {
+		t.Errorf("unexpected replay LSN: %v", d.ReplayLSN)
+	}
+	want := time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	if d.ReplayTimestamp == nil || !d.ReplayTimestamp.Equal(want) {
+		t.Errorf("unexpected replay timestamp: %v", d.ReplayTimestamp)
+	}
+	if d.Conflicts != nil || len(d.CollectionErrors) != 1 {
+		t.Errorf("expected the conflicts probe to fail on its own, got %v", d.CollectionErrors)
+	}
+
+	// reads skipped while the replica is down don't produce new reports
+	_, _ = db.Query("SELECT 1")
+	db.stopBackground()
+	select {
+	case d := <-reports:
+		t.Errorf("unexpected second report: %+v", d)
+	default:
+	}
+}
+
+func TestEjectionDiagnosisCauses(t *testing.T) {
+	replicaErr := errors.New("connection refused")
+
+	t.Run("health check", func(t *testing.T) {
+		reports := make(chan ReplicaDiagnosis, 1)
+		db, primaryMock, replicaMock, _ := newHealthDB(t, WithUnhealthyReplicaEviction(1))
+		opt := defaultOption()
+		WithEjectionDiagnosis(DiagnosisConfig{OnDiagnosis: func(d ReplicaDiagnosis) { reports <- d }})(opt)
+		db.diagnosis = opt.Diagnosis
+
+		expectCurrentWALLSN(primaryMock, "0/3000000")
+		replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").WillReturnError(replicaErr)
+		db.checkHealth()
+
+		assertDiagnosis(t, reports, EjectedByHealthCheck, replicaErr)
+	})
+
+	t.Run("error ratio", func(t *testing.T) {
+		reports := make(chan ReplicaDiagnosis, 1)
+		primary, _ := newMockDB(t)
+		replica, _ := newMockDB(t)
+		db := New(
+			WithPrimaryDBs(primary),
+			WithReplicaDBs(replica),
+			WithErrorRatioDemotion(time.Minute, 0.5, 1),
+			WithEjectionDiagnosis(DiagnosisConfig{OnDiagnosis: func(d ReplicaDiagnosis) { reports <- d }}),
+		)
+
+		db.observeReplica(replica, replicaErr)
+
+		assertDiagnosis(t, reports, EjectedByErrorRatio, replicaErr)
+	})
+}
+
+func assertDiagnosis(t *testing.T, reports <-chan ReplicaDiagnosis, cause EjectionCause, err error) {
+	t.Helper()
+	select {
+	case d := <-reports:
+		if d.Replica != "replica-0" || d.Cause != cause {
+			t.Errorf("want replica-0 ejected by %q, got %q by %q", cause, d.Replica, d.Cause)
+		}
+		if len(d.RecentErrors) != 1 || !errors.Is(d.RecentErrors[0].Err, err) {
+			t.Errorf("expected the ejecting error in the report, got %+v", d.RecentErrors)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a diagnosis report for the replica ejected by %q", cause)
+	}
+}
@@ -0,0 +1,223 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiscoveryInterval = 30 * time.Second
+	defaultPostgresPort      = "5432"
+)
+
+// DiscoveryResolver resolves the replica addresses, implemented by *net.Resolver
+type DiscoveryResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DiscoveryConfig configures the DNS-based replica discovery
+type DiscoveryConfig struct {
+	// DSN of the replicas, as a URL or key/value connection string. Every address its host resolves to is a replica.
+	DSN string
+	// SRV name of the replicas, e.g. "_postgresql._tcp.replicas.default.svc.cluster.local", resolved instead
+	// of the host of DSN. Each target and port replaces the host and port of DSN.
+	SRV string
+	// Open opens the connection pool of a discovered replica, e.g. with sql.Open
+	Open func(dsn string) (*sql.DB, error)
+	// Interval between re-resolutions, defaults to 30s
+	Interval time.Duration
+	// Resolver defaults to net.DefaultResolver
+	Resolver DiscoveryResolver
+}
+
+// WithReplicaDiscovery discovers replicas through DNS, e.g. from a Kubernetes headless service or an RDS reader
+// endpoint: one pool is opened per resolved address and added with AddReplica, and the host is re-resolved every
+// interval to add the new addresses and remove, then close, the pools of the addresses that disappeared.
+// The first resolution runs in the background right after New, and a failed resolution keeps the current replicas.
+func WithReplicaDiscovery(config DiscoveryConfig) OptionFunc {
+	return func(opt *Option) {
+		opt.ReplicaDiscovery = &config
+	}
+}
+
+// replicaDiscovery keeps one replica per resolved address
+type replicaDiscovery struct {
+	config DiscoveryConfig
+	db     *DB
+
+	pools map[string]*sql.DB // address -> pool, only used by the discovery goroutine
+}
+
+func newReplicaDiscovery(config DiscoveryConfig, db *DB) *replicaDiscovery {
+	if config.Interval <= 0 {
+		config.Interval = defaultDiscoveryInterval
+	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+	return &replicaDiscovery{config: config, db: db, pools: make(map[string]*sql.DB)}
+}
+
+// run discovers the replicas right away, then every interval
+func (d *replicaDiscovery) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.refresh()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh resolves the replica addresses and reconciles the pools with them
+func (d *replicaDiscovery) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Interval)
+	defer cancel()
+
+	addresses, err := d.resolve(ctx)
+	if err != nil {
+		d.db.log().Warn("replica discovery: resolution failed, keeping the current replicas", "error", err)
+		return
+	}
+
+	for address, pool := range d.pools {
+		if slices.Contains(addresses, address) {
+			continue
+		}
+		if err := d.db.RemoveReplica(pool); err != nil {
+			d.db.log().Warn("replica discovery: failed to remove replica", "address", address, "error", err)
+		}
+		delete(d.pools, address)
+		_ = pool.Close()
+	}
+	for _, address := range addresses {
+		if _, ok := d.pools[address]; ok {
+			continue
+		}
+		if err := d.add(address); err != nil {
+			d.db.log().Warn("replica discovery: failed to add replica", "address", address, "error", err)
+		}
+	}
+}
+
+func (d *replicaDiscovery) add(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	dsn, err := dsnWithAddress(d.config.DSN, host, port)
+	if err != nil {
+		return err
+	}
+	pool, err := d.config.Open(dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open replica: %w", err)
+	}
+	if err := d.db.AddReplica(pool); err != nil {
+		_ = pool.Close()
+		return err
+	}
+	d.pools[address] = pool
+	return nil
+}
+
+// resolve returns the sorted "host:port" addresses of the replicas
+func (d *replicaDiscovery) resolve(ctx context.Context) ([]string, error) {
+	var addresses []string
+	if d.config.SRV != "" {
+		_, records, err := d.config.Resolver.LookupSRV(ctx, "", "", d.config.SRV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve SRV %s: %w", d.config.SRV, err)
+		}
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			addresses = append(addresses, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	} else {
+		host, port, err := dsnAddress(d.config.DSN)
+		if err != nil {
+			return nil, err
+		}
+		hosts, err := d.config.Resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		for _, h := range hosts {
+			addresses = append(addresses, net.JoinHostPort(h, port))
+		}
+	}
+	slices.Sort(addresses)
+	return slices.Compact(addresses), nil
+}
+
+var (
+	dsnHostPattern = regexp.MustCompile(`(^|\s)host=(\S*)`)
+	dsnPortPattern = regexp.MustCompile(`(^|\s)port=(\S*)`)
+)
+
+// isURLDSN reports whether dsn is a URL rather than a key/value connection string
+func isURLDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// dsnAddress returns the host and port of dsn, the port defaulting to 5432
+func dsnAddress(dsn string) (host, port string, err error) {
+	if isURLDSN(dsn) {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid DSN: %w", err)
+		}
+		host, port = u.Hostname(), u.Port()
+	} else {
+		if m := dsnHostPattern.FindStringSubmatch(dsn); m != nil {
+			host = m[2]
+		}
+		if m := dsnPortPattern.FindStringSubmatch(dsn); m != nil {
+			port = m[2]
+		}
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid DSN: no host to resolve")
+	}
+	if port == "" {
+		port = defaultPostgresPort
+	}
+	return host, port, nil
+}
+
+// dsnWithAddress returns dsn connecting to host and port
+func dsnWithAddress(dsn, host, port string) (string, error) {
+	if isURLDSN(dsn) {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("invalid DSN: %w", err)
+		}
+		u.Host = net.JoinHostPort(host, port)
+		return u.String(), nil
+	}
+	for _, kv := range []struct {
+		pattern *regexp.Regexp
+		key     string
+		value   string
+	}{{dsnHostPattern, "host", host}, {dsnPortPattern, "port", port}} {
+		if kv.pattern.MatchString(dsn) {
+			dsn = kv.pattern.ReplaceAllString(dsn, "${1}"+kv.key+"="+kv.value)
+		} else {
+			dsn = strings.TrimSpace(dsn + " " + kv.key + "=" + kv.value)
+		}
+	}
+	return dsn, nil
+}
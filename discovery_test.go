@@ -0,0 +1,154 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// fakeResolver answers the lookups with fixed records, which can be changed between lookups
+type fakeResolver struct {
+	mu    sync.Mutex
+	hosts []string
+	srv   []*net.SRV
+	err   error
+}
+
+func (r *fakeResolver) set(hosts []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts, r.err = hosts, err
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, _ string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts, r.err
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _, _, _ string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return "", r.srv, r.err
+}
+
+// mockOpener opens sqlmock pools and records the DSN of each
+type mockOpener struct {
+	t    *testing.T
+	mu   sync.Mutex
+	dsns map[*sql.DB]string
+}
+
+func (o *mockOpener) open(dsn string) (*sql.DB, error) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		return nil, err
+	}
+	o.t.Cleanup(func() { _ = db.Close() })
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dsns[db] = dsn
+	return db, nil
+}
+
+func (o *mockOpener) dsnsOf(dbs []*sql.DB) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	dsns := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		dsns = append(dsns, o.dsns[db])
+	}
+	return dsns
+}
+
+func TestReplicaDiscoveryRefresh(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+	resolver := &fakeResolver{hosts: []string{"10.0.0.2", "10.0.0.1"}}
+	opener := &mockOpener{t: t, dsns: make(map[*sql.DB]string)}
+	d := newReplicaDiscovery(DiscoveryConfig{
+		DSN:      "postgres://app@replicas.svc:6432/app?sslmode=disable",
+		Open:     opener.open,
+		Resolver: resolver,
+	}, db)
+
+	d.refresh()
+	got := opener.dsnsOf(db.ReplicaDBs())
+	want := []string{
+		"postgres://app@10.0.0.1:6432/app?sslmode=disable",
+		"postgres://app@10.0.0.2:6432/app?sslmode=disable",
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("want replicas %v, got %v", want, got)
+	}
+
+	resolver.set(nil, errors.New("no such host"))
+	d.refresh()
+	if len(db.ReplicaDBs()) != 2 {
+		t.Error("failed resolution should keep the current replicas")
+	}
+
+	resolver.set([]string{"10.0.0.2", "10.0.0.3"}, nil)
+	d.refresh()
+	got = opener.dsnsOf(db.ReplicaDBs())
+	if len(got) != 2 || got[0] != want[1] || got[1] != "postgres://app@10.0.0.3:6432/app?sslmode=disable" {
+		t.Errorf("want 10.0.0.1 replaced with 10.0.0.3, got %v", got)
+	}
+}
+
+func TestReplicaDiscoverySRV(t *testing.T) {
+	primary, _ := newMockDB(t)
+	opener := &mockOpener{t: t, dsns: make(map[*sql.DB]string)}
+	resolver := &fakeResolver{srv: []*net.SRV{{Target: "pg-1.replicas.svc.", Port: 5433}}}
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDiscovery(DiscoveryConfig{
+		DSN:      "host=replicas.svc user=app dbname=app",
+		SRV:      "_postgresql._tcp.replicas.svc",
+		Open:     opener.open,
+		Interval: time.Hour,
+		Resolver: resolver,
+	}))
+	t.Cleanup(db.stopBackground)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(db.ReplicaDBs()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the SRV target to be discovered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := opener.dsnsOf(db.ReplicaDBs()); got[0] != "host=pg-1.replicas.svc user=app dbname=app port=5433" {
+		t.Errorf("unexpected DSN %q", got[0])
+	}
+}
+
+func TestDSNAddress(t *testing.T) {
+	tests := []struct {
+		dsn, host, port string
+	}{
+		{"postgres://u:p@db.internal/app", "db.internal", "5432"},
+		{"postgresql://u@[::1]:6432/app", "::1", "6432"},
+		{"host=db.internal port=6432 user=u", "db.internal", "6432"},
+		{"user=u host=db.internal", "db.internal", "5432"},
+	}
+	for _, tt := range tests {
+		host, port, err := dsnAddress(tt.dsn)
+		if err != nil || host != tt.host || port != tt.port {
+			t.Errorf("%s: want %s:%s, got %s:%s, %v", tt.dsn, tt.host, tt.port, host, port, err)
+		}
+	}
+	if _, _, err := dsnAddress("user=u dbname=app"); err == nil {
+		t.Error("want an error without host")
+	}
+
+	dsn, err := dsnWithAddress("host=db.internal port=6432 user=u", "10.0.0.1", "6432")
+	if err != nil || dsn != "host=10.0.0.1 port=6432 user=u" {
+		t.Errorf("unexpected DSN %q, %v", dsn, err)
+	}
+}
@@ -0,0 +1,143 @@
+package dbresolver
+
+import (
+	"cmp"
+	"database/sql"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxFingerprintedQueries bounds the queries whose tables are remembered by the read distribution
+const maxFingerprintedQueries = 10000
+
+// tableRegex matches the table following FROM or JOIN, optionally schema qualified and quoted
+var tableRegex = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+((?:"[^"]+"|[a-z_][\w$]*)(?:\s*\.\s*(?:"[^"]+"|[a-z_][\w$]*))?)`)
+
+// TableReads is the read traffic of a table per physical database, see DB.ReadDistribution
+type TableReads struct {
+	Table string            // Table as written in the queries, unquoted and lower-cased unless quoted
+	Reads map[string]uint64 // Reads per database name, see PhysicalDBMetrics.Name
+	Total uint64
+	// MaxShare is the share of the reads served by the busiest database, from 1/len(Reads) when balanced to 1
+	// when they all land on a single database
+	MaxShare float64
+}
+
+// WithReadDistribution counts the reads of every table per physical database, reported by DB.ReadDistribution,
+// e.g. to find the tables whose reads concentrate on a single replica. The tables are fingerprinted from the
+// identifiers following FROM and JOIN in the queries routed by the resolver, an approximation which also counts
+// e.g. the column of EXTRACT(YEAR FROM column). Prepared statements and transactions aren't counted.
+func WithReadDistribution() OptionFunc {
+	return func(opt *Option) {
+		opt.ReadDistribution = &readDistribution{}
+	}
+}
+
+// readDistribution counts the reads per table and database. Methods are nil-receiver safe.
+type readDistribution struct {
+	tables sync.Map // query -> []string, up to maxFingerprintedQueries
+
+	mu      sync.Mutex
+	queries int
+	reads   map[tableRead]uint64
+}
+
+type tableRead struct {
+	table string
+	db    *sql.DB
+}
+
+// record counts a read of query served by curDB
+func (d *readDistribution) record(query string, curDB *sql.DB) {
+	if d == nil {
+		return
+	}
+	tables, cached := d.tables.Load(query)
+	if !cached {
+		tables = queryTables(query)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !cached && d.queries < maxFingerprintedQueries {
+		if _, loaded := d.tables.LoadOrStore(query, tables); !loaded {
+			d.queries++
+		}
+	}
+	if d.reads == nil {
+		d.reads = make(map[tableRead]uint64)
+	}
+	for _, table := range tables.([]string) {
+		d.reads[tableRead{table: table, db: curDB}]++
+	}
+}
+
+// snapshot returns the reads counted per table and database
+func (d *readDistribution) snapshot() map[tableRead]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshot := make(map[tableRead]uint64, len(d.reads))
+	for key, reads := range d.reads {
+		snapshot[key] = reads
+	}
+	return snapshot
+}
+
+// queryTables returns the distinct tables read by query
+func queryTables(query string) []string {
+	var tables []string
+	for _, match := range tableRegex.FindAllStringSubmatch(query, -1) {
+		if table := normalizeTable(match[1]); !slices.Contains(tables, table) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// normalizeTable strips the spaces and quotes of a table name, lower-casing its unquoted parts like PostgreSQL
+func normalizeTable(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if unquoted, ok := strings.CutPrefix(part, `"`); ok {
+			parts[i] = strings.TrimSuffix(unquoted, `"`)
+		} else {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// ReadDistribution returns the reads of every table per physical database, the busiest tables first, or nil
+// without WithReadDistribution
+func (db *DB) ReadDistribution() []TableReads {
+	if db.distribution == nil {
+		return nil
+	}
+	byTable := make(map[string]*TableReads)
+	for key, reads := range db.distribution.snapshot() {
+		table, ok := byTable[key.table]
+		if !ok {
+			table = &TableReads{Table: key.table, Reads: make(map[string]uint64)}
+			byTable[key.table] = table
+		}
+		table.Reads[physicalDBName(db, key.db)] += reads
+		table.Total += reads
+	}
+
+	report := make([]TableReads, 0, len(byTable))
+	for _, table := range byTable {
+		var busiest uint64
+		for _, reads := range table.Reads {
+			busiest = max(busiest, reads)
+		}
+		table.MaxShare = float64(busiest) / float64(table.Total)
+		report = append(report, *table)
+	}
+	slices.SortFunc(report, func(a, b TableReads) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Table, b.Table))
+	})
+	return report
+}
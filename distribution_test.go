@@ -0,0 +1,65 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQueryTables(t *testing.T) {
+	for query, want := range map[string][]string{
+		"SELECT * FROM orders WHERE id = $1":                          {"orders"},
+		`select o.id from Public.Orders o join "LineItems" l on true`: {"public.orders", "LineItems"},
+		"SELECT 1": nil,
+		"SELECT * FROM users JOIN orders ON true JOIN users u2 ON true": {"users", "orders"},
+		`SELECT * FROM "app" . "Users"`:                                 {"app.Users"},
+		"SELECT count(*) FROM (SELECT * FROM invoices) AS sub":          {"invoices"},
+	} {
+		if got := queryTables(query); !slices.Equal(got, want) {
+			t.Errorf("%q: want tables %v, got %v", query, want, got)
+		}
+	}
+}
+
+func TestReadDistribution(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	other, otherMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica, other), WithReadDistribution())
+	db.loadBalancer = firstLoadBalancer[*sql.DB]{}
+
+	if New(WithPrimaryDBs(primary)).ReadDistribution() != nil {
+		t.Error("want no report without WithReadDistribution")
+	}
+	read := func(query string) {
+		t.Helper()
+		rows, err := db.QueryContext(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	for range 3 {
+		replicaMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		read("SELECT id FROM orders")
+	}
+	db.loadBalancer = &RoundRobinLoadBalancer[*sql.DB]{}
+	replicaMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	otherMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	read("SELECT id FROM users")
+	read("SELECT id FROM users")
+
+	report := db.ReadDistribution()
+	if len(report) != 2 {
+		t.Fatalf("want two tables, got %+v", report)
+	}
+	if orders := report[0]; orders.Table != "orders" || orders.Total != 3 || orders.MaxShare != 1 || orders.Reads["replica-0"] != 3 {
+		t.Errorf("want the orders reads on a single replica, got %+v", orders)
+	}
+	if users := report[1]; users.Table != "users" || users.MaxShare != 0.5 || len(users.Reads) != 2 {
+		t.Errorf("want the users reads balanced, got %+v", users)
+	}
+}
@@ -0,0 +1,72 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// replicaDrain keeps the replicas taken out of rotation with DrainReplica. The zero value is ready to use.
+type replicaDrain struct {
+	mu      sync.RWMutex
+	drained map[*sql.DB]struct{}
+}
+
+func (d *replicaDrain) set(db *sql.DB, drained bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !drained {
+		delete(d.drained, db)
+		return
+	}
+	if d.drained == nil {
+		d.drained = make(map[*sql.DB]struct{})
+	}
+	d.drained[db] = struct{}{}
+}
+
+// available returns the replicas that aren't drained
+func (d *replicaDrain) available(replicas []*sql.DB) []*sql.DB {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.drained) == 0 {
+		return replicas
+	}
+	serving := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if _, drained := d.drained[replica]; !drained {
+			serving = append(serving, replica)
+		}
+	}
+	return serving
+}
+
+// DrainReplica takes a replica out of rotation, e.g. before maintenance: new reads and prepared statements
+// aren't routed to it anymore, while the queries already running on it complete. The replica is idle once
+// its Stats().InUse drops to 0. It serves reads again after UndrainReplica.
+func (db *DB) DrainReplica(replica *sql.DB) error {
+	if !db.isReplica(replica) {
+		return fmt.Errorf("database is not a replica of the resolver")
+	}
+	db.drain.set(replica, true)
+	db.log().Debug("replica pool: replica drained", "db", physicalDBName(db, replica))
+	return nil
+}
+
+// UndrainReplica puts a replica drained with DrainReplica back into rotation
+func (db *DB) UndrainReplica(replica *sql.DB) error {
+	if !db.isReplica(replica) {
+		return fmt.Errorf("database is not a replica of the resolver")
+	}
+	db.drain.set(replica, false)
+	db.warmUp(replica)
+	db.log().Debug("replica pool: replica undrained", "db", physicalDBName(db, replica))
+	return nil
+}
+
+// isReplica reports whether replica is a replica of the resolver, including the resource class replicas
+func (db *DB) isReplica(replica *sql.DB) bool {
+	return containsDB(db.allReplicas(), replica) || containsDB(db.resourceClassReplicaDBs(), replica)
+}
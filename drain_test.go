@@ -0,0 +1,80 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDrainReplica(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	other, _ := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica, other))
+	if err := db.DrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	for range 4 {
+		if got := db.DbSelector(context.Background(), QueryTypeRead); got != other {
+			t.Fatal("drained replica should not serve new reads")
+		}
+	}
+
+	if err := db.DrainReplica(other); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.DbSelector(context.Background(), QueryTypeRead); got != primary {
+		t.Error("want the primary with every replica drained")
+	}
+
+	if err := db.UndrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != replica {
+		t.Errorf("want the undrained replica back into rotation, got %v", replicas)
+	}
+	if err := db.DrainReplica(primary); err == nil {
+		t.Error("want an error draining a database that isn't a replica")
+	}
+}
+
+func TestDrainReplicaPreparedStatements(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	db.stmtLoadBalancer = firstLoadBalancer[*sql.Stmt]{}
+
+	primaryMock.ExpectPrepare("SELECT 1")
+	replicaMock.ExpectPrepare("SELECT 1")
+	st, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := st.QueryRow().Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.UndrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	if err := st.QueryRow().Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
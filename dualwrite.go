@@ -0,0 +1,110 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+const defaultDualWriteTimeout = 2 * time.Second
+
+// ReasonDualWriteTarget is the route reason of the reads served by the target of a dual-write migration
+const ReasonDualWriteTarget = "dual_write_target"
+
+// DualWriteConfig configures WithDualWrite
+type DualWriteConfig struct {
+	Target *sql.DB // primary of the cluster migrated to, not closed by DB.Close
+	// ReadFromTarget routes the reads to Target instead of the replicas, see DB.SetDualWriteReads
+	ReadFromTarget bool
+	Timeout        time.Duration // bound of the write replayed on Target, defaults to 2s
+	// OnMismatch is called when Target returns a different outcome than the primary for a write
+	OnMismatch func(DualWriteMismatch)
+}
+
+// DualWriteMismatch describes a write whose outcome differs on the primary and on the target, see WithDualWrite
+type DualWriteMismatch struct {
+	Query               string
+	PrimaryErr          error
+	TargetErr           error
+	PrimaryRowsAffected int64
+	TargetRowsAffected  int64
+}
+
+// WithDualWrite verifies a migration to the primary of another cluster: the writes run with ExecContext on the
+// primary are replayed on config.Target, once the primary returned, and their errors and rows affected compared.
+// The primary stays authoritative, the outcome of the target never fails the write. Reads move to the target with
+// config.ReadFromTarget or DB.SetDualWriteReads, so the cutover is a configuration change: verify the writes, move the
+// reads, then make the target the primary with SetPrimary. Writes run with QueryContext, statements and
+// transactions aren't replayed.
+func WithDualWrite(config DualWriteConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.Timeout <= 0 {
+			config.Timeout = defaultDualWriteTimeout
+		}
+		opt.DualWrite = newDualWriter(config)
+	}
+}
+
+// dualWriter replays the writes on the target of a dual-write migration
+type dualWriter struct {
+	config     DualWriteConfig
+	readTarget atomic.Bool
+}
+
+func newDualWriter(config DualWriteConfig) *dualWriter {
+	w := &dualWriter{config: config}
+	w.readTarget.Store(config.ReadFromTarget)
+	return w
+}
+
+// SetDualWriteReads moves the reads to the target of WithDualWrite, or back to the replicas.
+// It does nothing without WithDualWrite.
+func (db *DB) SetDualWriteReads(fromTarget bool) {
+	if db.dualWrite == nil {
+		return
+	}
+	if db.dualWrite.readTarget.Swap(fromTarget) != fromTarget {
+		db.log().Warn("dual write: reads moved", "toTarget", fromTarget)
+	}
+}
+
+// dualWriteReadDB returns the target serving the reads with SetDualWriteReads, nil otherwise
+func (db *DB) dualWriteReadDB(ctx context.Context, queryType QueryType) *sql.DB {
+	if db.dualWrite == nil || !db.dualWrite.readTarget.Load() {
+		return nil
+	}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil && lsnCtx.ForceMaster {
+		return nil
+	}
+	target := db.dualWrite.config.Target
+	db.hooks.route(RouteDecision{QueryType: queryType, DB: target, Target: "dual-write-target", Reason: ReasonDualWriteTarget})
+	return target
+}
+
+// replayDualWrite replays on the target a write executed on the primary and reports a different outcome
+func (db *DB) replayDualWrite(ctx context.Context, primaryResult sql.Result, primaryErr error, query string, args ...any) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), db.dualWrite.config.Timeout)
+	defer cancel()
+	targetResult, targetErr := db.dualWrite.config.Target.ExecContext(ctx, query, args...)
+	db.metrics.dualWrites.Add(1)
+
+	mismatch := DualWriteMismatch{Query: query, PrimaryErr: primaryErr, TargetErr: targetErr}
+	if primaryErr == nil && targetErr == nil {
+		mismatch.PrimaryRowsAffected, _ = primaryResult.RowsAffected()
+		mismatch.TargetRowsAffected, _ = targetResult.RowsAffected()
+		if mismatch.PrimaryRowsAffected == mismatch.TargetRowsAffected {
+			return
+		}
+	} else if primaryErr != nil && targetErr != nil {
+		return // both failed, e.g. on the same constraint
+	}
+
+	db.metrics.dualWriteMismatches.Add(1)
+	db.log().Warn("dual write: outcome differs on the target", "query", query,
+		"primaryErr", primaryErr, "targetErr", targetErr,
+		"primaryRows", mismatch.PrimaryRowsAffected, "targetRows", mismatch.TargetRowsAffected)
+	if db.dualWrite.config.OnMismatch != nil {
+		db.dualWrite.config.OnMismatch(mismatch)
+	}
+}
@@ -0,0 +1,56 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDualWrite(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	target, targetMock := newMockDB(t)
+
+	var mismatches []DualWriteMismatch
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithDualWrite(DualWriteConfig{
+		Target:     target,
+		OnMismatch: func(m DualWriteMismatch) { mismatches = append(mismatches, m) },
+	}))
+	ctx := context.Background()
+
+	primaryMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 2))
+	targetMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 2))
+	if _, err := db.ExecContext(ctx, "UPDATE users SET active = true"); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryMock.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+	targetMock.ExpectExec("DELETE FROM users").WillReturnError(errors.New("relation does not exist"))
+	if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", 1); err != nil {
+		t.Fatalf("want the primary authoritative, got %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].TargetErr == nil || mismatches[0].PrimaryRowsAffected != 0 {
+		t.Fatalf("want the failed replay reported, got %+v", mismatches)
+	}
+	if m := db.Metrics(); m.DualWrites != 2 || m.DualWriteMismatches != 1 {
+		t.Errorf("want 2 replays and 1 mismatch, got %d and %d", m.DualWrites, m.DualWriteMismatches)
+	}
+
+	replicaMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("replica"))
+	targetMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("target"))
+	for _, want := range []string{"replica", "target"} {
+		var name string
+		if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil || name != want {
+			t.Errorf("want the read served by the %s, got %q, %v", want, name, err)
+		}
+		db.SetDualWriteReads(true)
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock, targetMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package echomw adapts the LSN middleware of dbresolver to Echo. The handlers run their queries with the context of
+// the request, c.Request().Context(), which carries the LSN context:
+//
+//	middleware := dbresolver.NewHTTPMiddleware(db, "", 5*time.Minute, true)
+//	e := echo.New()
+//	e.Use(echomw.Middleware(middleware))
+package echomw
+
+import (
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware runs the handlers of the request within m, which sets the LSN cookie with the headers of the
+// responses of the requests that wrote
+func Middleware(m *dbresolver.HTTPMiddleware) echo.MiddlewareFunc {
+	return echo.WrapMiddleware(m.Middleware)
+}
+
+// SetLSNCookie sets the LSN cookie of a write explicitly, e.g. after a write whose LSN was captured by the handler
+// itself, like dbresolver.SetLSNCookie
+func SetLSNCookie(c echo.Context, lsn dbresolver.LSN, cookieName string, maxAge time.Duration, secure bool) {
+	dbresolver.SetLSNCookie(c.Response(), lsn, cookieName, maxAge, secure)
+}
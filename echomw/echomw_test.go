@@ -0,0 +1,57 @@
+package echomw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/labstack/echo/v4"
+)
+
+// fixedTracker captures the same LSN after every write
+type fixedTracker struct{ lsn dbresolver.LSN }
+
+func (t fixedTracker) UpdateLSNAfterWrite(context.Context) (dbresolver.LSN, error) { return t.lsn, nil }
+
+func TestMiddleware(t *testing.T) {
+	middleware := dbresolver.NewHTTPMiddleware(fixedTracker{lsn: dbresolver.LSN{Lower: 0x2000}}, "", time.Minute, false)
+	e := echo.New()
+	e.Use(Middleware(middleware))
+	var required dbresolver.LSN
+	e.POST("/orders", func(c echo.Context) error {
+		lsnCtx := dbresolver.GetLSNContext(c.Request().Context())
+		required = lsnCtx.RequiredLSN
+		lsnCtx.HasWriteOperation = true // as routed by the resolver
+		return c.JSON(http.StatusCreated, map[string]int{"id": 1})
+	})
+	e.GET("/orders", func(c echo.Context) error { return c.String(http.StatusOK, "orders") })
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "pg_min_lsn", Value: "0/1000"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if required != (dbresolver.LSN{Lower: 0x1000}) {
+		t.Errorf("want the LSN of the cookie required, got %s", required)
+	}
+	if cookies := rec.Result().Cookies(); rec.Code != http.StatusCreated || len(cookies) != 1 || cookies[0].Value != "0/2000" {
+		t.Errorf("want the LSN cookie set with the response, got %d %+v", rec.Code, cookies)
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", http.NoBody))
+	if rec.Code != http.StatusOK || len(rec.Result().Cookies()) != 0 || rec.Body.String() != "orders" {
+		t.Errorf("want reads without cookie, got %d %+v", rec.Code, rec.Result().Cookies())
+	}
+}
+
+func TestSetLSNCookie(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", http.NoBody), rec)
+	SetLSNCookie(c, dbresolver.LSN{Upper: 1, Lower: 0x10}, "lsn", time.Minute, true)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "lsn" || cookies[0].Value != "1/10" {
+		t.Errorf("want the LSN cookie set, got %+v", cookies)
+	}
+}
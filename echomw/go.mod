@@ -0,0 +1,22 @@
+module github.com/alfari16/go-pgrouter/echomw
+
+go 1.25.5
+
+require (
+	github.com/alfari16/go-pgrouter v0.0.0-20261014132500-77a25c75cdef
+	github.com/labstack/echo/v4 v4.12.0
+)
+
+require (
+	github.com/labstack/gommon v0.4.2 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/net v0.24.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
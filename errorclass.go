@@ -0,0 +1,59 @@
+package dbresolver
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+)
+
+// ErrorClass classifies query errors for fallback, retries and replica ejection
+type ErrorClass int
+
+const (
+	// ErrorClassOther is an error unrelated to the availability of the database, e.g. a constraint violation
+	ErrorClassOther ErrorClass = iota
+	// ErrorClassConnection is an error reaching the database. Replica reads failing with it fall back to
+	// the primary, are retried by the retry policy and count towards the replica outage ejection.
+	ErrorClassConnection
+	// ErrorClassTransient is an error of a reachable database that may not happen again, e.g. a recovery
+	// conflict. Reads failing with it are only retried by the retry policy.
+	ErrorClassTransient
+)
+
+// ErrorClassifier classifies the errors of the queries executed on the physical databases
+type ErrorClassifier func(err error) ErrorClass
+
+// WithErrorClassifier sets the classifier deciding which errors trigger fallback, retries and replica
+// ejection, e.g. to recognize the errors of a connection proxy. It defaults to DefaultErrorClassifier,
+// which custom classifiers may call for the errors they don't recognize.
+func WithErrorClassifier(classifier ErrorClassifier) OptionFunc {
+	return func(opt *Option) {
+		if classifier != nil {
+			opt.ErrorClassifier = classifier
+		}
+	}
+}
+
+// DefaultErrorClassifier classifies network errors and driver.ErrBadConn as connection errors
+// and every other error as ErrorClassOther
+func DefaultErrorClassifier(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassOther
+	}
+	var netErr net.Error
+	if isDBConnectionError(err) || errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) {
+		return ErrorClassConnection
+	}
+	return ErrorClassOther
+}
+
+// isConnectionError reports whether classifier, or the default one when nil, classifies err as a connection error
+func isConnectionError(classifier ErrorClassifier, err error) bool {
+	if err == nil {
+		return false
+	}
+	if classifier == nil {
+		classifier = DefaultErrorClassifier
+	}
+	return classifier(err) == ErrorClassConnection
+}
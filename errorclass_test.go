@@ -0,0 +1,82 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ErrorClassOther},
+		{errors.New("duplicate key"), ErrorClassOther},
+		{driver.ErrBadConn, ErrorClassConnection},
+		{errConnReset, ErrorClassConnection},
+		{fmt.Errorf("query failed: %w", errConnReset), ErrorClassConnection},
+	}
+	for _, tt := range tests {
+		if got := DefaultErrorClassifier(tt.err); got != tt.want {
+			t.Errorf("DefaultErrorClassifier(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// proxyClassifier recognizes the errors of a connection proxy
+func proxyClassifier(err error) ErrorClass {
+	if err != nil && strings.Contains(err.Error(), "proxy: backend unavailable") {
+		return ErrorClassConnection
+	}
+	return DefaultErrorClassifier(err)
+}
+
+func TestErrorClassifierTriggersStmtFallback(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	primaryMock.ExpectPrepare("SELECT 1").ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	replicaMock.ExpectPrepare("SELECT 1").ExpectQuery().WillReturnError(errors.New("proxy: backend unavailable"))
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithErrorClassifier(proxyClassifier))
+	stmt, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := stmt.QueryContext(context.Background())
+	if err != nil {
+		t.Fatalf("want the proxy error to fall back to the primary, got %v", err)
+	}
+	_ = rows.Close()
+}
+
+func TestErrorClassifierTriggersOutageAndRetries(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(errors.New("proxy: backend unavailable"))
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithErrorClassifier(proxyClassifier),
+		WithRetryPolicy(2, nil),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{Policy: OutageDegradeSilently}),
+	)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("want the proxy error retried on the primary, got %v", err)
+	}
+	_ = rows.Close()
+	if len(db.ReplicaDBs()) != 0 {
+		t.Error("want the replica ejected after a proxy connection error")
+	}
+}
@@ -3,7 +3,7 @@ module github.com/alfari16/go-pgrouter/examples
 go 1.25.5
 
 require (
-	github.com/alfari16/go-pgrouter v0.0.0
+	github.com/alfari16/go-pgrouter v0.0.0-20261014132500-77a25c75cdef
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/stretchr/testify v1.8.1
 )
@@ -23,5 +23,3 @@ require (
 	golang.org/x/text v0.24.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
-
-replace github.com/alfari16/go-pgrouter => ./..
@@ -0,0 +1,111 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// RouteExplanation reports why a query is routed to a database
+type RouteExplanation struct {
+	QueryType         QueryType
+	CausalConsistency bool                   // Whether LSN-based routing is enabled
+	Level             CausalConsistencyLevel // Consistency level of the causal router
+	RequiredLSN       LSN                    // LSN the read must observe, zero without a requirement
+	ForceMaster       bool
+	ResourceClass     ResourceClass // Resource class of the read, empty if it has none
+
+	DB      *sql.DB
+	Target  string // Name of the selected database, see WithNamedReplicaDBs
+	Primary bool
+	Reason  string // See the Reason constants; empty when decided by a custom QueryRouter
+
+	// TargetLSN is the current replay LSN of the selected replica, nil for primaries
+	// or when it couldn't be queried, in which case TargetLSNErr is set
+	TargetLSN    *LSN
+	TargetLSNErr error
+	RouterErr    error // Error of the query router when Reason is ReasonRouterError
+}
+
+// ExplainRoute routes query like QueryContext would without executing it, and reports the decision.
+// The LSN context of ctx isn't modified and no read is counted, but replicas are queried for their LSN.
+// When the query would fail routing, the error is returned along with the explanation.
+func (db *DB) ExplainRoute(ctx context.Context, query string) (*RouteExplanation, error) {
+	e := &RouteExplanation{QueryType: db.queryTypeChecker.Check(query)}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		explainCtx := *lsnCtx
+		ctx = contextWithLSN(ctx, &explainCtx)
+		e.RequiredLSN = lsnCtx.RequiredLSN
+		e.ForceMaster = lsnCtx.ForceMaster
+	}
+	router, causal := db.queryRouter.(*CausalRouter)
+	if causal {
+		e.CausalConsistency = router.IsCausalConsistencyEnabled()
+		e.Level = router.level(GetLSNContext(ctx))
+	}
+
+	if e.QueryType != QueryTypeWrite {
+		e.ResourceClass = db.resourceClass(ctx, query)
+		if classDB, reason := db.resourceClassDB(ctx, query); classDB != nil {
+			e.DB, e.Reason = classDB, reason
+		}
+	}
+	if e.DB == nil {
+		if err := db.explainRouteDB(ctx, e, router); err != nil {
+			return e, err
+		}
+	}
+
+	e.Target = physicalDBName(db, e.DB)
+	e.Primary = slices.Contains(db.allPrimaries(), e.DB)
+	if !e.Primary {
+		lsn, err := getOrCreateChecker(e.DB, db.lsnQueryTimeout(router)).GetLastReplayLSN(ctx)
+		if err != nil {
+			e.TargetLSNErr = err
+		} else {
+			e.TargetLSN = &lsn
+		}
+	}
+	return e, nil
+}
+
+// explainRouteDB selects the database of an explained query, mirroring routeDB and DbSelector
+func (db *DB) explainRouteDB(ctx context.Context, e *RouteExplanation, router *CausalRouter) error {
+	if db.outage != nil && e.QueryType != QueryTypeWrite && !e.ForceMaster &&
+		len(db.allReplicas()) > 0 && len(db.ReplicaDBs()) == 0 {
+		e.Reason = ReasonReplicasUnavailable
+		if db.outage.config.Policy == OutageFailReads {
+			return ErrReplicasUnavailable
+		}
+		e.DB = db.ReadWrite()
+		return nil
+	}
+
+	switch {
+	case router != nil:
+		e.DB, e.Reason, e.RouterErr = router.route(ctx, e.QueryType)
+	case db.queryRouter != nil:
+		e.DB, e.RouterErr = db.queryRouter.RouteQuery(ctx, e.QueryType)
+	}
+	if e.RouterErr == nil && e.DB != nil {
+		return nil
+	}
+	if e.RouterErr != nil {
+		e.Reason = ReasonRouterError
+	}
+
+	e.DB = db.readWithoutLSN(e.QueryType)
+	if e.Reason == "" {
+		e.Reason = db.withoutLSNReason(e.QueryType)
+	}
+	return nil
+}
+
+// lsnQueryTimeout returns the timeout of the LSN queries of the router
+func (db *DB) lsnQueryTimeout(router *CausalRouter) time.Duration {
+	if router != nil {
+		return router.queryTimeout
+	}
+	return DefaultCausalConsistencyConfig().Timeout
+}
@@ -0,0 +1,87 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplainRoute(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            ReadYourWrites,
+			FallbackToMaster: true,
+		}),
+	)
+
+	lsnCtx := &LSNContext{RequiredLSN: LSN{Lower: 0x5000}}
+	ctx := WithReadAccumulator(WithLSNContext(context.Background(), lsnCtx))
+
+	// the lagging replica is reported as the reason of the primary read
+	expectReplayLSN(replicaMock, "0/4000")
+	e, err := db.ExplainRoute(ctx, "SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Primary || e.Target != "primary-0" || e.Reason != ReasonReplicaLagging || e.TargetLSN != nil {
+		t.Errorf("unexpected explanation %+v", e)
+	}
+	if e.Level != ReadYourWrites || !e.CausalConsistency || e.RequiredLSN != lsnCtx.RequiredLSN {
+		t.Errorf("unexpected consistency settings %+v", e)
+	}
+
+	// a caught up replica is reported with its current LSN
+	expectReplayLSN(replicaMock, "0/6000")
+	expectReplayLSN(replicaMock, "0/6000")
+	e, err = db.ExplainRoute(ctx, "SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Primary || e.Target != "replica-0" || e.Reason != ReasonReplicaCaughtUp ||
+		e.TargetLSN == nil || *e.TargetLSN != (LSN{Lower: 0x6000}) {
+		t.Errorf("unexpected explanation %+v", e)
+	}
+
+	// explaining doesn't change the request state or the metrics
+	if _, err := db.ExplainRoute(ctx, "UPDATE orders SET paid = true"); err != nil {
+		t.Fatal(err)
+	}
+	if lsnCtx.ForceMaster || lsnCtx.HasWriteOperation {
+		t.Error("expected the LSN context of the request to be left untouched")
+	}
+	if acc := GetReadAccumulator(ctx); acc.PrimaryReads() != 0 || acc.ReplicaReads() != 0 {
+		t.Error("expected explained reads not to be counted")
+	}
+	if m := db.Metrics(); m.LSNFallbacks != 0 {
+		t.Errorf("expected no LSN fallback to be counted, got %d", m.LSNFallbacks)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExplainRouteWithoutRouter(t *testing.T) {
+	primary, _ := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary))
+	e, err := db.ExplainRoute(context.Background(), "INSERT INTO orders VALUES (1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.QueryType != QueryTypeWrite || e.Reason != ReasonWrite || !e.Primary || e.CausalConsistency {
+		t.Errorf("unexpected explanation %+v", e)
+	}
+
+	e, err = db.ExplainRoute(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Reason != ReasonNoReplicas || !e.Primary {
+		t.Errorf("unexpected explanation %+v", e)
+	}
+}
@@ -0,0 +1,104 @@
+package failover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// PatroniSource reads the topology from the /cluster endpoint of the REST API of Patroni. The URLs of the
+// members are tried in turn, so the topology is still read while the former leader is down. Standbys tagged
+// noloadbalance, or not running, don't serve reads.
+type PatroniSource struct {
+	// URLs of the REST API of the members, e.g. "http://pg-0.pg:8008"
+	URLs []string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// patroniMember is the subset of a member of the /cluster response the source uses
+type patroniMember struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	State string `json:"state"`
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+	Tags  struct {
+		NoLoadBalance bool `json:"noloadbalance"`
+	} `json:"tags"`
+}
+
+// Topology returns the topology reported by the first member answering
+func (p *PatroniSource) Topology(ctx context.Context) (Topology, error) {
+	if len(p.URLs) == 0 {
+		return Topology{}, fmt.Errorf("no Patroni URL configured")
+	}
+	var errs error
+	for _, u := range p.URLs {
+		topology, err := p.clusterTopology(ctx, u)
+		if err == nil {
+			return topology, nil
+		}
+		errs = multierr.Append(errs, fmt.Errorf("%s: %w", u, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return Topology{}, errs
+}
+
+func (p *PatroniSource) clusterTopology(ctx context.Context, baseURL string) (Topology, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/cluster", nil)
+	if err != nil {
+		return Topology{}, err
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Topology{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Topology{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var cluster struct {
+		Members []patroniMember `json:"members"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cluster); err != nil {
+		return Topology{}, fmt.Errorf("failed to decode the cluster: %w", err)
+	}
+	return patroniTopology(cluster.Members)
+}
+
+// patroniTopology returns the leader and the standbys serving reads among members
+func patroniTopology(members []patroniMember) (Topology, error) {
+	var topology Topology
+	for _, m := range members {
+		address := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+		switch m.Role {
+		case "leader", "master": // "master" before Patroni 3
+			if m.State != "running" {
+				continue
+			}
+			if topology.Leader != "" {
+				return Topology{}, fmt.Errorf("several leaders reported: %s and %s", topology.Leader, address)
+			}
+			topology.Leader = address
+		case "replica", "sync_standby", "quorum_standby":
+			if (m.State == "running" || m.State == "streaming") && !m.Tags.NoLoadBalance {
+				topology.Standbys = append(topology.Standbys, address)
+			}
+		}
+	}
+	return topology, nil
+}
@@ -0,0 +1,60 @@
+package failover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+const patroniCluster = `{"members": [
+	{"name": "pg-0", "role": "replica", "state": "streaming", "host": "10.0.0.1", "port": 5432, "lag": 0},
+	{"name": "pg-1", "role": "leader", "state": "running", "host": "10.0.0.2", "port": 5432, "timeline": 4},
+	{"name": "pg-2", "role": "sync_standby", "state": "streaming", "host": "10.0.0.3", "port": 5432},
+	{"name": "pg-3", "role": "replica", "state": "streaming", "host": "10.0.0.4", "port": 5432,
+		"tags": {"noloadbalance": true}},
+	{"name": "pg-4", "role": "replica", "state": "stopped", "host": "10.0.0.5", "port": 5432}
+]}`
+
+func TestPatroniSourceTopology(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cluster" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, patroniCluster)
+	}))
+	defer up.Close()
+
+	source := &PatroniSource{URLs: []string{down.URL, up.URL + "/"}}
+	topology, err := source.Topology(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topology.Leader != "10.0.0.2:5432" {
+		t.Errorf("want the leader 10.0.0.2:5432, got %q", topology.Leader)
+	}
+	if want := []string{"10.0.0.1:5432", "10.0.0.3:5432"}; !slices.Equal(topology.Standbys, want) {
+		t.Errorf("want the standbys %v, got %v", want, topology.Standbys)
+	}
+
+	source.URLs = []string{down.URL}
+	if _, err := source.Topology(context.Background()); err == nil {
+		t.Error("want an error when no member answers")
+	}
+}
+
+func TestPatroniTopologySplitBrain(t *testing.T) {
+	_, err := patroniTopology([]patroniMember{
+		{Role: "leader", State: "running", Host: "10.0.0.1", Port: 5432},
+		{Role: "master", State: "running", Host: "10.0.0.2", Port: 5432},
+	})
+	if err == nil {
+		t.Error("want an error with several leaders")
+	}
+}
@@ -0,0 +1,221 @@
+// Package failover keeps the primary and replicas of a dbresolver.DB in sync with the leader and standbys
+// reported by a high availability manager such as Patroni, so writes are repointed to the new leader after a
+// failover instead of being sent to the demoted node.
+//
+// A Source reports the topology of the cluster; PatroniSource reads it from the REST API of Patroni and other
+// managers, e.g. the monitor of pg_auto_failover, can be plugged by implementing Source. The Watcher polls it,
+// opens one pool per member and sets them with DB.SetPrimary, DB.AddReplica and DB.RemoveReplica.
+package failover
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// DefaultInterval is the default interval between two polls of the topology
+const DefaultInterval = 5 * time.Second
+
+// errNoLeader reports a topology without leader, e.g. in the middle of a failover
+var errNoLeader = errors.New("no leader reported, keeping the current primary")
+
+// Topology is the state of the cluster reported by a Source
+type Topology struct {
+	Leader   string   // "host:port" of the leader, empty while none is elected
+	Standbys []string // "host:port" of the standbys serving reads
+}
+
+// Source reports the topology of the cluster
+type Source interface {
+	Topology(ctx context.Context) (Topology, error)
+}
+
+// OpenFunc opens the connection pool of the member listening on address, a "host:port" pair
+type OpenFunc func(address string) (*sql.DB, error)
+
+// Watcher repoints the primary and replicas of a DB as the topology of the cluster changes
+type Watcher struct {
+	db       *dbresolver.DB
+	source   Source
+	open     OpenFunc
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu         sync.Mutex
+	leader     string
+	leaderPool *sql.DB
+	standbys   map[string]*sql.DB // address -> pool
+}
+
+// Option configures a Watcher
+type Option func(w *Watcher)
+
+// WithInterval sets the interval between two polls of the topology, defaults to DefaultInterval
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		if interval > 0 {
+			w.interval = interval
+		}
+	}
+}
+
+// WithLogger sets the logger used to report failovers and poll failures, slog.Default() otherwise
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// NewWatcher creates a watcher following the topology reported by source, opening the pool of each member
+// with open. The primary and replicas passed to New only serve until the first poll: they're then replaced
+// by the pools of the members, and are left open for the caller to close.
+func NewWatcher(db *dbresolver.DB, source Source, open OpenFunc, opts ...Option) *Watcher {
+	w := &Watcher{
+		db:       db,
+		source:   source,
+		open:     open,
+		interval: DefaultInterval,
+		standbys: make(map[string]*sql.DB),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.logger == nil {
+		w.logger = slog.Default()
+	}
+	return w
+}
+
+// Leader returns the address of the leader the primary currently points to, empty before the first poll
+func (w *Watcher) Leader() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.leader
+}
+
+// Run polls the topology right away, then every interval, until ctx is done. Poll failures are logged and
+// keep the current primary and replicas. The pools opened by the watcher are left in the DB when Run
+// returns, and closed by DB.Close.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Sync(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Warn("failover: failed to sync the topology", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync polls the topology once and applies it, e.g. before serving to start with the current leader
+func (w *Watcher) Sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, w.interval)
+	defer cancel()
+
+	topology, err := w.source.Topology(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the topology: %w", err)
+	}
+	if topology.Leader == "" {
+		return errNoLeader
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if topology.Leader != w.leader {
+		if err := w.promote(topology); err != nil {
+			return err
+		}
+	}
+	w.reconcileStandbys(topology.Standbys)
+	return nil
+}
+
+// promote points the primary to the new leader of topology, moving the pool of the former leader to the
+// replicas when it's a standby now, or closing it
+func (w *Watcher) promote(topology Topology) error {
+	address := topology.Leader
+	pool, wasStandby := w.standbys[address]
+	if wasStandby {
+		if err := w.db.RemoveReplica(pool); err != nil {
+			return fmt.Errorf("failed to remove the new leader from the replicas: %w", err)
+		}
+		delete(w.standbys, address)
+	} else {
+		var err error
+		if pool, err = w.open(address); err != nil {
+			return fmt.Errorf("failed to open the new leader %s: %w", address, err)
+		}
+	}
+
+	if err := w.db.SetPrimary(pool); err != nil {
+		if wasStandby && w.db.AddReplica(pool) == nil {
+			w.standbys[address] = pool
+		} else {
+			_ = pool.Close()
+		}
+		return fmt.Errorf("failed to repoint the primary to %s: %w", address, err)
+	}
+	w.logger.Warn("failover: primary repointed to the new leader", "leader", address, "previous", w.leader)
+
+	former, formerPool := w.leader, w.leaderPool
+	w.leader, w.leaderPool = address, pool
+	if formerPool == nil {
+		return nil
+	}
+	if slices.Contains(topology.Standbys, former) {
+		if err := w.db.AddReplica(formerPool); err == nil {
+			w.standbys[former] = formerPool
+			return nil
+		}
+	}
+	_ = formerPool.Close()
+	return nil
+}
+
+// reconcileStandbys adds the replicas of the new standbys and removes, then closes, those of the standbys gone
+func (w *Watcher) reconcileStandbys(standbys []string) {
+	for address, pool := range w.standbys {
+		if slices.Contains(standbys, address) {
+			continue
+		}
+		if err := w.db.RemoveReplica(pool); err != nil {
+			w.logger.Warn("failover: failed to remove replica", "address", address, "error", err)
+		}
+		delete(w.standbys, address)
+		_ = pool.Close()
+	}
+	for _, address := range standbys {
+		if _, ok := w.standbys[address]; ok || address == w.leader {
+			continue
+		}
+		if err := w.addStandby(address); err != nil {
+			w.logger.Warn("failover: failed to add replica", "address", address, "error", err)
+		}
+	}
+}
+
+func (w *Watcher) addStandby(address string) error {
+	pool, err := w.open(address)
+	if err != nil {
+		return fmt.Errorf("failed to open replica: %w", err)
+	}
+	if err := w.db.AddReplica(pool); err != nil {
+		_ = pool.Close()
+		return err
+	}
+	w.standbys[address] = pool
+	return nil
+}
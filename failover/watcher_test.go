@@ -0,0 +1,115 @@
+package failover
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+type fakeSource struct {
+	topology Topology
+	err      error
+}
+
+func (s *fakeSource) Topology(context.Context) (Topology, error) {
+	return s.topology, s.err
+}
+
+func newTestWatcher(t *testing.T, source Source) (*Watcher, *dbresolver.DB, map[string]*sql.DB) {
+	t.Helper()
+	bootstrap, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbresolver.New(dbresolver.WithPrimaryDBs(bootstrap))
+	t.Cleanup(func() { _ = db.Close() })
+
+	pools := make(map[string]*sql.DB)
+	open := func(address string) (*sql.DB, error) {
+		if address == "10.0.0.9:5432" {
+			return nil, errors.New("connection refused")
+		}
+		pool, _, err := sqlmock.New()
+		pools[address] = pool
+		return pool, err
+	}
+	return NewWatcher(db, source, open), db, pools
+}
+
+func TestWatcherFollowsFailover(t *testing.T) {
+	source := &fakeSource{topology: Topology{Leader: "10.0.0.1:5432", Standbys: []string{"10.0.0.2:5432"}}}
+	w, db, pools := newTestWatcher(t, source)
+	ctx := context.Background()
+
+	if err := w.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	leader, standby := pools["10.0.0.1:5432"], pools["10.0.0.2:5432"]
+	if db.ReadWrite() != leader || w.Leader() != "10.0.0.1:5432" {
+		t.Fatalf("want the primary pointed to the leader, got %s", w.Leader())
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != standby {
+		t.Fatalf("want the standby as the replica, got %v", replicas)
+	}
+
+	// failover: the standby is promoted and the former leader rejoins as a standby
+	source.topology = Topology{Leader: "10.0.0.2:5432", Standbys: []string{"10.0.0.1:5432"}}
+	if err := w.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if db.ReadWrite() != standby {
+		t.Error("want the primary repointed to the promoted standby")
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != leader {
+		t.Errorf("want the former leader as the replica, got %v", replicas)
+	}
+	if len(pools) != 2 {
+		t.Errorf("want the pools reused across the failover, got %d opened", len(pools))
+	}
+
+	// no leader while the next failover is running
+	source.topology = Topology{Standbys: []string{"10.0.0.1:5432"}}
+	if err := w.Sync(ctx); !errors.Is(err, errNoLeader) {
+		t.Errorf("want errNoLeader, got %v", err)
+	}
+	if db.ReadWrite() != standby {
+		t.Error("the primary should be kept without a leader")
+	}
+
+	// the former leader is gone
+	source.err = errors.New("unreachable")
+	if err := w.Sync(ctx); err == nil {
+		t.Error("want the source error")
+	}
+	source.topology, source.err = Topology{Leader: "10.0.0.2:5432"}, nil
+	if err := w.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.ReplicaDBs()) != 0 {
+		t.Errorf("want the standbys gone removed, got %v", db.ReplicaDBs())
+	}
+}
+
+func TestWatcherPromoteFailure(t *testing.T) {
+	source := &fakeSource{topology: Topology{Leader: "10.0.0.1:5432", Standbys: []string{"10.0.0.2:5432"}}}
+	w, db, pools := newTestWatcher(t, source)
+	if err := w.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	source.topology = Topology{Leader: "10.0.0.9:5432", Standbys: []string{"10.0.0.2:5432"}}
+	if err := w.Sync(context.Background()); err == nil {
+		t.Error("want the error opening the new leader")
+	}
+	if db.ReadWrite() != pools["10.0.0.1:5432"] || w.Leader() != "10.0.0.1:5432" {
+		t.Error("want the current primary kept when the new leader can't be opened")
+	}
+	if len(db.ReplicaDBs()) != 1 {
+		t.Errorf("want the standby kept, got %v", db.ReplicaDBs())
+	}
+}
@@ -0,0 +1,125 @@
+package dbresolver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultFallbackRatioWindow    = time.Minute
+	defaultFallbackRatioSmoothing = 0.3
+)
+
+// FallbackRatioConfig configures WithFallbackRatio
+type FallbackRatioConfig struct {
+	Window         time.Duration // Period over which the ratio is measured, defaults to 1m
+	Smoothing      float64       // Weight of the last window in the moving average, in (0, 1], defaults to 0.3
+	AlertThreshold float64       // Smoothed ratio above which OnAlert fires, 0 to never alert
+	// OnAlert is called when the smoothed ratio rises above AlertThreshold, with firing set, and when it falls back
+	// under it. It runs on a background goroutine.
+	OnAlert func(ratio float64, firing bool)
+}
+
+// WithFallbackRatio tracks the exponential moving average of the ratio of reads falling back to a primary, for
+// lagging or unavailable replicas, over windows of config.Window. A rising ratio is the earliest signal of
+// replication trouble. The ratio is reported by DB.FallbackRatio and Metrics, and alerted on with config.OnAlert.
+func WithFallbackRatio(config FallbackRatioConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.Window <= 0 {
+			config.Window = defaultFallbackRatioWindow
+		}
+		if config.Smoothing <= 0 || config.Smoothing > 1 {
+			config.Smoothing = defaultFallbackRatioSmoothing
+		}
+		opt.FallbackRatio = &fallbackRatio{config: config}
+	}
+}
+
+// FallbackRatio returns the smoothed ratio of reads falling back to a primary, see WithFallbackRatio.
+// ok is false without WithFallbackRatio or until a window with reads has elapsed.
+func (db *DB) FallbackRatio() (ratio float64, ok bool) {
+	return db.fallbackRatio.value()
+}
+
+// fallbackRatio counts the reads and fallbacks of the current window and averages the ratio of the past ones.
+// Methods are nil-receiver safe.
+type fallbackRatio struct {
+	config FallbackRatioConfig
+
+	reads     atomic.Uint64
+	fallbacks atomic.Uint64
+
+	mu       sync.Mutex
+	average  float64
+	measured bool
+	firing   bool
+}
+
+func (f *fallbackRatio) read() {
+	if f != nil {
+		f.reads.Add(1)
+	}
+}
+
+func (f *fallbackRatio) fallback() {
+	if f != nil {
+		f.fallbacks.Add(1)
+	}
+}
+
+func (f *fallbackRatio) value() (float64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.average, f.measured
+}
+
+// roll closes the current window, returning the smoothed ratio and whether it crossed the alert threshold.
+// A window without reads leaves the ratio unchanged.
+func (f *fallbackRatio) roll() (ratio float64, changed, firing bool) {
+	reads, fallbacks := f.reads.Swap(0), f.fallbacks.Swap(0)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if reads == 0 {
+		return f.average, false, f.firing
+	}
+	windowRatio := min(float64(fallbacks)/float64(reads), 1)
+	if f.measured {
+		f.average += f.config.Smoothing * (windowRatio - f.average)
+	} else {
+		f.average, f.measured = windowRatio, true
+	}
+	wasFiring := f.firing
+	f.firing = f.config.AlertThreshold > 0 && f.average > f.config.AlertThreshold
+	return f.average, f.firing != wasFiring, f.firing
+}
+
+// runFallbackRatio closes a window of the fallback ratio every config.Window
+func (db *DB) runFallbackRatio(stop <-chan struct{}) {
+	ticker := time.NewTicker(db.fallbackRatio.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ratio, changed, firing := db.fallbackRatio.roll()
+			if !changed {
+				continue
+			}
+			if firing {
+				db.log().Warn("dbresolver: fallback ratio above the alert threshold",
+					"ratio", ratio, "threshold", db.fallbackRatio.config.AlertThreshold)
+			} else {
+				db.log().Debug("dbresolver: fallback ratio back under the alert threshold", "ratio", ratio)
+			}
+			if db.fallbackRatio.config.OnAlert != nil {
+				db.fallbackRatio.config.OnAlert(ratio, firing)
+			}
+		}
+	}
+}
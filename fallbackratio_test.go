@@ -0,0 +1,67 @@
+package dbresolver
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFallbackRatioAlert(t *testing.T) {
+	opt := defaultOption()
+	WithFallbackRatio(FallbackRatioConfig{Smoothing: 0.5, AlertThreshold: 0.2})(opt)
+	f := opt.FallbackRatio
+
+	if _, changed, _ := f.roll(); changed {
+		t.Error("want a window without reads ignored")
+	}
+	window := func(reads, fallbacks int) (float64, bool, bool) {
+		for range reads {
+			f.read()
+		}
+		for range fallbacks {
+			f.fallback()
+		}
+		return f.roll()
+	}
+	if ratio, changed, _ := window(10, 1); !approxEqual(ratio, 0.1) || changed {
+		t.Errorf("want a 0.1 ratio under the threshold, got %v (changed %v)", ratio, changed)
+	}
+	if ratio, changed, firing := window(10, 5); !approxEqual(ratio, 0.3) || !changed || !firing {
+		t.Errorf("want the alert firing at 0.3, got %v (changed %v, firing %v)", ratio, changed, firing)
+	}
+	if ratio, changed, firing := window(10, 0); !approxEqual(ratio, 0.15) || !changed || firing {
+		t.Errorf("want the alert resolved at 0.15, got %v (changed %v, firing %v)", ratio, changed, firing)
+	}
+}
+
+func TestFallbackRatioCountsReads(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	db := New(
+		WithPrimaryDBs(primary),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}),
+		WithFallbackRatio(FallbackRatioConfig{}),
+	)
+	if _, ok := db.FallbackRatio(); ok {
+		t.Error("want no ratio before a window elapsed")
+	}
+
+	// without replicas, the reads fall back to the primary
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	db.fallbackRatio.roll()
+	if ratio, ok := db.FallbackRatio(); !ok || ratio != 1 {
+		t.Errorf("want every read counted as a fallback, got %v", ratio)
+	}
+	if m := db.Metrics(); m.FallbackRatio != 1 {
+		t.Errorf("want the ratio in the metrics, got %v", m.FallbackRatio)
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
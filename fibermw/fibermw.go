@@ -0,0 +1,81 @@
+// Package fibermw adapts the LSN middleware of dbresolver to Fiber, like ginmw and echomw do for Gin and Echo, so
+// the requests follow the same cookie, session store, skip and route consistency options. The handlers run their
+// queries with the user context of the request, c.UserContext(), which carries the LSN context:
+//
+//	middleware := dbresolver.NewHTTPMiddleware(db, "", 5*time.Minute, true)
+//	app := fiber.New()
+//	app.Use(fibermw.Middleware(middleware))
+package fibermw
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// Middleware runs the handlers of the request within m, which sets the LSN cookie with the headers of the
+// responses of the requests that wrote. The status of a handler error is the code of a *fiber.Error, else 500.
+func Middleware(m *dbresolver.HTTPMiddleware) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		r, err := adaptor.ConvertRequest(c, false)
+		if err != nil {
+			return err
+		}
+		w := &headerWriter{header: make(http.Header)}
+		var handlerErr error
+		m.Middleware(http.HandlerFunc(func(lsnWriter http.ResponseWriter, r *http.Request) {
+			c.SetUserContext(r.Context())
+			handlerErr = c.Next()
+			lsnWriter.WriteHeader(status(c, handlerErr))
+		})).ServeHTTP(w, r.WithContext(c.UserContext()))
+		w.copyTo(c)
+		return handlerErr
+	}
+}
+
+// SetLSNCookie sets the LSN cookie of a write explicitly, e.g. after a write whose LSN was captured by the handler
+// itself, like dbresolver.SetLSNCookie
+func SetLSNCookie(c *fiber.Ctx, lsn dbresolver.LSN, cookieName string, maxAge time.Duration, secure bool) {
+	w := &headerWriter{header: make(http.Header)}
+	dbresolver.SetLSNCookie(w, lsn, cookieName, maxAge, secure)
+	w.copyTo(c)
+}
+
+// status returns the status code of the response of a handler returning err
+func status(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// headerWriter collects the headers set by the LSN middleware, the body being written by the handlers to Fiber
+type headerWriter struct {
+	header http.Header
+}
+
+func (w *headerWriter) Header() http.Header         { return w.header }
+func (w *headerWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *headerWriter) WriteHeader(int)             {}
+
+// copyTo adds the headers collected to the response of c. Fiber buffers the response: the trailers of streaming
+// responses are left out, the headers carrying the same consistency token.
+func (w *headerWriter) copyTo(c *fiber.Ctx) {
+	for key, values := range w.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			continue
+		}
+		for _, value := range values {
+			c.Response().Header.Add(key, value)
+		}
+	}
+}
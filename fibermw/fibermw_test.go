@@ -0,0 +1,99 @@
+package fibermw
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/gofiber/fiber/v2"
+)
+
+// fixedTracker captures the same LSN after every write
+type fixedTracker struct{ lsn dbresolver.LSN }
+
+func (t fixedTracker) UpdateLSNAfterWrite(context.Context) (dbresolver.LSN, error) { return t.lsn, nil }
+
+func TestMiddleware(t *testing.T) {
+	middleware := dbresolver.NewHTTPMiddleware(fixedTracker{lsn: dbresolver.LSN{Lower: 0x2000}}, "", time.Minute, false,
+		dbresolver.WithTokenVersion(dbresolver.TokenVersion1))
+	app := fiber.New()
+	app.Use(Middleware(middleware))
+	var required dbresolver.LSN
+	app.Post("/orders", func(c *fiber.Ctx) error {
+		lsnCtx := dbresolver.GetLSNContext(c.UserContext())
+		required = lsnCtx.RequiredLSN
+		lsnCtx.HasWriteOperation = true // as routed by the resolver
+		return c.Status(http.StatusCreated).JSON(fiber.Map{"id": 1})
+	})
+	app.Put("/orders", func(c *fiber.Ctx) error {
+		dbresolver.GetLSNContext(c.UserContext()).HasWriteOperation = true
+		return fiber.ErrConflict
+	})
+	app.Get("/orders", func(c *fiber.Ctx) error { return c.SendString("orders") })
+
+	// the cookies round-trip with the core middleware, versioned tokens included
+	req := httptest.NewRequest(http.MethodPost, "/orders", http.NoBody)
+	token := dbresolver.EncodeConsistencyToken(dbresolver.LSN{Lower: 0x1000}, dbresolver.TokenVersion1)
+	req.AddCookie(&http.Cookie{Name: "pg_min_lsn", Value: token})
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required != (dbresolver.LSN{Lower: 0x1000}) {
+		t.Errorf("want the LSN of the cookie required, got %s", required)
+	}
+	want := dbresolver.EncodeConsistencyToken(dbresolver.LSN{Lower: 0x2000}, dbresolver.TokenVersion1)
+	if cookies := resp.Cookies(); resp.StatusCode != http.StatusCreated || len(cookies) != 1 || cookies[0].Value != want {
+		t.Errorf("want the LSN cookie %s set with the response, got %d %+v", want, resp.StatusCode, cookies)
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodGet} {
+		resp, err := app.Test(httptest.NewRequest(method, "/orders", http.NoBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("want no cookie set by a %s failing or reading, got %+v", method, resp.Cookies())
+		}
+	}
+}
+
+func TestMiddlewareOptions(t *testing.T) {
+	middleware := dbresolver.NewHTTPMiddleware(fixedTracker{lsn: dbresolver.LSN{Lower: 0x2000}}, "", time.Minute, false,
+		dbresolver.WithSkipFunc(func(r *http.Request) bool { return r.URL.Path == "/health" }))
+	app := fiber.New()
+	app.Use(Middleware(middleware))
+	var tracked bool
+	app.Get("/health", func(c *fiber.Ctx) error {
+		tracked = dbresolver.GetLSNContext(c.UserContext()) != nil
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked || resp.StatusCode != http.StatusNoContent {
+		t.Errorf("want the skipped request untracked, got %v, %d", tracked, resp.StatusCode)
+	}
+}
+
+func TestSetLSNCookie(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		SetLSNCookie(c, dbresolver.LSN{Upper: 1, Lower: 0x10}, "lsn", time.Minute, true)
+		return nil
+	})
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Name != "lsn" || cookies[0].Value != "1/10" || !cookies[0].Secure {
+		t.Errorf("want the LSN cookie set, got %+v", cookies)
+	}
+}
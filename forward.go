@@ -0,0 +1,248 @@
+package dbresolver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultForwardTimeout = 2 * time.Second
+
+// ReasonWriteForwarded is the reason recorded in the decision log for the writes executed by the write forwarder
+const ReasonWriteForwarded = "write_forwarded"
+
+// maxForwardBodySize bounds the requests accepted by the write forwarding handler
+const maxForwardBodySize = 1 << 20
+
+// WriteForwarder executes the writes of an instance in a follower region against the primary region
+type WriteForwarder interface {
+	// ForwardExec executes a write and returns its result and the WAL LSN of the primary after the
+	// write, zero when unknown. A write that succeeded but whose LSN couldn't be captured returns
+	// both its result and the capture error.
+	ForwardExec(ctx context.Context, query string, args ...any) (sql.Result, LSN, error)
+}
+
+// WithWriteForwarder forwards the writes executed with ExecContext, e.g. from an instance in a follower
+// region. The LSN of a forwarded write is captured like the LSN of a local write: the following reads of
+// the LSN context wait for it, and it's returned to the middleware and CausalToken.
+// Writes run with QueryContext and transactions aren't forwarded.
+func WithWriteForwarder(forwarder WriteForwarder) OptionFunc {
+	return func(opt *Option) {
+		opt.WriteForwarder = forwarder
+	}
+}
+
+// forwardExec executes a write with the forwarder and records its LSN in the LSN context of ctx
+func (db *DB) forwardExec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, lsn, err := db.forwarder.ForwardExec(ctx, query, args...)
+	db.metrics.forwardLatency.observe(time.Since(start))
+	if err != nil && result == nil {
+		db.metrics.forwardErrors.Add(1)
+		return nil, fmt.Errorf("failed to forward write: %w", err)
+	}
+	db.metrics.forwardedWrites.Add(1)
+	db.decisions.record(RouteDecision{QueryType: QueryTypeWrite, Target: "write-forwarder", Reason: ReasonWriteForwarded})
+	if err != nil {
+		db.lsnTrackingFailed(ctx, fmt.Errorf("failed to capture LSN of forwarded write: %w", err))
+		return result, nil
+	}
+
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		lsnCtx.HasWriteOperation = true
+		if lsn.GreaterThan(lsnCtx.forwardedLSN) {
+			lsnCtx.forwardedLSN = lsn
+		}
+		if lsn.GreaterThan(lsnCtx.RequiredLSN) {
+			lsnCtx.RequiredLSN = lsn
+		}
+	}
+	return result, nil
+}
+
+// ForwardingConfig configures the connection pool of a DBWriteForwarder. Cross-region connections are
+// expensive to establish and slow to use, so they're usually capped lower and recycled less often than
+// local ones. Zero values keep the settings of the database.
+type ForwardingConfig struct {
+	Timeout         time.Duration // Bound of a forwarded write, including the LSN capture; defaults to 2s
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DBWriteForwarder forwards writes over a direct connection to the primary region
+type DBWriteForwarder struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewDBWriteForwarder returns a forwarder executing writes on primary, a connection pool dedicated to
+// forwarding, configured with config
+func NewDBWriteForwarder(primary *sql.DB, config ForwardingConfig) *DBWriteForwarder {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultForwardTimeout
+	}
+	if config.MaxOpenConns > 0 {
+		primary.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		primary.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		primary.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	return &DBWriteForwarder{db: primary, timeout: config.Timeout}
+}
+
+// ForwardExec executes the write on the primary, then captures its current WAL LSN
+func (f *DBWriteForwarder) ForwardExec(ctx context.Context, query string, args ...any) (sql.Result, LSN, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	result, err := f.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, LSN{}, err
+	}
+	lsn, err := getOrCreateChecker(f.db, f.timeout).GetCurrentWALLSN(ctx)
+	if err != nil {
+		return result, LSN{}, err
+	}
+	return result, lsn, nil
+}
+
+// forwardRequest is the body of the requests of HTTPWriteForwarder
+type forwardRequest struct {
+	Query string `json:"query"`
+	Args  []any  `json:"args,omitempty"`
+}
+
+// forwardResponse is the body of the responses of the write forwarding handler
+type forwardResponse struct {
+	RowsAffected int64  `json:"rows_affected"`
+	LastInsertID *int64 `json:"last_insert_id,omitempty"`
+	LSN          string `json:"lsn,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// HTTPWriteForwarder forwards writes to a service in the primary region serving NewWriteForwardingHandler
+type HTTPWriteForwarder struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPWriteForwarder returns a forwarder posting writes to endpoint with client.
+// Without a client, a client with a 2s timeout is used.
+func NewHTTPWriteForwarder(endpoint string, client *http.Client) *HTTPWriteForwarder {
+	if client == nil {
+		client = &http.Client{Timeout: defaultForwardTimeout}
+	}
+	return &HTTPWriteForwarder{endpoint: endpoint, client: client}
+}
+
+// ForwardExec posts the write to the endpoint. Arguments are sent as JSON values, so they must be
+// encodable; the handler passes numbers to the driver as strings, relying on PostgreSQL casts.
+func (f *HTTPWriteForwarder) ForwardExec(ctx context.Context, query string, args ...any) (sql.Result, LSN, error) {
+	body, err := json.Marshal(forwardRequest{Query: query, Args: args})
+	if err != nil {
+		return nil, LSN{}, fmt.Errorf("failed to encode forwarded write: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, LSN{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, LSN{}, err
+	}
+	defer resp.Body.Close()
+
+	var fr forwardResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxForwardBodySize)).Decode(&fr); err != nil {
+		return nil, LSN{}, fmt.Errorf("failed to decode forwarding response with status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, LSN{}, fmt.Errorf("write forwarding endpoint returned status %d: %s", resp.StatusCode, fr.Error)
+	}
+
+	result := forwardedResult{rowsAffected: fr.RowsAffected, lastInsertID: fr.LastInsertID}
+	if fr.LSN == "" {
+		return result, LSN{}, nil
+	}
+	lsn, err := ParseLSN(fr.LSN)
+	return result, lsn, err
+}
+
+// forwardedResult is the sql.Result of a write forwarded over HTTP
+type forwardedResult struct {
+	rowsAffected int64
+	lastInsertID *int64
+}
+
+func (r forwardedResult) LastInsertId() (int64, error) {
+	if r.lastInsertID == nil {
+		return 0, errors.New("LastInsertId is not supported by the forwarded database")
+	}
+	return *r.lastInsertID, nil
+}
+
+func (r forwardedResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// NewWriteForwardingHandler returns the handler executing the writes posted by HTTPWriteForwarder on the
+// primary of db, replying with the result and the WAL LSN after the write. Only writes, according to the
+// query type checker of db, are accepted. The handler executes arbitrary SQL and must only be reachable
+// by trusted services.
+func NewWriteForwardingHandler(db *DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeForwardResponse(w, http.StatusMethodNotAllowed, forwardResponse{Error: "method not allowed"})
+			return
+		}
+		var req forwardRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxForwardBodySize))
+		dec.UseNumber() // keeps integers exact, sent to the driver as strings
+		if err := dec.Decode(&req); err != nil {
+			writeForwardResponse(w, http.StatusBadRequest, forwardResponse{Error: "invalid request: " + err.Error()})
+			return
+		}
+		if db.queryTypeChecker.Check(req.Query) != QueryTypeWrite {
+			writeForwardResponse(w, http.StatusBadRequest, forwardResponse{Error: "only writes can be forwarded"})
+			return
+		}
+
+		primary := db.ReadWrite()
+		result, err := primary.ExecContext(r.Context(), req.Query, req.Args...)
+		if err != nil {
+			writeForwardResponse(w, http.StatusUnprocessableEntity, forwardResponse{Error: err.Error()})
+			return
+		}
+
+		var resp forwardResponse
+		resp.RowsAffected, _ = result.RowsAffected()
+		if id, err := result.LastInsertId(); err == nil {
+			resp.LastInsertID = &id
+		}
+		router, _ := db.queryRouter.(*CausalRouter)
+		if lsn, err := getOrCreateChecker(primary, db.lsnQueryTimeout(router)).GetCurrentWALLSN(r.Context()); err == nil {
+			resp.LSN = lsn.String()
+		} else {
+			db.log().Warn("write forwarding: failed to capture LSN", "error", err)
+		}
+		writeForwardResponse(w, http.StatusOK, resp)
+	})
+}
+
+func writeForwardResponse(w http.ResponseWriter, status int, resp forwardResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,139 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDBWriteForwarderCapturesLSN(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	remote, remoteMock := newMockDB(t)
+
+	remoteMock.ExpectExec("INSERT INTO users").WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCurrentWALLSN(remoteMock, "0/3000060")
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyLevel(ReadYourWrites),
+		WithWriteForwarder(NewDBWriteForwarder(remote, ForwardingConfig{Timeout: time.Second, MaxOpenConns: 2})),
+	)
+
+	lsnCtx := &LSNContext{Level: ReadYourWrites}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+	result, err := db.ExecContext(ctx, "INSERT INTO users (name) VALUES ($1)", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("want 1 row affected, got %d", n)
+	}
+	if !lsnCtx.HasWriteOperation || lsnCtx.RequiredLSN.String() != "0/3000060" {
+		t.Errorf("want the forwarded LSN required by the context, got %+v", lsnCtx)
+	}
+
+	lsn, err := db.queryRouter.UpdateLSNAfterWrite(ctx)
+	if err != nil || lsn.String() != "0/3000060" {
+		t.Errorf("want the forwarded LSN captured after the write, got %s, %v", lsn, err)
+	}
+	if m := db.Metrics(); m.ForwardedWrites != 1 || m.ForwardErrors != 0 || m.ForwardLatency.Count != 1 {
+		t.Errorf("unexpected forwarding metrics %+v", m)
+	}
+	if err := remoteMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDBWriteForwarderLSNCaptureFailure(t *testing.T) {
+	primary, _ := newMockDB(t)
+	remote, remoteMock := newMockDB(t)
+
+	remoteMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 3))
+	remoteMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(errors.New("timeout"))
+
+	var fallbacks []FallbackEvent
+	db := New(
+		WithPrimaryDBs(primary),
+		WithWriteForwarder(NewDBWriteForwarder(remote, ForwardingConfig{})),
+		WithRoutingHooks(nil, func(e FallbackEvent) { fallbacks = append(fallbacks, e) }, nil),
+	)
+
+	result, err := db.ExecContext(context.Background(), "UPDATE users SET active = true")
+	if err != nil {
+		t.Fatalf("a write whose LSN capture failed should succeed, got %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != 3 {
+		t.Errorf("want 3 rows affected, got %d", n)
+	}
+	if len(fallbacks) != 1 || fallbacks[0].Reason != ReasonLSNTrackingFailed {
+		t.Errorf("want an LSN tracking fallback, got %+v", fallbacks)
+	}
+}
+
+func TestHTTPWriteForwarder(t *testing.T) {
+	// primary region service
+	regionPrimary, regionMock := newMockDB(t)
+	regionMock.ExpectExec("INSERT INTO users").WithArgs("alice", "42").WillReturnResult(sqlmock.NewResult(7, 1))
+	expectCurrentWALLSN(regionMock, "0/3000060")
+	server := httptest.NewServer(NewWriteForwardingHandler(New(WithPrimaryDBs(regionPrimary))))
+	defer server.Close()
+
+	// follower region instance
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithWriteForwarder(NewHTTPWriteForwarder(server.URL, nil)))
+
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+	result, err := db.ExecContext(ctx, "INSERT INTO users (name, age) VALUES ($1, $2)", "alice", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := result.LastInsertId(); err != nil || id != 7 {
+		t.Errorf("want last insert id 7, got %d, %v", id, err)
+	}
+	if lsnCtx.RequiredLSN.String() != "0/3000060" {
+		t.Errorf("want forwarded LSN 0/3000060, got %s", lsnCtx.RequiredLSN)
+	}
+	if err := regionMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteForwardingHandlerRejectsReads(t *testing.T) {
+	primary, _ := newMockDB(t)
+	handler := NewWriteForwardingHandler(New(WithPrimaryDBs(primary)))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"query":"SELECT * FROM users"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("want status 400 for a read, got %d", rec.Code)
+	}
+}
+
+func TestHTTPWriteForwarderError(t *testing.T) {
+	regionPrimary, regionMock := newMockDB(t)
+	regionMock.ExpectExec("INSERT INTO users").WillReturnError(errors.New("duplicate key"))
+	server := httptest.NewServer(NewWriteForwardingHandler(New(WithPrimaryDBs(regionPrimary))))
+	defer server.Close()
+
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithWriteForwarder(NewHTTPWriteForwarder(server.URL, nil)))
+
+	_, err := db.ExecContext(context.Background(), "INSERT INTO users (name) VALUES ('alice')")
+	if err == nil || !strings.Contains(err.Error(), "duplicate key") {
+		t.Fatalf("want the primary region error, got %v", err)
+	}
+	if m := db.Metrics(); m.ForwardErrors != 1 {
+		t.Errorf("want 1 forward error, got %d", m.ForwardErrors)
+	}
+}
@@ -0,0 +1,63 @@
+// Package ginmw adapts the LSN middleware of dbresolver to Gin. The handlers run their queries with the context of
+// the request, c.Request.Context(), which carries the LSN context:
+//
+//	middleware := dbresolver.NewHTTPMiddleware(db, "", 5*time.Minute, true)
+//	router := gin.New()
+//	router.Use(ginmw.Middleware(middleware))
+package ginmw
+
+import (
+	"net/http"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware runs the handlers of the request within m, which sets the LSN cookie with the headers of the
+// responses of the requests that wrote
+func Middleware(m *dbresolver.HTTPMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ginWriter := c.Writer
+		m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Request = r
+			if w != http.ResponseWriter(ginWriter) {
+				c.Writer = &responseWriter{ResponseWriter: ginWriter, lsn: w}
+			}
+			c.Next()
+		})).ServeHTTP(ginWriter, c.Request)
+		c.Writer = ginWriter
+	}
+}
+
+// SetLSNCookie sets the LSN cookie of a write explicitly, e.g. after a write whose LSN was captured by the handler
+// itself, like dbresolver.SetLSNCookie
+func SetLSNCookie(c *gin.Context, lsn dbresolver.LSN, cookieName string, maxAge time.Duration, secure bool) {
+	dbresolver.SetLSNCookie(c.Writer, lsn, cookieName, maxAge, secure)
+}
+
+// responseWriter sends the headers and body written by the handlers through the writer of the LSN middleware,
+// which adds the LSN cookie to the headers, and the rest to the writer of Gin
+type responseWriter struct {
+	gin.ResponseWriter
+	lsn http.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.lsn.WriteHeader(code)
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.lsn.WriteHeader(w.Status())
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.lsn.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.lsn.Write([]byte(s))
+}
@@ -0,0 +1,60 @@
+package ginmw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/gin-gonic/gin"
+)
+
+// fixedTracker captures the same LSN after every write
+type fixedTracker struct{ lsn dbresolver.LSN }
+
+func (t fixedTracker) UpdateLSNAfterWrite(context.Context) (dbresolver.LSN, error) { return t.lsn, nil }
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware := dbresolver.NewHTTPMiddleware(fixedTracker{lsn: dbresolver.LSN{Lower: 0x2000}}, "", time.Minute, false)
+	router := gin.New()
+	router.Use(Middleware(middleware))
+	var required dbresolver.LSN
+	router.POST("/orders", func(c *gin.Context) {
+		lsnCtx := dbresolver.GetLSNContext(c.Request.Context())
+		required = lsnCtx.RequiredLSN
+		lsnCtx.HasWriteOperation = true // as routed by the resolver
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+	router.GET("/orders", func(c *gin.Context) { c.String(http.StatusOK, "orders") })
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "pg_min_lsn", Value: "0/1000"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if required != (dbresolver.LSN{Lower: 0x1000}) {
+		t.Errorf("want the LSN of the cookie required, got %s", required)
+	}
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusCreated || len(cookies) != 1 || cookies[0].Value != "0/2000" || rec.Body.String() != `{"id":1}` {
+		t.Errorf("want the LSN cookie set with the response, got %d %+v %q", rec.Code, cookies, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", http.NoBody))
+	if rec.Code != http.StatusOK || len(rec.Result().Cookies()) != 0 || rec.Body.String() != "orders" {
+		t.Errorf("want reads without cookie, got %d %+v", rec.Code, rec.Result().Cookies())
+	}
+}
+
+func TestSetLSNCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	SetLSNCookie(c, dbresolver.LSN{Upper: 1, Lower: 0x10}, "lsn", time.Minute, true)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "lsn" || cookies[0].Value != "1/10" {
+		t.Errorf("want the LSN cookie set, got %+v", cookies)
+	}
+}
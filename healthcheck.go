@@ -0,0 +1,374 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+const defaultHealthCheckTimeout = 3 * time.Second
+
+// HealthEventType identifies a state transition observed by the health monitor
+type HealthEventType int
+
+const (
+	HealthEventReplicaDown         HealthEventType = iota + 1 // A replica failed its check
+	HealthEventReplicaUp                                      // A failed replica passed its check again
+	HealthEventReplicaLagging                                 // A replica lags more than the configured maximum
+	HealthEventReplicaCaughtUp                                // A lagging replica is back within the maximum
+	HealthEventPrimaryDown                                    // A primary failed its check
+	HealthEventPrimaryUp                                      // A failed primary passed its check again
+	HealthEventReplicaEvicted                                 // An unhealthy replica was removed from the read pool
+	HealthEventReplicaDemoted                                 // A replica exceeded the error ratio, see WithErrorRatioDemotion
+	HealthEventRoleMismatch                                   // A primary is in recovery or a replica isn't, see WithRoleVerification
+	HealthEventRoleRestored                                   // A database is back in its configured role
+	HealthEventReplicaDelayed                                 // A replica with delayed replication was excluded from routing
+	HealthEventReplicaDelayCleared                            // A replica no longer delays its replication
+)
+
+// HealthEvent is sent to the health callback on every state transition
+type HealthEvent struct {
+	Type     HealthEventType
+	DB       string // Name of the database, see WithNamedReplicaDBs
+	Err      error  // Error of the failed check, for the down events
+	LagBytes int64  // Replication lag, for the replica events
+	// ErrorRatio is the ratio of failed queries over the window, for the demotion events
+	ErrorRatio float64
+}
+
+// WithHealthCheck starts a background health monitor checking every database each interval: primaries
+// report their current WAL LSN and replicas their replay LSN, each query bounded by timeout
+// (defaults to 3s). Replica health and lag are reported by DB.GetReplicaStatus.
+func WithHealthCheck(interval, timeout time.Duration) OptionFunc {
+	return func(opt *Option) {
+		opt.HealthCheckInterval = interval
+		opt.HealthCheckTimeout = timeout
+	}
+}
+
+// WithHealthCallback sets the callback receiving the state transitions observed by the health monitor.
+// It is called from the monitor goroutine, one check after the other.
+func WithHealthCallback(onEvent func(HealthEvent)) OptionFunc {
+	return func(opt *Option) {
+		opt.OnHealthEvent = onEvent
+	}
+}
+
+// WithMaxReplicaLag sets the replication lag in bytes above which the health monitor reports a
+// replica as lagging. Zero disables lag events.
+func WithMaxReplicaLag(maxLagBytes int64) OptionFunc {
+	return func(opt *Option) {
+		opt.MaxReplicaLagBytes = maxLagBytes
+	}
+}
+
+// WithUnhealthyReplicaEviction removes a replica from the read pool after failures consecutive failed
+// health checks; it's re-added on its first successful check. Zero, the default, never evicts replicas.
+// Requires WithHealthCheck.
+func WithUnhealthyReplicaEviction(failures int) OptionFunc {
+	return func(opt *Option) {
+		opt.EvictAfterFailures = failures
+	}
+}
+
+// healthMonitor periodically checks the databases and keeps the status of the replicas
+type healthMonitor struct {
+	interval time.Duration
+	timeout  time.Duration
+	maxLag   int64
+	onEvent  func(HealthEvent)
+	// consecutive failures evicting a replica, 0 never evicts
+	evictAfter int
+	// whether the checks also collect the backend params, see WithBackendParams
+	backendParams bool
+	// whether the checks detect the delayed replicas, see WithDelayedReplicaDetection
+	detectDelayed bool
+	// collects the stats of the pools fronting the replicas, see WithPoolerStats
+	pooler PoolerStatsCollector
+
+	mu        sync.RWMutex
+	replicas  map[*sql.DB]*replicaHealth
+	evicted   int
+	primaries map[*sql.DB]*PrimaryStatus
+}
+
+// replicaHealth is the status of a replica and the state its transitions are computed from
+type replicaHealth struct {
+	status   ReplicaStatus
+	failures int // consecutive failed checks
+	lagging  bool
+}
+
+func newHealthMonitor(opt *Option) *healthMonitor {
+	timeout := opt.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	return &healthMonitor{
+		interval:      opt.HealthCheckInterval,
+		evictAfter:    opt.EvictAfterFailures,
+		backendParams: opt.BackendParams,
+		detectDelayed: opt.DetectDelayedReplicas,
+		pooler:        opt.PoolerStats,
+		timeout:       timeout,
+		maxLag:        opt.MaxReplicaLagBytes,
+		onEvent:       opt.OnHealthEvent,
+		replicas:      make(map[*sql.DB]*replicaHealth),
+		primaries:     make(map[*sql.DB]*PrimaryStatus),
+	}
+}
+
+// runHealthCheck checks the databases right away, then every interval
+func (db *DB) runHealthCheck(stop <-chan struct{}) {
+	ticker := time.NewTicker(db.health.interval)
+	defer ticker.Stop()
+
+	for {
+		db.checkHealth()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth checks every primary, then every replica against the highest primary LSN
+func (db *DB) checkHealth() {
+	h := db.health
+	var masterLSN LSN
+	for _, primary := range db.allPrimaries() {
+		lsn, err := db.checkDB(primary, true)
+		if err == nil && lsn.GreaterThan(masterLSN) {
+			masterLSN = lsn
+		}
+		db.updatePrimaryHealth(primary, err)
+	}
+
+	routed := db.allReplicas()
+	replicas := append(append([]*sql.DB(nil), routed...), db.resourceClassReplicaDBs()...)
+	pools := db.collectPoolerStats()
+	for i, replica := range replicas {
+		name := physicalDBName(db, replica)
+		lsn, err := db.checkDB(replica, false)
+		if err == nil && i < len(routed) {
+			db.observeReplay(lsn)
+			if h.detectDelayed {
+				db.detectDelay(replica)
+			}
+		}
+		var lag int64
+		if err == nil && masterLSN.GreaterThan(lsn) {
+			lag = int64(masterLSN.Subtract(lsn))
+		}
+		for _, event := range h.updateReplica(replica, name, lsn, lag, err) {
+			h.notify(event)
+			if event.Type == HealthEventReplicaUp {
+				db.warmUp(replica)
+			}
+			if event.Type == HealthEventReplicaEvicted {
+				now := time.Now()
+				db.diagnoseReplica(replica, EjectedByHealthCheck, now, []ReplicaError{{At: now, Err: event.Err}})
+			}
+		}
+		if err == nil {
+			h.setReplicaParams(replica, db.checkBackendParams(replica))
+		}
+		if stats, ok := pools[name]; ok {
+			h.setReplicaPooler(replica, stats)
+		}
+	}
+}
+
+// checkDB queries the current WAL LSN of a primary or the replay LSN of a replica
+func (db *DB) checkDB(target *sql.DB, primary bool) (LSN, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), db.health.timeout)
+	defer cancel()
+
+	checker := getOrCreateChecker(target, db.health.timeout)
+	if primary {
+		return checker.GetCurrentWALLSN(ctx)
+	}
+	return checker.GetLastReplayLSN(ctx)
+}
+
+// updatePrimaryHealth records the outcome of a primary check, with its backend params, and reports its transition
+func (db *DB) updatePrimaryHealth(primary *sql.DB, err error) {
+	h := db.health
+	var params *BackendParams
+	if err == nil {
+		params = db.checkBackendParams(primary)
+	}
+
+	name := physicalDBName(db, primary)
+
+	h.mu.Lock()
+	status, ok := h.primaries[primary]
+	if !ok {
+		status = &PrimaryStatus{Name: name, IsHealthy: true}
+		h.primaries[primary] = status
+	}
+	wasDown := !status.IsHealthy
+	status.IsHealthy = err == nil
+	status.LastCheck = time.Now()
+	status.LastError = err
+	if params != nil {
+		status.Params = params
+	}
+	h.mu.Unlock()
+
+	switch {
+	case err != nil && !wasDown:
+		h.notify(HealthEvent{Type: HealthEventPrimaryDown, DB: name, Err: err})
+	case err == nil && wasDown:
+		h.notify(HealthEvent{Type: HealthEventPrimaryUp, DB: name})
+	}
+}
+
+// updateReplica records the outcome of a replica check and returns the transitions it caused
+func (h *healthMonitor) updateReplica(replica *sql.DB, name string, lsn LSN, lag int64, err error) []HealthEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.replicas[replica]
+	if !ok {
+		r = &replicaHealth{status: ReplicaStatus{Name: name, IsHealthy: true}}
+		h.replicas[replica] = r
+	}
+	wasHealthy := r.status.IsHealthy
+	r.status.LastCheck = time.Now()
+
+	var events []HealthEvent
+	if err != nil {
+		r.status.IsHealthy = false
+		r.status.ErrorCount++
+		r.status.LastError = err
+		r.failures++
+		if wasHealthy {
+			events = append(events, HealthEvent{Type: HealthEventReplicaDown, DB: name, Err: err})
+		}
+		if h.evictAfter > 0 && r.failures >= h.evictAfter && !r.status.Evicted {
+			r.status.Evicted = true
+			h.evicted++
+			events = append(events, HealthEvent{Type: HealthEventReplicaEvicted, DB: name, Err: err})
+		}
+		return events
+	}
+
+	r.failures = 0
+	if r.status.Evicted {
+		r.status.Evicted = false
+		h.evicted--
+	}
+	r.status.IsHealthy = true
+	r.status.LastError = nil
+	r.status.LastLSN = &lsn
+	r.status.LagBytes = lag
+	if !wasHealthy {
+		events = append(events, HealthEvent{Type: HealthEventReplicaUp, DB: name, LagBytes: lag})
+	}
+	if h.maxLag > 0 {
+		lagging := lag > h.maxLag
+		switch {
+		case lagging && !r.lagging:
+			events = append(events, HealthEvent{Type: HealthEventReplicaLagging, DB: name, LagBytes: lag})
+		case !lagging && r.lagging:
+			events = append(events, HealthEvent{Type: HealthEventReplicaCaughtUp, DB: name, LagBytes: lag})
+		}
+		r.lagging = lagging
+	}
+	return events
+}
+
+// setReplicaParams records the backend params of a replica, keeping the previous ones when nil
+func (h *healthMonitor) setReplicaParams(replica *sql.DB, params *BackendParams) {
+	if params == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r, ok := h.replicas[replica]; ok {
+		r.status.Params = params
+	}
+}
+
+// available returns the replicas that aren't evicted
+func (h *healthMonitor) available(replicas []*sql.DB) []*sql.DB {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.evicted == 0 {
+		return replicas
+	}
+	healthy := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if r, ok := h.replicas[replica]; ok && r.status.Evicted {
+			continue
+		}
+		healthy = append(healthy, replica)
+	}
+	return healthy
+}
+
+// forget drops the status of a replica removed from the resolver
+func (h *healthMonitor) forget(replica *sql.DB) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r, ok := h.replicas[replica]; ok && r.status.Evicted {
+		h.evicted--
+	}
+	delete(h.replicas, replica)
+}
+
+// isPrimaryDown reports whether a primary failed its last check
+func (h *healthMonitor) isPrimaryDown(primary *sql.DB) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.primaries[primary]
+	return ok && !status.IsHealthy
+}
+
+// forgetPrimary drops the status of a primary replaced with SetPrimary
+func (h *healthMonitor) forgetPrimary(primary *sql.DB) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.primaries, primary)
+}
+
+func (h *healthMonitor) notify(event HealthEvent) {
+	if h.onEvent != nil {
+		h.onEvent(event)
+	}
+}
+
+// GetReplicaStatus returns the status of every replica maintained by the health monitor, in the
+// order they were passed to New or added followed by the resource class replicas.
+// It returns nil when the health monitor isn't enabled with WithHealthCheck.
+func (db *DB) GetReplicaStatus() []*ReplicaStatus {
+	if db.health == nil {
+		return nil
+	}
+	replicas := append(append([]*sql.DB(nil), db.allReplicas()...), db.resourceClassReplicaDBs()...)
+	// named and flagged before locking the monitor, naming may look the replicas up
+	statuses := make([]*ReplicaStatus, len(replicas))
+	for i, replica := range replicas {
+		statuses[i] = &ReplicaStatus{Name: physicalDBName(db, replica)}
+	}
+
+	db.health.mu.RLock()
+	for i, replica := range replicas {
+		if r, ok := db.health.replicas[replica]; ok {
+			*statuses[i] = r.status
+		}
+	}
+	db.health.mu.RUnlock()
+
+	for i, replica := range replicas {
+		if delayed, ok := db.delay.get(replica); ok {
+			statuses[i].Delayed, statuses[i].ApplyDelay = true, delayed.applyDelay
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,215 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectCurrentWALLSN(mock sqlmock.Sqlmock, lsn string) {
+	mock.ExpectQuery("SELECT pg_current_wal_lsn()").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow(lsn))
+}
+
+// newHealthDB returns a DB whose health checks are run by the test with checkHealth
+func newHealthDB(t *testing.T, opts ...OptionFunc) (*DB, sqlmock.Sqlmock, sqlmock.Sqlmock, *[]HealthEvent) {
+	t.Helper()
+
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	var events []HealthEvent
+	opt := defaultOption()
+	opt.OnHealthEvent = func(e HealthEvent) { events = append(events, e) }
+	for _, fn := range opts {
+		fn(opt)
+	}
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	db.health = newHealthMonitor(opt)
+	return db, primaryMock, replicaMock, &events
+}
+
+func TestHealthCheckReplicaStatus(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t)
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/2FFFF00")
+
+	db.checkHealth()
+
+	statuses := db.GetReplicaStatus()
+	if len(statuses) != 1 {
+		t.Fatalf("want 1 replica status, got %d", len(statuses))
+	}
+	status := statuses[0]
+	if !status.IsHealthy || status.LagBytes != 0x100 || status.LastLSN == nil || status.LastLSN.String() != "0/2FFFF00" {
+		t.Errorf("unexpected replica status %+v", status)
+	}
+	if status.Name != "replica-0" || status.LastCheck.IsZero() {
+		t.Errorf("want a checked replica-0 status, got %+v", status)
+	}
+	if len(*events) != 0 {
+		t.Errorf("healthy databases should not fire events, got %+v", *events)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHealthCheckReplicaTransitions(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t, WithMaxReplicaLag(0x1000))
+	replicaErr := errors.New("connection refused")
+
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").WillReturnError(replicaErr)
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").WillReturnError(replicaErr)
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/2000000")
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+	db.checkHealth()
+	if status := db.GetReplicaStatus()[0]; status.IsHealthy || status.ErrorCount != 2 || !errors.Is(status.LastError, replicaErr) {
+		t.Errorf("want an unhealthy replica with 2 errors, got %+v", status)
+	}
+	db.checkHealth()
+	db.checkHealth()
+
+	want := []HealthEventType{HealthEventReplicaDown, HealthEventReplicaUp, HealthEventReplicaLagging, HealthEventReplicaCaughtUp}
+	if len(*events) != len(want) {
+		t.Fatalf("want events %v, got %+v", want, *events)
+	}
+	for i, e := range *events {
+		if e.Type != want[i] || e.DB != "replica-0" {
+			t.Errorf("event %d: want %v on replica-0, got %+v", i, want[i], e)
+		}
+	}
+	if lag := (*events)[2].LagBytes; lag != 0x1000000 {
+		t.Errorf("want lagging event with 0x1000000 bytes, got %#x", lag)
+	}
+	if !errors.Is((*events)[0].Err, replicaErr) {
+		t.Errorf("want the replica error in the down event, got %v", (*events)[0].Err)
+	}
+}
+
+func TestHealthCheckPrimaryTransitions(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t)
+
+	primaryMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(errors.New("timeout"))
+	expectReplayLSN(replicaMock, "0/3000000")
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+	db.checkHealth()
+
+	if len(*events) != 2 || (*events)[0].Type != HealthEventPrimaryDown || (*events)[1].Type != HealthEventPrimaryUp {
+		t.Fatalf("want primary down then up events, got %+v", *events)
+	}
+	if (*events)[0].DB != "primary-0" || (*events)[0].Err == nil {
+		t.Errorf("want the primary-0 error in the down event, got %+v", (*events)[0])
+	}
+}
+
+func TestHealthCheckBackground(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithHealthCheck(time.Hour, time.Second),
+	)
+	waitForExpectations(t, replicaMock)
+	db.stopBackground()
+
+	if status := db.GetReplicaStatus()[0]; !status.IsHealthy {
+		t.Errorf("want a healthy replica after the first check, got %+v", status)
+	}
+}
+
+func TestGetReplicaStatusDisabled(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+
+	if statuses := db.GetReplicaStatus(); statuses != nil {
+		t.Errorf("want nil statuses without health checks, got %+v", statuses)
+	}
+}
+
+func TestHealthCheckEvictsUnhealthyReplica(t *testing.T) {
+	db, primaryMock, replicaMock, events := newHealthDB(t, WithUnhealthyReplicaEviction(2))
+	replica := db.replicas[0]
+	replicaErr := errors.New("connection refused")
+
+	for range 2 {
+		expectCurrentWALLSN(primaryMock, "0/3000000")
+		replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").WillReturnError(replicaErr)
+	}
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+	if len(db.ReplicaDBs()) != 1 {
+		t.Fatal("replica should stay in the read pool until the eviction threshold")
+	}
+	db.checkHealth()
+	if len(db.ReplicaDBs()) != 0 {
+		t.Fatal("replica should be evicted after 2 failed checks")
+	}
+	if got := db.ReadOnly(); got != db.primaries[0] {
+		t.Error("reads should fall back to the primary when every replica is evicted")
+	}
+	if status := db.GetReplicaStatus()[0]; !status.Evicted {
+		t.Errorf("want an evicted replica status, got %+v", status)
+	}
+
+	db.checkHealth()
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != replica {
+		t.Fatal("recovered replica should be re-added to the read pool")
+	}
+
+	want := []HealthEventType{HealthEventReplicaDown, HealthEventReplicaEvicted, HealthEventReplicaUp}
+	if len(*events) != len(want) {
+		t.Fatalf("want events %v, got %+v", want, *events)
+	}
+	for i, e := range *events {
+		if e.Type != want[i] {
+			t.Errorf("event %d: want %v, got %+v", i, want[i], e)
+		}
+	}
+}
+
+func TestHealthCheckEvictedReplicaPreparedStatements(t *testing.T) {
+	db, primaryMock, replicaMock, _ := newHealthDB(t, WithUnhealthyReplicaEviction(1))
+	db.stmtLoadBalancer = firstLoadBalancer[*sql.Stmt]{}
+
+	primaryMock.ExpectPrepare("SELECT 1")
+	replicaMock.ExpectPrepare("SELECT 1")
+	st, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").WillReturnError(errors.New("connection refused"))
+	db.checkHealth()
+
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := st.QueryRow().Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package dbresolver
 
 import (
 	"net"
+	"strings"
 	"sync"
 
 	"go.uber.org/multierr"
@@ -43,3 +44,8 @@ func isDBConnectionError(err error) bool {
 	}
 	return false
 }
+
+// quoteIdentifier quotes name as an SQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
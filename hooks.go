@@ -0,0 +1,84 @@
+package dbresolver
+
+import (
+	"database/sql"
+)
+
+// ReasonLSNTrackingFailed is the FallbackEvent reason reported when the LSN of a write couldn't be
+// captured, so the following reads of the request aren't guaranteed to observe it
+const ReasonLSNTrackingFailed = "lsn_tracking_failed"
+
+// RouteDecision describes the database chosen for a query
+type RouteDecision struct {
+	QueryType   QueryType
+	DB          *sql.DB
+	Target      string // "primary-<index>" or "replica-<index>"
+	Reason      string // Why the target was chosen, see the Reason constants
+	RequiredLSN LSN    // LSN the read must observe, zero without a requirement
+}
+
+// FallbackEvent describes a read served by a primary instead of a replica,
+// or a write whose LSN couldn't be tracked
+type FallbackEvent struct {
+	QueryType   QueryType
+	Reason      string // ReasonReplicaLagging, ReasonNoReplicas, ReasonReplicasUnavailable, ...
+	RequiredLSN LSN
+	Err         error // Cause of the fallback, if any
+}
+
+// routingHooks are the user callbacks set with WithRoutingHooks. Methods are nil-receiver safe.
+type routingHooks struct {
+	onRoute     func(RouteDecision)
+	onFallback  func(FallbackEvent)
+	onLSNUpdate func(LSN)
+	decisions   *decisionLog // records every decision, see WithDecisionLog
+	// counts the fallbacks, see WithFallbackRatio
+	fallbackRatio *fallbackRatio
+}
+
+// WithRoutingHooks registers callbacks, e.g. to emit custom metrics or audit logs:
+// onRoute for every routing decision of the causal router and of replica outage degradation,
+// onFallback when a read falls back to the primary or LSN tracking of a write fails,
+// onLSNUpdate with the LSN captured after every write. Any of them may be nil.
+// Hooks run synchronously on the query path and must be fast.
+func WithRoutingHooks(onRoute func(RouteDecision), onFallback func(FallbackEvent), onLSNUpdate func(LSN)) OptionFunc {
+	return func(opt *Option) {
+		opt.RoutingHooks = &routingHooks{onRoute: onRoute, onFallback: onFallback, onLSNUpdate: onLSNUpdate}
+	}
+}
+
+// isFallbackReason reports whether a read routed for reason went to the primary by necessity
+func isFallbackReason(reason string) bool {
+	switch reason {
+	case ReasonReplicaLagging, ReasonNoReplicas, ReasonDefaultFallback, ReasonReplicasUnavailable, ReasonResourceClassFallback:
+		return true
+	}
+	return false
+}
+
+// route reports a routing decision, and the fallback it implies
+func (h *routingHooks) route(decision RouteDecision) {
+	if h == nil {
+		return
+	}
+	h.decisions.record(decision)
+	if h.onRoute != nil {
+		h.onRoute(decision)
+	}
+	if decision.QueryType != QueryTypeWrite && isFallbackReason(decision.Reason) {
+		h.fallbackRatio.fallback()
+		h.fallback(FallbackEvent{QueryType: decision.QueryType, Reason: decision.Reason, RequiredLSN: decision.RequiredLSN})
+	}
+}
+
+func (h *routingHooks) fallback(event FallbackEvent) {
+	if h != nil && h.onFallback != nil {
+		h.onFallback(event)
+	}
+}
+
+func (h *routingHooks) lsnUpdate(lsn LSN) {
+	if h != nil && h.onLSNUpdate != nil {
+		h.onLSNUpdate(lsn)
+	}
+}
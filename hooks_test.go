@@ -0,0 +1,95 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRoutingHooks(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	var (
+		decisions []RouteDecision
+		fallbacks []FallbackEvent
+		updates   []LSN
+	)
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            ReadYourWrites,
+			FallbackToMaster: true,
+		}),
+		WithRoutingHooks(
+			func(d RouteDecision) { decisions = append(decisions, d) },
+			func(e FallbackEvent) { fallbacks = append(fallbacks, e) },
+			func(lsn LSN) { updates = append(updates, lsn) },
+		),
+	)
+
+	// a write followed by a read the lagging replica can't serve
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+	db.DbSelector(ctx, QueryTypeWrite)
+	primaryMock.ExpectQuery("pg_current_wal_lsn").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	if _, err := db.queryRouter.UpdateLSNAfterWrite(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lsnCtx.ForceMaster = false
+	expectReplayLSN(replicaMock, "0/4000")
+	db.DbSelector(ctx, QueryTypeRead)
+
+	if len(decisions) != 2 || decisions[0].Reason != ReasonWrite || decisions[1].Target != "primary-0" {
+		t.Fatalf("unexpected route decisions: %+v", decisions)
+	}
+	if len(updates) != 1 || updates[0] != (LSN{Lower: 0x5000}) {
+		t.Errorf("unexpected LSN updates: %v", updates)
+	}
+	if len(fallbacks) != 1 || fallbacks[0].Reason != ReasonReplicaLagging || fallbacks[0].RequiredLSN != (LSN{Lower: 0x5000}) {
+		t.Fatalf("expected a replica lagging fallback, got %+v", fallbacks)
+	}
+
+	// failures to track the LSN of a write are reported instead of being swallowed
+	db.DbSelector(ctx, QueryTypeWrite)
+	primaryMock.ExpectQuery("pg_current_wal_lsn").WillReturnError(errors.New("connection lost"))
+	if _, err := db.queryRouter.UpdateLSNAfterWrite(ctx); err == nil {
+		t.Fatal("expected the LSN query error")
+	}
+	if len(fallbacks) != 2 || fallbacks[1].Reason != ReasonLSNTrackingFailed || fallbacks[1].Err == nil {
+		t.Errorf("expected an LSN tracking failure, got %+v", fallbacks)
+	}
+}
+
+func TestRoutingHooksOutageDegradation(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	var fallbacks []FallbackEvent
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{Policy: OutageDegradeSilently}),
+		WithRoutingHooks(nil, func(e FallbackEvent) { fallbacks = append(fallbacks, e) }, nil),
+	)
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("reset")})
+	_, _ = db.Query("SELECT 1")
+
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	if len(fallbacks) != 1 || fallbacks[0].Reason != ReasonReplicasUnavailable {
+		t.Errorf("expected a replicas unavailable fallback, got %+v", fallbacks)
+	}
+}
@@ -0,0 +1,406 @@
+// Package k8sendpoints reconciles the replicas of a dbresolver.DB with the ready endpoints of a Kubernetes
+// Service, e.g. the headless Service of a standby StatefulSet, as standby pods come and go.
+//
+// The EndpointSlices of the Service are listed, then watched, through the Kubernetes API. One pool is opened
+// per ready endpoint and added with DB.AddReplica; the pools of the endpoints that stop being ready or go away
+// are removed with DB.RemoveReplica and closed. Only the standard library is used: by default the watcher
+// authenticates with the service account of the pod, which needs the permission to list and watch
+// endpointslices.discovery.k8s.io in the namespace of the Service.
+package k8sendpoints
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// Service account files mounted in every pod
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	tokenFile         = serviceAccountDir + "token"
+	caFile            = serviceAccountDir + "ca.crt"
+	namespaceFile     = serviceAccountDir + "namespace"
+)
+
+const (
+	// DefaultRetryInterval is the wait before listing the endpoints again after a failed list or watch
+	DefaultRetryInterval = 5 * time.Second
+	// watchTimeoutSeconds makes the API server end the watches periodically, so they're renewed
+	watchTimeoutSeconds = 300
+)
+
+// errWatchExpired reports a watch whose resource version is too old, which requires listing the endpoints again
+var errWatchExpired = errors.New("watch expired")
+
+// OpenFunc opens the connection pool of the replica listening on address, a "host:port" pair
+type OpenFunc func(address string) (*sql.DB, error)
+
+// Watcher keeps the replicas of a DB in sync with the ready endpoints of a Service
+type Watcher struct {
+	db            *dbresolver.DB
+	service       string
+	open          OpenFunc
+	namespace     string
+	portName      string
+	apiServer     string
+	client        *http.Client
+	token         func() (string, error)
+	retryInterval time.Duration
+	logger        *slog.Logger
+
+	// slices are only used by the Run goroutine
+	slices map[string]endpointSlice
+
+	mu    sync.Mutex
+	pools map[string]*sql.DB // address -> pool
+}
+
+// Option configures a Watcher
+type Option func(w *Watcher)
+
+// WithNamespace sets the namespace of the Service, the namespace of the pod by default
+func WithNamespace(namespace string) Option {
+	return func(w *Watcher) {
+		w.namespace = namespace
+	}
+}
+
+// WithPortName selects the endpoint port by name, the first port of the EndpointSlices by default
+func WithPortName(name string) Option {
+	return func(w *Watcher) {
+		w.portName = name
+	}
+}
+
+// WithAPIServer sets the Kubernetes API server and the client reaching it, instead of the in-cluster ones.
+// token returns the bearer token of each request; nil sends none.
+func WithAPIServer(apiServer string, client *http.Client, token func() (string, error)) Option {
+	return func(w *Watcher) {
+		w.apiServer = strings.TrimSuffix(apiServer, "/")
+		w.client = client
+		w.token = token
+	}
+}
+
+// WithRetryInterval sets the wait before listing the endpoints again after a failure, defaults to DefaultRetryInterval
+func WithRetryInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		if interval > 0 {
+			w.retryInterval = interval
+		}
+	}
+}
+
+// WithLogger sets the logger used to report watch failures, slog.Default() otherwise
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// NewWatcher creates a watcher reconciling the replicas of db with the ready endpoints of service, opening the
+// pool of each endpoint with open. It fails when the in-cluster configuration is needed and unavailable.
+func NewWatcher(db *dbresolver.DB, service string, open OpenFunc, opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		db:            db,
+		service:       service,
+		open:          open,
+		retryInterval: DefaultRetryInterval,
+		slices:        make(map[string]endpointSlice),
+		pools:         make(map[string]*sql.DB),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.namespace == "" {
+		namespace, err := os.ReadFile(namespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the namespace of the pod, set it with WithNamespace: %w", err)
+		}
+		w.namespace = strings.TrimSpace(string(namespace))
+	}
+	if w.apiServer == "" {
+		if err := w.inClusterConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if w.client == nil {
+		w.client = http.DefaultClient
+	}
+	if w.logger == nil {
+		w.logger = slog.Default()
+	}
+	return w, nil
+}
+
+// inClusterConfig configures the API server of the cluster, authenticated with the service account of the pod
+func (w *Watcher) inClusterConfig() error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return fmt.Errorf("not running in a Kubernetes cluster, set the API server with WithAPIServer")
+	}
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("invalid cluster CA in %s", caFile)
+	}
+
+	w.apiServer = "https://" + net.JoinHostPort(host, port)
+	w.client = &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}}
+	// the token is rotated by the kubelet, so it's read again for each request
+	w.token = func() (string, error) {
+		token, err := os.ReadFile(tokenFile)
+		return strings.TrimSpace(string(token)), err
+	}
+	return nil
+}
+
+// Addresses returns the addresses of the replicas currently added by the watcher, sorted
+func (w *Watcher) Addresses() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	addresses := make([]string, 0, len(w.pools))
+	for address := range w.pools {
+		addresses = append(addresses, address)
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+// Run lists and watches the endpoints until ctx is done, reconciling the replicas after every change.
+// Failures are logged and retried after the retry interval, keeping the current replicas meanwhile.
+// The replicas added by the watcher are left in the DB when Run returns, and closed by DB.Close.
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		resourceVersion, err := w.list(ctx)
+		for err == nil {
+			err = w.watch(ctx, &resourceVersion)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, errWatchExpired) {
+			w.logger.Warn("k8sendpoints: failed to watch the endpoints, retrying", "service", w.service, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(w.retryInterval):
+			}
+		}
+	}
+}
+
+// list replaces the known EndpointSlices with the current ones and returns the resource version to watch from
+func (w *Watcher) list(ctx context.Context) (string, error) {
+	resp, err := w.get(ctx, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []endpointSlice `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("failed to decode the EndpointSlices: %w", err)
+	}
+
+	w.slices = make(map[string]endpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		w.slices[slice.Metadata.Name] = slice
+	}
+	w.reconcile()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch applies the changes of the EndpointSlices from resourceVersion until the watch ends, advancing
+// resourceVersion to the last version seen
+func (w *Watcher) watch(ctx context.Context, resourceVersion *string) error {
+	resp, err := w.get(ctx, url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {*resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(watchTimeoutSeconds)},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil // ended by the API server, renewed from the last resource version
+			}
+			return fmt.Errorf("failed to decode the watch event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			// most likely 410 Gone: the resource version is too old
+			return errWatchExpired
+		}
+
+		var slice endpointSlice
+		if err := json.Unmarshal(event.Object, &slice); err != nil {
+			return fmt.Errorf("failed to decode the EndpointSlice: %w", err)
+		}
+		*resourceVersion = slice.Metadata.ResourceVersion
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			w.slices[slice.Metadata.Name] = slice
+		case "DELETED":
+			delete(w.slices, slice.Metadata.Name)
+		default: // BOOKMARK
+			continue
+		}
+		w.reconcile()
+	}
+}
+
+// get requests the EndpointSlices of the Service
+func (w *Watcher) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	query.Set("labelSelector", "kubernetes.io/service-name="+w.service)
+	endpoint := w.apiServer + "/apis/discovery.k8s.io/v1/namespaces/" + url.PathEscape(w.namespace) +
+		"/endpointslices?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if w.token != nil {
+		token, err := w.token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the API token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, errWatchExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s listing the EndpointSlices", resp.Status)
+	}
+	return resp, nil
+}
+
+// reconcile adds the replicas of the new ready endpoints and removes those of the endpoints gone
+func (w *Watcher) reconcile() {
+	addresses := readyAddresses(w.slices, w.portName)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for address, pool := range w.pools {
+		if slices.Contains(addresses, address) {
+			continue
+		}
+		if err := w.db.RemoveReplica(pool); err != nil {
+			w.logger.Warn("k8sendpoints: failed to remove replica", "address", address, "error", err)
+		}
+		delete(w.pools, address)
+		_ = pool.Close()
+	}
+	for _, address := range addresses {
+		if _, ok := w.pools[address]; ok {
+			continue
+		}
+		if err := w.add(address); err != nil {
+			w.logger.Warn("k8sendpoints: failed to add replica", "address", address, "error", err)
+		}
+	}
+}
+
+func (w *Watcher) add(address string) error {
+	pool, err := w.open(address)
+	if err != nil {
+		return fmt.Errorf("failed to open replica: %w", err)
+	}
+	if err := w.db.AddReplica(pool); err != nil {
+		_ = pool.Close()
+		return err
+	}
+	w.pools[address] = pool
+	return nil
+}
+
+// endpointSlice is the subset of a discovery.k8s.io/v1 EndpointSlice the watcher uses
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port *int32 `json:"port"`
+	} `json:"ports"`
+}
+
+// readyAddresses returns the sorted, deduplicated "host:port" addresses of the ready endpoints of slices
+func readyAddresses(endpointSlices map[string]endpointSlice, portName string) []string {
+	var addresses []string
+	for _, slice := range endpointSlices {
+		port := -1
+		for _, p := range slice.Ports {
+			if p.Port != nil && (portName == "" || p.Name == portName) {
+				port = int(*p.Port)
+				break
+			}
+		}
+		if port < 0 {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			// a nil ready condition means ready
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				addresses = append(addresses, net.JoinHostPort(address, strconv.Itoa(port)))
+			}
+		}
+	}
+	slices.Sort(addresses)
+	return slices.Compact(addresses)
+}
@@ -0,0 +1,200 @@
+package k8sendpoints
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func sliceJSON(name, resourceVersion string, ready map[string]bool) string {
+	endpoints := make([]map[string]any, 0, len(ready))
+	for address, isReady := range ready {
+		endpoints = append(endpoints, map[string]any{
+			"addresses":  []string{address},
+			"conditions": map[string]any{"ready": isReady},
+		})
+	}
+	b, _ := json.Marshal(map[string]any{
+		"metadata":  map[string]any{"name": name, "resourceVersion": resourceVersion},
+		"endpoints": endpoints,
+		"ports":     []map[string]any{{"name": "metrics", "port": 9187}, {"name": "postgres", "port": 5432}},
+	})
+	return string(b)
+}
+
+// fakeAPIServer serves the list of EndpointSlices, then streams the watch events sent on events
+func fakeAPIServer(t *testing.T, list string, events <-chan string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/db/endpointslices" ||
+			r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=pg-standby" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("want the bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(w, list)
+			return
+		}
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				fmt.Fprintln(w, event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestWatcher(t *testing.T, server *httptest.Server, opened *atomic.Int32) (*Watcher, *dbresolver.DB) {
+	t.Helper()
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbresolver.New(dbresolver.WithPrimaryDBs(primary))
+	t.Cleanup(func() { _ = db.Close() })
+
+	open := func(string) (*sql.DB, error) {
+		opened.Add(1)
+		replica, _, err := sqlmock.New()
+		return replica, err
+	}
+	w, err := NewWatcher(db, "pg-standby", open,
+		WithNamespace("db"),
+		WithPortName("postgres"),
+		WithAPIServer(server.URL, server.Client(), func() (string, error) { return "secret", nil }),
+		WithRetryInterval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, db
+}
+
+func waitAddresses(t *testing.T, w *Watcher, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.Equal(w.Addresses(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("want replicas %v, got %v", want, w.Addresses())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatcherReconcilesReplicas(t *testing.T) {
+	events := make(chan string)
+	list := `{"metadata":{"resourceVersion":"100"},"items":[` +
+		sliceJSON("pg-standby-a", "90", map[string]bool{"10.0.0.1": true, "10.0.0.2": false}) + `]}`
+	server := fakeAPIServer(t, list, events)
+
+	var opened atomic.Int32
+	w, db := newTestWatcher(t, server, &opened)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	waitAddresses(t, w, "10.0.0.1:5432")
+	if len(db.ReplicaDBs()) != 1 {
+		t.Errorf("want the ready endpoint added as a replica, got %d replicas", len(db.ReplicaDBs()))
+	}
+
+	events <- `{"type":"MODIFIED","object":` +
+		sliceJSON("pg-standby-a", "101", map[string]bool{"10.0.0.1": true, "10.0.0.2": true}) + `}`
+	events <- `{"type":"ADDED","object":` + sliceJSON("pg-standby-b", "102", map[string]bool{"10.0.0.3": true}) + `}`
+	waitAddresses(t, w, "10.0.0.1:5432", "10.0.0.2:5432", "10.0.0.3:5432")
+
+	events <- `{"type":"DELETED","object":` + sliceJSON("pg-standby-a", "103", nil) + `}`
+	waitAddresses(t, w, "10.0.0.3:5432")
+	if len(db.ReplicaDBs()) != 1 {
+		t.Errorf("want the endpoints gone removed from the replicas, got %d replicas", len(db.ReplicaDBs()))
+	}
+	if opened.Load() != 3 {
+		t.Errorf("want one pool opened per endpoint, got %d", opened.Load())
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want Run to stop with the context, got %v", err)
+	}
+}
+
+func TestWatcherRelistsExpiredWatch(t *testing.T) {
+	events := make(chan string)
+	var lists atomic.Int32
+	list := `{"metadata":{"resourceVersion":"100"},"items":[` +
+		sliceJSON("pg-standby-a", "90", map[string]bool{"10.0.0.1": true}) + `]}`
+	server := fakeAPIServer(t, list, events)
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			lists.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	var opened atomic.Int32
+	w, _ := newTestWatcher(t, server, &opened)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = w.Run(ctx) }()
+
+	waitAddresses(t, w, "10.0.0.1:5432")
+	events <- `{"type":"ERROR","object":{"kind":"Status","code":410,"reason":"Expired"}}`
+
+	deadline := time.Now().Add(2 * time.Second)
+	for lists.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("want the endpoints listed again after an expired watch")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if opened.Load() != 1 {
+		t.Errorf("want the replica kept across the relist, got %d pools opened", opened.Load())
+	}
+}
+
+func TestReadyAddresses(t *testing.T) {
+	var slice endpointSlice
+	err := json.Unmarshal([]byte(`{
+		"metadata": {"name": "pg-standby-a"},
+		"endpoints": [
+			{"addresses": ["10.0.0.2"]},
+			{"addresses": ["10.0.0.1"], "conditions": {"ready": true}},
+			{"addresses": ["10.0.0.3"], "conditions": {"ready": false}}
+		],
+		"ports": [{"name": "postgres", "port": 5433}]
+	}`), &slice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var noPort endpointSlice
+	noPort.Metadata.Name = "pg-standby-b"
+	noPort.Endpoints = slice.Endpoints
+
+	got := readyAddresses(map[string]endpointSlice{"a": slice, "b": noPort}, "")
+	if want := []string{"10.0.0.1:5433", "10.0.0.2:5433"}; !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if got := readyAddresses(map[string]endpointSlice{"a": slice}, "replication"); len(got) != 0 {
+		t.Errorf("want no address without the named port, got %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaActivity records the last time each physical DB was selected for a query
+type replicaActivity struct {
+	lastUsed sync.Map // *sql.DB -> *atomic.Int64 (unix nanoseconds)
+}
+
+// touch marks the given DB as used now
+func (a *replicaActivity) touch(db *sql.DB) {
+	if db == nil {
+		return
+	}
+	v, ok := a.lastUsed.Load(db)
+	if !ok {
+		v, _ = a.lastUsed.LoadOrStore(db, &atomic.Int64{})
+	}
+	v.(*atomic.Int64).Store(time.Now().UnixNano())
+}
+
+// forget drops the activity of a replica removed from the resolver
+func (a *replicaActivity) forget(db *sql.DB) {
+	a.lastUsed.Delete(db)
+}
+
+// idleFor returns how long the given DB has not been selected for a query.
+// A DB that has never been used is considered idle since forever.
+func (a *replicaActivity) idleFor(db *sql.DB, now time.Time) time.Duration {
+	v, ok := a.lastUsed.Load(db)
+	if !ok {
+		return time.Duration(math.MaxInt64)
+	}
+	return now.Sub(time.Unix(0, v.(*atomic.Int64).Load()))
+}
+
+// runKeepalive periodically probes replicas that have been idle for at least the keepalive interval.
+// Probes run sequentially so the keepalive traffic stays at a low, predictable rate.
+func (db *DB) runKeepalive(stop <-chan struct{}) {
+	ticker := time.NewTicker(db.keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, replica := range db.ReplicaDBs() {
+				if db.activity.idleFor(replica, now) < db.keepaliveInterval {
+					continue
+				}
+				db.keepaliveReplica(replica)
+			}
+		}
+	}
+}
+
+// keepaliveReplica runs a lightweight query against an idle replica.
+// When causal consistency is enabled the replay LSN is queried, which also refreshes the
+// checker's cached replica LSN; otherwise a plain SELECT 1 is used.
+func (db *DB) keepaliveReplica(replica *sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), db.keepaliveTimeout)
+	defer cancel()
+
+	var err error
+	if db.IsCausalConsistencyEnabled() {
+		_, err = getOrCreateChecker(replica, db.keepaliveTimeout).GetLastReplayLSN(ctx)
+	} else {
+		var one int
+		err = replica.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+	}
+
+	if err != nil {
+		db.log().Warn("keepalive: replica probe failed", "replica", physicalDBName(db, replica), "error", err)
+		return
+	}
+	db.log().Debug("keepalive: replica probe succeeded", "replica", physicalDBName(db, replica))
+}
@@ -0,0 +1,101 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaActivityIdleFor(t *testing.T) {
+	var activity replicaActivity
+	db := &sql.DB{}
+	now := time.Now()
+
+	if idle := activity.idleFor(db, now); idle < time.Hour {
+		t.Errorf("unused DB should be idle forever, got %v", idle)
+	}
+
+	activity.touch(db)
+	if idle := activity.idleFor(db, time.Now()); idle > time.Second {
+		t.Errorf("recently used DB should not be idle, got %v", idle)
+	}
+}
+
+func TestReplicaKeepaliveSelectOne(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating primary mock failed: %s", err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating replica mock failed: %s", err)
+	}
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithReplicaKeepalive(10*time.Millisecond),
+	)
+
+	waitForExpectations(t, replicaMock)
+	_ = db.Close()
+}
+
+func TestReplicaKeepaliveWarmsLSNCache(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating primary mock failed: %s", err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating replica mock failed: %s", err)
+	}
+
+	replicaMock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000060"))
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites}),
+		WithReplicaKeepalive(10*time.Millisecond),
+	)
+
+	waitForExpectations(t, replicaMock)
+	defer db.Close() // purges the checker of the replica
+
+	var (
+		lsn LSN
+		ok  bool
+	)
+	for deadline := time.Now().Add(time.Second); !ok && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		lsn, _, ok = getOrCreateChecker(replica, time.Second).CachedReplayLSN()
+	}
+	if !ok {
+		t.Fatal("expected keepalive to cache the replica replay LSN")
+	}
+	if lsn.String() != "0/3000060" {
+		t.Errorf("want cached LSN 0/3000060, got %s", lsn)
+	}
+}
+
+// waitForExpectations polls the mock until all expectations are met or a deadline passes
+func waitForExpectations(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		err := mock.ExpectationsWereMet()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("mock expectations were not met: %s", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,159 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"math/rand/v2"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultLeakThreshold = 30 * time.Second
+
+// Kinds of the handles tracked by the leak detection
+const (
+	HandleConn = "conn"
+	HandleTx   = "tx"
+)
+
+// HeldHandle describes a Conn or Tx that is checked out and not yet released
+type HeldHandle struct {
+	Kind  string // HandleConn or HandleTx
+	DB    string // Name of the database holding the connection, see WithNamedPrimaryDBs
+	Since time.Time
+	Stack string // Stack trace of the creation of the handle, empty if not sampled
+}
+
+// WithLeakDetection tracks the Conn and Tx handles checked out of the DB and logs a warning, once per
+// handle, when one is held for longer than threshold (defaults to 30s) without being closed, committed
+// or rolled back.
+// The creation stack trace is captured for a fraction stackSampleRate of the handles, from 0 to 1.
+// Held handles are reported by DB.HeldHandles.
+func WithLeakDetection(threshold time.Duration, stackSampleRate float64) OptionFunc {
+	if threshold <= 0 {
+		threshold = defaultLeakThreshold
+	}
+	return func(opt *Option) {
+		opt.LeakDetection = &leakDetector{
+			threshold:  threshold,
+			sampleRate: stackSampleRate,
+			handles:    make(map[*leakHandle]struct{}),
+		}
+	}
+}
+
+// leakDetector tracks the checked out handles. Methods are nil-receiver safe.
+type leakDetector struct {
+	threshold  time.Duration
+	sampleRate float64
+
+	mu      sync.Mutex
+	handles map[*leakHandle]struct{}
+}
+
+// leakHandle is a tracked handle, released when the handle is closed. Methods are nil-receiver safe.
+type leakHandle struct {
+	detector *leakDetector
+	kind     string
+	db       string
+	since    time.Time
+	stack    []byte
+	warned   bool
+}
+
+// track starts tracking a handle holding a connection of the named database
+func (d *leakDetector) track(kind, db string) *leakHandle {
+	if d == nil {
+		return nil
+	}
+	h := &leakHandle{detector: d, kind: kind, db: db, since: time.Now()}
+	if d.sampleRate > 0 && rand.Float64() < d.sampleRate {
+		h.stack = debug.Stack()
+	}
+
+	d.mu.Lock()
+	d.handles[h] = struct{}{}
+	d.mu.Unlock()
+	return h
+}
+
+// release stops tracking the handle, it may be called more than once
+func (h *leakHandle) release() {
+	if h == nil {
+		return
+	}
+	h.detector.mu.Lock()
+	delete(h.detector.handles, h)
+	h.detector.mu.Unlock()
+}
+
+// child starts tracking a handle of the same database, such as a Tx begun on a Conn
+func (h *leakHandle) child(kind string) *leakHandle {
+	if h == nil {
+		return nil
+	}
+	return h.detector.track(kind, h.db)
+}
+
+func (h *leakHandle) report() HeldHandle {
+	return HeldHandle{Kind: h.kind, DB: h.db, Since: h.since, Stack: string(h.stack)}
+}
+
+// leaked returns the handles held beyond the threshold that weren't reported yet, and marks them reported
+func (d *leakDetector) leaked(now time.Time) []HeldHandle {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var leaked []HeldHandle
+	for h := range d.handles {
+		if !h.warned && now.Sub(h.since) >= d.threshold {
+			h.warned = true
+			leaked = append(leaked, h.report())
+		}
+	}
+	return leaked
+}
+
+// runLeakDetection warns about the handles held beyond the threshold, checking every half threshold
+func (db *DB) runLeakDetection(stop <-chan struct{}) {
+	ticker := time.NewTicker(max(db.leaks.threshold/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, h := range db.leaks.leaked(now) {
+				db.log().Warn("leak detection: handle held beyond threshold, it may be leaked",
+					"kind", h.Kind, "db", h.DB, "held", now.Sub(h.Since).Round(time.Millisecond), "stack", h.Stack)
+			}
+		}
+	}
+}
+
+// HeldHandles returns the Conn and Tx handles currently checked out, oldest first.
+// It returns nil when leak detection isn't enabled with WithLeakDetection.
+func (db *DB) HeldHandles() []HeldHandle {
+	if db.leaks == nil {
+		return nil
+	}
+	db.leaks.mu.Lock()
+	held := make([]HeldHandle, 0, len(db.leaks.handles))
+	for h := range db.leaks.handles {
+		held = append(held, h.report())
+	}
+	db.leaks.mu.Unlock()
+
+	slices.SortFunc(held, func(a, b HeldHandle) int { return a.Since.Compare(b.Since) })
+	return held
+}
+
+// trackHandle starts tracking a handle holding a connection of sourceDB, nil without leak detection
+func (db *DB) trackHandle(kind string, sourceDB *sql.DB) *leakHandle {
+	if db.leaks == nil {
+		return nil
+	}
+	return db.leaks.track(kind, physicalDBName(db, sourceDB))
+}
@@ -0,0 +1,122 @@
+package dbresolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeakDetectionTracksHandles(t *testing.T) {
+	primary, mock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithLeakDetection(time.Hour, 1))
+	t.Cleanup(db.stopBackground)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held := db.HeldHandles()
+	if len(held) != 2 || held[0].Kind == held[1].Kind {
+		t.Fatalf("want a held conn and tx, got %+v", held)
+	}
+	for _, h := range held {
+		if h.DB != "primary-0" || !strings.Contains(h.Stack, "TestLeakDetectionTracksHandles") {
+			t.Errorf("want a sampled primary-0 handle with its creation stack, got %+v", h)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if held := db.HeldHandles(); len(held) != 0 {
+		t.Errorf("want no held handle after release, got %+v", held)
+	}
+
+	tx, err = db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.HeldHandles()) != 1 {
+		t.Error("want the tx held until rolled back")
+	}
+	_ = tx.Rollback()
+	_ = tx.Rollback()
+	if len(db.HeldHandles()) != 0 {
+		t.Error("want the tx released by the rollback")
+	}
+}
+
+func TestLeakDetectorReportsOnce(t *testing.T) {
+	d := &leakDetector{threshold: time.Minute, handles: make(map[*leakHandle]struct{})}
+	h := d.track(HandleTx, "replica-0")
+
+	if leaked := d.leaked(time.Now()); len(leaked) != 0 {
+		t.Errorf("fresh handle should not be reported, got %+v", leaked)
+	}
+	later := time.Now().Add(2 * time.Minute)
+	if leaked := d.leaked(later); len(leaked) != 1 || leaked[0].Stack != "" {
+		t.Errorf("want one unsampled leaked handle, got %+v", leaked)
+	}
+	if leaked := d.leaked(later); len(leaked) != 0 {
+		t.Errorf("leaked handle should only be reported once, got %+v", leaked)
+	}
+
+	h.release()
+	if len(d.handles) != 0 {
+		t.Error("released handle should not be tracked")
+	}
+}
+
+func TestLeakDetectionBackgroundWarning(t *testing.T) {
+	primary, mock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithLeakDetection(10*time.Millisecond, 0))
+
+	mock.ExpectBegin()
+	if _, err := db.BeginTx(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		db.leaks.mu.Lock()
+		warned := false
+		for h := range db.leaks.handles {
+			warned = h.warned
+		}
+		db.leaks.mu.Unlock()
+		if warned {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the held tx to be reported as leaked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	db.stopBackground()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeldHandlesDisabled(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	if held := db.HeldHandles(); held != nil {
+		t.Errorf("want nil without leak detection, got %+v", held)
+	}
+}
@@ -0,0 +1,184 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultListenRetryInterval = time.Second
+	unlistenTimeout            = 5 * time.Second
+)
+
+// ErrListenUnsupported is returned by Listen without WithNotifications, database/sql having no notifications API
+var ErrListenUnsupported = errors.New("dbresolver: LISTEN needs a driver-specific NotificationConfig.Wait")
+
+// Notification is a NOTIFY received by a Listener
+type Notification struct {
+	Channel string
+	Payload string
+	PID     uint32 // Process ID of the notifying backend
+}
+
+// WaitForNotificationFunc waits for the next notification received by conn, a connection of the primary
+// listening to channels, until ctx is done
+type WaitForNotificationFunc func(ctx context.Context, conn *sql.Conn) (Notification, error)
+
+// NotificationConfig configures WithNotifications
+type NotificationConfig struct {
+	// Wait receives the notifications of the driver, e.g. pgx.Conn.WaitForNotification through conn.Raw
+	Wait          WaitForNotificationFunc
+	RetryInterval time.Duration // Pause between the attempts to subscribe again, defaults to 1s
+	// OnResubscribe is called once a listener subscribed again after losing its connection, e.g. on a failover.
+	// The notifications sent meanwhile are lost, reload the state they signal.
+	OnResubscribe func(channels []string)
+}
+
+// WithNotifications enables Listen with the driver-specific config.Wait
+func WithNotifications(config NotificationConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.RetryInterval <= 0 {
+			config.RetryInterval = defaultListenRetryInterval
+		}
+		opt.Notifications = &config
+	}
+}
+
+// Listener receives the notifications of channels on the primary, see DB.Listen
+type Listener struct {
+	db            *DB
+	channels      []string
+	notifications chan Notification
+	cancel        context.CancelFunc
+	done          chan struct{}
+}
+
+// Listen subscribes to the notifications of channels with LISTEN on a connection of the primary, since standbys
+// don't deliver them, held until the listener is closed. When the connection is lost, e.g. on a failover, the
+// listener subscribes again on the current primary, see NotificationConfig.OnResubscribe. The listener stops
+// when ctx is done, on Close, or when the resolver is closed.
+func (db *DB) Listen(ctx context.Context, channels ...string) (*Listener, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	if db.notifications == nil {
+		return nil, ErrListenUnsupported
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("dbresolver: no channel to listen to")
+	}
+	conn, err := db.subscribe(ctx, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	l := &Listener{
+		db:            db,
+		channels:      channels,
+		notifications: make(chan Notification),
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	started := db.goBackground(func(stop <-chan struct{}) {
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		l.run(ctx, conn)
+	})
+	if !started {
+		cancel()
+		releaseListenConn(conn, false)
+		return nil, ErrResolverClosed
+	}
+	return l, nil
+}
+
+// Notifications returns the channel of the notifications received, closed once the listener stopped
+func (l *Listener) Notifications() <-chan Notification {
+	return l.notifications
+}
+
+// Close unsubscribes and stops the listener
+func (l *Listener) Close() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
+
+// run delivers the notifications received on conn, subscribing again when it is lost
+func (l *Listener) run(ctx context.Context, conn *sql.Conn) {
+	defer close(l.done)
+	defer close(l.notifications)
+
+	config := l.db.notifications
+	for {
+		notification, err := config.Wait(ctx, conn)
+		if err == nil {
+			select {
+			case l.notifications <- notification:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		releaseListenConn(conn, err == nil)
+		if ctx.Err() != nil {
+			return
+		}
+		l.db.log().Debug("listener: connection lost, subscribing again", "channels", l.channels, "error", err)
+
+		for conn = nil; conn == nil; {
+			timer := time.NewTimer(config.RetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if conn, err = l.db.subscribe(ctx, l.channels); err != nil {
+				l.db.log().Debug("listener: failed to subscribe again", "channels", l.channels, "error", err)
+			}
+		}
+		if config.OnResubscribe != nil {
+			config.OnResubscribe(l.channels)
+		}
+	}
+}
+
+// subscribe runs LISTEN for channels on a connection of the primary
+func (db *DB) subscribe(ctx context.Context, channels []string) (*sql.Conn, error) {
+	conn, err := db.ReadWrite().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if _, err := conn.ExecContext(ctx, "LISTEN "+quoteIdentifier(channel)); err != nil {
+			releaseListenConn(conn, false)
+			return nil, fmt.Errorf("failed to listen to %s: %w", channel, err)
+		}
+	}
+	return conn, nil
+}
+
+// releaseListenConn returns conn to its pool once unsubscribed, or discards it when it may be broken, so no pooled
+// connection keeps listening
+func releaseListenConn(conn *sql.Conn, healthy bool) {
+	if healthy {
+		ctx, cancel := context.WithTimeout(context.Background(), unlistenTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(ctx, "UNLISTEN *"); err == nil {
+			_ = conn.Close()
+			return
+		}
+	}
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
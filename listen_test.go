@@ -0,0 +1,133 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// fakeNotifications feeds the notifications, or the connection losses, of a fake driver
+type fakeNotifications chan error
+
+func (f fakeNotifications) wait(payload string) WaitForNotificationFunc {
+	return func(ctx context.Context, conn *sql.Conn) (Notification, error) {
+		select {
+		case err := <-f:
+			if err != nil {
+				return Notification{}, err
+			}
+			return Notification{Channel: "orders", Payload: payload, PID: 42}, nil
+		case <-ctx.Done():
+			return Notification{}, ctx.Err()
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	feed := make(fakeNotifications)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithNotifications(NotificationConfig{Wait: feed.wait("42")}))
+	defer db.Close()
+
+	primaryMock.ExpectExec(`LISTEN "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	l, err := db.Listen(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed <- nil
+	if n := <-l.Notifications(); n != (Notification{Channel: "orders", Payload: "42", PID: 42}) {
+		t.Errorf("unexpected notification %+v", n)
+	}
+
+	// the connection interrupted waiting is discarded, not pooled while listening
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range l.Notifications() {
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestListenResubscribesOnNewPrimary(t *testing.T) {
+	primaryA, primaryAMock := newMockDB(t)
+	primaryB, primaryBMock := newMockDB(t)
+	feed := make(fakeNotifications)
+	resubscribed := make(chan []string, 1)
+	db := New(WithPrimaryDBs(primaryA), WithNotifications(NotificationConfig{
+		Wait:          feed.wait("after failover"),
+		RetryInterval: time.Millisecond,
+		OnResubscribe: func(channels []string) { resubscribed <- channels },
+	}))
+	defer db.Close()
+
+	primaryAMock.ExpectExec(`LISTEN "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	l, err := db.Listen(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the primary fails over, its connection is lost
+	primaryBMock.ExpectExec(`LISTEN "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := db.SetPrimary(primaryB); err != nil {
+		t.Fatal(err)
+	}
+	feed <- errors.New("connection reset by peer")
+
+	select {
+	case channels := <-resubscribed:
+		if len(channels) != 1 || channels[0] != "orders" {
+			t.Errorf("unexpected channels %v", channels)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listener didn't subscribe again")
+	}
+	feed <- nil
+	if n := <-l.Notifications(); n.Payload != "after failover" {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	if err := primaryBMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListenStopsWithResolver(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	feed := make(fakeNotifications)
+	db := New(WithPrimaryDBs(primary), WithNotifications(NotificationConfig{Wait: feed.wait("")}))
+
+	primaryMock.ExpectExec(`LISTEN "orders"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	l, err := db.Listen(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if _, ok := <-l.Notifications(); ok {
+		t.Error("want the notifications closed with the resolver")
+	}
+	if _, err := db.Listen(context.Background(), "orders"); !errors.Is(err, ErrResolverClosed) {
+		t.Errorf("want ErrResolverClosed, got %v", err)
+	}
+}
+
+func TestListenUnsupported(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+	defer db.Close()
+
+	if _, err := db.Listen(context.Background(), "orders"); !errors.Is(err, ErrListenUnsupported) {
+		t.Errorf("want ErrListenUnsupported, got %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
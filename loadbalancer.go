@@ -2,7 +2,8 @@ package dbresolver
 
 import (
 	"database/sql"
-	"math/rand"
+	"math/rand/v2"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -12,59 +13,40 @@ type DBConnection interface {
 	*sql.DB | *sql.Stmt
 }
 
-// LoadBalancer define the load balancer contract
+// LoadBalancer define the load balancer contract, implemented by the built-in policies and the balancers set
+// with WithCustomLoadBalancer. Resolve is called concurrently with a non-empty slice it must not modify.
 type LoadBalancer[T DBConnection] interface {
 	Resolve([]T) T
 	Name() LoadBalancerPolicy
-	predict(n int) int
 }
 
-// RandomLoadBalancer represent for Random LB policy
-type RandomLoadBalancer[T DBConnection] struct {
-	randInt chan int
-}
+// RandomLoadBalancer represent for Random LB policy, it is safe for concurrent use
+type RandomLoadBalancer[T DBConnection] struct{}
 
 // RandomLoadBalancer return the LB policy name
 func (lb RandomLoadBalancer[T]) Name() LoadBalancerPolicy {
 	return RandomLB
 }
 
-// Resolve return the resolved option for Random LB.
-// Marked with go:nosplit to prevent preemption.
-//
-//go:nosplit
+// Resolve return the resolved option for Random LB
 func (lb RandomLoadBalancer[T]) Resolve(dbs []T) T {
-	if len(lb.randInt) == 0 {
-		lb.predict(len(dbs))
-	}
-	randomInt := <-lb.randInt
-	return dbs[randomInt]
+	return dbs[lb.predict(len(dbs))]
 }
 
 func (lb RandomLoadBalancer[T]) predict(n int) int {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	max := n - 1 //nolint
-	min := 0     //nolint
-	idx := r.Intn(max-min+1) + min
-
-	// Make sure channel is not full before sending
-	select {
-	case lb.randInt <- idx:
-	default:
-		// Channel is full, drain it first
-		<-lb.randInt
-		lb.randInt <- idx
+	if n <= 1 {
+		return 0
 	}
-	return idx
+	return rand.IntN(n)
 }
 
-// RoundRobinLoadBalancer represent for RoundRobin LB policy
+// RoundRobinLoadBalancer represent for RoundRobin LB policy, it is safe for concurrent use
 type RoundRobinLoadBalancer[T DBConnection] struct {
-	counter uint64 // Monotonically incrementing counter on every call
+	counter atomic.Uint64 // Monotonically incrementing counter on every call
 }
 
 // Name return the LB policy name
-func (lb RoundRobinLoadBalancer[T]) Name() LoadBalancerPolicy {
+func (lb *RoundRobinLoadBalancer[T]) Name() LoadBalancerPolicy {
 	return RoundRobinLB
 }
 
@@ -78,6 +60,192 @@ func (lb *RoundRobinLoadBalancer[T]) predict(n int) int {
 	if n <= 1 {
 		return 0
 	}
-	// counter := lb.counter
-	return int(atomic.AddUint64(&lb.counter, 1) % uint64(n)) //nolint:gosec // G115 - n is bounded by checked conditions
+	return int(lb.counter.Add(1) % uint64(n)) //nolint:gosec // G115 - n is bounded by checked conditions
+}
+
+// LeastConnectionsLoadBalancer resolves the database with the fewest connections in use, see sql.DBStats.InUse,
+// rotating among the databases tied. Statements have no statistics and are resolved in round robin.
+// It is safe for concurrent use.
+type LeastConnectionsLoadBalancer[T DBConnection] struct {
+	counter atomic.Uint64 // rotates the first database considered, so ties are spread
+}
+
+// Name return the LB policy name
+func (lb *LeastConnectionsLoadBalancer[T]) Name() LoadBalancerPolicy {
+	return LeastConnectionsLB
+}
+
+// Resolve return the database with the fewest connections in use
+func (lb *LeastConnectionsLoadBalancer[T]) Resolve(dbs []T) T {
+	start := lb.predict(len(dbs))
+	best, bestInUse := start, -1
+	for i := range dbs {
+		idx := (start + i) % len(dbs)
+		db, ok := any(dbs[idx]).(*sql.DB)
+		if !ok {
+			return dbs[start]
+		}
+		if inUse := db.Stats().InUse; bestInUse < 0 || inUse < bestInUse {
+			best, bestInUse = idx, inUse
+		}
+	}
+	return dbs[best]
+}
+
+func (lb *LeastConnectionsLoadBalancer[T]) predict(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(lb.counter.Add(1) % uint64(n)) //nolint:gosec // G115 - n is bounded by checked conditions
+}
+
+// LagAwareLoadBalancer resolves the replica with the highest replay LSN last observed by the routing and the
+// health checks, see PGLSNChecker.CachedReplayLSN, so reads requiring a recent LSN find it caught up more often.
+// It rotates among the databases tied, such as the primaries or the replicas not observed yet, which rank last.
+// Statements have no replay LSN and are resolved in round robin. It is safe for concurrent use.
+type LagAwareLoadBalancer[T DBConnection] struct {
+	counter atomic.Uint64 // rotates the first database considered, so ties are spread
+}
+
+// Name return the LB policy name
+func (lb *LagAwareLoadBalancer[T]) Name() LoadBalancerPolicy {
+	return LagAwareLB
+}
+
+// Resolve return the most caught up database
+func (lb *LagAwareLoadBalancer[T]) Resolve(dbs []T) T {
+	start := lb.predict(len(dbs))
+	best, bestLSN := start, LSN{}
+	for i := range dbs {
+		idx := (start + i) % len(dbs)
+		db, ok := any(dbs[idx]).(*sql.DB)
+		if !ok {
+			return dbs[start]
+		}
+		checker := lookupChecker(db)
+		if checker == nil {
+			continue
+		}
+		if lsn, _, ok := checker.CachedReplayLSN(); ok && lsn.GreaterThan(bestLSN) {
+			best, bestLSN = idx, lsn
+		}
+	}
+	return dbs[best]
+}
+
+func (lb *LagAwareLoadBalancer[T]) predict(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(lb.counter.Add(1) % uint64(n)) //nolint:gosec // G115 - n is bounded by checked conditions
+}
+
+// LatencyObserver is implemented by the load balancers adapting to the latency of the databases they resolve.
+// The resolver reports them the latency of every successful read, until its first row.
+type LatencyObserver[T DBConnection] interface {
+	ObserveLatency(db T, latency time.Duration)
+}
+
+const (
+	defaultLatencyProbeInterval = 5 * time.Second
+	// latencySmoothing is the weight of the latest read in the average latency of a database
+	latencySmoothing = 0.2
+)
+
+// LatencyAwareLoadBalancer resolves the databases at random, weighted by the inverse of their average read
+// latency, so the faster ones serve more reads. A database not resolved for ProbeInterval is resolved once, so
+// the latency of a slow database is measured again and it can recover. It is safe for concurrent use.
+type LatencyAwareLoadBalancer[T DBConnection] struct {
+	ProbeInterval time.Duration // defaults to 5s
+
+	mu    sync.Mutex
+	stats map[T]*latencyStats
+}
+
+// latencyStats is the average latency of a database
+type latencyStats struct {
+	average  float64 // nanoseconds, zero until measured
+	resolved time.Time
+}
+
+// Name return the LB policy name
+func (lb *LatencyAwareLoadBalancer[T]) Name() LoadBalancerPolicy {
+	return LatencyAwareLB
+}
+
+// ObserveLatency records the latency of a read on db
+func (lb *LatencyAwareLoadBalancer[T]) ObserveLatency(db T, latency time.Duration) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	s := lb.statsLocked(db)
+	if s.average == 0 {
+		s.average = float64(max(latency, time.Nanosecond))
+		return
+	}
+	s.average += latencySmoothing * (float64(latency) - s.average)
+}
+
+// Resolve return a database, the faster ones more often
+func (lb *LatencyAwareLoadBalancer[T]) Resolve(dbs []T) T {
+	if len(dbs) == 1 {
+		return dbs[0]
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	idx := lb.pickLocked(dbs, time.Now())
+	lb.statsLocked(dbs[idx]).resolved = time.Now()
+	return dbs[idx]
+}
+
+// pickLocked returns the index of a database not resolved for the probe interval, or a weighted random index.
+// The databases not measured yet weigh the average of the measured ones.
+func (lb *LatencyAwareLoadBalancer[T]) pickLocked(dbs []T, now time.Time) int {
+	probeInterval := lb.ProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = defaultLatencyProbeInterval
+	}
+	weights := make([]float64, len(dbs))
+	var measured, measuredWeight float64
+	for i, db := range dbs {
+		s := lb.statsLocked(db)
+		if !s.resolved.IsZero() && now.Sub(s.resolved) >= probeInterval {
+			return i
+		}
+		if s.average > 0 {
+			weights[i] = 1 / s.average
+			measured++
+			measuredWeight += weights[i]
+		}
+	}
+	unmeasuredWeight := 1.0
+	if measured > 0 {
+		unmeasuredWeight = measuredWeight / measured
+	}
+	var total float64
+	for i := range weights {
+		if weights[i] == 0 {
+			weights[i] = unmeasuredWeight
+		}
+		total += weights[i]
+	}
+	r := rand.Float64() * total //nolint:gosec // G404 - load balancing doesn't need a secure source
+	for i, weight := range weights {
+		if r < weight {
+			return i
+		}
+		r -= weight
+	}
+	return len(dbs) - 1
+}
+
+func (lb *LatencyAwareLoadBalancer[T]) statsLocked(db T) *latencyStats {
+	if lb.stats == nil {
+		lb.stats = make(map[T]*latencyStats)
+	}
+	s, ok := lb.stats[db]
+	if !ok {
+		s = &latencyStats{}
+		lb.stats[db] = s
+	}
+	return s
 }
@@ -2,8 +2,13 @@ package dbresolver
 
 import (
 	"database/sql"
+	"slices"
+	"sync"
 	"testing"
 	"testing/quick"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestReplicaRoundRobin(t *testing.T) {
@@ -27,3 +32,219 @@ func TestReplicaRoundRobin(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestLeastConnectionsLoadBalancer(t *testing.T) {
+	busy, _ := newMockDB(t)
+	idle, _ := newMockDB(t)
+	conn, err := busy.Conn(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	lb := &LeastConnectionsLoadBalancer[*sql.DB]{}
+	for range 4 {
+		if got := lb.Resolve([]*sql.DB{busy, idle}); got != idle {
+			t.Fatal("want the database without connections in use")
+		}
+	}
+
+	_ = conn.Close()
+	resolved := map[*sql.DB]bool{}
+	for range 4 {
+		resolved[lb.Resolve([]*sql.DB{busy, idle})] = true
+	}
+	if len(resolved) != 2 {
+		t.Error("want the ties spread over both databases")
+	}
+
+	stmts := []*sql.Stmt{{}, {}}
+	if got := (&LeastConnectionsLoadBalancer[*sql.Stmt]{}).Resolve(stmts); !slices.Contains(stmts, got) {
+		t.Error("want a statement of the pool")
+	}
+}
+
+func TestLagAwareLoadBalancer(t *testing.T) {
+	behind, behindMock := newMockDB(t)
+	ahead, aheadMock := newMockDB(t)
+	unobserved, _ := newMockDB(t)
+	expectReplayLSN(behindMock, "0/1000")
+	expectReplayLSN(aheadMock, "0/2000")
+	for _, replica := range []*sql.DB{behind, ahead} {
+		if _, err := getOrCreateChecker(replica, time.Second).GetLastReplayLSN(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lb := &LagAwareLoadBalancer[*sql.DB]{}
+	for range 4 {
+		if got := lb.Resolve([]*sql.DB{unobserved, behind, ahead}); got != ahead {
+			t.Fatal("want the most caught up replica")
+		}
+	}
+
+	resolved := map[*sql.DB]bool{}
+	other, _ := newMockDB(t)
+	for range 4 {
+		resolved[lb.Resolve([]*sql.DB{unobserved, other})] = true
+	}
+	if len(resolved) != 2 {
+		t.Error("want the databases without a replay LSN spread")
+	}
+}
+
+func TestLatencyAwareLoadBalancer(t *testing.T) {
+	fast, slow := &sql.DB{}, &sql.DB{}
+	dbs := []*sql.DB{fast, slow}
+	lb := &LatencyAwareLoadBalancer[*sql.DB]{ProbeInterval: time.Hour}
+	for range 5 {
+		lb.ObserveLatency(fast, time.Millisecond)
+		lb.ObserveLatency(slow, 100*time.Millisecond)
+	}
+
+	resolved := map[*sql.DB]int{}
+	for range 1000 {
+		resolved[lb.Resolve(dbs)]++
+	}
+	if resolved[fast] < 900 {
+		t.Errorf("want most reads on the fast database, got %d of 1000", resolved[fast])
+	}
+
+	// the slow database is probed once it wasn't resolved for the probe interval
+	lb.stats[slow].resolved = time.Now().Add(-2 * time.Hour)
+	if got := lb.Resolve(dbs); got != slow {
+		t.Error("want the slow database probed")
+	}
+	for range 50 {
+		lb.ObserveLatency(slow, time.Millisecond)
+	}
+	resolved = map[*sql.DB]int{}
+	for range 1000 {
+		resolved[lb.Resolve(dbs)]++
+	}
+	if resolved[slow] < 400 {
+		t.Errorf("want the recovered database to serve its share, got %d of 1000", resolved[slow])
+	}
+}
+
+func TestLatencyAwareLoadBalancerObservesReads(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithLoadBalancer(LatencyAwareLB))
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := db.QueryRowContext(t.Context(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	lb := db.loadBalancer.(*LatencyAwareLoadBalancer[*sql.DB])
+	if s := lb.stats[replica]; s == nil || s.average == 0 {
+		t.Error("want the replica read latency observed")
+	}
+}
+
+// lastLoadBalancer is a custom balancer always resolving the last database
+type lastLoadBalancer[T DBConnection] struct{}
+
+func (lastLoadBalancer[T]) Resolve(dbs []T) T        { return dbs[len(dbs)-1] }
+func (lastLoadBalancer[T]) Name() LoadBalancerPolicy { return "LAST" }
+
+func TestCustomLoadBalancer(t *testing.T) {
+	primary, _ := newMockDB(t)
+	first, _ := newMockDB(t)
+	last, lastMock := newMockDB(t)
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(first, last),
+		WithCustomLoadBalancer(lastLoadBalancer[*sql.DB]{}, nil),
+	)
+	if _, ok := db.stmtLoadBalancer.(*RoundRobinLoadBalancer[*sql.Stmt]); !ok {
+		t.Error("want the default statement balancer kept")
+	}
+
+	lastMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := db.QueryRowContext(t.Context(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if err := lastMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("want the read on the replica resolved by the custom balancer: %v", err)
+	}
+}
+
+func TestLoadBalancersConcurrentResolve(t *testing.T) {
+	dbs := []*sql.DB{{}, {}, {}}
+	balancers := []DBLoadBalancer{
+		&RoundRobinLoadBalancer[*sql.DB]{}, &RandomLoadBalancer[*sql.DB]{}, &LeastConnectionsLoadBalancer[*sql.DB]{},
+		&LatencyAwareLoadBalancer[*sql.DB]{}, &LagAwareLoadBalancer[*sql.DB]{},
+	}
+	for _, lb := range balancers {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				for range 1000 {
+					if !slices.Contains(dbs, lb.Resolve(dbs)) {
+						t.Errorf("%s resolved a database out of the pool", lb.Name())
+						return
+					}
+				}
+			})
+		}
+		wg.Wait()
+	}
+}
+
+func TestRoundRobinLoadBalancerConcurrentDistribution(t *testing.T) {
+	dbs := []*sql.DB{{}, {}, {}}
+	lb := &RoundRobinLoadBalancer[*sql.DB]{}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = make(map[*sql.DB]int)
+	)
+	for range 8 {
+		wg.Go(func() {
+			resolved := make(map[*sql.DB]int)
+			for range 999 {
+				resolved[lb.Resolve(dbs)]++
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for db, n := range resolved {
+				counts[db] += n
+			}
+		})
+	}
+	wg.Wait()
+
+	// every Resolve takes its own turn, so contention doesn't skew the rotation
+	for i, db := range dbs {
+		if counts[db] != 8*999/len(dbs) {
+			t.Errorf("want database %d resolved %d times, got %d", i, 8*999/len(dbs), counts[db])
+		}
+	}
+}
+
+func TestRoundRobinRouterConcurrentRouting(t *testing.T) {
+	replicas := []*sql.DB{{}, {}}
+	router := NewRoundRobinRouter(&staticProvider{primaries: []*sql.DB{{}}, replicas: replicas})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				if _, err := router.RouteQuery(t.Context(), QueryTypeRead); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	// 800 reads advanced the index evenly, the next one lands on the first replica again
+	if got, _ := router.RouteQuery(t.Context(), QueryTypeRead); got != replicas[0] {
+		t.Error("expected the round robin index to account for every concurrent read")
+	}
+}
@@ -0,0 +1,86 @@
+package dbresolver
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogComponent identifies the part of the resolver emitting a log record
+type LogComponent string
+
+const (
+	LogComponentRouter     LogComponent = "router"     // Causal routing decisions and LSN tracking
+	LogComponentReplicas   LogComponent = "replicas"   // Keepalive probes and ejection diagnosis
+	LogComponentMiddleware LogComponent = "middleware" // HTTPMiddleware, see WithMiddlewareLogger
+)
+
+// WithLogger sets the logger used by the resolver instead of slog.Default().
+// Records carry a "component" attribute with the LogComponent emitting them.
+func WithLogger(logger *slog.Logger) OptionFunc {
+	return func(opt *Option) {
+		opt.Logger = logger
+	}
+}
+
+// WithComponentLogLevel sets the minimum level logged by a component, e.g. slog.LevelWarn to
+// silence the routing debug logs in production. The logger's own level still applies.
+func WithComponentLogLevel(component LogComponent, level slog.Leveler) OptionFunc {
+	return func(opt *Option) {
+		if opt.LogLevels == nil {
+			opt.LogLevels = make(map[LogComponent]slog.Leveler)
+		}
+		opt.LogLevels[component] = level
+	}
+}
+
+// componentLogger returns the logger of a component, or nil to log with slog.Default()
+func componentLogger(logger *slog.Logger, component LogComponent, levels map[LogComponent]slog.Leveler) *slog.Logger {
+	level := levels[component]
+	if logger == nil && level == nil {
+		return nil
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger = logger.With("component", string(component))
+	if level != nil {
+		logger = slog.New(&levelHandler{level: level, handler: logger.Handler()})
+	}
+	return logger
+}
+
+// loggerOrDefault returns logger, falling back to the current slog.Default()
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// log returns the logger of the replica background workers
+func (db *DB) log() *slog.Logger {
+	return loggerOrDefault(db.logger)
+}
+
+// levelHandler drops the records below level before they reach handler
+type levelHandler struct {
+	level   slog.Leveler
+	handler slog.Handler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, handler: h.handler.WithGroup(name)}
+}
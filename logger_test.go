@@ -0,0 +1,104 @@
+package dbresolver
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// recordingHandler keeps the records it handles
+type recordingHandler struct {
+	mu      sync.Mutex
+	attrs   []slog.Attr
+	records *[]slog.Record
+}
+
+func newRecordingHandler() *recordingHandler {
+	return &recordingHandler{records: &[]slog.Record{}}
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r = r.Clone()
+	r.AddAttrs(h.attrs...)
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recordingHandler{attrs: append(append([]slog.Attr{}, h.attrs...), attrs...), records: h.records}
+}
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *recordingHandler) components() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var components []string
+	for _, r := range *h.records {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "component" {
+				components = append(components, a.Value.String())
+			}
+			return true
+		})
+	}
+	return components
+}
+
+func TestWithLogger(t *testing.T) {
+	primary, _ := newMockDB(t)
+
+	handler := newRecordingHandler()
+	db := New(
+		WithPrimaryDBs(primary),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: StrongConsistency}),
+		WithLogger(slog.New(handler)),
+	)
+	db.DbSelector(context.Background(), QueryTypeRead)
+
+	components := handler.components()
+	if len(components) == 0 {
+		t.Fatal("expected the routing logs to go to the configured logger")
+	}
+	for _, c := range components {
+		if c != string(LogComponentRouter) {
+			t.Errorf("expected router component, got %q", c)
+		}
+	}
+}
+
+func TestWithComponentLogLevel(t *testing.T) {
+	primary, _ := newMockDB(t)
+
+	handler := newRecordingHandler()
+	db := New(
+		WithPrimaryDBs(primary),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: StrongConsistency}),
+		WithLogger(slog.New(handler)),
+		WithComponentLogLevel(LogComponentRouter, slog.LevelWarn),
+	)
+	db.DbSelector(context.Background(), QueryTypeRead)
+
+	if components := handler.components(); len(components) != 0 {
+		t.Errorf("expected the router debug logs to be silenced, got %d records", len(components))
+	}
+	if !db.log().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected the level of the router not to apply to other components")
+	}
+}
+
+func TestComponentLoggerDefaults(t *testing.T) {
+	if componentLogger(nil, LogComponentRouter, nil) != nil {
+		t.Error("expected no component logger without a logger or level, so slog.Default() is used")
+	}
+	levels := map[LogComponent]slog.Leveler{LogComponentRouter: slog.LevelError}
+	logger := componentLogger(nil, LogComponentRouter, levels)
+	if logger == nil || logger.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("expected the component level to apply on top of slog.Default()")
+	}
+}
@@ -0,0 +1,277 @@
+// Package logicaldecoding consumes a PostgreSQL logical replication slot and exposes the
+// committed row changes as events carrying their commit LSN.
+//
+// The commit LSN is the same position dbresolver tracks for causal consistency, so a cache
+// invalidated from these events can tell whether it already reflects the state a request
+// must observe: once Consumer.ProcessedLSN is at or past the request's required LSN, every
+// invalidation the request depends on has been applied.
+//
+// The slot is read through the SQL interface (pg_logical_slot_peek_changes and
+// pg_logical_slot_peek_binary_changes), so any database/sql driver works and no
+// replication connection is needed. The slot is only advanced after the handler
+// accepted a transaction, which gives at-least-once delivery.
+package logicaldecoding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// Action is the kind of row change
+type Action string
+
+// Row change actions
+const (
+	ActionInsert   Action = "INSERT"
+	ActionUpdate   Action = "UPDATE"
+	ActionDelete   Action = "DELETE"
+	ActionTruncate Action = "TRUNCATE"
+)
+
+// ChangeEvent is a committed row change
+type ChangeEvent struct {
+	Schema     string
+	Table      string
+	Action     Action
+	PrimaryKey map[string]string // Primary key (replica identity) columns in text format; nil for truncates
+	CommitLSN  dbresolver.LSN    // End of the commit record of the transaction that made the change
+}
+
+// Handler processes the events of one committed transaction.
+// Returning an error stops the consumer without advancing the slot past the transaction.
+type Handler func(ctx context.Context, events []ChangeEvent) error
+
+// Plugin decodes the output of a logical decoding output plugin
+type Plugin interface {
+	// Name is the output plugin name used when creating the slot
+	Name() string
+	// Query returns the statement peeking at most limit changes from the slot, with the slot name as $1.
+	// It must return the lsn and data columns of the pg_logical_slot_peek_*changes functions.
+	Query(limit int) string
+	// Decode consumes one change row and returns the transaction it completes, if any
+	Decode(lsn dbresolver.LSN, data []byte) (*Transaction, error)
+	// Reset discards any partially decoded transaction
+	Reset()
+}
+
+// Transaction is a decoded, committed transaction.
+// CommitLSN points just past the commit record: any read observing that LSN, such as a
+// master LSN captured by dbresolver after the write, also observes the transaction.
+type Transaction struct {
+	CommitLSN dbresolver.LSN
+	Events    []ChangeEvent
+}
+
+// Defaults for the consumer
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 1000
+)
+
+// Consumer polls a logical replication slot and hands committed transactions to a Handler
+type Consumer struct {
+	db           *sql.DB
+	slot         string
+	plugin       Plugin
+	pollInterval time.Duration
+	batchSize    int
+	createSlot   bool
+	logger       *slog.Logger
+
+	mu           sync.RWMutex
+	processedLSN dbresolver.LSN
+}
+
+// Option configures a Consumer
+type Option func(c *Consumer)
+
+// WithPlugin sets the output plugin of the slot. Defaults to wal2json.
+func WithPlugin(plugin Plugin) Option {
+	return func(c *Consumer) {
+		c.plugin = plugin
+	}
+}
+
+// WithPollInterval sets how long to wait before polling again once the slot is drained
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Consumer) {
+		c.pollInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of changes read per poll.
+// PostgreSQL always finishes the current transaction, so a batch may contain more changes.
+func WithBatchSize(size int) Option {
+	return func(c *Consumer) {
+		c.batchSize = size
+	}
+}
+
+// WithCreateSlot creates the replication slot on Run if it doesn't exist yet
+func WithCreateSlot() Option {
+	return func(c *Consumer) {
+		c.createSlot = true
+	}
+}
+
+// WithLogger sets the logger used by the consumer instead of slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Consumer) {
+		c.logger = logger
+	}
+}
+
+// NewConsumer creates a consumer for the logical replication slot on the primary db
+func NewConsumer(db *sql.DB, slot string, opts ...Option) *Consumer {
+	c := &Consumer{
+		db:           db,
+		slot:         slot,
+		plugin:       NewWal2JSON(),
+		pollInterval: DefaultPollInterval,
+		batchSize:    DefaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ProcessedLSN returns the commit LSN of the last transaction accepted by the handler
+func (c *Consumer) ProcessedLSN() dbresolver.LSN {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.processedLSN
+}
+
+// Run consumes the slot until ctx is canceled or the handler fails
+func (c *Consumer) Run(ctx context.Context, handler Handler) error {
+	if c.createSlot {
+		if err := c.ensureSlot(ctx); err != nil {
+			return err
+		}
+	}
+
+	for {
+		n, err := c.Poll(ctx, handler)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// Poll reads one batch of changes, hands every committed transaction to the handler and
+// advances the slot past the handled transactions. It returns the number of handled transactions.
+func (c *Consumer) Poll(ctx context.Context, handler Handler) (int, error) {
+	txs, err := c.peek(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	for _, tx := range txs {
+		if err := handler(ctx, tx.Events); err != nil {
+			return handled, c.advanceAfter(ctx, txs[:handled], fmt.Errorf("handler failed at LSN %s: %w", tx.CommitLSN, err))
+		}
+		handled++
+	}
+	return handled, c.advanceAfter(ctx, txs, nil)
+}
+
+// peek decodes the next batch of committed transactions without consuming them
+func (c *Consumer) peek(ctx context.Context) ([]*Transaction, error) {
+	rows, err := c.db.QueryContext(ctx, c.plugin.Query(c.batchSize), c.slot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to peek slot %s: %w", c.slot, err)
+	}
+	defer rows.Close()
+
+	// a partially read transaction is delivered again by the next peek
+	c.plugin.Reset()
+
+	var txs []*Transaction
+	for rows.Next() {
+		var lsnStr string
+		var data []byte
+		if err := rows.Scan(&lsnStr, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		lsn, err := dbresolver.ParseLSN(lsnStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid change LSN: %w", err)
+		}
+
+		tx, err := c.plugin.Decode(lsn, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode change at LSN %s: %w", lsn, err)
+		}
+		if tx != nil {
+			txs = append(txs, tx)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read changes: %w", err)
+	}
+	return txs, nil
+}
+
+// advanceAfter moves the slot past the handled transactions and returns cause, or the advance error
+func (c *Consumer) advanceAfter(ctx context.Context, handled []*Transaction, cause error) error {
+	if len(handled) == 0 {
+		return cause
+	}
+
+	last := handled[len(handled)-1]
+	// the handler's error is the relevant one; advance with a context that survives its cancellation
+	if _, err := c.db.ExecContext(context.WithoutCancel(ctx),
+		"SELECT pg_replication_slot_advance($1, $2::pg_lsn)", c.slot, last.CommitLSN.String()); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to advance slot %s to %s: %w", c.slot, last.CommitLSN, err))
+	}
+
+	c.mu.Lock()
+	c.processedLSN = last.CommitLSN
+	c.mu.Unlock()
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Debug("logicaldecoding: advanced slot", "slot", c.slot, "lsn", last.CommitLSN)
+	return cause
+}
+
+// ensureSlot creates the replication slot if it doesn't exist
+func (c *Consumer) ensureSlot(ctx context.Context) error {
+	var exists bool
+	err := c.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", c.slot).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up slot %s: %w", c.slot, err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := c.db.ExecContext(ctx,
+		"SELECT pg_create_logical_replication_slot($1, $2)", c.slot, c.plugin.Name()); err != nil {
+		return fmt.Errorf("failed to create slot %s: %w", c.slot, err)
+	}
+	return nil
+}
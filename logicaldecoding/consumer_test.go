@@ -0,0 +1,115 @@
+package logicaldecoding
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func changeRows() *sqlmock.Rows {
+	insert := `{"action":"I","schema":"public","table":"orders","columns":[{"name":"id","value":1}],"pk":[{"name":"id"}]}`
+	return sqlmock.NewRows([]string{"lsn", "data"}).
+		AddRow("0/100", []byte(`{"action":"B"}`)).
+		AddRow("0/100", []byte(insert)).
+		AddRow("0/180", []byte(`{"action":"C"}`)).
+		AddRow("0/200", []byte(`{"action":"B"}`)).
+		AddRow("0/200", []byte(`{"action":"D","schema":"public","table":"orders","identity":[{"name":"id","value":1}],"pk":[{"name":"id"}]}`)).
+		AddRow("0/280", []byte(`{"action":"C"}`)).
+		// an incomplete transaction at the end of the batch is left for the next poll
+		AddRow("0/300", []byte(`{"action":"B"}`))
+}
+
+func TestConsumerPoll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("pg_logical_slot_peek_changes($1, NULL, 10")).
+		WithArgs("cache_slot").WillReturnRows(changeRows())
+	mock.ExpectExec(regexp.QuoteMeta("pg_replication_slot_advance")).
+		WithArgs("cache_slot", "0/280").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	consumer := NewConsumer(db, "cache_slot", WithBatchSize(10))
+
+	var commits []dbresolver.LSN
+	n, err := consumer.Poll(context.Background(), func(_ context.Context, events []ChangeEvent) error {
+		commits = append(commits, events[0].CommitLSN)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 || len(commits) != 2 || commits[1] != (dbresolver.LSN{Lower: 0x280}) {
+		t.Errorf("expected two transactions committed up to 0/280, got %d: %v", n, commits)
+	}
+	if consumer.ProcessedLSN() != (dbresolver.LSN{Lower: 0x280}) {
+		t.Errorf("want processed LSN 0/280, got %s", consumer.ProcessedLSN())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerPollHandlerFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("pg_logical_slot_peek_changes").WillReturnRows(changeRows())
+	// only the transaction accepted before the failure is consumed
+	mock.ExpectExec("pg_replication_slot_advance").
+		WithArgs("cache_slot", "0/180").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	consumer := NewConsumer(db, "cache_slot")
+	errCache := errors.New("cache unavailable")
+	calls := 0
+	n, err := consumer.Poll(context.Background(), func(_ context.Context, _ []ChangeEvent) error {
+		calls++
+		if calls == 2 {
+			return errCache
+		}
+		return nil
+	})
+	if !errors.Is(err, errCache) || n != 1 {
+		t.Errorf("expected handler error after one transaction, got %d (%v)", n, err)
+	}
+	if consumer.ProcessedLSN() != (dbresolver.LSN{Lower: 0x180}) {
+		t.Errorf("want processed LSN 0/180, got %s", consumer.ProcessedLSN())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerCreatesSlot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("pg_replication_slots").WithArgs("cache_slot").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta("pg_create_logical_replication_slot")).
+		WithArgs("cache_slot", "pgoutput").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("pg_logical_slot_peek_binary_changes").
+		WillReturnError(errors.New("connection reset"))
+
+	consumer := NewConsumer(db, "cache_slot", WithPlugin(NewPGOutput("app")), WithCreateSlot())
+	err = consumer.Run(context.Background(), func(_ context.Context, _ []ChangeEvent) error { return nil })
+	if err == nil {
+		t.Error("expected Run to return the peek error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,258 @@
+package logicaldecoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// pgoutput message types of protocol version 1
+const (
+	msgBegin    = 'B'
+	msgCommit   = 'C'
+	msgRelation = 'R'
+	msgInsert   = 'I'
+	msgUpdate   = 'U'
+	msgDelete   = 'D'
+	msgTruncate = 'T'
+)
+
+// pgoutputActions maps row change messages to their action
+var pgoutputActions = map[byte]Action{msgInsert: ActionInsert, msgUpdate: ActionUpdate, msgDelete: ActionDelete}
+
+// pgoutputKeyColumn flags a replica identity column in a relation message
+const pgoutputKeyColumn = 1
+
+var errShortMessage = errors.New("pgoutput message is truncated")
+
+// PGOutput decodes the built-in pgoutput plugin (protocol version 1) for the given publications
+type PGOutput struct {
+	publications []string
+	relations    map[uint32]relationInfo
+	pending      []ChangeEvent
+}
+
+// relationInfo is the table described by a relation message
+type relationInfo struct {
+	schema  string
+	table   string
+	columns []string
+	key     []bool
+}
+
+// NewPGOutput creates a decoder for pgoutput slots streaming the given publications
+func NewPGOutput(publications ...string) *PGOutput {
+	return &PGOutput{
+		publications: publications,
+		relations:    make(map[uint32]relationInfo),
+	}
+}
+
+// Name returns the output plugin name
+func (d *PGOutput) Name() string {
+	return "pgoutput"
+}
+
+// Query peeks binary changes with protocol version 1
+func (d *PGOutput) Query(limit int) string {
+	names := make([]string, len(d.publications))
+	for i, name := range d.publications {
+		names[i] = strings.ReplaceAll(name, "'", "''")
+	}
+	return "SELECT lsn::text, data FROM pg_logical_slot_peek_binary_changes($1, NULL, " + strconv.Itoa(limit) +
+		", 'proto_version', '1', 'publication_names', '" + strings.Join(names, ",") + "')"
+}
+
+// Reset discards the changes of the transaction being decoded.
+// Relations are kept, since pgoutput only describes them once per decoding session.
+func (d *PGOutput) Reset() {
+	d.pending = nil
+}
+
+// Decode consumes one pgoutput message and returns the transaction completed by a commit
+func (d *PGOutput) Decode(lsn dbresolver.LSN, data []byte) (*Transaction, error) {
+	if len(data) == 0 {
+		return nil, errShortMessage
+	}
+
+	msg := &pgoutputReader{buf: data[1:]}
+	switch data[0] {
+	case msgBegin:
+		d.pending = nil
+	case msgCommit:
+		msg.skip(1) // flags
+		msg.skip(8) // commit LSN, the start of the commit record
+		end := dbresolver.LSNFromUint64(msg.uint64())
+		if msg.err != nil {
+			return nil, msg.err
+		}
+		tx := &Transaction{CommitLSN: end, Events: d.pending}
+		for i := range tx.Events {
+			tx.Events[i].CommitLSN = end
+		}
+		d.pending = nil
+		return tx, nil
+	case msgRelation:
+		d.decodeRelation(msg)
+	case msgInsert, msgUpdate, msgDelete:
+		d.decodeRowChange(data[0], msg)
+	case msgTruncate:
+		d.decodeTruncate(msg)
+	}
+	// type and origin messages carry no row changes
+	return nil, msg.err
+}
+
+func (d *PGOutput) decodeRelation(msg *pgoutputReader) {
+	id := msg.uint32()
+	rel := relationInfo{schema: msg.string(), table: msg.string()}
+	msg.skip(1) // replica identity setting
+	n := int(msg.uint16())
+	for i := 0; i < n && msg.err == nil; i++ {
+		flags := msg.byte()
+		rel.columns = append(rel.columns, msg.string())
+		rel.key = append(rel.key, flags&pgoutputKeyColumn != 0)
+		msg.skip(8) // type OID and modifier
+	}
+	if msg.err == nil {
+		d.relations[id] = rel
+	}
+}
+
+func (d *PGOutput) decodeRowChange(kind byte, msg *pgoutputReader) {
+	rel, ok := d.relation(msg)
+	if !ok {
+		return
+	}
+
+	// updates and deletes start with the old key ('K') or old row ('O') when available
+	tupleKind := msg.byte()
+	if tupleKind == 'N' && kind == msgDelete {
+		msg.err = fmt.Errorf("unexpected new tuple in delete of %s.%s", rel.schema, rel.table)
+	}
+	values := msg.tuple()
+	if msg.err != nil {
+		return
+	}
+
+	key := make(map[string]string)
+	for i, value := range values {
+		if i < len(rel.key) && rel.key[i] && value != nil {
+			key[rel.columns[i]] = *value
+		}
+	}
+	d.pending = append(d.pending, ChangeEvent{Schema: rel.schema, Table: rel.table, Action: pgoutputActions[kind], PrimaryKey: key})
+}
+
+func (d *PGOutput) decodeTruncate(msg *pgoutputReader) {
+	n := int(msg.uint32())
+	msg.skip(1) // options
+	for i := 0; i < n && msg.err == nil; i++ {
+		if rel, ok := d.relation(msg); ok {
+			d.pending = append(d.pending, ChangeEvent{Schema: rel.schema, Table: rel.table, Action: ActionTruncate})
+		}
+	}
+}
+
+// relation reads a relation ID and resolves it from the relation messages seen so far
+func (d *PGOutput) relation(msg *pgoutputReader) (relationInfo, bool) {
+	id := msg.uint32()
+	if msg.err != nil {
+		return relationInfo{}, false
+	}
+	rel, ok := d.relations[id]
+	if !ok {
+		msg.err = fmt.Errorf("change for unknown relation %d", id)
+	}
+	return rel, ok
+}
+
+// pgoutputReader reads the big-endian fields of a pgoutput message, recording the first error
+type pgoutputReader struct {
+	buf []byte
+	err error
+}
+
+func (r *pgoutputReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *pgoutputReader) skip(n int) {
+	r.next(n)
+}
+
+func (r *pgoutputReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// string reads a null-terminated string
+func (r *pgoutputReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.buf, 0)
+	if i < 0 {
+		r.err = errShortMessage
+		return ""
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+	return s
+}
+
+// tuple reads tuple data; null and unchanged TOAST values are returned as nil
+func (r *pgoutputReader) tuple() []*string {
+	n := int(r.uint16())
+	values := make([]*string, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		switch r.byte() {
+		case 't':
+			size := int(r.uint32())
+			if b := r.next(size); b != nil {
+				value := string(b)
+				values = append(values, &value)
+			}
+		default: // 'n' null, 'u' unchanged TOAST
+			values = append(values, nil)
+		}
+	}
+	return values
+}
@@ -0,0 +1,93 @@
+package logicaldecoding
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// pgoutputMessage builds a pgoutput message from bytes, strings (null-terminated) and fixed size integers
+func pgoutputMessage(fields ...interface{}) []byte {
+	var buf []byte
+	for _, field := range fields {
+		switch v := field.(type) {
+		case byte:
+			buf = append(buf, v)
+		case string:
+			buf = append(append(buf, v...), 0)
+		case uint16:
+			buf = binary.BigEndian.AppendUint16(buf, v)
+		case uint32:
+			buf = binary.BigEndian.AppendUint32(buf, v)
+		case uint64:
+			buf = binary.BigEndian.AppendUint64(buf, v)
+		case []byte:
+			buf = append(buf, v...)
+		}
+	}
+	return buf
+}
+
+// textValue encodes a text column of tuple data
+func textValue(value string) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{'t'}, uint32(len(value))), value...)
+}
+
+func TestPGOutputDecode(t *testing.T) {
+	d := NewPGOutput("app")
+	if want := "'publication_names', 'app'"; !strings.Contains(d.Query(10), want) {
+		t.Errorf("query %q should contain %q", d.Query(10), want)
+	}
+
+	const relID uint32 = 16384
+	end := dbresolver.LSN{Lower: 0x3000200}
+	messages := [][]byte{
+		pgoutputMessage(byte(msgBegin), uint64(0x3000100), uint64(0), uint32(740)),
+		pgoutputMessage(byte(msgRelation), relID, "public", "orders", byte('d'), uint16(2),
+			byte(1), "id", uint32(23), uint32(0xFFFFFFFF),
+			byte(0), "note", uint32(25), uint32(0xFFFFFFFF)),
+		pgoutputMessage(byte(msgInsert), relID, byte('N'), uint16(2), textValue("7"), textValue("hello")),
+		pgoutputMessage(byte(msgUpdate), relID, byte('K'), uint16(2), textValue("6"), byte('n'),
+			byte('N'), uint16(2), textValue("7"), byte('u')),
+		pgoutputMessage(byte(msgDelete), relID, byte('K'), uint16(2), textValue("7"), byte('n')),
+		pgoutputMessage(byte(msgTruncate), uint32(1), byte(0), relID),
+		pgoutputMessage(byte('Y'), uint32(1), "public", "mood"),
+	}
+	for _, msg := range messages {
+		if tx, err := d.Decode(dbresolver.LSN{}, msg); err != nil || tx != nil {
+			t.Fatalf("message %q: expected no transaction yet, got %+v (%v)", msg[0], tx, err)
+		}
+	}
+
+	tx, err := d.Decode(end, pgoutputMessage(byte(msgCommit), byte(0), uint64(0x3000100), end.ToUint64(), uint64(0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ChangeEvent{
+		{Schema: "public", Table: "orders", Action: ActionInsert, PrimaryKey: map[string]string{"id": "7"}, CommitLSN: end},
+		{Schema: "public", Table: "orders", Action: ActionUpdate, PrimaryKey: map[string]string{"id": "6"}, CommitLSN: end},
+		{Schema: "public", Table: "orders", Action: ActionDelete, PrimaryKey: map[string]string{"id": "7"}, CommitLSN: end},
+		{Schema: "public", Table: "orders", Action: ActionTruncate, CommitLSN: end},
+	}
+	if tx == nil || tx.CommitLSN != end || !reflect.DeepEqual(tx.Events, expected) {
+		t.Fatalf("want %+v at %s, got %+v", expected, end, tx)
+	}
+}
+
+func TestPGOutputDecodeErrors(t *testing.T) {
+	d := NewPGOutput("app")
+
+	if _, err := d.Decode(dbresolver.LSN{}, pgoutputMessage(byte(msgInsert), uint32(1), byte('N'), uint16(0))); err == nil {
+		t.Error("expected an error for a change of an unknown relation")
+	}
+	if _, err := d.Decode(dbresolver.LSN{}, pgoutputMessage(byte(msgCommit), byte(0), uint32(1))); err == nil {
+		t.Error("expected an error for a truncated commit")
+	}
+	if _, err := d.Decode(dbresolver.LSN{}, nil); err == nil {
+		t.Error("expected an error for an empty message")
+	}
+}
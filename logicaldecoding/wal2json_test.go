@@ -0,0 +1,48 @@
+package logicaldecoding
+
+import (
+	"reflect"
+	"testing"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func TestWal2JSONDecode(t *testing.T) {
+	d := NewWal2JSON()
+	commit := dbresolver.LSN{Lower: 0x3000100}
+
+	changes := []string{
+		`{"action":"B"}`,
+		`{"action":"I","schema":"public","table":"orders","columns":[{"name":"id","type":"integer","value":7},` +
+			`{"name":"note","type":"text","value":"x"}],"pk":[{"name":"id","type":"integer"}]}`,
+		`{"action":"U","schema":"public","table":"orders","columns":[{"name":"id","type":"integer","value":7}],` +
+			`"identity":[{"name":"id","type":"integer","value":6}],"pk":[{"name":"id","type":"integer"}]}`,
+		`{"action":"D","schema":"public","table":"tags","identity":[{"name":"name","type":"text","value":"a"}],` +
+			`"pk":[{"name":"name","type":"text"}]}`,
+		`{"action":"M","prefix":"app","content":"ignored"}`,
+	}
+	for _, change := range changes {
+		tx, err := d.Decode(dbresolver.LSN{Lower: 0x3000000}, []byte(change))
+		if err != nil || tx != nil {
+			t.Fatalf("change %s: expected no transaction yet, got %+v (%v)", change, tx, err)
+		}
+	}
+
+	tx, err := d.Decode(commit, []byte(`{"action":"C"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ChangeEvent{
+		{Schema: "public", Table: "orders", Action: ActionInsert, PrimaryKey: map[string]string{"id": "7"}, CommitLSN: commit},
+		{Schema: "public", Table: "orders", Action: ActionUpdate, PrimaryKey: map[string]string{"id": "6"}, CommitLSN: commit},
+		{Schema: "public", Table: "tags", Action: ActionDelete, PrimaryKey: map[string]string{"name": "a"}, CommitLSN: commit},
+	}
+	if tx == nil || tx.CommitLSN != commit || !reflect.DeepEqual(tx.Events, expected) {
+		t.Fatalf("want %+v at %s, got %+v", expected, commit, tx)
+	}
+
+	if _, err := d.Decode(commit, []byte("not json")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
@@ -41,6 +41,72 @@ func ParseLSN(lsnStr string) (LSN, error) {
 	}, nil
 }
 
+// maxLSNPartLength is the number of hexadecimal digits of the longest LSN part PostgreSQL formats
+const maxLSNPartLength = 8
+
+// LSNLimits bounds the LSNs accepted by ParseLSNStrict
+type LSNLimits struct {
+	MaxUpper uint32          // Highest plausible upper part, e.g. above the current WAL position with a margin; 0 for no bound
+	Validate func(LSN) error // Extra check of the parsed LSN, e.g. against the current WAL position; nil to skip
+}
+
+// ParseLSNStrict parses lsnStr like ParseLSN, rejecting the values PostgreSQL never formats, which ParseLSN
+// accepts as long as they fit: empty parts and parts of more than 8 hexadecimal digits. The parsed LSN must also
+// be within limits. Use it on LSNs from untrusted sources, such as the cookies of the HTTP middleware.
+func ParseLSNStrict(lsnStr string, limits LSNLimits) (LSN, error) {
+	upper, lower, found := strings.Cut(lsnStr, "/")
+	if !found {
+		return LSN{}, fmt.Errorf("invalid LSN format: %q (expected X/Y)", lsnStr)
+	}
+	for _, part := range []string{upper, lower} {
+		if part == "" || len(part) > maxLSNPartLength {
+			return LSN{}, fmt.Errorf("invalid LSN part: %q (expected 1 to %d hexadecimal digits)", part, maxLSNPartLength)
+		}
+	}
+	lsn, err := ParseLSN(lsnStr)
+	if err != nil {
+		return LSN{}, err
+	}
+	if err := limits.check(lsn); err != nil {
+		return LSN{}, err
+	}
+	return lsn, nil
+}
+
+// check returns an error if lsn is out of the limits
+func (l LSNLimits) check(lsn LSN) error {
+	if l.MaxUpper > 0 && lsn.Upper > l.MaxUpper {
+		return fmt.Errorf("implausible LSN %s: upper part above %X", lsn, l.MaxUpper)
+	}
+	if l.Validate != nil {
+		if err := l.Validate(lsn); err != nil {
+			return fmt.Errorf("rejected LSN %s: %w", lsn, err)
+		}
+	}
+	return nil
+}
+
+// FuzzLSN is a go-fuzz compatible entry point checking the invariants of the LSN parsers on data, for users
+// fuzzing their own LSN sources. It panics when an invariant breaks, and returns 1 when data parses, 0 otherwise.
+func FuzzLSN(data []byte) int {
+	input := string(data)
+	strict, strictErr := ParseLSNStrict(input, LSNLimits{})
+	lenient, err := ParseLSN(input)
+	if strictErr == nil && (err != nil || strict != lenient) {
+		panic(fmt.Sprintf("ParseLSNStrict accepted %q, which ParseLSN parses differently", input))
+	}
+	if err != nil {
+		return 0
+	}
+	if roundTrip, err := ParseLSNStrict(lenient.String(), LSNLimits{}); err != nil || roundTrip != lenient {
+		panic(fmt.Sprintf("LSN %s parsed from %q doesn't round trip: %v", lenient, input, err))
+	}
+	if lenient.LessThan(LSN{}) || lenient.Subtract(LSN{}) != uint64(lenient.Upper)<<32|uint64(lenient.Lower) {
+		panic(fmt.Sprintf("inconsistent arithmetic on LSN %s", lenient))
+	}
+	return 1
+}
+
 // String returns the string representation of the LSN in PostgreSQL format X/Y
 func (lsn LSN) String() string {
 	return fmt.Sprintf("%X/%X", lsn.Upper, lsn.Lower)
@@ -149,6 +215,9 @@ const (
 	// PostgreSQL function to get current WAL LSN from master
 	PGCurrentWALLSN = "pg_current_wal_lsn()"
 
+	// PostgreSQL function to get the current WAL insert location from master, see WithTxCommitLSN
+	PGCurrentWALInsertLSN = "pg_current_wal_insert_lsn()"
+
 	// PostgreSQL function to get last replay LSN from replica
 	PGLastWalReplayLSN = "pg_last_wal_replay_lsn()"
 
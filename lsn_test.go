@@ -3,6 +3,7 @@ package dbresolver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -374,3 +375,40 @@ func BenchmarkLSNCompare(b *testing.B) {
 		_ = lsn1.Compare(lsn2)
 	}
 }
+
+func TestParseLSNStrict(t *testing.T) {
+	limits := LSNLimits{MaxUpper: 0x10}
+	for _, valid := range []string{"0/0", "1/ABCDEF", "10/FFFFFFFF", "a/b"} {
+		if _, err := ParseLSNStrict(valid, limits); err != nil {
+			t.Errorf("want %q accepted, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"", "/1", "1/", "1/2/3", "000000001/0", "0/0000000001", "11/0", "+1/0", "1 /0"} {
+		if _, err := ParseLSNStrict(invalid, limits); err == nil {
+			t.Errorf("want %q rejected", invalid)
+		}
+	}
+	if _, err := ParseLSN("000000001/0"); err != nil {
+		t.Errorf("want ParseLSN to keep accepting padded parts, got %v", err)
+	}
+
+	errFuture := errors.New("ahead of the primary")
+	validate := LSNLimits{Validate: func(lsn LSN) error {
+		if lsn.GreaterThan(LSN{Upper: 1}) {
+			return errFuture
+		}
+		return nil
+	}}
+	if _, err := ParseLSNStrict("2/0", validate); !errors.Is(err, errFuture) {
+		t.Errorf("want the validation error, got %v", err)
+	}
+}
+
+func FuzzParseLSNStrict(f *testing.F) {
+	for _, seed := range []string{"0/3000060", "1/A0B1C2", "FFFFFFFF/FFFFFFFF", "000000001/0", "1/", "v1.1/2"} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzLSN(data)
+	})
+}
@@ -0,0 +1,24 @@
+package dbresolver
+
+import "context"
+
+// WithLSNTrackingErrorHandler calls handler with the errors of the LSN captures after writes, by the causal
+// router, the write forwarder, ExecReturningID and the commits of transactions. The write succeeded but its
+// causal token or LSN cookie is missing, so the next reads of the client may not observe it. Failures are
+// counted in Metrics.LSNTrackingFailures and reported to the fallback hook with or without handler, which runs on
+// the path of the writes and must not block.
+func WithLSNTrackingErrorHandler(handler func(ctx context.Context, err error)) OptionFunc {
+	return func(opt *Option) {
+		opt.LSNTrackingErrorHandler = handler
+	}
+}
+
+// lsnTrackingFailed reports a failed LSN capture after a write
+func (db *DB) lsnTrackingFailed(ctx context.Context, err error) {
+	db.metrics.lsnTrackingFailures.Add(1)
+	db.log().Warn("failed to capture the LSN of a write", "error", err)
+	db.hooks.fallback(FallbackEvent{QueryType: QueryTypeWrite, Reason: ReasonLSNTrackingFailed, Err: err})
+	if db.onLSNTrackingError != nil {
+		db.onLSNTrackingError(ctx, err)
+	}
+}
@@ -0,0 +1,53 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLSNTrackingErrorHandler(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	var failures []error
+	var events []FallbackEvent
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}),
+		WithRoutingHooks(nil, func(event FallbackEvent) { events = append(events, event) }, nil),
+		WithLSNTrackingErrorHandler(func(_ context.Context, err error) { failures = append(failures, err) }))
+	errDown := errors.New("connection reset by peer")
+
+	// by the causal router, after the write of the request
+	ctx := WithLSNContext(context.Background(), &LSNContext{})
+	primaryMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(errDown)
+	if _, err := db.ExecContext(ctx, "UPDATE users SET active = true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpdateLSNAfterWrite(ctx); !errors.Is(err, errDown) {
+		t.Fatalf("want the capture error returned, got %v", err)
+	}
+
+	// by the resolver, after a write returning an id
+	ctx = WithLSNContext(context.Background(), &LSNContext{})
+	primaryMock.ExpectQuery("INSERT INTO users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	primaryMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(errDown)
+	if _, err := db.InsertReturningID(ctx, "INSERT INTO users (name) VALUES ($1)", "alice"); err != nil {
+		t.Fatalf("want the write to succeed, got %v", err)
+	}
+
+	if len(failures) != 2 || !errors.Is(failures[0], errDown) || !errors.Is(failures[1], errDown) {
+		t.Errorf("want both failures handled, got %v", failures)
+	}
+	if len(events) != 2 || events[0].Reason != ReasonLSNTrackingFailed || events[1].Reason != ReasonLSNTrackingFailed {
+		t.Errorf("want both failures reported to the fallback hook, got %+v", events)
+	}
+	if m := db.Metrics(); m.LSNTrackingFailures != 2 {
+		t.Errorf("want 2 LSN tracking failures, got %d", m.LSNTrackingFailures)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
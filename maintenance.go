@@ -0,0 +1,52 @@
+package dbresolver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaintenanceLockTimeout      = 5 * time.Second
+	defaultMaintenanceStatementTimeout = time.Minute
+)
+
+// MaintenanceConfig configures the transactions of DB.Maintenance, see WithMaintenanceTimeouts
+type MaintenanceConfig struct {
+	LockTimeout      time.Duration // lock_timeout, defaults to 5s
+	StatementTimeout time.Duration // statement_timeout, defaults to 1m
+}
+
+// WithMaintenanceTimeouts overrides the lock_timeout and statement_timeout of the transactions of DB.Maintenance
+func WithMaintenanceTimeouts(config MaintenanceConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.LockTimeout <= 0 {
+			config.LockTimeout = defaultMaintenanceLockTimeout
+		}
+		if config.StatementTimeout <= 0 {
+			config.StatementTimeout = defaultMaintenanceStatementTimeout
+		}
+		opt.Maintenance = config
+	}
+}
+
+// Maintenance begins a transaction on the primary for schema migrations and other maintenance, with lock_timeout
+// and statement_timeout set locally to the transaction: a DDL waiting for the lock of a table held by a long-running
+// query fails after lock_timeout instead of queueing every later query on the table behind it. The timeouts default to 5s and 1m, see
+// WithMaintenanceTimeouts. Statements that can't run in a transaction, such as CREATE INDEX CONCURRENTLY, run on
+// a connection from db.Conn instead.
+func (db *DB) Maintenance(ctx context.Context) (Tx, error) {
+	t, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.ExecContext(ctx, "SELECT set_config('lock_timeout', $1, true), set_config('statement_timeout', $2, true)",
+		strconv.FormatInt(db.maintenance.LockTimeout.Milliseconds(), 10),
+		strconv.FormatInt(db.maintenance.StatementTimeout.Milliseconds(), 10))
+	if err != nil {
+		_ = t.Rollback()
+		return nil, fmt.Errorf("failed to set the maintenance timeouts: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMaintenance(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithMaintenanceTimeouts(MaintenanceConfig{LockTimeout: 2 * time.Second}))
+	setTimeouts := regexp.QuoteMeta("SELECT set_config('lock_timeout', $1, true), set_config('statement_timeout', $2, true)")
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(setTimeouts).WithArgs("2000", "60000").WillReturnResult(sqlmock.NewResult(0, 0))
+	primaryMock.ExpectExec("ALTER TABLE users").WillReturnResult(sqlmock.NewResult(0, 0))
+	primaryMock.ExpectCommit()
+	tx, err := db.Maintenance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(context.Background(), "ALTER TABLE users ADD COLUMN age int"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(setTimeouts).WillReturnError(errors.New("permission denied"))
+	primaryMock.ExpectRollback()
+	if _, err := db.Maintenance(context.Background()); err == nil {
+		t.Error("want an error when the timeouts can't be set")
+	}
+
+	db.EnterReadOnlyMode()
+	if _, err := db.Maintenance(context.Background()); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode, got %v", err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
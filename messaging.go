@@ -0,0 +1,34 @@
+package dbresolver
+
+import (
+	"context"
+	"fmt"
+)
+
+// CausalTokenHeader is the message header carrying the causal token of the write that produced an event
+const CausalTokenHeader = "pg-causal-token"
+
+// InjectCausalToken stores the causal token of ctx in the message headers, so consumers reading
+// from replicas see at least the state produced by the write that emitted the message.
+// The headers are a generic carrier that maps onto Kafka, NATS or AMQP headers; the header
+// is left untouched when the context carries no LSN requirement.
+func (db *DB) InjectCausalToken(ctx context.Context, headers map[string][]byte) error {
+	token, err := db.CausalToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to inject causal token: %w", err)
+	}
+	if token != "" {
+		headers[CausalTokenHeader] = []byte(token)
+	}
+	return nil
+}
+
+// ExtractCausalToken returns a context whose reads honor the causal token found in the message headers.
+// Messages without a token, or with one that doesn't verify, leave ctx unchanged.
+func (db *DB) ExtractCausalToken(ctx context.Context, headers map[string][]byte) context.Context {
+	token, ok := headers[CausalTokenHeader]
+	if !ok {
+		return ctx
+	}
+	return db.WithCausalToken(ctx, string(token))
+}
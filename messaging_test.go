@@ -0,0 +1,41 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCausalTokenMessageHeaders(t *testing.T) {
+	primary, _ := newMockDB(t)
+	writeLSN := LSN{Upper: 1, Lower: 0x2000}
+
+	producer := New(WithPrimaryDBs(primary), WithCausalTokenKey([]byte("events")))
+	producer.queryRouter = &fixedLSNRouter{lsn: writeLSN}
+	consumer := New(WithPrimaryDBs(primary), WithCausalTokenKey([]byte("events")))
+
+	headers := map[string][]byte{"trace-id": []byte("abc")}
+	ctx := WithLSNContext(context.Background(), &LSNContext{HasWriteOperation: true})
+	if err := producer.InjectCausalToken(ctx, headers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(headers[CausalTokenHeader]) == 0 {
+		t.Fatal("expected the causal token header to be set")
+	}
+
+	lsnCtx := GetLSNContext(consumer.ExtractCausalToken(context.Background(), headers))
+	if lsnCtx == nil || lsnCtx.RequiredLSN != writeLSN {
+		t.Fatalf("expected required LSN %s for the consumer, got %+v", writeLSN, lsnCtx)
+	}
+
+	// messages produced without a write carry no header and impose no requirement
+	empty := map[string][]byte{}
+	if err := producer.InjectCausalToken(context.Background(), empty); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := empty[CausalTokenHeader]; ok {
+		t.Error("no header expected without an LSN requirement")
+	}
+	if GetLSNContext(consumer.ExtractCausalToken(context.Background(), empty)) != nil {
+		t.Error("messages without a token must leave the context unchanged")
+	}
+}
@@ -0,0 +1,262 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lsnCheckBuckets are the upper bounds, in seconds, of the LSN check latency histogram
+var lsnCheckBuckets = [...]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// latencyHistogram is a lock-free histogram with the lsnCheckBuckets bounds
+type latencyHistogram struct {
+	counts [len(lsnCheckBuckets) + 1]atomic.Uint64 // one per bucket, plus +Inf
+	count  atomic.Uint64
+	sum    atomic.Uint64 // float64 bits of the sum in seconds
+}
+
+// observe records a single duration
+func (h *latencyHistogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(lsnCheckBuckets) && seconds > lsnCheckBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+seconds)) {
+			return
+		}
+	}
+}
+
+// snapshot returns the histogram with cumulative bucket counts
+func (h *latencyHistogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Buckets: make(map[float64]uint64, len(lsnCheckBuckets)),
+		Count:   h.count.Load(),
+		Sum:     math.Float64frombits(h.sum.Load()),
+	}
+	var cumulative uint64
+	for i, bound := range lsnCheckBuckets {
+		cumulative += h.counts[i].Load()
+		s.Buckets[bound] = cumulative
+	}
+	return s
+}
+
+// HistogramSnapshot is a point in time copy of a latency histogram
+type HistogramSnapshot struct {
+	Buckets map[float64]uint64 // cumulative count of observations per upper bound, in seconds
+	Count   uint64
+	Sum     float64 // sum of all observations, in seconds
+}
+
+// routingMetrics counts the routing decisions of a DB
+type routingMetrics struct {
+	replicaReads atomic.Uint64
+	primaryReads atomic.Uint64
+
+	forwardedWrites atomic.Uint64
+	forwardErrors   atomic.Uint64
+	forwardLatency  latencyHistogram
+
+	dualWrites          atomic.Uint64
+	dualWriteMismatches atomic.Uint64
+
+	lsnTrackingFailures atomic.Uint64
+
+	prepareFailures sync.Map // *sql.DB -> *atomic.Uint64
+}
+
+// prepareFailed counts a statement that failed to prepare on target
+func (m *routingMetrics) prepareFailed(target *sql.DB) {
+	counter, _ := m.prepareFailures.LoadOrStore(target, new(atomic.Uint64))
+	counter.(*atomic.Uint64).Add(1)
+}
+
+// prepareFailuresOf returns the number of statements that failed to prepare on target
+func (m *routingMetrics) prepareFailuresOf(target *sql.DB) uint64 {
+	if counter, ok := m.prepareFailures.Load(target); ok {
+		return counter.(*atomic.Uint64).Load()
+	}
+	return 0
+}
+
+// routerMetrics counts the LSN based decisions of a CausalRouter
+type routerMetrics struct {
+	lsnFallbacks     atomic.Uint64
+	skippedLSNChecks atomic.Uint64
+	lsnChecks        latencyHistogram
+	catchUps         catchUpTracker
+	// failed LSN captures after writes
+	lsnTrackingFailures atomic.Uint64
+}
+
+// Metrics is a point in time snapshot of the routing metrics of a DB
+type Metrics struct {
+	ReplicaReads    uint64            // reads routed to a replica
+	PrimaryReads    uint64            // reads routed to a primary
+	LSNFallbacks    uint64            // reads sent to a primary because no replica caught up to the required LSN
+	LSNCheckLatency HistogramSnapshot // latency of replica replay LSN checks
+	// SkippedLSNChecks counts the reads served by a replica without checking their required LSN, see
+	// LSNContext.SkipCheckRate
+	SkippedLSNChecks uint64
+	ForwardedWrites  uint64            // writes executed by the write forwarder, see WithWriteForwarder
+	ForwardErrors    uint64            // forwarded writes that failed
+	ForwardLatency   HistogramSnapshot // latency of forwarded writes, including their LSN capture
+	// DualWrites counts the writes replayed on the target of WithDualWrite, DualWriteMismatches the ones whose
+	// outcome differed from the primary
+	DualWrites          uint64
+	DualWriteMismatches uint64
+	// LSNTrackingFailures counts the writes whose LSN couldn't be captured, whose causal token or LSN cookie is
+	// missing, so the next reads of the client may not observe them; see WithLSNTrackingErrorHandler
+	LSNTrackingFailures uint64
+	ReplicaCatchUp      HistogramSnapshot // time replicas took to replay the captured writes, see RecommendedCookieMaxAge
+	// RecommendedCookieMaxAge is the p99 of ReplicaCatchUp, zero until a catch-up was observed
+	RecommendedCookieMaxAge time.Duration
+	PreparedStatements      int // open statements created with Prepare
+	// FallbackRatio is the smoothed ratio of reads falling back to a primary, see WithFallbackRatio
+	FallbackRatio float64
+	Primaries     []PhysicalDBMetrics
+	Replicas      []PhysicalDBMetrics
+}
+
+// PhysicalDBMetrics describes one physical database
+type PhysicalDBMetrics struct {
+	Name  string        // name given with WithNamedPrimaryDBs or WithNamedReplicaDBs, or "<role>-<index>"
+	Index int           // position in the primary or replica list passed to New
+	Class ResourceClass // resource class of a dedicated replica, empty for the regular replicas
+	Stats sql.DBStats   // connection pool statistics
+	// LagBytes is the replication lag of a replica, computed from the last observed master
+	// and replay LSNs; HasLag is false until both have been observed
+	LagBytes uint64
+	HasLag   bool
+	Delayed  bool // whether the replica never serves reads, see WithDelayedReplicas
+	// PrepareFailures counts the statements that failed to prepare on the database, including the replica
+	// statements served by a primary instead
+	PrepareFailures uint64
+	// FallbackStatements is the number of open statements of a replica served by a primary because they failed to
+	// prepare on it, which moves their reads to the primary
+	FallbackStatements int
+}
+
+// Metrics returns a snapshot of the routing metrics and connection pool statistics.
+// No database is queried: replica lag is derived from the LSNs observed while routing.
+func (db *DB) Metrics() Metrics {
+	m := Metrics{
+		ReplicaReads:    db.metrics.replicaReads.Load(),
+		PrimaryReads:    db.metrics.primaryReads.Load(),
+		ForwardedWrites: db.metrics.forwardedWrites.Load(),
+		ForwardErrors:   db.metrics.forwardErrors.Load(),
+		ForwardLatency:  db.metrics.forwardLatency.snapshot(),
+
+		DualWrites:          db.metrics.dualWrites.Load(),
+		DualWriteMismatches: db.metrics.dualWriteMismatches.Load(),
+		LSNTrackingFailures: db.metrics.lsnTrackingFailures.Load(),
+	}
+	if router, ok := db.queryRouter.(*CausalRouter); ok {
+		m.LSNFallbacks = router.metrics.lsnFallbacks.Load()
+		m.SkippedLSNChecks = router.metrics.skippedLSNChecks.Load()
+		m.LSNTrackingFailures += router.metrics.lsnTrackingFailures.Load()
+		m.LSNCheckLatency = router.metrics.lsnChecks.snapshot()
+		m.ReplicaCatchUp = router.metrics.catchUps.snapshot()
+		m.RecommendedCookieMaxAge, _ = router.RecommendedCookieMaxAge()
+	} else {
+		m.LSNCheckLatency = (&latencyHistogram{}).snapshot()
+		m.ReplicaCatchUp = (&catchUpTracker{}).snapshot()
+	}
+
+	m.FallbackRatio, _ = db.FallbackRatio()
+	var fallbacks map[*sql.DB]int
+	m.PreparedStatements, fallbacks = db.statementMetrics()
+
+	var masterLSN LSN
+	for i, primary := range db.allPrimaries() {
+		m.Primaries = append(m.Primaries, PhysicalDBMetrics{
+			Name: physicalDBName(db, primary), Index: i, Stats: primary.Stats(), PrepareFailures: db.metrics.prepareFailuresOf(primary),
+		})
+		if checker := lookupChecker(primary); checker != nil {
+			if lsn, _, ok := checker.cachedWALLSN(); ok && lsn.GreaterThan(masterLSN) {
+				masterLSN = lsn
+			}
+		}
+	}
+	m.Replicas = db.appendReplicaMetrics(m.Replicas, "", db.allReplicas(), masterLSN, fallbacks)
+
+	classes := make([]ResourceClass, 0, len(db.classReplicas))
+	for class := range db.classReplicas {
+		classes = append(classes, class)
+	}
+	slices.Sort(classes)
+	for _, class := range classes {
+		m.Replicas = db.appendReplicaMetrics(m.Replicas, class, db.classReplicas[class], masterLSN, nil)
+	}
+	return m
+}
+
+// appendReplicaMetrics appends the metrics of the replicas of a resource class
+func (db *DB) appendReplicaMetrics(
+	m []PhysicalDBMetrics, class ResourceClass, replicas []*sql.DB, masterLSN LSN, fallbacks map[*sql.DB]int,
+) []PhysicalDBMetrics {
+	for i, replica := range replicas {
+		replicaMetrics := PhysicalDBMetrics{
+			Name: physicalDBName(db, replica), Index: i, Class: class, Stats: replica.Stats(),
+			PrepareFailures: db.metrics.prepareFailuresOf(replica), FallbackStatements: fallbacks[replica],
+		}
+		_, replicaMetrics.Delayed = db.delay.get(replica)
+		if checker := lookupChecker(replica); checker != nil && !masterLSN.IsZero() {
+			if lsn, _, ok := checker.CachedReplayLSN(); ok {
+				replicaMetrics.LagBytes = masterLSN.Subtract(lsn)
+				replicaMetrics.HasLag = true
+			}
+		}
+		m = append(m, replicaMetrics)
+	}
+	return m
+}
+
+// statementMetrics returns the number of open statements and, per replica, the number of them served by a primary
+func (db *DB) statementMetrics() (int, map[*sql.DB]int) {
+	var open int
+	fallbacks := make(map[*sql.DB]int)
+	db.stmts.Range(func(key, _ any) bool {
+		s := key.(*stmt)
+		open++
+		s.mu.RLock()
+		for i, replica := range s.replicaDBs {
+			if slices.Contains(s.primaryStmts, s.replicaStmts[i]) {
+				fallbacks[replica]++
+			}
+		}
+		s.mu.RUnlock()
+		return true
+	})
+	return open, fallbacks
+}
+
+// recordRead counts a read routed to curDB and enforces the primary read guard
+func (db *DB) recordRead(ctx context.Context, curDB *sql.DB) error {
+	primary := false
+	for _, p := range db.allPrimaries() {
+		if p == curDB {
+			primary = true
+			break
+		}
+	}
+
+	db.fallbackRatio.read()
+	if primary {
+		db.metrics.primaryReads.Add(1)
+	} else {
+		db.metrics.replicaReads.Add(1)
+	}
+	return db.readGuard.check(ctx, primary)
+}
@@ -0,0 +1,117 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	h.observe(200 * time.Microsecond)
+	h.observe(3 * time.Millisecond)
+	h.observe(2 * time.Second)
+
+	s := h.snapshot()
+	if s.Count != 3 {
+		t.Errorf("want 3 observations, got %d", s.Count)
+	}
+	if s.Buckets[0.0005] != 1 || s.Buckets[0.005] != 2 || s.Buckets[1] != 2 {
+		t.Errorf("unexpected cumulative buckets: %v", s.Buckets)
+	}
+	if s.Sum < 2.003 || s.Sum > 2.0033 {
+		t.Errorf("unexpected sum: %f", s.Sum)
+	}
+}
+
+func TestDBMetrics(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            ReadYourWrites,
+			FallbackToMaster: true,
+			Timeout:          time.Second,
+		}),
+		WithLoadBalancer(RoundRobinLB),
+	)
+
+	// a write captures the master LSN
+	primaryMock.ExpectExec("INSERT").WillReturnResult(sqlmock.NewResult(1, 1))
+	primaryMock.ExpectQuery("pg_current_wal_lsn").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000"))
+	ctx := WithLSNContext(context.Background(), &LSNContext{})
+	if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	required, err := db.queryRouter.UpdateLSNAfterWrite(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica lags, so the read falls back to the primary
+	expectReplayLSN(replicaMock, "0/1000")
+	primaryMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	readCtx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: required})
+	rows, err := db.QueryContext(readCtx, "SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	// without a requirement the read goes to the replica
+	replicaMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err = db.QueryContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	m := db.Metrics()
+	if m.ReplicaReads != 1 || m.PrimaryReads != 1 || m.LSNFallbacks != 1 {
+		t.Errorf("unexpected routing counters: %+v", m)
+	}
+	if m.LSNCheckLatency.Count != 1 {
+		t.Errorf("want one LSN check, got %d", m.LSNCheckLatency.Count)
+	}
+	if len(m.Primaries) != 1 || len(m.Replicas) != 1 {
+		t.Fatalf("expected one primary and one replica, got %+v", m)
+	}
+	if !m.Replicas[0].HasLag || m.Replicas[0].LagBytes != 0x2000 {
+		t.Errorf("want replica lag of 0x2000 bytes, got %+v", m.Replicas[0])
+	}
+}
+
+func TestStatementMetrics(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	healthy, healthyMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica, healthy))
+
+	primaryMock.ExpectPrepare("SELECT name FROM users")
+	replicaMock.ExpectPrepare("SELECT name FROM users").WillReturnError(errConnReset)
+	healthyMock.ExpectPrepare("SELECT name FROM users")
+	if _, err := db.Prepare("SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := db.Metrics()
+	if m.PreparedStatements != 1 {
+		t.Errorf("want one open statement, got %d", m.PreparedStatements)
+	}
+	if got := m.Replicas[0]; got.PrepareFailures != 1 || got.FallbackStatements != 1 {
+		t.Errorf("want the failed statement counted on the replica, got %+v", got)
+	}
+	if got := m.Replicas[1]; got.PrepareFailures != 0 || got.FallbackStatements != 0 {
+		t.Errorf("want no failure on the healthy replica, got %+v", got)
+	}
+	if m.Primaries[0].PrepareFailures != 0 {
+		t.Errorf("want no failure on the primary, got %+v", m.Primaries[0])
+	}
+}
@@ -2,7 +2,10 @@ package dbresolver
 
 import (
 	"context"
+	"log/slog"
+	"math"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,12 +16,21 @@ type lsnResponseWriter struct {
 	http.ResponseWriter
 	middleware  *HTTPMiddleware
 	ctx         context.Context
+	sessionKey  string
+	deadline    time.Time // end of the write latency budget, zero if unbounded
 	wroteHeader bool
 	statusCode  int
+
+	persistedLSN LSN // LSN delivered with the response headers, zero if none
 }
 
 // WriteHeader intercepts the WriteHeader call to set LSN cookies when appropriate
 func (lrw *lsnResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		// Informational responses, such as 103 Early Hints, precede the final headers
+		lrw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
 	if !lrw.wroteHeader {
 		lrw.statusCode = statusCode
 		lrw.wroteHeader = true
@@ -27,8 +39,12 @@ func (lrw *lsnResponseWriter) WriteHeader(statusCode int) {
 		if statusCode >= 200 && statusCode < 300 {
 			if lsnCtx := GetLSNContext(lrw.ctx); lsnCtx != nil && lsnCtx.HasWriteOperation {
 				// Get LSN from router and set cookie
-				if lsn, err := lrw.middleware.router.UpdateLSNAfterWrite(lrw.ctx); err == nil && !lsn.IsZero() {
-					SetLSNCookie(lrw.ResponseWriter, lsn, lrw.middleware.cookieName, lrw.middleware.cookieMaxAge, lrw.middleware.cookieSecure)
+				if lsn, ok := lrw.middleware.captureLSN(lrw.ctx, lrw.sessionKey, lrw.deadline); ok && !lsn.IsZero() {
+					lrw.middleware.persistLSN(lrw.ctx, lrw.ResponseWriter, lrw.sessionKey, lsn)
+					lrw.persistedLSN = lsn
+					if lrw.middleware.streamingToken != "" && lrw.middleware.sessionStore == nil {
+						lrw.ResponseWriter.Header().Set(lrw.middleware.streamingToken, EncodeConsistencyToken(lsn, lrw.middleware.tokenVersion))
+					}
 				}
 			}
 		}
@@ -37,38 +53,222 @@ func (lrw *lsnResponseWriter) WriteHeader(statusCode int) {
 	}
 }
 
-func (lrw *lsnResponseWriter) reset(ctx context.Context, w http.ResponseWriter) {
+// Write writes the headers first, like http.ResponseWriter, so that they carry the LSN cookie
+func (lrw *lsnResponseWriter) Write(b []byte) (int, error) {
+	if !lrw.wroteHeader {
+		lrw.WriteHeader(http.StatusOK)
+	}
+	return lrw.ResponseWriter.Write(b)
+}
+
+// Flush writes the headers and flushes the buffered data of streaming responses
+func (lrw *lsnResponseWriter) Flush() {
+	if !lrw.wroteHeader {
+		lrw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController
+func (lrw *lsnResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+func (lrw *lsnResponseWriter) reset(ctx context.Context, w http.ResponseWriter, sessionKey string, deadline time.Time) {
 	lrw.ResponseWriter = w
 	lrw.ctx = ctx
+	lrw.sessionKey = sessionKey
+	lrw.deadline = deadline
 	lrw.wroteHeader = false
 	lrw.statusCode = 0
+	lrw.persistedLSN = LSN{}
 }
 
 // HTTPMiddleware provides HTTP middleware for LSN-aware database routing
 // Optimized version with automatic cookie setting via response wrapper
 type HTTPMiddleware struct {
-	router       QueryRouter
-	cookieName   string
-	cookieMaxAge time.Duration
-	cookieSecure bool
+	router       LSNTracker
+	capability   CausalConsistencyCapability
+	cookie       CookieOptions
+	tokenVersion TokenVersion
 	wrapperPool  *sync.Pool
+
+	// server-side LSN tracking, used instead of the cookie when set
+	sessionStore SessionLSNStore
+	sessionKey   SessionKeyFunc
+
+	// optional bound on the time spent capturing the LSN of a write
+	writeBudget *writeBudget
+
+	logger *slog.Logger
+
+	// reports requests excluded from consistency tracking
+	isAnonymous func(r *http.Request) bool
+
+	// reports requests bypassing the middleware
+	skip func(r *http.Request) bool
+
+	// header and trailer carrying the consistency token of streaming responses, empty if disabled
+	streamingToken string
+
+	// bounds of the cookie MaxAge recommended by the router, nil to use cookieMaxAge
+	adaptiveMaxAge *adaptiveMaxAge
+
+	// limits of the LSNs read from the requests, nil to parse them with ParseLSN
+	lsnLimits *LSNLimits
+
+	// consistency levels of the routes, see WithRouteConsistency
+	routeLevels []RouteConsistencyRule
 }
 
-// NewHTTPMiddleware creates new HTTP middleware for LSN tracking
-// maxAge determine your threshold of avg time sync between master and replica
-func NewHTTPMiddleware(router QueryRouter, cookieName string, maxAge time.Duration, useSecureCookie bool) *HTTPMiddleware {
-	if cookieName == "" {
-		cookieName = "pg_min_lsn"
+// CausalConsistencyCapability is implemented by components that can report whether
+// causal consistency routing is currently active, such as *DB and *CausalRouter
+type CausalConsistencyCapability interface {
+	IsCausalConsistencyEnabled() bool
+}
+
+// ConsistencyConfigProvider is implemented by routers exposing their causal consistency configuration, such as
+// *DB and *CausalRouter. The middleware takes the LSN cookie name and MaxAge it isn't given from it.
+type ConsistencyConfigProvider interface {
+	ConsistencyConfig() (CausalConsistencyConfig, bool)
+}
+
+// MiddlewareOption configures optional HTTPMiddleware behavior
+type MiddlewareOption func(m *HTTPMiddleware)
+
+// WithConsistencyCapability sets the component consulted on every request to find out whether
+// causal consistency is enabled. When it reports disabled, the middleware stops creating LSN
+// contexts and expires LSN cookies still carried by clients.
+// Defaults to the router itself if it implements CausalConsistencyCapability.
+func WithConsistencyCapability(capability CausalConsistencyCapability) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.capability = capability
 	}
-	if maxAge <= 0 {
-		maxAge = 5 * time.Minute
+}
+
+// WithTokenVersion sets the consistency token version written to LSN cookies.
+// Defaults to TokenVersionLegacy, which every release understands; bump it only once
+// all application instances are able to parse the new version.
+func WithTokenVersion(version TokenVersion) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.tokenVersion = version
+	}
+}
+
+// WithMiddlewareLogger sets the logger used by the middleware instead of slog.Default()
+func WithMiddlewareLogger(logger *slog.Logger) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.logger = componentLogger(logger, LogComponentMiddleware, nil)
+	}
+}
+
+// WithAnonymousRequests excludes the requests isAnonymous reports, e.g. unauthenticated or crawler traffic,
+// from consistency tracking: their writes don't pin later reads to the primary, they never receive an
+// LSN cookie or session entry, and cookies they still carry are expired.
+func WithAnonymousRequests(isAnonymous func(r *http.Request) bool) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.isAnonymous = isAnonymous
+	}
+}
+
+// WithSkipFunc bypasses the middleware for the requests skip reports, e.g. health checks, static assets and
+// webhooks: their cookies aren't parsed, no LSN context is created and the response isn't wrapped. Unlike
+// WithAnonymousRequests, the LSN cookies they carry are left untouched.
+func WithSkipFunc(skip func(r *http.Request) bool) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.skip = skip
+	}
+}
+
+// WithStrictLSNParsing parses the LSNs of the cookies and streaming tokens of the requests with ParseLSNStrict
+// within limits, ignoring the ones rejected, so hostile values can't require implausible LSNs that no replica
+// reaches, pinning the reads of the request to the primary.
+func WithStrictLSNParsing(limits LSNLimits) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.lsnLimits = &limits
+	}
+}
+
+// CookieOptions configures the LSN cookie of NewHTTPMiddlewareWithCookie
+type CookieOptions struct {
+	Name     string        // Defaults to "pg_min_lsn"
+	MaxAge   time.Duration // Threshold of the avg time sync between master and replica, defaults to 5m
+	Secure   bool
+	SameSite http.SameSite // Defaults to http.SameSiteLaxMode
+	Domain   string
+	Path     string // Defaults to "/"
+	HttpOnly bool   //nolint:revive // named like http.Cookie
+}
+
+// DefaultCookieOptions returns the options of the LSN cookie set by NewHTTPMiddleware, to be adjusted
+// for NewHTTPMiddlewareWithCookie
+func DefaultCookieOptions() CookieOptions {
+	return CookieOptions{
+		Name:     "pg_min_lsn",
+		MaxAge:   5 * time.Minute,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+		HttpOnly: true,
+	}
+}
+
+// withDefaults fills the unset options with their default
+func (c CookieOptions) withDefaults() CookieOptions {
+	defaults := DefaultCookieOptions()
+	if c.Name == "" {
+		c.Name = defaults.Name
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = defaults.MaxAge
+	}
+	if c.SameSite == 0 {
+		c.SameSite = defaults.SameSite
 	}
+	if c.Path == "" {
+		c.Path = defaults.Path
+	}
+	return c
+}
+
+// NewHTTPMiddleware creates new HTTP middleware for LSN tracking
+// maxAge determine your threshold of avg time sync between master and replica.
+// router is any LSNTracker: a *DB, a QueryRouter such as *CausalRouter or *SimpleRouter, or the resolver of
+// another backend. An empty cookieName or a zero maxAge is taken from the config of a ConsistencyConfigProvider
+// router, else defaults to "pg_min_lsn" and 5m.
+// It sets an HttpOnly LSN cookie, see NewHTTPMiddlewareWithCookie for the other cookie attributes.
+func NewHTTPMiddleware(
+	router LSNTracker, cookieName string, maxAge time.Duration, useSecureCookie bool, opts ...MiddlewareOption,
+) *HTTPMiddleware {
+	cookie := DefaultCookieOptions()
+	cookie.Name, cookie.MaxAge, cookie.Secure = cookieName, maxAge, useSecureCookie
+	return NewHTTPMiddlewareWithCookie(router, cookie, opts...)
+}
 
+// NewHTTPMiddlewareWithCookie creates new HTTP middleware for LSN tracking with the LSN cookie set by cookie.
+// Unset fields take their default, except HttpOnly: start from DefaultCookieOptions to keep it. Like with
+// NewHTTPMiddleware, an unset Name or MaxAge is first taken from the config of a ConsistencyConfigProvider router.
+func NewHTTPMiddlewareWithCookie(router LSNTracker, cookie CookieOptions, opts ...MiddlewareOption) *HTTPMiddleware {
+	if provider, ok := router.(ConsistencyConfigProvider); ok {
+		if config, ok := provider.ConsistencyConfig(); ok {
+			if cookie.Name == "" {
+				cookie.Name = config.CookieName
+			}
+			if cookie.MaxAge <= 0 {
+				cookie.MaxAge = config.CookieMaxAge
+			}
+		}
+	}
 	m := &HTTPMiddleware{
-		router:       router,
-		cookieName:   cookieName,
-		cookieMaxAge: maxAge,
-		cookieSecure: useSecureCookie,
+		router: router,
+		cookie: cookie.withDefaults(),
+	}
+	if capability, ok := router.(CausalConsistencyCapability); ok {
+		m.capability = capability
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
 	// Initialize wrapper pool for reuse
@@ -87,49 +287,179 @@ func NewHTTPMiddleware(router QueryRouter, cookieName string, maxAge time.Durati
 // Enhanced version with automatic cookie setting via response wrapper
 func (m *HTTPMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.skip != nil && m.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
 		ctx := r.Context()
+		start := time.Now()
 
-		// Extract LSN from cookie if present
-		requiredLSN, hasLSN := GetLSNFromCookie(r, m.cookieName)
+		// Causal consistency was disabled (e.g. by a deploy) while clients still carry cookies,
+		// or the request is anonymous: skip LSN tracking entirely and expire the stale cookie
+		if (m.capability != nil && !m.capability.IsCausalConsistencyEnabled()) || (m.isAnonymous != nil && m.isAnonymous(r)) {
+			if cookie, err := r.Cookie(m.cookie.Name); err == nil && cookie.Value != "" {
+				m.cookie.clear(w)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Extract LSN from the session store or cookie if present
+		sessionKey := ""
+		if m.sessionStore != nil {
+			sessionKey = m.sessionKey(r)
+		}
+		requiredLSN, hasLSN := m.requiredLSN(ctx, r, sessionKey)
 
 		// Create LSN context only if cookie exists
 		lsnCtx := &LSNContext{}
 		if hasLSN {
 			lsnCtx.RequiredLSN = requiredLSN
 		}
-		ctx = WithLSNContext(ctx, lsnCtx)
+		if level, ok := m.routeLevel(r); ok {
+			lsnCtx.Level, lsnCtx.OverrideLevel = level, true
+		}
+		ctx = WithReadAccumulator(WithLSNContext(ctx, lsnCtx))
 
 		// Get response writer from pool and set up for reuse
 		rw := m.wrapperPool.Get().(*lsnResponseWriter)
 		defer m.wrapperPool.Put(rw)
 
-		rw.reset(ctx, w)
+		var deadline time.Time
+		if m.writeBudget != nil {
+			deadline = m.writeBudget.deadline(ctx, start)
+		}
+		rw.reset(ctx, w, sessionKey, deadline)
 
 		// Call next handler with wrapped response writer
 		next.ServeHTTP(rw, r.WithContext(ctx))
+		if m.streamingToken != "" {
+			rw.finishStreaming()
+		}
 	})
 }
 
+// log returns the logger of the middleware
+func (m *HTTPMiddleware) log() *slog.Logger {
+	return loggerOrDefault(m.logger)
+}
+
+// requiredLSN returns the LSN the request must observe, read from the session store
+// when configured or from the LSN cookie otherwise
+func (m *HTTPMiddleware) requiredLSN(ctx context.Context, r *http.Request, sessionKey string) (LSN, bool) {
+	if m.sessionStore == nil {
+		if lsn, ok := m.cookieLSN(r); ok || m.streamingToken == "" {
+			return lsn, ok
+		}
+		return m.streamingTokenLSN(r)
+	}
+	if sessionKey == "" {
+		return LSN{}, false
+	}
+
+	lsn, ok, err := m.sessionStore.Get(ctx, sessionKey)
+	if err != nil {
+		m.log().Debug("HTTPMiddleware: failed to read LSN from session store", "error", err)
+		return LSN{}, false
+	}
+	return lsn, ok && !lsn.IsZero()
+}
+
+// cookieLSN returns the LSN of the consistency token cookie of the request
+func (m *HTTPMiddleware) cookieLSN(r *http.Request) (LSN, bool) {
+	if m.lsnLimits == nil {
+		return GetLSNFromCookie(r, m.cookie.Name)
+	}
+	cookie, err := r.Cookie(m.cookie.Name)
+	if err != nil || cookie.Value == "" {
+		return LSN{}, false
+	}
+	lsn, err := m.tokenLSN(cookie.Value)
+	if err != nil {
+		m.log().Debug("HTTPMiddleware: ignoring invalid LSN cookie", "error", err)
+		return LSN{}, false
+	}
+	return lsn, true
+}
+
+// tokenLSN parses the LSN of a consistency token read from a request, strictly with WithStrictLSNParsing
+func (m *HTTPMiddleware) tokenLSN(value string) (LSN, error) {
+	token, err := ParseConsistencyToken(value)
+	if err != nil || m.lsnLimits == nil {
+		return token.LSN, err
+	}
+	field := value
+	if _, rest, versioned, _ := splitTokenVersion(value); versioned {
+		field, _, _ = strings.Cut(rest, tokenFieldSeparator)
+	}
+	return ParseLSNStrict(field, *m.lsnLimits)
+}
+
+// persistLSN records the LSN of a write in the session store when configured or in the LSN cookie otherwise
+func (m *HTTPMiddleware) persistLSN(ctx context.Context, w http.ResponseWriter, sessionKey string, lsn LSN) {
+	if m.sessionStore == nil {
+		m.cookie.set(w, EncodeConsistencyToken(lsn, m.tokenVersion), m.tokenMaxAge())
+		return
+	}
+	if sessionKey == "" {
+		return
+	}
+
+	if err := m.sessionStore.Set(ctx, sessionKey, lsn, m.tokenMaxAge()); err != nil {
+		m.log().Debug("HTTPMiddleware: failed to store LSN in session store", "error", err)
+	}
+}
+
 // SetLSNCookie is a helper function to set LSN cookie after write operations
 // Call this explicitly after write operations instead of relying on response wrapping
 func SetLSNCookie(w http.ResponseWriter, lsn LSN, cookieName string, maxAge time.Duration, secure bool) {
 	if lsn.IsZero() {
 		return
 	}
-	if cookieName == "" {
-		cookieName = "pg_min_lsn"
-	}
+	setTokenCookie(w, lsn.String(), cookieName, maxAge, secure)
+}
+
+// setTokenCookie sets the LSN cookie to the encoded consistency token
+func setTokenCookie(w http.ResponseWriter, token, cookieName string, maxAge time.Duration, secure bool) {
+	cookie := DefaultCookieOptions()
+	cookie.Name, cookie.Secure = cookieName, secure
+	cookie.withDefaults().set(w, token, maxAge)
+}
+
+// set sets the LSN cookie to the encoded consistency token
+func (c CookieOptions) set(w http.ResponseWriter, token string, maxAge time.Duration) {
 	if maxAge <= 0 {
-		maxAge = 5 * time.Minute
+		maxAge = c.MaxAge
 	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.Name,
+		Value:    token,
+		MaxAge:   int(math.Ceil(maxAge.Seconds())), // threshold on avg time your database sync took.
+		HttpOnly: c.HttpOnly,
+		Secure:   c.Secure, // Set to true in production with HTTPS
+		Path:     c.Path,
+		Domain:   c.Domain,
+		SameSite: c.SameSite,
+	})
+}
+
+// ClearLSNCookie expires the LSN cookie on the client
+func ClearLSNCookie(w http.ResponseWriter, cookieName string, secure bool) {
+	cookie := DefaultCookieOptions()
+	cookie.Name, cookie.Secure = cookieName, secure
+	cookie.withDefaults().clear(w)
+}
 
+// clear expires the LSN cookie on the client, with the attributes it was set with
+func (c CookieOptions) clear(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
-		Value:    lsn.String(),
-		MaxAge:   int(maxAge.Seconds()), // threshold on avg time your database sync took.
-		HttpOnly: true,
-		Secure:   secure, // Set to true in production with HTTPS
-		Path:     "/",
-		SameSite: http.SameSiteLaxMode,
+		Name:     c.Name,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: c.HttpOnly,
+		Secure:   c.Secure,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		SameSite: c.SameSite,
 	})
 }
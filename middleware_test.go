@@ -3,7 +3,9 @@ package dbresolver
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHTTPMiddleware(t *testing.T) {
@@ -121,3 +123,233 @@ func TestHTTPMiddlewareWithExistingLSNCookie(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", resp.StatusCode)
 	}
 }
+
+// staticCapability reports a fixed causal consistency state
+type staticCapability bool
+
+func (c staticCapability) IsCausalConsistencyEnabled() bool { return bool(c) }
+
+func TestHTTPMiddlewareExpiresCookieWhenDisabled(t *testing.T) {
+	// A new deploy disabled causal consistency while the client still carries the cookie
+	// set by an older instance.
+	router := NewCausalRouter(nil, &CausalConsistencyConfig{Enabled: false})
+	middleware := NewHTTPMiddleware(router, "test_lsn", 0, false)
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetLSNContext(r.Context()) != nil {
+			t.Error("LSN context should not be created when causal consistency is disabled")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "test_lsn", Value: "1/ABCDEF"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "test_lsn" || cookies[0].MaxAge >= 0 {
+		t.Fatalf("expected the LSN cookie to be expired, got %+v", cookies)
+	}
+}
+
+func TestHTTPMiddlewareMixedVersionRollout(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []MiddlewareOption
+		wantLSNCtx    bool
+		wantExpiry    bool
+		carriesCookie bool
+	}{
+		{name: "enabled instance keeps cookie", opts: []MiddlewareOption{WithConsistencyCapability(staticCapability(true))},
+			wantLSNCtx: true, carriesCookie: true},
+		{name: "disabled instance expires cookie", opts: []MiddlewareOption{WithConsistencyCapability(staticCapability(false))},
+			wantExpiry: true, carriesCookie: true},
+		{name: "disabled instance without cookie sets nothing", opts: []MiddlewareOption{WithConsistencyCapability(staticCapability(false))}},
+		{name: "router without capability keeps previous behavior", wantLSNCtx: true, carriesCookie: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := NewHTTPMiddleware(NewSimpleRouter(nil), "test_lsn", 0, false, tt.opts...)
+
+			handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := GetLSNContext(r.Context()) != nil; got != tt.wantLSNCtx {
+					t.Errorf("LSN context present = %v, want %v", got, tt.wantLSNCtx)
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			if tt.carriesCookie {
+				req.AddCookie(&http.Cookie{Name: "test_lsn", Value: "0/16B3748"})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			expired := false
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "test_lsn" && c.MaxAge < 0 {
+					expired = true
+				}
+			}
+			if expired != tt.wantExpiry {
+				t.Errorf("cookie expired = %v, want %v", expired, tt.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareSkipsAnonymousRequests(t *testing.T) {
+	middleware := NewHTTPMiddleware(&fixedLSNRouter{lsn: LSN{Lower: 0x2000}}, "test_lsn", 0, false,
+		WithAnonymousRequests(func(r *http.Request) bool { return r.Header.Get("Authorization") == "" }))
+
+	var tracked bool
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked = GetLSNContext(r.Context()) != nil
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// an anonymous crawler carrying an old cookie gets neither tracking nor a new cookie
+	req := httptest.NewRequest("POST", "/", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "test_lsn", Value: "1/ABCDEF"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if tracked {
+		t.Error("expected no LSN context for an anonymous request")
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("expected the carried LSN cookie to be expired, got %+v", cookies)
+	}
+
+	req = httptest.NewRequest("GET", "/", http.NoBody)
+	req.Header.Set("Authorization", "Bearer token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !tracked {
+		t.Error("expected an LSN context for an authenticated request")
+	}
+}
+
+func TestHTTPMiddlewareCookieOptions(t *testing.T) {
+	cookie := DefaultCookieOptions()
+	cookie.Name, cookie.Secure, cookie.SameSite, cookie.Domain, cookie.Path = "lsn", true, http.SameSiteStrictMode, "example.com", "/app"
+	middleware := NewHTTPMiddlewareWithCookie(&fixedLSNRouter{lsn: LSN{Lower: 0x2000}}, cookie)
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/app/orders", http.NoBody))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("want the LSN cookie set, got %+v", cookies)
+	}
+	got := cookies[0]
+	if got.Name != "lsn" || got.MaxAge != 300 || !got.Secure || !got.HttpOnly || got.SameSite != http.SameSiteStrictMode ||
+		got.Domain != "example.com" || got.Path != "/app" {
+		t.Errorf("want the cookie set with its options, got %+v", got)
+	}
+
+	// unset options take their default
+	middleware = NewHTTPMiddlewareWithCookie(&fixedLSNRouter{lsn: LSN{Lower: 0x2000}}, CookieOptions{MaxAge: time.Minute})
+	rec = httptest.NewRecorder()
+	middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusCreated)
+	})).ServeHTTP(rec, httptest.NewRequest("POST", "/", http.NoBody))
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "pg_min_lsn" || cookies[0].Path != "/" ||
+		cookies[0].MaxAge != 60 || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Errorf("want the default cookie attributes, got %+v", cookies)
+	}
+}
+
+func TestHTTPMiddlewareAcceptsAnyRouter(t *testing.T) {
+	config := &CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, CookieName: "app_lsn", CookieMaxAge: time.Minute}
+	db := New(WithPrimaryDBs(MockDB()), WithReplicaDBs(MockDB()), WithCausalConsistencyConfig(config))
+
+	for _, tt := range []struct {
+		name       string
+		router     LSNTracker
+		wantCookie string
+	}{
+		{name: "resolver", router: db, wantCookie: "app_lsn"},
+		{name: "causal router", router: QueryRouter(NewCausalRouter(db, config)), wantCookie: "app_lsn"},
+		{name: "simple router", router: QueryRouter(NewSimpleRouter(db)), wantCookie: "pg_min_lsn"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := NewHTTPMiddleware(tt.router, "", 0, false)
+			if middleware.cookie.Name != tt.wantCookie {
+				t.Errorf("want the %s cookie, got %s", tt.wantCookie, middleware.cookie.Name)
+			}
+
+			var required LSN
+			handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				required = GetLSNContext(r.Context()).RequiredLSN
+			}))
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.AddCookie(&http.Cookie{Name: tt.wantCookie, Value: "0/1000"})
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if required != (LSN{Lower: 0x1000}) {
+				t.Errorf("want the LSN of the cookie required, got %s", required)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareSkipFunc(t *testing.T) {
+	middleware := NewHTTPMiddleware(&fixedLSNRouter{lsn: LSN{Lower: 0x2000}}, "test_lsn", 0, false,
+		WithSkipFunc(func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/healthz") }))
+
+	var tracked bool
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked = GetLSNContext(r.Context()) != nil
+		_, wrapped := w.(*lsnResponseWriter)
+		if tracked != wrapped {
+			t.Errorf("want the response wrapped only for tracked requests, tracked %v and wrapped %v", tracked, wrapped)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// the carried cookie is neither parsed nor expired
+	req := httptest.NewRequest("GET", "/healthz", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "test_lsn", Value: "1/ABCDEF"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if tracked {
+		t.Error("expected no LSN context for a skipped request")
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected the carried cookie left untouched, got %+v", cookies)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", http.NoBody))
+	if !tracked {
+		t.Error("expected an LSN context for a request not skipped")
+	}
+}
+
+func TestHTTPMiddlewareStrictLSNParsing(t *testing.T) {
+	db := New(WithPrimaryDBs(MockDB()), WithReplicaDBs(MockDB()))
+	middleware := NewHTTPMiddleware(NewSimpleRouter(db), "test_lsn", 0, false, WithStrictLSNParsing(LSNLimits{MaxUpper: 0xFF}))
+
+	for value, want := range map[string]LSN{
+		"1/ABCDEF":       {Upper: 1, Lower: 0xABCDEF},
+		"v1.2/10.ext":    {Upper: 2, Lower: 0x10},
+		"FFFFFFFF/0":     {},
+		"000000000001/0": {},
+	} {
+		var got LSN
+		handler := middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			if lsnCtx := GetLSNContext(r.Context()); lsnCtx != nil {
+				got = lsnCtx.RequiredLSN
+			}
+		}))
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "test_lsn", Value: value})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != want {
+			t.Errorf("cookie %q: want required LSN %s, got %s", value, want, got)
+		}
+	}
+}
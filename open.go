@@ -0,0 +1,106 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// PoolConfig configures the connection pools opened by Open, zero fields keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// apply configures the pool of db
+func (c *PoolConfig) apply(db *sql.DB) {
+	if c == nil {
+		return
+	}
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
+
+// WithConnPool configures the pools of the primaries and replicas opened by Open
+func WithConnPool(config PoolConfig) OptionFunc {
+	return func(opt *Option) {
+		opt.PrimaryPool = &config
+		opt.ReplicaPool = &config
+	}
+}
+
+// WithReplicaConnPool configures the pools of the replicas opened by Open, overriding WithConnPool
+func WithReplicaConnPool(config PoolConfig) OptionFunc {
+	return func(opt *Option) {
+		opt.ReplicaPool = &config
+	}
+}
+
+// Open opens one pool per DSN with sql.Open, configures them with WithConnPool and WithReplicaConnPool,
+// creates the resolver with opts and pings every database, see PingContext. Every pool is closed when a
+// DSN can't be opened or the ping fails, with WithPartialFailureTolerance the replicas are allowed to fail it.
+// The pools are closed by Close.
+func Open(driverName string, primaryDSNs, replicaDSNs []string, opts ...OptionFunc) (*DB, error) {
+	if len(primaryDSNs) == 0 {
+		return nil, errors.New("dbresolver: at least one primary DSN is required")
+	}
+	opt := defaultOption()
+	for _, optFunc := range opts {
+		optFunc(opt)
+	}
+
+	primaries, err := openPools(driverName, primaryDSNs, opt.PrimaryPool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open primary: %w", err)
+	}
+	replicas, err := openPools(driverName, replicaDSNs, opt.ReplicaPool)
+	if err != nil {
+		return nil, multierr.Append(fmt.Errorf("failed to open replica: %w", err), closePools(primaries))
+	}
+
+	db := New(append(slices.Clip(opts), WithPrimaryDBs(primaries...), WithReplicaDBs(replicas...))...)
+	if err := db.PingContext(context.Background()); err != nil {
+		return nil, multierr.Append(fmt.Errorf("failed to ping: %w", err), db.Close())
+	}
+	return db, nil
+}
+
+// openPools opens and configures a pool per DSN, closing the ones already opened on failure
+func openPools(driverName string, dsns []string, config *PoolConfig) ([]*sql.DB, error) {
+	pools := make([]*sql.DB, 0, len(dsns))
+	for i, dsn := range dsns {
+		pool, err := sql.Open(driverName, dsn)
+		if err != nil {
+			return nil, multierr.Append(fmt.Errorf("DSN %d: %w", i, err), closePools(pools))
+		}
+		config.apply(pool)
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// closePools closes every pool of pools
+func closePools(pools []*sql.DB) error {
+	var errs error
+	for _, pool := range pools {
+		errs = multierr.Append(errs, pool.Close())
+	}
+	return errs
+}
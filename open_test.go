@@ -0,0 +1,76 @@
+package dbresolver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newDSNMock registers a pinged sqlmock connection under dsn
+func newDSNMock(t *testing.T, dsn string) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return mock
+}
+
+func TestOpen(t *testing.T) {
+	primaryMock := newDSNMock(t, "open-primary")
+	replicaMock := newDSNMock(t, "open-replica")
+	primaryMock.ExpectPing()
+	replicaMock.ExpectPing()
+
+	db, err := Open("sqlmock", []string{"open-primary"}, []string{"open-replica"},
+		WithConnPool(PoolConfig{MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: time.Hour}),
+		WithReplicaConnPool(PoolConfig{MaxOpenConns: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if got := db.PrimaryDBs()[0].Stats().MaxOpenConnections; got != 20 {
+		t.Errorf("expected 20 max open connections on the primary, got %d", got)
+	}
+	if got := db.ReplicaDBs()[0].Stats().MaxOpenConnections; got != 10 {
+		t.Errorf("expected 10 max open connections on the replica, got %d", got)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenFailedPing(t *testing.T) {
+	primaryMock := newDSNMock(t, "open-failed-primary")
+	replicaMock := newDSNMock(t, "open-failed-replica")
+	primaryMock.ExpectPing()
+	replicaMock.ExpectPing().WillReturnError(errConnReset)
+
+	if _, err := Open("sqlmock", []string{"open-failed-primary"}, []string{"open-failed-replica"}); !errors.Is(err, errConnReset) {
+		t.Fatalf("expected the ping error, got %v", err)
+	}
+
+	primaryMock.ExpectPing()
+	replicaMock.ExpectPing().WillReturnError(errConnReset)
+	db, err := Open("sqlmock", []string{"open-failed-primary"}, []string{"open-failed-replica"}, WithPartialFailureTolerance(nil))
+	if err != nil {
+		t.Fatalf("expected the replica failure to be tolerated, got %v", err)
+	}
+	_ = db.Close()
+}
+
+func TestOpenRequiresPrimary(t *testing.T) {
+	if _, err := Open("sqlmock", nil, []string{"open-replica"}); err == nil {
+		t.Fatal("expected an error without primary DSN")
+	}
+	if _, err := Open("unknown-driver", []string{"dsn"}, nil); err == nil {
+		t.Fatal("expected an error for an unknown driver")
+	}
+}
@@ -1,8 +1,11 @@
 package dbresolver
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"slices"
 	"time"
 )
 
@@ -11,8 +14,11 @@ type LoadBalancerPolicy string
 
 // Supported Loadbalancer policy
 const (
-	RoundRobinLB LoadBalancerPolicy = "ROUND_ROBIN"
-	RandomLB     LoadBalancerPolicy = "RANDOM"
+	RoundRobinLB       LoadBalancerPolicy = "ROUND_ROBIN"
+	RandomLB           LoadBalancerPolicy = "RANDOM"
+	LeastConnectionsLB LoadBalancerPolicy = "LEAST_CONNECTIONS"
+	LatencyAwareLB     LoadBalancerPolicy = "LATENCY_AWARE"
+	LagAwareLB         LoadBalancerPolicy = "LAG_AWARE"
 )
 
 // Option define the option property
@@ -24,6 +30,84 @@ type Option struct {
 	QueryTypeChecker QueryTypeChecker
 	QueryRouter      QueryRouter
 	CCConfig         *CausalConsistencyConfig
+
+	KeepaliveInterval time.Duration
+	OutageConfig      *ReplicaOutageConfig
+	CausalTokenKeys   [][]byte
+
+	ResourceClassReplicas map[ResourceClass][]*sql.DB
+	QueryResourceClasses  []queryResourceClass
+	Tracer                Tracer
+	PrimaryReadGuard      *primaryReadGuard
+
+	Logger    *slog.Logger
+	LogLevels map[LogComponent]slog.Leveler
+
+	RoutingHooks *routingHooks
+
+	DBNames map[*sql.DB]string
+
+	ConsistencyAssertions *consistencyAssertions
+
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	MaxReplicaLagBytes  int64
+	OnHealthEvent       func(HealthEvent)
+	EvictAfterFailures  int
+	BackendParams       bool
+	PoolerStats         PoolerStatsCollector
+
+	Regions        []Region
+	LocalRegion    string
+	RegionFailover []string
+	WriteForwarder WriteForwarder
+
+	LeakDetection *leakDetector
+	RetryPolicy   *retryPolicy
+
+	ErrorRatioDemotion *errorRatioConfig
+	ErrorClassifier    ErrorClassifier
+
+	DecisionLog *decisionLog
+
+	ReplicaDiscovery *DiscoveryConfig
+
+	PartialFailureTolerance *partialFailureTolerance
+
+	RoleVerificationInterval time.Duration
+	AutoFailover             *autoFailover
+
+	PrimaryPool *PoolConfig
+	ReplicaPool *PoolConfig
+
+	DelayedReplicaDBs     []*sql.DB
+	DetectDelayedReplicas bool
+
+	Diagnosis *DiagnosisConfig
+
+	ReplicaBoost *replicaBoost
+
+	Maintenance MaintenanceConfig
+
+	FallbackRatio *fallbackRatio
+
+	ReadDistribution *readDistribution
+
+	QueryTypeCacheSize int
+
+	DualWrite *dualWriter
+
+	ReadCapture *ReadCaptureConfig
+
+	ReplicaWarmUp *replicaWarmUp
+
+	TxCommitLSN bool
+
+	LSNTrackingErrorHandler func(ctx context.Context, err error)
+
+	Copy *CopyConfig
+
+	Notifications *NotificationConfig
 }
 
 // OptionFunc used for option chaining
@@ -43,6 +127,41 @@ func WithReplicaDBs(replicaDBs ...*sql.DB) OptionFunc {
 	}
 }
 
+// WithNamedPrimaryDBs adds named primary DBs to the resolver, in the order of their names.
+// Names identify the databases in logs, diagnosis reports, traces and metrics instead of "primary-<index>".
+func WithNamedPrimaryDBs(primaryDBs map[string]*sql.DB) OptionFunc {
+	return func(opt *Option) {
+		opt.PrimaryDBs = namedDBs(opt, primaryDBs)
+	}
+}
+
+// WithNamedReplicaDBs adds named replica DBs to the resolver, in the order of their names.
+// Names identify the databases in logs, diagnosis reports, traces and metrics instead of "replica-<index>".
+func WithNamedReplicaDBs(replicaDBs map[string]*sql.DB) OptionFunc {
+	return func(opt *Option) {
+		opt.ReplicaDBs = namedDBs(opt, replicaDBs)
+	}
+}
+
+// namedDBs records the names of dbs and returns them sorted by name
+func namedDBs(opt *Option, dbs map[string]*sql.DB) []*sql.DB {
+	if opt.DBNames == nil {
+		opt.DBNames = make(map[*sql.DB]string, len(dbs))
+	}
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	sorted := make([]*sql.DB, 0, len(dbs))
+	for _, name := range names {
+		opt.DBNames[dbs[name]] = name
+		sorted = append(sorted, dbs[name])
+	}
+	return sorted
+}
+
 // WithQueryTypeChecker sets the query type checker instance.
 func WithQueryTypeChecker(checker QueryTypeChecker) OptionFunc {
 	return func(opt *Option) {
@@ -58,24 +177,45 @@ func WithLoadBalancer(lb LoadBalancerPolicy) OptionFunc {
 			opt.DBLB = &RoundRobinLoadBalancer[*sql.DB]{}
 			opt.StmtLB = &RoundRobinLoadBalancer[*sql.Stmt]{}
 		case RandomLB:
-			opt.DBLB = &RandomLoadBalancer[*sql.DB]{
-				randInt: make(chan int, 1),
-			}
-			opt.StmtLB = &RandomLoadBalancer[*sql.Stmt]{
-				randInt: make(chan int, 1),
-			}
+			opt.DBLB = &RandomLoadBalancer[*sql.DB]{}
+			opt.StmtLB = &RandomLoadBalancer[*sql.Stmt]{}
+		case LeastConnectionsLB:
+			opt.DBLB = &LeastConnectionsLoadBalancer[*sql.DB]{}
+			opt.StmtLB = &LeastConnectionsLoadBalancer[*sql.Stmt]{}
+		case LatencyAwareLB:
+			opt.DBLB = &LatencyAwareLoadBalancer[*sql.DB]{}
+			opt.StmtLB = &LatencyAwareLoadBalancer[*sql.Stmt]{}
+		case LagAwareLB:
+			opt.DBLB = &LagAwareLoadBalancer[*sql.DB]{}
+			opt.StmtLB = &LagAwareLoadBalancer[*sql.Stmt]{}
 		default:
 			panic(fmt.Sprintf("LoadBalancer: %s is not supported", lb))
 		}
 	}
 }
 
+// WithCustomLoadBalancer sets the load balancers of the databases and of the prepared statements, e.g. to route by
+// a custom metric. A nil balancer keeps the current one, RoundRobinLB by default. A balancer implementing
+// LatencyObserver is reported the latency of the reads.
+func WithCustomLoadBalancer(dbLB DBLoadBalancer, stmtLB StmtLoadBalancer) OptionFunc {
+	return func(opt *Option) {
+		if dbLB != nil {
+			opt.DBLB = dbLB
+		}
+		if stmtLB != nil {
+			opt.StmtLB = stmtLB
+		}
+	}
+}
+
 func defaultOption() *Option {
 	return &Option{
 		DBLB:             &RoundRobinLoadBalancer[*sql.DB]{},
 		StmtLB:           &RoundRobinLoadBalancer[*sql.Stmt]{},
 		QueryTypeChecker: NewDefaultQueryTypeChecker(),
 		CCConfig:         DefaultCausalConsistencyConfig(),
+		ErrorClassifier:  DefaultErrorClassifier,
+		Maintenance:      MaintenanceConfig{LockTimeout: defaultMaintenanceLockTimeout, StatementTimeout: defaultMaintenanceStatementTimeout},
 	}
 }
 
@@ -110,6 +250,20 @@ func WithLSNQueryTimeout(timeout time.Duration) OptionFunc {
 	}
 }
 
+// WithLSNThrottleTime queries the LSN of a database at most once per throttle. Reads requiring an LSN reuse the
+// replay LSN of a replica observed less than throttle ago, and a replica found lagging by it isn't queried again
+// before the window elapses. The writes of the window share the next query of the primary WAL LSN, started once the
+// window elapsed, which delays the LSN capture after a burst of writes by up to throttle.
+func WithLSNThrottleTime(throttle time.Duration) OptionFunc {
+	return func(opt *Option) {
+		if opt.CCConfig == nil {
+			opt.CCConfig = DefaultCausalConsistencyConfig()
+		}
+		opt.CCConfig.ThrottleTime = throttle
+		opt.CCConfig.Enabled = true
+	}
+}
+
 // WithMasterFallback configures whether to fallback to master when LSN requirements can't be met
 func WithMasterFallback(fallback bool) OptionFunc {
 	return func(opt *Option) {
@@ -121,11 +275,49 @@ func WithMasterFallback(fallback bool) OptionFunc {
 	}
 }
 
-// WithCausalConsistencyConfig sets the complete causal consistency configuration
+// WithMaxReplicaWait configures how long a read may wait for a lagging replica
+// to reach the required LSN before falling back to the master
+func WithMaxReplicaWait(maxWait time.Duration) OptionFunc {
+	return func(opt *Option) {
+		if opt.CCConfig == nil {
+			opt.CCConfig = DefaultCausalConsistencyConfig()
+		}
+		opt.CCConfig.MaxReplicaWait = maxWait
+		opt.CCConfig.Enabled = true
+	}
+}
+
+// WithCausalConsistencyConfig sets the complete causal consistency configuration.
+// The configuration is copied: changing config afterwards doesn't affect the resolver.
 func WithCausalConsistencyConfig(config *CausalConsistencyConfig) OptionFunc {
 	return func(opt *Option) {
 		if config != nil {
-			opt.CCConfig = config
+			cfg := *config
+			opt.CCConfig = &cfg
 		}
 	}
 }
+
+// WithReplicaKeepalive enables lightweight keepalive queries on replicas that have not served
+// any query for at least the given interval, keeping their pools and cached LSN warm
+func WithReplicaKeepalive(interval time.Duration) OptionFunc {
+	return func(opt *Option) {
+		opt.KeepaliveInterval = interval
+	}
+}
+
+// WithReplicaOutagePolicy enables replica outage tracking and configures how reads
+// are served while every replica is unavailable
+func WithReplicaOutagePolicy(config ReplicaOutageConfig) OptionFunc {
+	return func(opt *Option) {
+		opt.OutageConfig = &config
+	}
+}
+
+// WithCausalTokenKey sets the HMAC key used to sign causal tokens produced by DB.CausalToken.
+// Previous keys are still accepted by DB.WithCausalToken, allowing key rotation.
+func WithCausalTokenKey(key []byte, previousKeys ...[]byte) OptionFunc {
+	return func(opt *Option) {
+		opt.CausalTokenKeys = append([][]byte{key}, previousKeys...)
+	}
+}
@@ -3,6 +3,7 @@ package dbresolver_test
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/alfari16/go-pgrouter"
 )
@@ -50,3 +51,34 @@ func TestOptionWithLoadBalancerNonExist(t *testing.T) {
 	opt := &dbresolver.Option{}
 	optFunc(opt)
 }
+
+func TestOptionWithNamedDBs(t *testing.T) {
+	primary, replicaA, replicaB := &sql.DB{}, &sql.DB{}, &sql.DB{}
+	opt := &dbresolver.Option{}
+	dbresolver.WithNamedPrimaryDBs(map[string]*sql.DB{"main": primary})(opt)
+	dbresolver.WithNamedReplicaDBs(map[string]*sql.DB{"replica-b": replicaB, "replica-a": replicaA})(opt)
+
+	if len(opt.PrimaryDBs) != 1 || len(opt.ReplicaDBs) != 2 {
+		t.Fatalf("want 1 primary and 2 replicas, got %d and %d", len(opt.PrimaryDBs), len(opt.ReplicaDBs))
+	}
+	if opt.ReplicaDBs[0] != replicaA || opt.ReplicaDBs[1] != replicaB {
+		t.Error("want replicas ordered by name")
+	}
+	if opt.DBNames[primary] != "main" || opt.DBNames[replicaB] != "replica-b" {
+		t.Errorf("unexpected names %v", opt.DBNames)
+	}
+}
+
+func TestOptionWithCausalConsistencyConfigCopies(t *testing.T) {
+	config := dbresolver.DefaultCausalConsistencyConfig()
+	opt := &dbresolver.Option{}
+	dbresolver.WithCausalConsistencyConfig(config)(opt)
+	dbresolver.WithLSNQueryTimeout(time.Second)(opt)
+
+	if config.Timeout == time.Second || config.Enabled {
+		t.Error("expected the options not to change the caller's configuration")
+	}
+	if opt.CCConfig.Timeout != time.Second {
+		t.Errorf("want %v, got %v", time.Second, opt.CCConfig.Timeout)
+	}
+}
@@ -0,0 +1,59 @@
+// Package oteltracing traces dbresolver routing decisions and LSN queries with OpenTelemetry.
+//
+// It lives in its own module so the core package doesn't depend on OpenTelemetry.
+package oteltracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// instrumentationName identifies the spans of this package
+const instrumentationName = "github.com/alfari16/go-pgrouter"
+
+// Tracer adapts an OpenTelemetry tracer to dbresolver.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates a tracer using the given provider, or the global one if nil.
+// Pass it to dbresolver.New with dbresolver.WithTracer.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+// Start starts an internal span as a child of the span in ctx
+func (t *Tracer) Start(ctx context.Context, spanName string) (context.Context, dbresolver.Span) {
+	ctx, span := t.tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttributes(attrs ...dbresolver.SpanAttribute) {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = attribute.String(attr.Key, attr.Value)
+	}
+	s.span.SetAttributes(kvs...)
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
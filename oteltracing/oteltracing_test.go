@@ -0,0 +1,64 @@
+package oteltracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func TestTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbresolver.New(
+		dbresolver.WithPrimaryDBs(primary),
+		dbresolver.WithReplicaDBs(replica),
+		dbresolver.WithCausalConsistencyConfig(&dbresolver.CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            dbresolver.ReadYourWrites,
+			FallbackToMaster: true,
+			Timeout:          time.Second,
+		}),
+		dbresolver.WithTracer(New(provider)),
+	)
+	defer db.Close()
+
+	replicaMock.ExpectQuery("pg_last_wal_replay_lsn").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000"))
+	ctx := dbresolver.WithLSNContext(context.Background(), &dbresolver.LSNContext{RequiredLSN: dbresolver.LSN{Lower: 0x2000}})
+	if got := db.DbSelector(ctx, dbresolver.QueryTypeRead); got != replica {
+		t.Fatal("expected the caught up replica to serve the read")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected route and replay LSN spans, got %d", len(spans))
+	}
+	check, route := spans[0], spans[1]
+	if check.Name != dbresolver.SpanLastReplayLSN || check.Parent.SpanID() != route.SpanContext.SpanID() {
+		t.Errorf("expected the replay LSN check to be a child of the route span, got %s", check.Name)
+	}
+
+	attrs := map[attribute.Key]string{}
+	for _, kv := range route.Attributes {
+		attrs[kv.Key] = kv.Value.AsString()
+	}
+	if attrs[dbresolver.AttrTargetDB] != "replica-0" || attrs[dbresolver.AttrRouteReason] != dbresolver.ReasonReplicaCaughtUp {
+		t.Errorf("unexpected route attributes: %v", attrs)
+	}
+}
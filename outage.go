@@ -0,0 +1,343 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrReplicasUnavailable is returned for reads rejected by the replica outage policy
+// because every replica is currently unavailable
+var ErrReplicasUnavailable = errors.New("dbresolver: all replicas are unavailable")
+
+// ReplicaOutagePolicy defines how reads are served while every replica is unavailable
+type ReplicaOutagePolicy int
+
+const (
+	// OutageDegradeSilently serves reads from the primary without any notification (default)
+	OutageDegradeSilently ReplicaOutagePolicy = iota
+	// OutageDegradeWithHook serves reads from the primary and notifies OnOutage on state transitions
+	OutageDegradeWithHook
+	// OutageRateLimit serves at most MaxDegradedReadsPerSecond reads from the primary
+	// and rejects the rest with ErrReplicasUnavailable
+	OutageRateLimit
+	// OutageFailReads rejects every read with ErrReplicasUnavailable
+	OutageFailReads
+)
+
+// ReplicaOutageConfig configures replica outage detection and the degraded read behavior
+type ReplicaOutageConfig struct {
+	Policy                    ReplicaOutagePolicy // How reads are served during an outage
+	OnOutage                  func(OutageEvent)   // Called when an outage starts or ends (not called for OutageDegradeSilently)
+	MaxDegradedReadsPerSecond int                 // Primary read budget for OutageRateLimit
+	RetryInterval             time.Duration       // How long a failed replica is skipped before it is tried again
+}
+
+// OutageEvent describes a replica outage state transition
+type OutageEvent struct {
+	Active    bool      // True when the outage starts, false when a replica recovered
+	Since     time.Time // When the outage started
+	LastError error     // Last connection error observed on a replica
+}
+
+const defaultOutageRetryInterval = 5 * time.Second
+
+// replicaOutage tracks replicas that failed with connection errors and
+// applies the configured policy once none of them is available
+type replicaOutage struct {
+	config ReplicaOutageConfig
+
+	mu         sync.RWMutex
+	downUntil  map[*sql.DB]time.Time
+	recentErrs map[*sql.DB][]ReplicaError
+	lastErr    error
+	active     bool
+	since      time.Time
+
+	// fixed one second window for OutageRateLimit
+	windowStart time.Time
+	windowReads int
+
+	classifier ErrorClassifier
+
+	// called when a replica that was up is marked down, see WithEjectionDiagnosis
+	onEject func(db *sql.DB, ejectedAt time.Time, recentErrors []ReplicaError)
+}
+
+func newReplicaOutage(config ReplicaOutageConfig) *replicaOutage {
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultOutageRetryInterval
+	}
+	return &replicaOutage{
+		config:     config,
+		downUntil:  make(map[*sql.DB]time.Time),
+		recentErrs: make(map[*sql.DB][]ReplicaError),
+	}
+}
+
+// available returns the replicas that are not marked down, including those
+// whose retry interval has elapsed so they can be probed by the next read
+func (o *replicaOutage) available(replicas []*sql.DB) []*sql.DB {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if len(o.downUntil) == 0 {
+		return replicas
+	}
+
+	now := time.Now()
+	healthy := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if until, down := o.downUntil[replica]; down && now.Before(until) {
+			continue
+		}
+		healthy = append(healthy, replica)
+	}
+	return healthy
+}
+
+// observe records the outcome of a query executed on one of the given replicas
+func (o *replicaOutage) observe(db *sql.DB, err error, replicas []*sql.DB) {
+	if !containsDB(replicas, db) {
+		return
+	}
+
+	if isConnectionError(o.classifier, err) {
+		o.markDown(db, err, replicas)
+		return
+	}
+	if err == nil {
+		o.markUp(db)
+	}
+}
+
+func (o *replicaOutage) markDown(db *sql.DB, err error, replicas []*sql.DB) {
+	now := time.Now()
+	o.mu.Lock()
+	_, wasDown := o.downUntil[db]
+	o.downUntil[db] = now.Add(o.config.RetryInterval)
+	o.lastErr = err
+
+	recent := append(o.recentErrs[db], ReplicaError{At: now, Err: err})
+	if len(recent) > maxRecentReplicaErrors {
+		recent = recent[len(recent)-maxRecentReplicaErrors:]
+	}
+	o.recentErrs[db] = recent
+	ejected := !wasDown && o.onEject != nil
+	if ejected {
+		recent = append([]ReplicaError(nil), recent...)
+	}
+
+	started := false
+	if !o.active && o.allDownLocked(replicas) {
+		o.active = true
+		o.since = time.Now()
+		started = true
+	}
+	event := OutageEvent{Active: o.active, Since: o.since, LastError: o.lastErr}
+	o.mu.Unlock()
+
+	if ejected {
+		o.onEject(db, now, recent)
+	}
+	if started {
+		o.notify(event)
+	}
+}
+
+func (o *replicaOutage) markUp(db *sql.DB) {
+	o.mu.RLock()
+	_, down := o.downUntil[db]
+	o.mu.RUnlock()
+	if !down {
+		return
+	}
+
+	o.mu.Lock()
+	delete(o.downUntil, db)
+	delete(o.recentErrs, db)
+	ended := o.active
+	o.active = false
+	event := OutageEvent{Active: false, Since: o.since, LastError: o.lastErr}
+	o.mu.Unlock()
+
+	if ended {
+		o.notify(event)
+	}
+}
+
+// forget drops the state of a replica removed from the resolver
+func (o *replicaOutage) forget(db *sql.DB) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.downUntil, db)
+	delete(o.recentErrs, db)
+}
+
+func (o *replicaOutage) allDownLocked(replicas []*sql.DB) bool {
+	now := time.Now()
+	for _, replica := range replicas {
+		if until, down := o.downUntil[replica]; !down || !now.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *replicaOutage) notify(event OutageEvent) {
+	if o.config.Policy == OutageDegradeSilently || o.config.OnOutage == nil {
+		return
+	}
+	o.config.OnOutage(event)
+}
+
+// isActive reports whether an outage is currently in progress
+func (o *replicaOutage) isActive() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.active
+}
+
+// degradedRead decides whether a read may be served by the primary during an outage.
+// It returns nil if the primary should be used, or ErrReplicasUnavailable otherwise.
+func (o *replicaOutage) degradedRead() error {
+	switch o.config.Policy {
+	case OutageFailReads:
+		return ErrReplicasUnavailable
+	case OutageRateLimit:
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		now := time.Now()
+		if now.Sub(o.windowStart) >= time.Second {
+			o.windowStart = now
+			o.windowReads = 0
+		}
+		if o.windowReads >= o.config.MaxDegradedReadsPerSecond {
+			return ErrReplicasUnavailable
+		}
+		o.windowReads++
+		return nil
+	default:
+		return nil
+	}
+}
+
+// erroredContext is an already finished context reporting a custom error.
+// database/sql checks the context before acquiring a connection, so querying a row with it
+// yields a *sql.Row carrying that error, which is the only way to build such a row.
+type erroredContext struct {
+	context.Context
+	err error
+}
+
+var closedDone = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func (c erroredContext) Done() <-chan struct{} { return closedDone }
+func (c erroredContext) Err() error            { return c.err }
+
+// errorRow returns a *sql.Row whose Scan reports err
+func errorRow(ctx context.Context, db *sql.DB, err error) *sql.Row {
+	return db.QueryRowContext(erroredContext{Context: ctx, err: err}, "")
+}
+
+// containsDB reports whether target is one of dbs
+func containsDB(dbs []*sql.DB, target *sql.DB) bool {
+	for _, db := range dbs {
+		if db == target {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReplicaOutage reports whether every replica is currently considered unavailable.
+// It always returns false unless WithReplicaOutagePolicy is configured.
+func (db *DB) IsReplicaOutage() bool {
+	return db.outage != nil && db.outage.isActive()
+}
+
+// selectDB returns the database for the query like DbSelector, but routes reads of a resource class
+// to their replica pool and applies the replica outage policy when every replica is unavailable,
+// which may reject the read
+func (db *DB) selectDB(ctx context.Context, queryType QueryType, query string) (*sql.DB, error) {
+	if db.readOnly.Load() {
+		return db.selectReadOnlyModeDB(ctx, queryType, query)
+	}
+	if queryType != QueryTypeWrite {
+		if target := db.dualWriteReadDB(ctx, queryType); target != nil {
+			return target, db.recordRead(ctx, target)
+		}
+	}
+	if queryType != QueryTypeWrite {
+		if classDB, reason := db.resourceClassDB(ctx, query); classDB != nil {
+			db.routeClassRead(ctx, queryType, classDB, reason)
+			db.distribution.record(query, classDB)
+			return classDB, db.recordRead(ctx, classDB)
+		}
+	}
+
+	curDB, err := db.routeDB(ctx, queryType)
+	if err != nil {
+		return nil, err
+	}
+	if queryType != QueryTypeWrite {
+		curDB = db.boostRead(ctx, queryType, curDB)
+		db.distribution.record(query, curDB)
+		if err := db.recordRead(ctx, curDB); err != nil {
+			return nil, err
+		}
+	}
+	return curDB, nil
+}
+
+// routeDB selects the database for selectDB
+func (db *DB) routeDB(ctx context.Context, queryType QueryType) (*sql.DB, error) {
+	if db.outage == nil || queryType == QueryTypeWrite || len(db.allReplicas()) == 0 {
+		return db.dbSelector(ctx, queryType)
+	}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil && lsnCtx.ForceMaster {
+		return db.dbSelector(ctx, queryType)
+	}
+
+	if len(db.ReplicaDBs()) > 0 {
+		return db.dbSelector(ctx, queryType)
+	}
+
+	if err := db.outage.degradedRead(); err != nil {
+		return nil, err
+	}
+	primary := db.ReadWrite()
+	db.hooks.route(RouteDecision{
+		QueryType: queryType,
+		DB:        primary,
+		Target:    physicalDBName(db, primary),
+		Reason:    ReasonReplicasUnavailable,
+	})
+	return primary, nil
+}
+
+// observeLatency reports the latency of a successful read on curDB to the replica boost and the load balancer
+func (db *DB) observeLatency(curDB *sql.DB, latency time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	db.observePrimaryRead(curDB, latency)
+	if observer, ok := db.loadBalancer.(LatencyObserver[*sql.DB]); ok {
+		observer.ObserveLatency(curDB, latency)
+	}
+}
+
+// observeReplica records the outcome of a query for replica outage detection and error ratio demotion
+func (db *DB) observeReplica(curDB *sql.DB, err error) {
+	db.errorRatio.observe(curDB, err)
+	if db.outage != nil {
+		db.outage.observe(curDB, err, db.allReplicas())
+	}
+}
@@ -0,0 +1,101 @@
+package dbresolver
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaOutageFailReads(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	var events []OutageEvent
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{
+			Policy:        OutageFailReads,
+			OnOutage:      func(e OutageEvent) { events = append(events, e) },
+			RetryInterval: 50 * time.Millisecond,
+		}),
+	)
+
+	connErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(connErr)
+	if _, err := db.Query("SELECT 1"); err == nil {
+		t.Fatal("expected the replica connection error")
+	}
+
+	if !db.IsReplicaOutage() {
+		t.Fatal("expected replica outage after the only replica failed")
+	}
+	if len(events) != 1 || !events[0].Active {
+		t.Fatalf("expected one outage start event, got %+v", events)
+	}
+
+	if _, err := db.Query("SELECT 1"); !errors.Is(err, ErrReplicasUnavailable) {
+		t.Errorf("want ErrReplicasUnavailable, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); !errors.Is(err, ErrReplicasUnavailable) {
+		t.Errorf("want ErrReplicasUnavailable from QueryRow, got %v", err)
+	}
+
+	// once the retry interval elapses the replica is probed again and recovers
+	time.Sleep(60 * time.Millisecond)
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		t.Fatalf("expected the recovered replica to serve the read, got %v", err)
+	}
+	_ = rows.Close()
+
+	if db.IsReplicaOutage() {
+		t.Error("expected the outage to end after a successful replica read")
+	}
+	if len(events) != 2 || events[1].Active {
+		t.Errorf("expected an outage end event, got %+v", events)
+	}
+}
+
+func TestReplicaOutageDegradeToPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{Policy: OutageDegradeSilently}),
+	)
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("reset")})
+	_, _ = db.Query("SELECT 1")
+
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		t.Fatalf("expected degraded read on the primary, got %v", err)
+	}
+	_ = rows.Close()
+
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplicaOutageRateLimit(t *testing.T) {
+	outage := newReplicaOutage(ReplicaOutageConfig{Policy: OutageRateLimit, MaxDegradedReadsPerSecond: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := outage.degradedRead(); err != nil {
+			t.Fatalf("read %d should be within budget, got %v", i, err)
+		}
+	}
+	if err := outage.degradedRead(); !errors.Is(err, ErrReplicasUnavailable) {
+		t.Errorf("want ErrReplicasUnavailable once the budget is spent, got %v", err)
+	}
+}
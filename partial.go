@@ -0,0 +1,62 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/multierr"
+)
+
+// Operations reported by PartialFailure
+const (
+	PartialFailurePrepare = "prepare"
+	PartialFailurePing    = "ping"
+)
+
+// PartialFailure reports the replicas that failed an operation tolerated with WithPartialFailureTolerance
+type PartialFailure struct {
+	Op       string   // PartialFailurePrepare or PartialFailurePing
+	Replicas []string // Names of the failed replicas
+	Err      error    // Errors of the failed replicas, split with multierr.Errors
+}
+
+// partialFailureTolerance lets Prepare and Ping succeed when only replicas fail
+type partialFailureTolerance struct {
+	onFailure func(PartialFailure)
+}
+
+// WithPartialFailureTolerance makes Prepare and Ping succeed when only a subset of the replicas fail, instead
+// of failing wholesale: the statements of the failed replicas are served by the primary, like when they
+// fail to prepare with a connection error, and the failures are recorded as failed health checks when the
+// health monitor is enabled. onFailure, which may be nil, receives the failures of each tolerated operation.
+// Failures of the primaries still fail the operation.
+func WithPartialFailureTolerance(onFailure func(PartialFailure)) OptionFunc {
+	return func(opt *Option) {
+		opt.PartialFailureTolerance = &partialFailureTolerance{onFailure: onFailure}
+	}
+}
+
+// tolerateReplicaFailures degrades the replicas failing op, errs being indexed like replicas, and reports them
+func (db *DB) tolerateReplicaFailures(op string, replicas []*sql.DB, errs []error) {
+	failure := PartialFailure{Op: op}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		name := physicalDBName(db, replicas[i])
+		failure.Replicas = append(failure.Replicas, name)
+		failure.Err = multierr.Append(failure.Err, fmt.Errorf("%s: %w", name, err))
+		if db.health != nil {
+			for _, event := range db.health.updateReplica(replicas[i], name, LSN{}, 0, err) {
+				db.health.notify(event)
+			}
+		}
+	}
+	if failure.Err == nil {
+		return
+	}
+	db.log().Warn("replica failures tolerated", "op", op, "replicas", failure.Replicas, "error", failure.Err)
+	if db.partialFailures.onFailure != nil {
+		db.partialFailures.onFailure(failure)
+	}
+}
@@ -0,0 +1,101 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/multierr"
+)
+
+func TestPartialFailureTolerancePrepare(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	healthy, healthyMock := newMockDB(t)
+	broken, brokenMock := newMockDB(t)
+
+	var failures []PartialFailure
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(healthy, broken),
+		WithPartialFailureTolerance(func(f PartialFailure) { failures = append(failures, f) }),
+	)
+	db.health = newHealthMonitor(&Option{EvictAfterFailures: 1})
+
+	primaryMock.ExpectPrepare("SELECT name FROM users")
+	healthyMock.ExpectPrepare("SELECT name FROM users")
+	brokenMock.ExpectPrepare("SELECT name FROM users").WillReturnError(errors.New("relation \"users\" does not exist"))
+	st, err := db.Prepare("SELECT name FROM users")
+	if err != nil {
+		t.Fatalf("want the replica failure tolerated, got %v", err)
+	}
+
+	if len(failures) != 1 || failures[0].Op != PartialFailurePrepare || len(failures[0].Replicas) != 1 ||
+		failures[0].Replicas[0] != "replica-1" {
+		t.Fatalf("want the failure of replica-1 reported, got %+v", failures)
+	}
+	if status := db.GetReplicaStatus()[1]; status.IsHealthy || !status.Evicted {
+		t.Errorf("want the failed replica degraded in the health monitor, got %+v", status)
+	}
+	if s := st.(*stmt); s.replicaStmts[1] != s.primaryStmts[0] {
+		t.Error("want the statement of the failed replica served by the primary")
+	}
+
+	primaryMock.ExpectPrepare("SELECT 1").WillReturnError(errConnReset)
+	healthyMock.ExpectPrepare("SELECT 1")
+	brokenMock.ExpectPrepare("SELECT 1")
+	if _, err := db.Prepare("SELECT 1"); err == nil {
+		t.Error("want the primary failure to fail the prepare")
+	}
+}
+
+func TestPartialFailureTolerancePing(t *testing.T) {
+	primary, primaryMock := sqlmockPinger(t)
+	replica, replicaMock := sqlmockPinger(t)
+
+	var failures []PartialFailure
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithPartialFailureTolerance(func(f PartialFailure) { failures = append(failures, f) }),
+	)
+
+	replicaErr := errors.New("connection refused")
+	primaryMock.ExpectPing()
+	replicaMock.ExpectPing().WillReturnError(replicaErr)
+	if err := db.Ping(); err != nil {
+		t.Fatalf("want the replica failure tolerated, got %v", err)
+	}
+	if len(failures) != 1 || failures[0].Op != PartialFailurePing || !errors.Is(multierr.Errors(failures[0].Err)[0], replicaErr) {
+		t.Errorf("want the ping failure of the replica reported, got %+v", failures)
+	}
+
+	primaryMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	replicaMock.ExpectPing()
+	if err := db.Ping(); err == nil {
+		t.Error("want the primary failure to fail the ping")
+	}
+}
+
+func TestPingFailsWholesaleByDefault(t *testing.T) {
+	primary, primaryMock := sqlmockPinger(t)
+	replica, replicaMock := sqlmockPinger(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+
+	primaryMock.ExpectPing()
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	if err := db.Ping(); err == nil {
+		t.Error("want the replica failure to fail the ping without tolerance")
+	}
+}
+
+// sqlmockPinger returns a mock database expecting its pings
+func sqlmockPinger(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, mock
+}
@@ -63,10 +63,48 @@ func getOrCreateChecker(db *sql.DB, queryTimeout time.Duration) *PGLSNChecker {
 	return checker
 }
 
+// lookupChecker returns the checker of db, or nil if no LSN was ever checked on it
+func lookupChecker(db *sql.DB) *PGLSNChecker {
+	registry := getRegistry()
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.checkers[db]
+}
+
+// PurgeChecker drops the LSN checker of db from the registry shared by the resolvers, along with the LSNs it
+// observed. The resolvers purge the databases they close and the replicas removed with RemoveReplica; databases
+// closed outside of a resolver are purged with PurgeChecker. A purged database that is used again gets a new checker.
+func PurgeChecker(db *sql.DB) {
+	registry := getRegistry()
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.checkers, db)
+}
+
 // PGLSNChecker handles PostgreSQL-specific LSN queries and operations
 type PGLSNChecker struct {
 	db           *sql.DB
 	queryTimeout time.Duration
+
+	// last successfully observed replay and current WAL LSN
+	mu            sync.RWMutex
+	lastReplayLSN LSN
+	lastReplayAt  time.Time
+	lastWALLSN    LSN
+	lastWALAt     time.Time
+
+	// replay LSN query shared by the concurrent GetLastReplayLSN calls, and the next throttled WAL LSN query
+	flightMu     sync.Mutex
+	replayFlight *lsnFlight
+	walFlight    *lsnFlight
+	walQueriedAt time.Time
+}
+
+// lsnFlight is an LSN query shared by concurrent callers, done is closed once lsn and err are set
+type lsnFlight struct {
+	done chan struct{}
+	lsn  LSN
+	err  error
 }
 
 // PGLSNCheckerOption configures the PGLSNChecker
@@ -81,11 +119,69 @@ func WithQueryTimeout(timeout time.Duration) PGLSNCheckerOption {
 
 // GetCurrentWALLSN queries the current WAL LSN from the master database
 func (c *PGLSNChecker) GetCurrentWALLSN(ctx context.Context) (LSN, error) {
+	ctx, span := startSpan(ctx, SpanCurrentWALLSN)
+	defer span.End()
+
 	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
 	defer cancel()
 
+	lsn, err := c.queryCurrentWALLSN(queryCtx)
+	if err != nil {
+		span.RecordError(err)
+		return LSN{}, err
+	}
+	span.SetAttributes(SpanAttribute{Key: AttrObservedLSN, Value: lsn.String()})
+	return lsn, nil
+}
+
+// throttledWALLSN queries the current WAL LSN at most once per window: the callers arriving within window of the
+// last query share the next one, started when the window elapses. Unlike a cached LSN, the shared query starts
+// after every caller arrived, so its LSN covers the writes they committed before.
+func (c *PGLSNChecker) throttledWALLSN(ctx context.Context, window time.Duration) (LSN, error) {
+	ctx, span := startSpan(ctx, SpanCurrentWALLSN)
+	defer span.End()
+
+	c.flightMu.Lock()
+	flight := c.walFlight
+	if flight == nil {
+		flight = &lsnFlight{done: make(chan struct{})}
+		c.walFlight = flight
+		wait := window - time.Since(c.walQueriedAt)
+		go func() {
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+			c.flightMu.Lock()
+			c.walFlight = nil // the later callers wait for the next query
+			c.walQueriedAt = time.Now()
+			c.flightMu.Unlock()
+
+			queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.queryTimeout)
+			defer cancel()
+			flight.lsn, flight.err = c.queryCurrentWALLSN(queryCtx)
+			close(flight.done)
+		}()
+	}
+	c.flightMu.Unlock()
+
+	select {
+	case <-flight.done:
+	case <-ctx.Done():
+		err := fmt.Errorf("failed to get current WAL LSN: %w", ctx.Err())
+		span.RecordError(err)
+		return LSN{}, err
+	}
+	if flight.err != nil {
+		span.RecordError(flight.err)
+		return LSN{}, flight.err
+	}
+	span.SetAttributes(SpanAttribute{Key: AttrObservedLSN, Value: flight.lsn.String()})
+	return flight.lsn, nil
+}
+
+func (c *PGLSNChecker) queryCurrentWALLSN(ctx context.Context) (LSN, error) {
 	var lsnStr string
-	err := c.db.QueryRowContext(queryCtx, "SELECT "+PGCurrentWALLSN).Scan(&lsnStr)
+	err := c.db.QueryRowContext(ctx, "SELECT "+PGCurrentWALLSN).Scan(&lsnStr)
 	if err != nil {
 		return LSN{}, fmt.Errorf("failed to get current WAL LSN: %w", err)
 	}
@@ -95,16 +191,61 @@ func (c *PGLSNChecker) GetCurrentWALLSN(ctx context.Context) (LSN, error) {
 		return LSN{}, fmt.Errorf("failed to parse master LSN: %w", err)
 	}
 
+	c.mu.Lock()
+	c.lastWALLSN = lsn
+	c.lastWALAt = time.Now()
+	c.mu.Unlock()
+
 	return lsn, nil
 }
 
-// GetLastReplayLSN queries the last replay LSN from a replica database
+// GetLastReplayLSN queries the last replay LSN from a replica database. Concurrent calls share a single query,
+// whose result is at most as old as the query in flight when they're made.
 func (c *PGLSNChecker) GetLastReplayLSN(ctx context.Context) (LSN, error) {
-	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
-	defer cancel()
+	ctx, span := startSpan(ctx, SpanLastReplayLSN)
+	defer span.End()
 
+	lsn, err := c.shareReplayQuery(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return LSN{}, err
+	}
+	span.SetAttributes(SpanAttribute{Key: AttrObservedLSN, Value: lsn.String()})
+	return lsn, nil
+}
+
+// shareReplayQuery joins the replay LSN query in flight, or starts one. The query runs bounded by the query
+// timeout even if the caller starting it gives up, so the callers that joined it still get its result.
+func (c *PGLSNChecker) shareReplayQuery(ctx context.Context) (LSN, error) {
+	c.flightMu.Lock()
+	flight := c.replayFlight
+	if flight == nil {
+		flight = &lsnFlight{done: make(chan struct{})}
+		c.replayFlight = flight
+		go func() {
+			queryCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.queryTimeout)
+			defer cancel()
+			flight.lsn, flight.err = c.queryLastReplayLSN(queryCtx)
+
+			c.flightMu.Lock()
+			c.replayFlight = nil
+			c.flightMu.Unlock()
+			close(flight.done)
+		}()
+	}
+	c.flightMu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.lsn, flight.err
+	case <-ctx.Done():
+		return LSN{}, fmt.Errorf("failed to get last replay LSN: %w", ctx.Err())
+	}
+}
+
+func (c *PGLSNChecker) queryLastReplayLSN(ctx context.Context) (LSN, error) {
 	var lsnStr string
-	err := c.db.QueryRowContext(queryCtx, "SELECT "+PGLastWalReplayLSN).Scan(&lsnStr)
+	err := c.db.QueryRowContext(ctx, "SELECT "+PGLastWalReplayLSN).Scan(&lsnStr)
 	if err != nil {
 		return LSN{}, fmt.Errorf("failed to get last replay LSN: %w", err)
 	}
@@ -114,9 +255,36 @@ func (c *PGLSNChecker) GetLastReplayLSN(ctx context.Context) (LSN, error) {
 		return LSN{}, fmt.Errorf("failed to parse replica LSN: %w", err)
 	}
 
+	c.mu.Lock()
+	c.lastReplayLSN = lsn
+	c.lastReplayAt = time.Now()
+	c.mu.Unlock()
+
 	return lsn, nil
 }
 
+// CachedReplayLSN returns the last replay LSN successfully observed on the replica
+// and when it was observed, without querying the database.
+// The boolean is false if no replay LSN has been observed yet.
+func (c *PGLSNChecker) CachedReplayLSN() (LSN, time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastReplayLSN, c.lastReplayAt, !c.lastReplayAt.IsZero()
+}
+
+// cachedReplayLSNWithin returns the last replay LSN observed on the replica if it was observed less than maxAge ago
+func (c *PGLSNChecker) cachedReplayLSNWithin(maxAge time.Duration) (LSN, bool) {
+	lsn, at, ok := c.CachedReplayLSN()
+	return lsn, ok && maxAge > 0 && time.Since(at) < maxAge
+}
+
+// cachedWALLSN returns the last current WAL LSN successfully observed on the master
+func (c *PGLSNChecker) cachedWALLSN() (LSN, time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastWALLSN, c.lastWALAt, !c.lastWALAt.IsZero()
+}
+
 // GetReplicationLag calculates the replication lag in bytes between master and replica
 func (c *PGLSNChecker) GetReplicationLag(ctx context.Context, masterLSN LSN) (uint64, error) {
 	replicaLSN, err := c.GetLastReplayLSN(ctx)
@@ -147,8 +315,9 @@ func (c *PGLSNChecker) IsReplicaHealthy(ctx context.Context, masterLSN LSN, maxL
 	return true, nil
 }
 
-// GetWALLagBytes queries the WAL lag in bytes between two LSNs using pg_wal_lsn_diff
-func (c *PGLSNChecker) GetWALLagBytes(ctx context.Context, fromLSN, toLSN LSN) (uint64, error) {
+// GetWALLagBytes queries the WAL lag in bytes of fromLSN behind toLSN using pg_wal_lsn_diff. The lag is negative
+// when fromLSN is ahead, e.g. for a replica compared with a stale primary LSN.
+func (c *PGLSNChecker) GetWALLagBytes(ctx context.Context, fromLSN, toLSN LSN) (int64, error) {
 	if fromLSN.IsZero() || toLSN.IsZero() {
 		return 0, fmt.Errorf("both LSNs must be non-zero")
 	}
@@ -156,11 +325,9 @@ func (c *PGLSNChecker) GetWALLagBytes(ctx context.Context, fromLSN, toLSN LSN) (
 	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
 	defer cancel()
 
-	var lagBytes uint64
-	// #nosec G201 -- LSN values are validated by ParseLSN before being passed here
-	query := fmt.Sprintf("SELECT pg_wal_lsn_diff('%s'::pg_lsn, '%s'::pg_lsn)", toLSN.String(), fromLSN.String())
-
-	err := c.db.QueryRowContext(queryCtx, query).Scan(&lagBytes)
+	var lagBytes int64
+	err := c.db.QueryRowContext(queryCtx, "SELECT pg_wal_lsn_diff($1::pg_lsn, $2::pg_lsn)::bigint",
+		toLSN.String(), fromLSN.String()).Scan(&lagBytes)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get WAL lag: %w", err)
 	}
@@ -168,6 +335,35 @@ func (c *PGLSNChecker) GetWALLagBytes(ctx context.Context, fromLSN, toLSN LSN) (
 	return lagBytes, nil
 }
 
+// GetWALLag queries the WAL lag of fromLSN behind toLSN like GetWALLagBytes
+func (c *PGLSNChecker) GetWALLag(ctx context.Context, fromLSN, toLSN LSN) (WALLag, error) {
+	lagBytes, err := c.GetWALLagBytes(ctx, fromLSN, toLSN)
+	return WALLag{Bytes: lagBytes}, err
+}
+
+// WALLag is a WAL lag in bytes, negative when ahead
+type WALLag struct {
+	Bytes int64
+}
+
+// String formats the lag in binary units, e.g. "1.5 MiB" or "-512 B"
+func (l WALLag) String() string {
+	const unit = 1024
+	sign, abs := "", uint64(l.Bytes)
+	if l.Bytes < 0 {
+		sign, abs = "-", uint64(-l.Bytes)
+	}
+	if abs < unit {
+		return fmt.Sprintf("%s%d B", sign, abs)
+	}
+	div, exp := uint64(unit), 0
+	for n := abs / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s%.1f %ciB", sign, float64(abs)/float64(div), "KMGTPE"[exp])
+}
+
 // TestConnection performs a basic connection test
 func (c *PGLSNChecker) TestConnection(ctx context.Context) error {
 	queryCtx, cancel := context.WithTimeout(ctx, c.queryTimeout)
@@ -0,0 +1,173 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPGLSNCheckerSharesConcurrentReplayChecks(t *testing.T) {
+	replica, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000"))
+	checker := getOrCreateChecker(replica, time.Second)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range 50 {
+		wg.Go(func() {
+			<-start
+			if lsn, err := checker.GetLastReplayLSN(context.Background()); err != nil || lsn != (LSN{Lower: 0x3000}) {
+				t.Errorf("want the shared replay LSN, got %s, %v", lsn, err)
+			}
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	// a single query was expected, the others would have failed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPGLSNCheckerJoinerGivesUp(t *testing.T) {
+	replica, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT pg_last_wal_replay_lsn()").
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000"))
+	checker := getOrCreateChecker(replica, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := checker.GetLastReplayLSN(ctx); err == nil {
+		t.Fatal("want the caller's deadline returned")
+	}
+	// the query started by the caller that gave up still completes for the others
+	if lsn, err := checker.GetLastReplayLSN(context.Background()); err != nil || lsn != (LSN{Lower: 0x3000}) {
+		t.Errorf("want the query in flight joined, got %s, %v", lsn, err)
+	}
+}
+
+func TestCausalRouterThrottlesReplayChecks(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, mock := newMockDB(t)
+	expectReplayLSN(mock, "0/3000")
+
+	router := NewCausalRouter(&staticProvider{primaries: []*sql.DB{primary}, replicas: []*sql.DB{replica}},
+		&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true, ThrottleTime: time.Minute})
+
+	// the LSN replayed by the replica is queried once, then reused for a minute
+	for _, read := range []struct {
+		required LSN
+		want     *sql.DB
+	}{{LSN{Lower: 0x1000}, replica}, {LSN{Lower: 0x2000}, replica}, {LSN{Lower: 0x4000}, primary}} {
+		ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: read.required})
+		if selected, err := router.RouteQuery(ctx, QueryTypeRead); err != nil || selected != read.want {
+			t.Errorf("want the read requiring %s routed by the reused LSN, got %v", read.required, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPGLSNCheckerThrottlesWALChecks(t *testing.T) {
+	primary, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/1000"))
+	mock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/2000"))
+	checker := getOrCreateChecker(primary, time.Second)
+	ctx := context.Background()
+
+	start := time.Now()
+	if lsn, err := checker.throttledWALLSN(ctx, 100*time.Millisecond); err != nil || lsn != (LSN{Lower: 0x1000}) {
+		t.Fatalf("want the first write checked right away, got %s, %v", lsn, err)
+	}
+	// the writes of the window don't reuse the LSN queried before them, they share the next query
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if lsn, err := checker.throttledWALLSN(ctx, 100*time.Millisecond); err != nil || lsn != (LSN{Lower: 0x2000}) {
+				t.Errorf("want the next WAL LSN shared, got %s, %v", lsn, err)
+			}
+		})
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("want the second query throttled, ran after %v", elapsed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPGLSNCheckerRegistryEviction(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, mock := newMockDB(t)
+	removed, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica, removed))
+	for _, target := range []*sql.DB{primary, replica, removed} {
+		getOrCreateChecker(target, time.Second)
+	}
+
+	if err := db.RemoveReplica(removed); err != nil {
+		t.Fatal(err)
+	}
+	if lookupChecker(removed) != nil {
+		t.Error("want the checker of a removed replica purged")
+	}
+	mock.ExpectClose()
+	_ = db.Close()
+	if lookupChecker(primary) != nil || lookupChecker(replica) != nil {
+		t.Error("want the checkers of the closed databases purged")
+	}
+
+	standalone, _ := newMockDB(t)
+	getOrCreateChecker(standalone, time.Second)
+	PurgeChecker(standalone)
+	if lookupChecker(standalone) != nil {
+		t.Error("want the checker purged")
+	}
+}
+
+func TestPGLSNCheckerGetWALLag(t *testing.T) {
+	replica, mock := newMockDB(t)
+	checker := getOrCreateChecker(replica, time.Second)
+	replayed, current := LSN{Lower: 0x1000}, LSN{Lower: 0x181000}
+
+	mock.ExpectQuery(`SELECT pg_wal_lsn_diff\(\$1::pg_lsn, \$2::pg_lsn\)::bigint`).
+		WithArgs("0/181000", "0/1000").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(int64(0x180000)))
+	if lag, err := checker.GetWALLag(context.Background(), replayed, current); err != nil || lag.String() != "1.5 MiB" {
+		t.Errorf("want a lag of 1.5 MiB, got %s, %v", lag, err)
+	}
+
+	// a replica ahead of a stale primary LSN has a negative lag
+	mock.ExpectQuery("SELECT pg_wal_lsn_diff").
+		WithArgs("0/1000", "0/181000").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(int64(-0x180000)))
+	if lag, err := checker.GetWALLagBytes(context.Background(), current, replayed); err != nil || lag != -0x180000 {
+		t.Errorf("want a negative lag, got %d, %v", lag, err)
+	}
+
+	if _, err := checker.GetWALLagBytes(context.Background(), LSN{}, current); err == nil {
+		t.Error("want zero LSNs rejected")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	for _, tt := range []struct {
+		bytes int64
+		want  string
+	}{{0, "0 B"}, {-512, "-512 B"}, {2048, "2.0 KiB"}, {3 << 30, "3.0 GiB"}} {
+		if got := (WALLag{Bytes: tt.bytes}).String(); got != tt.want {
+			t.Errorf("want %d bytes formatted as %q, got %q", tt.bytes, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package cluster starts a PostgreSQL primary with streaming replicas in Docker, so integration
+// tests can exercise dbresolver against a real replication topology. It requires docker with the
+// compose plugin; Setup skips the test when docker isn't installed.
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"text/template"
+	"time"
+)
+
+const (
+	DefaultImage        = "postgres:15-alpine"
+	DefaultStartTimeout = 2 * time.Minute
+)
+
+// Credentials and database of every node, as used in the DSNs
+const (
+	User     = "postgres"
+	Password = "postgres"
+	Database = "pgroutertest"
+)
+
+//go:embed compose.yaml.tmpl
+var composeTemplate string
+
+var composeTmpl = template.Must(template.New("compose").Parse(composeTemplate))
+
+// composeData is rendered into the compose file
+type composeData struct {
+	Image       string
+	User        string
+	Password    string
+	Database    string
+	PrimaryPort int
+	Replicas    []replicaService
+}
+
+type replicaService struct {
+	Name string
+	Port int
+}
+
+type config struct {
+	image        string
+	replicas     int
+	startTimeout time.Duration
+}
+
+// Option configures the cluster
+type Option func(c *config)
+
+// WithReplicas sets the number of streaming replicas. Defaults to 1.
+func WithReplicas(n int) Option {
+	return func(c *config) {
+		c.replicas = n
+	}
+}
+
+// WithImage sets the PostgreSQL image of every node. Defaults to DefaultImage.
+func WithImage(image string) Option {
+	return func(c *config) {
+		c.image = image
+	}
+}
+
+// WithStartTimeout bounds the time to wait for every node to be healthy. Defaults to DefaultStartTimeout.
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.startTimeout = timeout
+	}
+}
+
+// Cluster is a running primary and replicas compose project
+type Cluster struct {
+	project     string
+	dir         string
+	primaryPort int
+	replicas    []replicaService
+}
+
+// Start starts a cluster and waits until every node accepts connections.
+// The cluster must be stopped with Stop.
+func Start(ctx context.Context, opts ...Option) (*Cluster, error) {
+	cfg := config{image: DefaultImage, replicas: 1, startTimeout: DefaultStartTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c, err := newCluster(cfg.replicas)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeComposeFile(cfg.image); err != nil {
+		_ = os.RemoveAll(c.dir)
+		return nil, err
+	}
+
+	timeout := strconv.Itoa(int(cfg.startTimeout.Seconds()))
+	if err := c.compose(ctx, "up", "--detach", "--wait", "--wait-timeout", timeout); err != nil {
+		_ = c.Stop(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return c, nil
+}
+
+// Setup starts a cluster for the test and stops it on cleanup.
+// The test is skipped when docker isn't available and fails if the cluster can't be started.
+func Setup(tb testing.TB, opts ...Option) *Cluster {
+	tb.Helper()
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		tb.Skip("pgroutertest/cluster: docker is not available")
+	}
+	c, err := Start(context.Background(), opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := c.Stop(context.Background()); err != nil {
+			tb.Error(err)
+		}
+	})
+	return c
+}
+
+// newCluster names the compose project and reserves the host ports of the nodes
+func newCluster(replicas int) (*Cluster, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to name the cluster: %w", err)
+	}
+	c := &Cluster{project: "pgroutertest-" + hex.EncodeToString(suffix)}
+
+	ports, err := freePorts(replicas + 1)
+	if err != nil {
+		return nil, err
+	}
+	c.primaryPort = ports[0]
+	for i := range replicas {
+		c.replicas = append(c.replicas, replicaService{Name: "replica-" + strconv.Itoa(i), Port: ports[i+1]})
+	}
+	return c, nil
+}
+
+// freePorts returns n local ports free at the time of the call. The ports are fixed in the compose
+// file so that they survive restarts of the nodes.
+func freePorts(n int) ([]int, error) {
+	listeners := make([]net.Listener, 0, n)
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+
+	ports := make([]int, 0, n)
+	for range n {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve a port: %w", err)
+		}
+		listeners = append(listeners, l)
+		ports = append(ports, l.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// writeComposeFile renders the compose file into a temporary directory
+func (c *Cluster) writeComposeFile(image string) error {
+	var buf bytes.Buffer
+	err := composeTmpl.Execute(&buf, composeData{
+		Image:       image,
+		User:        User,
+		Password:    Password,
+		Database:    Database,
+		PrimaryPort: c.primaryPort,
+		Replicas:    c.replicas,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render compose file: %w", err)
+	}
+
+	c.dir, err = os.MkdirTemp("", c.project)
+	if err != nil {
+		return fmt.Errorf("failed to create compose directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, "compose.yaml"), buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+	return nil
+}
+
+// Stop removes the containers and volumes of the cluster
+func (c *Cluster) Stop(ctx context.Context) error {
+	err := c.compose(ctx, "down", "--volumes", "--remove-orphans")
+	if rmErr := os.RemoveAll(c.dir); err == nil && rmErr != nil {
+		err = fmt.Errorf("failed to remove compose directory: %w", rmErr)
+	}
+	return err
+}
+
+// PrimaryDSN returns the connection string of the primary
+func (c *Cluster) PrimaryDSN() string {
+	return dsn(c.primaryPort)
+}
+
+// ReplicaDSNs returns the connection strings of the replicas, in order
+func (c *Cluster) ReplicaDSNs() []string {
+	dsns := make([]string, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		dsns = append(dsns, dsn(r.Port))
+	}
+	return dsns
+}
+
+func dsn(port int) string {
+	return fmt.Sprintf("postgres://%s:%s@127.0.0.1:%d/%s?sslmode=disable", User, Password, port, Database)
+}
+
+// PauseReplay pauses WAL replay on a replica, so it lags behind every following write
+func (c *Cluster) PauseReplay(ctx context.Context, replica int) error {
+	return c.psqlReplica(ctx, replica, "SELECT pg_wal_replay_pause()")
+}
+
+// ResumeReplay resumes WAL replay on a replica
+func (c *Cluster) ResumeReplay(ctx context.Context, replica int) error {
+	return c.psqlReplica(ctx, replica, "SELECT pg_wal_replay_resume()")
+}
+
+// InduceLag delays the replay of every transaction on a replica by delay, 0 removes the delay
+func (c *Cluster) InduceLag(ctx context.Context, replica int, delay time.Duration) error {
+	set := "ALTER SYSTEM RESET recovery_min_apply_delay"
+	if delay > 0 {
+		set = fmt.Sprintf("ALTER SYSTEM SET recovery_min_apply_delay = '%dms'", delay.Milliseconds())
+	}
+	return c.psqlReplica(ctx, replica, set, "SELECT pg_reload_conf()")
+}
+
+// Promote promotes a replica to a primary. The other replicas keep following the old primary.
+func (c *Cluster) Promote(ctx context.Context, replica int) error {
+	return c.psqlReplica(ctx, replica, "SELECT pg_promote()")
+}
+
+// StopReplica stops the container of a replica, e.g. to test replica outages
+func (c *Cluster) StopReplica(ctx context.Context, replica int) error {
+	service, err := c.replicaService(replica)
+	if err != nil {
+		return err
+	}
+	return c.compose(ctx, "stop", service)
+}
+
+// StartReplica starts a replica stopped with StopReplica and waits until it accepts connections
+func (c *Cluster) StartReplica(ctx context.Context, replica int) error {
+	service, err := c.replicaService(replica)
+	if err != nil {
+		return err
+	}
+	return c.compose(ctx, "up", "--detach", "--wait", service)
+}
+
+func (c *Cluster) replicaService(replica int) (string, error) {
+	if replica < 0 || replica >= len(c.replicas) {
+		return "", fmt.Errorf("replica %d out of range, the cluster has %d replicas", replica, len(c.replicas))
+	}
+	return c.replicas[replica].Name, nil
+}
+
+// psqlReplica runs each statement in its own transaction on a replica
+func (c *Cluster) psqlReplica(ctx context.Context, replica int, statements ...string) error {
+	service, err := c.replicaService(replica)
+	if err != nil {
+		return err
+	}
+	return c.psql(ctx, service, statements...)
+}
+
+func (c *Cluster) psql(ctx context.Context, service string, statements ...string) error {
+	args := []string{"exec", "-T", service, "psql", "-U", User, "-d", Database, "-v", "ON_ERROR_STOP=1", "-qAt"}
+	for _, s := range statements {
+		args = append(args, "-c", s)
+	}
+	return c.compose(ctx, args...)
+}
+
+// compose runs a docker compose command on the cluster project
+func (c *Cluster) compose(ctx context.Context, args ...string) error {
+	cmdArgs := append([]string{"compose", "--project-name", c.project, "--file", filepath.Join(c.dir, "compose.yaml")}, args...)
+	out, err := exec.CommandContext(ctx, "docker", cmdArgs...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker compose %v failed: %w: %s", args, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// Package pgroutertest provides helpers to test code built on dbresolver, see the cluster
+// package for a real replication topology.
+package pgroutertest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// MaxReportedErrors bounds the errors kept in a HammerReport
+const MaxReportedErrors = 32
+
+// HammerOptions configures Hammer
+type HammerOptions struct {
+	Workers    int           // Concurrent query workers, defaults to 8
+	Duration   time.Duration // How long the workers run, defaults to 1s
+	ReadQuery  string        // Read issued by the workers, defaults to "SELECT 1"
+	WriteQuery string        // Write issued by every fourth worker, none when empty
+	// Topology drains, delays, removes and re-adds the replicas routable when Hammer starts,
+	// concurrently with the queries. Every change is undone right away.
+	Topology bool
+}
+
+// HammerReport counts the operations run by Hammer
+type HammerReport struct {
+	Reads           int64
+	Writes          int64
+	TopologyChanges int64
+	Observations    int64
+	Errors          []error // The first MaxReportedErrors errors
+	DroppedErrors   int64   // Errors beyond MaxReportedErrors
+}
+
+// Hammer exercises db concurrently for opts.Duration, every worker running at least once: workers route reads, through the resolver and a
+// prepared statement, and writes whose causal tokens the reads then honor, while an observer reads the
+// status, metrics and decisions of the resolver and, with opts.Topology, the replica pool churns.
+// Health transitions are exercised by enabling dbresolver.WithHealthCheck with a short interval.
+// Running it under the race detector checks the concurrency guarantees of dbresolver together with
+// the router, balancer, checker and callbacks of the application.
+func Hammer(db *dbresolver.DB, opts HammerOptions) HammerReport {
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = time.Second
+	}
+	if opts.ReadQuery == "" {
+		opts.ReadQuery = "SELECT 1"
+	}
+
+	h := &hammer{db: db, opts: opts, deadline: time.Now().Add(opts.Duration)}
+	h.token.Store("")
+
+	stmt, err := db.Prepare(opts.ReadQuery)
+	if err != nil {
+		h.fail(fmt.Errorf("failed to prepare read: %w", err))
+	} else {
+		defer stmt.Close()
+	}
+
+	var wg sync.WaitGroup
+	for i := range opts.Workers {
+		switch {
+		case i%4 == 0 && opts.WriteQuery != "":
+			wg.Go(h.writer)
+		case i%4 == 1 && stmt != nil:
+			wg.Go(func() { h.reader(func(ctx context.Context) (*sql.Rows, error) { return stmt.QueryContext(ctx) }) })
+		default:
+			wg.Go(func() {
+				h.reader(func(ctx context.Context) (*sql.Rows, error) { return db.QueryContext(ctx, opts.ReadQuery) })
+			})
+		}
+	}
+	wg.Go(h.observer)
+	if opts.Topology {
+		wg.Go(h.topology)
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.report.Reads = h.reads.Load()
+	h.report.Writes = h.writes.Load()
+	h.report.TopologyChanges = h.changes.Load()
+	h.report.Observations = h.observations.Load()
+	return h.report
+}
+
+// hammer is the state shared by the workers of Hammer
+type hammer struct {
+	db       *dbresolver.DB
+	opts     HammerOptions
+	deadline time.Time
+
+	token                                atomic.Value // causal token of the latest write
+	reads, writes, changes, observations atomic.Int64
+
+	mu     sync.Mutex
+	report HammerReport
+}
+
+// loop runs iteration until the deadline, at least once
+func (h *hammer) loop(iteration func(i int)) {
+	for i := 0; i == 0 || time.Now().Before(h.deadline); i++ {
+		iteration(i)
+	}
+}
+
+func (h *hammer) fail(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.report.Errors) < MaxReportedErrors {
+		h.report.Errors = append(h.report.Errors, err)
+		return
+	}
+	h.report.DroppedErrors++
+}
+
+// reader issues the read, honoring the causal token of the latest write
+func (h *hammer) reader(query func(ctx context.Context) (*sql.Rows, error)) {
+	h.loop(func(int) {
+		ctx := h.db.WithCausalToken(context.Background(), h.token.Load().(string))
+		if err := drain(query(ctx)); err != nil {
+			h.fail(fmt.Errorf("read: %w", err))
+		}
+		h.reads.Add(1)
+	})
+}
+
+// drain reads every row, closing rows
+func drain(rows *sql.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// writer issues the write in its own LSN context and publishes its causal token
+func (h *hammer) writer() {
+	h.loop(func(int) {
+		ctx := dbresolver.WithLSNContext(context.Background(), &dbresolver.LSNContext{})
+		h.writes.Add(1)
+		if _, err := h.db.ExecContext(ctx, h.opts.WriteQuery); err != nil {
+			h.fail(fmt.Errorf("write: %w", err))
+			return
+		}
+		token, err := h.db.CausalToken(ctx)
+		if err != nil {
+			h.fail(fmt.Errorf("causal token: %w", err))
+			return
+		}
+		if token != "" {
+			h.token.Store(token)
+		}
+	})
+}
+
+// observer reads the state of the resolver
+func (h *hammer) observer() {
+	h.loop(func(int) {
+		h.db.GetReplicaStatus()
+		h.db.GetPrimaryStatus()
+		h.db.Metrics()
+		h.db.RoutingDecisions()
+		h.db.ReplicaDBs()
+		h.db.IsReplicaOutage()
+		h.db.HeldHandles()
+		if err := h.db.PingContext(context.Background()); err != nil {
+			h.fail(fmt.Errorf("ping: %w", err))
+		}
+		h.observations.Add(1)
+	})
+}
+
+// topology drains, delays, then removes each replica in turn, undoing every change right away
+func (h *hammer) topology() {
+	replicas := h.db.ReplicaDBs()
+	if len(replicas) == 0 {
+		return
+	}
+	h.loop(func(i int) {
+		replica := replicas[i%len(replicas)]
+		var err error
+		switch i % 3 {
+		case 0:
+			if err = h.db.DrainReplica(replica); err == nil {
+				err = h.db.UndrainReplica(replica)
+			}
+		case 1:
+			if err = h.db.SetReplicaDelayed(replica, true); err == nil {
+				err = h.db.SetReplicaDelayed(replica, false)
+			}
+		case 2:
+			if err = h.db.RemoveReplica(replica); err == nil {
+				err = h.db.AddReplica(replica)
+			}
+		}
+		if err != nil {
+			h.fail(fmt.Errorf("topology: %w", err))
+		}
+		h.changes.Add(1)
+	})
+}
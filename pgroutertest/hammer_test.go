@@ -0,0 +1,168 @@
+package pgroutertest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// walLSN is the WAL position of the fake primary, advanced by every write
+var walLSN atomic.Uint64
+
+func init() {
+	sql.Register("pgroutertest-fake", fakeDriver{})
+}
+
+// fakeDriver answers the LSN queries of dbresolver and "SELECT 1". The replicas named "flapping"
+// fail every third replay LSN query, so the health monitor sees them going down and up.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{flapping: name == "flapping"}, nil
+}
+
+type fakeConn struct {
+	flapping bool
+	replays  int
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions aren't supported")
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	walLSN.Add(1)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, dbresolver.PGCurrentWALLSN):
+		return &fakeRows{value: fmt.Sprintf("0/%X", walLSN.Load())}, nil
+	case strings.Contains(query, dbresolver.PGLastWalReplayLSN):
+		c.replays++
+		if c.flapping && c.replays%3 == 0 {
+			return nil, errors.New("replica is restarting")
+		}
+		return &fakeRows{value: fmt.Sprintf("0/%X", walLSN.Load())}, nil
+	default:
+		return &fakeRows{value: "1"}, nil
+	}
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+type fakeRows struct {
+	value string
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func openFake(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("pgroutertest-fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxIdleConns(16)
+	return db
+}
+
+func TestHammer(t *testing.T) {
+	db := dbresolver.New(
+		dbresolver.WithPrimaryDBs(openFake(t, "primary")),
+		dbresolver.WithReplicaDBs(openFake(t, "replica"), openFake(t, "flapping")),
+		dbresolver.WithCausalConsistencyLevel(dbresolver.ReadYourWrites),
+		dbresolver.WithHealthCheck(time.Millisecond, time.Second),
+		dbresolver.WithHealthCallback(func(dbresolver.HealthEvent) {}),
+	)
+	defer db.Close()
+
+	report := Hammer(db, HammerOptions{
+		Workers:    8,
+		Duration:   500 * time.Millisecond,
+		WriteQuery: "INSERT INTO events DEFAULT VALUES",
+		Topology:   true,
+	})
+	for _, err := range report.Errors {
+		t.Error(err)
+	}
+	if report.Reads == 0 || report.Writes == 0 || report.TopologyChanges == 0 || report.Observations == 0 {
+		t.Errorf("expected every kind of operation to run: %+v", report)
+	}
+	if got := len(db.ReplicaDBs()); got != 2 {
+		t.Errorf("expected the replicas to be restored, got %d", got)
+	}
+}
+
+func TestHammerRouters(t *testing.T) {
+	for name, router := range map[string]func(dbresolver.DBProvider) dbresolver.QueryRouter{
+		"round robin": func(p dbresolver.DBProvider) dbresolver.QueryRouter { return dbresolver.NewRoundRobinRouter(p) },
+		"random":      func(p dbresolver.DBProvider) dbresolver.QueryRouter { return dbresolver.NewRandomRouter(p) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			primary, replica := openFake(t, "primary"), openFake(t, "replica")
+			provider := &staticProvider{primaries: []*sql.DB{primary}, replicas: []*sql.DB{replica}}
+			db := dbresolver.New(
+				dbresolver.WithPrimaryDBs(primary),
+				dbresolver.WithReplicaDBs(replica),
+				dbresolver.WithLoadBalancer(dbresolver.RandomLB),
+				dbresolver.WithCausalConsistency(router(provider)),
+			)
+			defer db.Close()
+
+			report := Hammer(db, HammerOptions{Duration: 100 * time.Millisecond, WriteQuery: "UPDATE counters SET n = n + 1"})
+			for _, err := range report.Errors {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+type staticProvider struct {
+	primaries, replicas []*sql.DB
+}
+
+func (p *staticProvider) PrimaryDBs() []*sql.DB { return p.primaries }
+func (p *staticProvider) ReplicaDBs() []*sql.DB { return p.replicas }
+func (p *staticProvider) LoadBalancer() dbresolver.LoadBalancer[*sql.DB] {
+	return &dbresolver.RandomLoadBalancer[*sql.DB]{}
+}
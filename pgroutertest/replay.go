@@ -0,0 +1,147 @@
+package pgroutertest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// ReplayOptions configures Replay
+type ReplayOptions struct {
+	Workers int     // Maximum concurrent reads, defaults to 8
+	Speed   float64 // Pace of the replay relative to the capture, 2 replays twice as fast; defaults to 1
+	// Args returns the args of a captured read, whose values aren't captured; none by default
+	Args func(read dbresolver.CapturedRead) []any
+}
+
+// ReplayReport compares the routing of the replayed reads with their capture
+type ReplayReport struct {
+	Reads                int64
+	CapturedPrimaryReads int64 // reads served by a primary when captured
+	PrimaryReads         int64 // reads served by a primary during the replay, from the metrics of the resolver
+	ReplicaReads         int64
+	CapturedDuration     time.Duration // sum of the captured read durations
+	Duration             time.Duration // sum of the replayed read durations
+	Errors               []error       // The first MaxReportedErrors errors
+	DroppedErrors        int64         // Errors beyond MaxReportedErrors
+}
+
+// Replay re-issues the reads captured with dbresolver.WithReadCapture and a dbresolver.JSONReadCaptureSink against
+// db, typically a staging resolver with the routing configuration under test, at the pace they were captured.
+// Reads that required an LSN are replayed without one. The metrics of db are read before and after the replay, so
+// it shouldn't serve other traffic meanwhile. Replay returns early with the error of ctx or of reading r.
+func Replay(ctx context.Context, db *dbresolver.DB, r io.Reader, opts ReplayOptions) (ReplayReport, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+
+	rp := &replayer{db: db, opts: opts}
+	before := db.Metrics()
+
+	var (
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, opts.Workers)
+		first    time.Time
+		startAt  = time.Now()
+		scanner  = bufio.NewScanner(r)
+		replayed error
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var read dbresolver.CapturedRead
+		if err := json.Unmarshal(scanner.Bytes(), &read); err != nil {
+			replayed = fmt.Errorf("failed to decode captured read: %w", err)
+			break
+		}
+		if first.IsZero() {
+			first = read.Time
+		}
+		// waits for the offset of the read in the capture, scaled by the speed
+		if wait := time.Until(startAt.Add(time.Duration(float64(read.Time.Sub(first)) / opts.Speed))); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			replayed = ctx.Err()
+			break
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			rp.replay(ctx, read)
+		})
+	}
+	wg.Wait()
+	if replayed == nil {
+		replayed = scanner.Err()
+	}
+
+	after := db.Metrics()
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.report.Reads = rp.reads.Load()
+	rp.report.CapturedPrimaryReads = rp.capturedPrimary.Load()
+	rp.report.CapturedDuration = time.Duration(rp.capturedDuration.Load())
+	rp.report.Duration = time.Duration(rp.duration.Load())
+	rp.report.PrimaryReads = int64(after.PrimaryReads - before.PrimaryReads) //nolint:gosec // counters only grow
+	rp.report.ReplicaReads = int64(after.ReplicaReads - before.ReplicaReads) //nolint:gosec // counters only grow
+	return rp.report, replayed
+}
+
+// replayer is the state shared by the reads of Replay
+type replayer struct {
+	db   *dbresolver.DB
+	opts ReplayOptions
+
+	reads, capturedPrimary, capturedDuration, duration atomic.Int64
+
+	mu     sync.Mutex
+	report ReplayReport
+}
+
+// replay issues a captured read
+func (rp *replayer) replay(ctx context.Context, read dbresolver.CapturedRead) {
+	var args []any
+	if rp.opts.Args != nil {
+		args = rp.opts.Args(read)
+	}
+	rp.reads.Add(1)
+	if read.Primary {
+		rp.capturedPrimary.Add(1)
+	}
+	rp.capturedDuration.Add(int64(read.Duration))
+
+	start := time.Now()
+	err := drain(rp.db.QueryContext(ctx, read.Query, args...))
+	rp.duration.Add(int64(time.Since(start)))
+	if err != nil {
+		rp.fail(fmt.Errorf("replay %q: %w", read.Query, err))
+	}
+}
+
+func (rp *replayer) fail(err error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if len(rp.report.Errors) < MaxReportedErrors {
+		rp.report.Errors = append(rp.report.Errors, err)
+		return
+	}
+	rp.report.DroppedErrors++
+}
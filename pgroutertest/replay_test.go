@@ -0,0 +1,57 @@
+package pgroutertest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func TestReplay(t *testing.T) {
+	var captured bytes.Buffer
+	production := dbresolver.New(
+		dbresolver.WithPrimaryDBs(openFake(t, "primary")),
+		dbresolver.WithReplicaDBs(openFake(t, "replica")),
+		dbresolver.WithReadCapture(dbresolver.ReadCaptureConfig{
+			Sink:       dbresolver.NewJSONReadCaptureSink(&captured),
+			SampleRate: 1,
+		}),
+	)
+	defer production.Close()
+	for range 20 {
+		if err := drain(production.QueryContext(context.Background(), "SELECT 1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// staging has no replica, every replayed read moves to the primary
+	staging := dbresolver.New(dbresolver.WithPrimaryDBs(openFake(t, "primary")))
+	defer staging.Close()
+	report, err := Replay(context.Background(), staging, &captured, ReplayOptions{Speed: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range report.Errors {
+		t.Error(err)
+	}
+	if report.Reads != 20 || report.CapturedPrimaryReads != 0 || report.PrimaryReads != 20 {
+		t.Errorf("want the 20 replica reads replayed on the primary, got %+v", report)
+	}
+}
+
+func TestReplayCanceled(t *testing.T) {
+	capture := `{"time":"2026-01-01T00:00:00Z","query":"SELECT 1"}` + "\n" +
+		`{"time":"2026-01-01T01:00:00Z","query":"SELECT 1"}` + "\n"
+	db := dbresolver.New(dbresolver.WithPrimaryDBs(openFake(t, "primary")))
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	report, err := Replay(ctx, db, strings.NewReader(capture), ReplayOptions{})
+	if err == nil || report.Reads != 1 {
+		t.Errorf("want the replay stopped before the read captured an hour later, got %d reads, %v", report.Reads, err)
+	}
+}
@@ -0,0 +1,129 @@
+package pgxresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// ErrCopyUnsupported is returned by CopyFrom and CopyTo for the pools implementing neither CopyFromPool, CopyToPool
+// nor *pgxpool.Pool
+var ErrCopyUnsupported = errors.New("pgxresolver: the pool doesn't support COPY")
+
+// CopyFromPool is implemented by the pools supporting CopyFrom, like *pgxpool.Pool
+type CopyFromPool interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// CopyToPool is implemented by the pools supporting CopyTo other than *pgxpool.Pool, which runs it on an acquired
+// connection
+type CopyToPool interface {
+	CopyTo(ctx context.Context, w io.Writer, sql string) (pgconn.CommandTag, error)
+}
+
+var _ CopyFromPool = (*pgxpool.Pool)(nil)
+
+// WithCopyToReplicas routes CopyTo like a read, e.g. to a replica which caught up to the LSN required by the
+// context, instead of the primary
+func WithCopyToReplicas() Option {
+	return func(db *DB) {
+		db.copyToReplicas = true
+	}
+}
+
+// CopyFrom bulk loads the rows of src into the columns of table on the primary with the COPY protocol of pgx.
+// Like Exec, it pins the following reads of the LSN context of ctx to the primary.
+func (db *DB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	pool, err := db.RouteQuery(ctx, dbresolver.QueryTypeWrite)
+	if err != nil {
+		return 0, err
+	}
+	copier, ok := pool.(CopyFromPool)
+	if !ok {
+		return 0, ErrCopyUnsupported
+	}
+	return copier.CopyFrom(ctx, table, columns, src)
+}
+
+// CopyTo runs sql, a COPY ... TO STDOUT, and writes its output to w, returning the number of rows copied. It runs
+// on the primary, or with WithCopyToReplicas on the pool selected like the reads of Query.
+func (db *DB) CopyTo(ctx context.Context, w io.Writer, sql string) (int64, error) {
+	pool := db.primary
+	if db.copyToReplicas {
+		var err error
+		if pool, err = db.RouteQuery(ctx, dbresolver.QueryTypeRead); err != nil {
+			return 0, err
+		}
+	}
+
+	var tag pgconn.CommandTag
+	switch p := pool.(type) {
+	case CopyToPool:
+		var err error
+		if tag, err = p.CopyTo(ctx, w, sql); err != nil {
+			return 0, err
+		}
+	case *pgxpool.Pool:
+		conn, err := p.Acquire(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Release()
+		if tag, err = conn.Conn().PgConn().CopyTo(ctx, w, sql); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, ErrCopyUnsupported
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CopyFromStdlib is the dbresolver.CopyFromFunc of the pgx stdlib driver, loading the rows with the COPY protocol
+// of pgx.Conn.CopyFrom instead of COPY FROM STDIN statements:
+//
+//	dbresolver.WithCopy(dbresolver.CopyConfig{From: pgxresolver.CopyFromStdlib, To: pgxresolver.CopyToStdlib})
+func CopyFromStdlib(ctx context.Context, conn *sql.Conn, table string, columns []string, src dbresolver.CopyFromSource) (int64, error) {
+	var n int64
+	err := conn.Raw(func(driverConn any) error {
+		pgxConn, err := stdlibConn(driverConn)
+		if err != nil {
+			return err
+		}
+		n, err = pgxConn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, src)
+		return err
+	})
+	return n, err
+}
+
+// CopyToStdlib is the dbresolver.CopyToFunc of the pgx stdlib driver, running statement with pgconn.PgConn.CopyTo
+func CopyToStdlib(ctx context.Context, conn *sql.Conn, w io.Writer, statement string) (n int64, err error) {
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn, err := stdlibConn(driverConn)
+		if err != nil {
+			return err
+		}
+		tag, err := pgxConn.PgConn().CopyTo(ctx, w, statement)
+		n = tag.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// stdlibConn returns the pgx connection of a connection of the pgx stdlib driver
+func stdlibConn(driverConn any) (*pgx.Conn, error) {
+	c, ok := driverConn.(*stdlib.Conn)
+	if !ok {
+		return nil, fmt.Errorf("pgxresolver: %T is not a connection of the pgx stdlib driver", driverConn)
+	}
+	return c.Conn(), nil
+}
@@ -0,0 +1,128 @@
+package pgxresolver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// copyPool is a fakePool supporting COPY
+type copyPool struct {
+	fakePool
+	copied [][]any
+}
+
+func (p *copyPool) CopyFrom(_ context.Context, table pgx.Identifier, _ []string, src pgx.CopyFromSource) (int64, error) {
+	p.queries = append(p.queries, "COPY "+table.Sanitize()+" FROM STDIN")
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		p.copied = append(p.copied, values)
+	}
+	return int64(len(p.copied)), src.Err()
+}
+
+func (p *copyPool) CopyTo(_ context.Context, w io.Writer, sql string) (pgconn.CommandTag, error) {
+	p.queries = append(p.queries, sql)
+	_, err := io.WriteString(w, "1,alice\n")
+	return pgconn.NewCommandTag("COPY 1"), err
+}
+
+func TestCopyFrom(t *testing.T) {
+	primary := &copyPool{fakePool: fakePool{lsn: "0/2000"}}
+	replica := &copyPool{fakePool: fakePool{lsn: "0/2000"}}
+	db := New(primary, []Pool{replica})
+	lsnCtx := &dbresolver.LSNContext{}
+	ctx := dbresolver.WithLSNContext(context.Background(), lsnCtx)
+
+	n, err := db.CopyFrom(ctx, pgx.Identifier{"public", "users"}, []string{"id", "name"}, pgx.CopyFromRows([][]any{{1, "alice"}}))
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 row loaded, got %d, %v", n, err)
+	}
+	if len(primary.queries) != 1 || primary.queries[0] != `COPY "public"."users" FROM STDIN` || len(replica.queries) != 0 {
+		t.Errorf("want the rows loaded on the primary, got %v and %v", primary.queries, replica.queries)
+	}
+	if !lsnCtx.HasWriteOperation || !lsnCtx.ForceMaster {
+		t.Errorf("want the load tracked as a write, got %+v", lsnCtx)
+	}
+
+	_, err = New(&fakePool{}, nil).CopyFrom(ctx, pgx.Identifier{"users"}, nil, pgx.CopyFromRows(nil))
+	if !errors.Is(err, ErrCopyUnsupported) {
+		t.Errorf("want ErrCopyUnsupported, got %v", err)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	for _, toReplicas := range []bool{false, true} {
+		primary := &copyPool{fakePool: fakePool{lsn: "0/2000"}}
+		replica := &copyPool{fakePool: fakePool{lsn: "0/2000"}}
+		opts := []Option{}
+		if toReplicas {
+			opts = append(opts, WithCopyToReplicas())
+		}
+		db := New(primary, []Pool{replica}, opts...)
+
+		var out bytes.Buffer
+		n, err := db.CopyTo(context.Background(), &out, "COPY users TO STDOUT")
+		if err != nil || n != 1 || out.String() != "1,alice\n" {
+			t.Fatalf("want the table copied, got %d %q, %v", n, out.String(), err)
+		}
+		target, other := primary, replica
+		if toReplicas {
+			target, other = replica, primary
+		}
+		if len(target.queries) != 1 || len(other.queries) != 0 {
+			t.Errorf("toReplicas %v: want the copy run on one pool, got %v and %v", toReplicas, target.queries, other.queries)
+		}
+	}
+
+	if _, err := New(&fakePool{}, nil).CopyTo(context.Background(), io.Discard, "COPY users TO STDOUT"); !errors.Is(err, ErrCopyUnsupported) {
+		t.Errorf("want ErrCopyUnsupported, got %v", err)
+	}
+}
+
+// otherDriver is a database/sql driver other than the pgx stdlib one
+type otherDriver struct{}
+
+func (otherDriver) Open(string) (driver.Conn, error) { return otherConn{}, nil }
+
+type otherConn struct{}
+
+func (otherConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (otherConn) Close() error                        { return nil }
+func (otherConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestCopyStdlibRequiresPgxDriver(t *testing.T) {
+	sql.Register("pgxresolver-other", otherDriver{})
+	db, err := sql.Open("pgxresolver-other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := CopyFromStdlib(ctx, conn, "users", []string{"id"}, dbresolver.CopyFromRows(nil)); err == nil ||
+		!strings.Contains(err.Error(), "not a connection of the pgx stdlib driver") {
+		t.Errorf("want the driver rejected, got %v", err)
+	}
+	if _, err := CopyToStdlib(ctx, conn, io.Discard, "COPY users TO STDOUT"); err == nil {
+		t.Error("want the driver rejected")
+	}
+}
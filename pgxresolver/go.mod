@@ -0,0 +1,19 @@
+module github.com/alfari16/go-pgrouter/pgxresolver
+
+go 1.25.5
+
+require (
+	github.com/alfari16/go-pgrouter v0.0.0-20261014144327-926d0eb040bc
+	github.com/jackc/pgx/v5 v5.7.6
+	go.uber.org/multierr v1.11.0
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+)
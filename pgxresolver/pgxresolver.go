@@ -0,0 +1,214 @@
+// Package pgxresolver routes the queries of pgx v5 native pools, such as *pgxpool.Pool, between a primary and
+// its replicas with the LSN-based causal consistency of dbresolver, for applications not using database/sql.
+// *DB implements dbresolver.LSNTracker and dbresolver.CausalConsistencyCapability, so the HTTP middleware of
+// dbresolver works with it unchanged:
+//
+//	db := pgxresolver.New(primaryPool, []pgxresolver.Pool{replicaPool},
+//		pgxresolver.WithCausalConsistencyConfig(&dbresolver.CausalConsistencyConfig{
+//			Enabled: true, Level: dbresolver.ReadYourWrites, FallbackToMaster: true,
+//		}))
+//	middleware := dbresolver.NewHTTPMiddleware(db, "pg_min_lsn", 5*time.Minute, true)
+//
+// It lives in its own module so the core package doesn't depend on pgx.
+package pgxresolver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/multierr"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// defaultLSNQueryTimeout bounds the LSN queries, see WithLSNQueryTimeout
+const defaultLSNQueryTimeout = 3 * time.Second
+
+// ErrNoReplicaCaughtUp is returned for the reads no replica can serve when FallbackToMaster is disabled
+var ErrNoReplicaCaughtUp = errors.New("pgxresolver: no replica has caught up to the required LSN")
+
+// Pool is the subset of *pgxpool.Pool used by the resolver
+type Pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+var _ Pool = (*pgxpool.Pool)(nil)
+
+// DB routes the queries between a primary pool and its replica pools
+type DB struct {
+	primary  Pool
+	replicas []Pool
+	next     atomic.Uint64 // round robin position among the replicas
+
+	queryTypeChecker dbresolver.QueryTypeChecker
+	config           *dbresolver.CausalConsistencyConfig
+	lsnQueryTimeout  time.Duration
+	logger           *slog.Logger
+	copyToReplicas   bool // see WithCopyToReplicas
+}
+
+// Option configures the DB
+type Option func(db *DB)
+
+// WithCausalConsistencyConfig enables the LSN-based routing of the reads, see dbresolver.CausalConsistencyConfig.
+// ReplicaWaitPoll, MaxReplicaWait and PinAfterFallback aren't supported. The configuration is copied.
+func WithCausalConsistencyConfig(config *dbresolver.CausalConsistencyConfig) Option {
+	return func(db *DB) {
+		if config == nil {
+			db.config = nil
+			return
+		}
+		cfg := *config
+		db.config = &cfg
+	}
+}
+
+// WithQueryTypeChecker sets the checker classifying the queries of Query and QueryRow,
+// defaults to dbresolver.NewDefaultQueryTypeChecker
+func WithQueryTypeChecker(checker dbresolver.QueryTypeChecker) Option {
+	return func(db *DB) {
+		db.queryTypeChecker = checker
+	}
+}
+
+// WithLSNQueryTimeout bounds the LSN queries (defaults to 3s)
+func WithLSNQueryTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.lsnQueryTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger used instead of slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(db *DB) {
+		db.logger = logger
+	}
+}
+
+// New creates a resolver writing to primary and reading from replicas, in round robin.
+// The pools are closed by Close.
+func New(primary Pool, replicas []Pool, opts ...Option) *DB {
+	if primary == nil {
+		panic("pgxresolver: required primary pool")
+	}
+	db := &DB{
+		primary:          primary,
+		replicas:         replicas,
+		queryTypeChecker: dbresolver.NewDefaultQueryTypeChecker(),
+		lsnQueryTimeout:  defaultLSNQueryTimeout,
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+// Primary returns the primary pool
+func (db *DB) Primary() Pool {
+	return db.primary
+}
+
+// Replicas returns the replica pools
+func (db *DB) Replicas() []Pool {
+	return db.replicas
+}
+
+// IsCausalConsistencyEnabled reports whether the reads are routed by LSN
+func (db *DB) IsCausalConsistencyEnabled() bool {
+	return db.config != nil && db.config.Enabled
+}
+
+func (db *DB) log() *slog.Logger {
+	if db.logger != nil {
+		return db.logger
+	}
+	return slog.Default()
+}
+
+// Exec executes a write on the primary
+func (db *DB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	pool, err := db.RouteQuery(ctx, dbresolver.QueryTypeWrite)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pool.Exec(ctx, sql, args...)
+}
+
+// Query runs a query on the pool its type is routed to, see WithQueryTypeChecker
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	pool, err := db.RouteQuery(ctx, db.queryTypeChecker.Check(sql))
+	if err != nil {
+		return nil, err
+	}
+	return pool.Query(ctx, sql, args...)
+}
+
+// QueryRow runs a query returning at most one row on the pool its type is routed to, see WithQueryTypeChecker
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	pool, err := db.RouteQuery(ctx, db.queryTypeChecker.Check(sql))
+	if err != nil {
+		return errRow{err: err}
+	}
+	return pool.QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a read/write transaction on the primary
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return db.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction on the primary, or on a replica when it is read-only
+func (db *DB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	queryType := dbresolver.QueryTypeWrite
+	if txOptions.AccessMode == pgx.ReadOnly {
+		queryType = dbresolver.QueryTypeRead
+	}
+	pool, err := db.RouteQuery(ctx, queryType)
+	if err != nil {
+		return nil, err
+	}
+	return pool.BeginTx(ctx, txOptions)
+}
+
+// Ping pings every pool concurrently
+func (db *DB) Ping(ctx context.Context) error {
+	pools := append([]Pool{db.primary}, db.replicas...)
+	errs := make([]error, len(pools))
+	done := make(chan struct{})
+	for i, pool := range pools {
+		go func() {
+			errs[i] = pool.Ping(ctx)
+			done <- struct{}{}
+		}()
+	}
+	for range pools {
+		<-done
+	}
+	return multierr.Combine(errs...)
+}
+
+// Close closes every pool
+func (db *DB) Close() {
+	db.primary.Close()
+	for _, replica := range db.replicas {
+		replica.Close()
+	}
+}
+
+// errRow is the pgx.Row of a query that couldn't be routed
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error { return r.err }
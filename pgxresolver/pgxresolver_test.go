@@ -0,0 +1,164 @@
+package pgxresolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// fakePool reports a fixed LSN and records the statements it runs
+type fakePool struct {
+	lsn     string
+	lsnErr  error
+	pingErr error
+	queries []string
+	closed  bool
+}
+
+func (p *fakePool) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	p.queries = append(p.queries, sql)
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (p *fakePool) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	p.queries = append(p.queries, sql)
+	return nil, nil
+}
+
+func (p *fakePool) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	if strings.Contains(sql, "_lsn()") {
+		return lsnRow{lsn: p.lsn, err: p.lsnErr}
+	}
+	p.queries = append(p.queries, sql)
+	return lsnRow{}
+}
+
+func (p *fakePool) BeginTx(_ context.Context, _ pgx.TxOptions) (pgx.Tx, error) {
+	p.queries = append(p.queries, "BEGIN")
+	return nil, nil
+}
+
+func (p *fakePool) Ping(_ context.Context) error { return p.pingErr }
+func (p *fakePool) Close()                       { p.closed = true }
+
+type lsnRow struct {
+	lsn string
+	err error
+}
+
+func (r lsnRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(dest) == 1 {
+		if s, ok := dest[0].(*string); ok {
+			*s = r.lsn
+		}
+	}
+	return nil
+}
+
+func newCausalDB(primary *fakePool, replicas ...*fakePool) *DB {
+	pools := make([]Pool, len(replicas))
+	for i, replica := range replicas {
+		pools[i] = replica
+	}
+	return New(primary, pools, WithCausalConsistencyConfig(&dbresolver.CausalConsistencyConfig{
+		Enabled: true, Level: dbresolver.ReadYourWrites, FallbackToMaster: true,
+	}))
+}
+
+func TestRoutesReadsAndWrites(t *testing.T) {
+	primary, replica := &fakePool{}, &fakePool{}
+	db := newCausalDB(primary, replica)
+	ctx := context.Background()
+
+	_, _ = db.Query(ctx, "SELECT * FROM users")
+	_ = db.QueryRow(ctx, "SELECT name FROM users WHERE id = $1", 1).Scan()
+	_, _ = db.Exec(ctx, "UPDATE users SET name = $1", "a")
+	_, _ = db.Query(ctx, "INSERT INTO users (name) VALUES ($1) RETURNING id", "b")
+	_, _ = db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	_, _ = db.Begin(ctx)
+
+	if len(replica.queries) != 3 || replica.queries[2] != "BEGIN" {
+		t.Errorf("expected the reads and the read-only transaction on the replica, got %q", replica.queries)
+	}
+	if len(primary.queries) != 3 {
+		t.Errorf("expected the writes and the transaction on the primary, got %q", primary.queries)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	primary := &fakePool{lsn: "0/5000"}
+	lagging, caughtUp := &fakePool{lsn: "0/4000"}, &fakePool{lsn: "0/5000"}
+	db := newCausalDB(primary, lagging, caughtUp)
+
+	lsnCtx := &dbresolver.LSNContext{}
+	ctx := dbresolver.WithLSNContext(context.Background(), lsnCtx)
+	if _, err := db.Exec(ctx, "INSERT INTO users (name) VALUES ($1)", "a"); err != nil {
+		t.Fatal(err)
+	}
+	lsn, err := db.UpdateLSNAfterWrite(ctx)
+	if err != nil || lsn != (dbresolver.LSN{Lower: 0x5000}) || lsnCtx.RequiredLSN != lsn {
+		t.Fatalf("expected the LSN 0/5000 captured, got %s (%v)", lsn, err)
+	}
+
+	// a following request carrying the LSN
+	ctx = dbresolver.WithLSNContext(context.Background(), &dbresolver.LSNContext{RequiredLSN: lsn})
+	for range 2 {
+		if pool, _ := db.RouteQuery(ctx, dbresolver.QueryTypeRead); pool != caughtUp {
+			t.Fatal("expected the caught up replica")
+		}
+	}
+
+	caughtUp.lsnErr = errors.New("connection reset")
+	if pool, _ := db.RouteQuery(ctx, dbresolver.QueryTypeRead); pool != primary {
+		t.Error("expected a fallback to the primary when no replica caught up")
+	}
+
+	db.config.FallbackToMaster = false
+	if _, err := db.RouteQuery(ctx, dbresolver.QueryTypeRead); !errors.Is(err, ErrNoReplicaCaughtUp) {
+		t.Errorf("expected ErrNoReplicaCaughtUp, got %v", err)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	primary, replica := &fakePool{lsn: "1/ABCDEF"}, &fakePool{}
+	db := newCausalDB(primary, replica)
+	middleware := dbresolver.NewHTTPMiddleware(db, "pg_min_lsn", time.Minute, false)
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := db.Exec(r.Context(), "INSERT INTO users (name) VALUES ($1)", "a"); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/users", http.NoBody))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != "1/ABCDEF" {
+		t.Fatalf("expected the LSN cookie of the write, got %+v", cookies)
+	}
+}
+
+func TestPingAndClose(t *testing.T) {
+	primary, replica := &fakePool{}, &fakePool{pingErr: errors.New("down")}
+	db := New(primary, []Pool{replica})
+	if err := db.Ping(context.Background()); err == nil {
+		t.Error("expected the replica ping error")
+	}
+	db.Close()
+	if !primary.closed || !replica.closed {
+		t.Error("expected every pool closed")
+	}
+}
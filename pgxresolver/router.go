@@ -0,0 +1,122 @@
+package pgxresolver
+
+import (
+	"context"
+	"fmt"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// RouteQuery selects the pool of a query of queryType, following the rules of dbresolver.CausalRouter:
+// writes go to the primary and pin the following reads of their LSN context to it, reads requiring an LSN
+// go to the first replica that replayed it, or to the primary with FallbackToMaster.
+func (db *DB) RouteQuery(ctx context.Context, queryType dbresolver.QueryType) (Pool, error) {
+	lsnCtx := dbresolver.GetLSNContext(ctx)
+	if queryType == dbresolver.QueryTypeWrite {
+		if lsnCtx != nil {
+			lsnCtx.ForceMaster = true
+			lsnCtx.HasWriteOperation = true
+		}
+		return db.primary, nil
+	}
+	if len(db.replicas) == 0 || (lsnCtx != nil && lsnCtx.ForceMaster) {
+		return db.primary, nil
+	}
+	if !db.IsCausalConsistencyEnabled() {
+		return db.replica(), nil
+	}
+
+	switch db.config.Level {
+	case dbresolver.StrongConsistency:
+		return db.primary, nil
+	case dbresolver.ReadYourWrites:
+		if lsnCtx == nil || lsnCtx.RequiredLSN.IsZero() {
+			return db.replica(), nil
+		}
+		if replica := db.caughtUpReplica(ctx, lsnCtx.RequiredLSN); replica != nil {
+			return replica, nil
+		}
+		if !db.config.FallbackToMaster {
+			return nil, ErrNoReplicaCaughtUp
+		}
+		db.log().Debug("pgxresolver: replicas lagging, falling back to primary", "requiredLSN", lsnCtx.RequiredLSN)
+		return db.primary, nil
+	default:
+		return db.replica(), nil
+	}
+}
+
+// replica selects a replica in round robin
+func (db *DB) replica() Pool {
+	return db.replicas[(db.next.Add(1)-1)%uint64(len(db.replicas))]
+}
+
+// caughtUpReplica returns a replica that replayed requiredLSN, starting with the round robin selected one,
+// nil if every replica lags behind or fails
+func (db *DB) caughtUpReplica(ctx context.Context, requiredLSN dbresolver.LSN) Pool {
+	start := db.next.Add(1) - 1
+	for i := range uint64(len(db.replicas)) {
+		candidate := db.replicas[(start+i)%uint64(len(db.replicas))]
+		lsn, err := db.lastReplayLSN(ctx, candidate)
+		if err != nil {
+			db.log().Debug("pgxresolver: failed to get replica LSN", "error", err)
+			continue
+		}
+		if !lsn.LessThan(requiredLSN) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// UpdateLSNAfterWrite raises the required LSN of the LSN context of ctx to the current WAL LSN of the
+// primary when the context performed a write, and returns it; zero otherwise
+func (db *DB) UpdateLSNAfterWrite(ctx context.Context) (dbresolver.LSN, error) {
+	lsnCtx := dbresolver.GetLSNContext(ctx)
+	if !db.IsCausalConsistencyEnabled() || lsnCtx == nil || !lsnCtx.HasWriteOperation {
+		return dbresolver.LSN{}, nil
+	}
+	lsn, err := db.currentWALLSN(ctx)
+	if err != nil {
+		return dbresolver.LSN{}, fmt.Errorf("failed to get master LSN after write: %w", err)
+	}
+	if lsn.GreaterThan(lsnCtx.RequiredLSN) {
+		lsnCtx.RequiredLSN = lsn
+	}
+	return lsn, nil
+}
+
+func (db *DB) currentWALLSN(ctx context.Context) (dbresolver.LSN, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.lsnQueryTimeout)
+	defer cancel()
+	return CurrentWALLSN(ctx, db.primary)
+}
+
+func (db *DB) lastReplayLSN(ctx context.Context, replica Pool) (dbresolver.LSN, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.lsnQueryTimeout)
+	defer cancel()
+	return LastReplayLSN(ctx, replica)
+}
+
+// CurrentWALLSN queries the current WAL LSN of a primary pool
+func CurrentWALLSN(ctx context.Context, pool Pool) (dbresolver.LSN, error) {
+	return queryLSN(ctx, pool, dbresolver.PGCurrentWALLSN)
+}
+
+// LastReplayLSN queries the last replay LSN of a replica pool
+func LastReplayLSN(ctx context.Context, pool Pool) (dbresolver.LSN, error) {
+	return queryLSN(ctx, pool, dbresolver.PGLastWalReplayLSN)
+}
+
+// queryLSN selects the LSN returned by function
+func queryLSN(ctx context.Context, pool Pool, function string) (dbresolver.LSN, error) {
+	var lsnStr string
+	if err := pool.QueryRow(ctx, "SELECT "+function+"::text").Scan(&lsnStr); err != nil {
+		return dbresolver.LSN{}, fmt.Errorf("failed to query %s: %w", function, err)
+	}
+	lsn, err := dbresolver.ParseLSN(lsnStr)
+	if err != nil {
+		return dbresolver.LSN{}, fmt.Errorf("failed to parse LSN: %w", err)
+	}
+	return lsn, nil
+}
@@ -0,0 +1,55 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// ReasonPinnedAfterFallback is the route reason of the reads kept on the primary after a fallback of their request
+const ReasonPinnedAfterFallback = "pinned_after_fallback"
+
+// ReadPinPolicy controls whether the reads of a request stay on the primary once one of them fell back to it
+// because the replicas lagged behind the required LSN, so the request doesn't mix fresher and staler data
+type ReadPinPolicy int
+
+const (
+	// ReadPinDefault follows CausalConsistencyConfig.PinAfterFallback
+	ReadPinDefault ReadPinPolicy = iota
+	// ReadPinAfterFallback keeps the reads following the first fallback on the primary that served it
+	ReadPinAfterFallback
+	// ReadPinNever routes every read on its own
+	ReadPinNever
+)
+
+// pinsAfterFallback reports whether the reads of lsnCtx stay on the primary after a fallback
+func (r *CausalRouter) pinsAfterFallback(lsnCtx *LSNContext) bool {
+	switch lsnCtx.ReadPin {
+	case ReadPinAfterFallback:
+		return true
+	case ReadPinNever:
+		return false
+	default:
+		return r.config.PinAfterFallback
+	}
+}
+
+// pinnedPrimary returns the primary the reads of lsnCtx are pinned to, nil if they aren't or it was replaced
+func (r *CausalRouter) pinnedPrimary(lsnCtx *LSNContext, primaries []*sql.DB) *sql.DB {
+	if lsnCtx == nil || lsnCtx.pinnedPrimary == nil || !r.pinsAfterFallback(lsnCtx) {
+		return nil
+	}
+	if !slices.Contains(primaries, lsnCtx.pinnedPrimary) {
+		return nil
+	}
+	return lsnCtx.pinnedPrimary
+}
+
+// fallBackToPrimary selects the primary serving a read whose replicas lag behind, pinning the following
+// reads of the request to it when the policy asks for it
+func (r *CausalRouter) fallBackToPrimary(lsnCtx *LSNContext, primaries []*sql.DB) *sql.DB {
+	primary := r.dbProvider.LoadBalancer().Resolve(primaries)
+	if r.pinsAfterFallback(lsnCtx) {
+		lsnCtx.pinnedPrimary = primary
+	}
+	return primary
+}
@@ -0,0 +1,77 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestCausalRouterPinsReadsAfterFallback(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	router := NewCausalRouter(&staticProvider{primaries: []*sql.DB{primary}, replicas: []*sql.DB{replica}},
+		&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true, PinAfterFallback: true})
+
+	var reasons []string
+	router.hooks = &routingHooks{onRoute: func(d RouteDecision) { reasons = append(reasons, d.Reason) }}
+
+	expectReplayLSN(replicaMock, "0/100")
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	for range 2 {
+		if selected, err := router.RouteQuery(ctx, QueryTypeRead); err != nil || selected != primary {
+			t.Fatalf("expected the primary, got %v (%v)", selected, err)
+		}
+	}
+	if len(reasons) != 2 || reasons[0] != ReasonReplicaLagging || reasons[1] != ReasonPinnedAfterFallback {
+		t.Errorf("unexpected route reasons: %v", reasons)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// another request isn't pinned
+	expectReplayLSN(replicaMock, "0/2000")
+	ctx = WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+	if selected, _ := router.RouteQuery(ctx, QueryTypeRead); selected != replica {
+		t.Error("expected the caught up replica for a new request")
+	}
+}
+
+func TestCausalRouterReadPinPolicyOverride(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	provider := &staticProvider{primaries: []*sql.DB{primary}, replicas: []*sql.DB{replica}}
+
+	tests := []struct {
+		name       string
+		pinConfig  bool
+		readPin    ReadPinPolicy
+		wantPinned bool
+	}{
+		{name: "default without config", wantPinned: false},
+		{name: "request opts in", readPin: ReadPinAfterFallback, wantPinned: true},
+		{name: "request opts out", pinConfig: true, readPin: ReadPinNever, wantPinned: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewCausalRouter(provider, &CausalConsistencyConfig{
+				Enabled: true, Level: ReadYourWrites, FallbackToMaster: true, PinAfterFallback: tt.pinConfig,
+			})
+			lsnCtx := &LSNContext{RequiredLSN: LSN{Lower: 0x1000}, ReadPin: tt.readPin}
+			ctx := WithLSNContext(context.Background(), lsnCtx)
+
+			expectReplayLSN(replicaMock, "0/100")
+			_, _ = router.RouteQuery(ctx, QueryTypeRead)
+			if !tt.wantPinned {
+				expectReplayLSN(replicaMock, "0/2000")
+			}
+			selected, _ := router.RouteQuery(ctx, QueryTypeRead)
+			if pinned := selected == primary; pinned != tt.wantPinned {
+				t.Errorf("expected pinned %v, got %v", tt.wantPinned, pinned)
+			}
+			if err := replicaMock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
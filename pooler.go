@@ -0,0 +1,69 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PoolerStats is the saturation of the pool of a pooler or proxy fronting a replica, such as pgbouncer or
+// HAProxy, which the sql.DBStats of the resolver can't see
+type PoolerStats struct {
+	ClientsActive  int           // Clients served by a server connection
+	ClientsWaiting int           // Clients queued for a server connection
+	ServersActive  int           // Server connections in use
+	ServersIdle    int           // Server connections available
+	Wait           time.Duration // Time the clients spend queued, e.g. pgbouncer maxwait or HAProxy qtime
+	CollectedAt    time.Time
+}
+
+// Saturated reports whether clients are queued for a server connection
+func (s *PoolerStats) Saturated() bool {
+	return s.ClientsWaiting > 0
+}
+
+// PoolerStatsCollector queries the admin interface of the poolers or proxies fronting the replicas, see the
+// poolerstats package for pgbouncer and HAProxy
+type PoolerStatsCollector interface {
+	// CollectPoolerStats returns the stats of the pools, keyed by the name of the replica they front
+	// (see WithNamedReplicaDBs)
+	CollectPoolerStats(ctx context.Context) (map[string]PoolerStats, error)
+}
+
+// WithPoolerStats makes the health monitor also collect the stats of the pools fronting the replicas with
+// collector on every check, reported in ReplicaStatus.Pooler, so pooler-side queueing shows in the health
+// snapshot. Replicas missing from the collected stats keep their previous ones. Requires WithHealthCheck.
+func WithPoolerStats(collector PoolerStatsCollector) OptionFunc {
+	return func(opt *Option) {
+		opt.PoolerStats = collector
+	}
+}
+
+// collectPoolerStats collects the pool stats when enabled, bounded by the health check timeout, nil when
+// disabled or failed
+func (db *DB) collectPoolerStats() map[string]PoolerStats {
+	if db.health.pooler == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), db.health.timeout)
+	defer cancel()
+
+	pools, err := db.health.pooler.CollectPoolerStats(ctx)
+	if err != nil {
+		db.log().Debug("health check: pooler stats unavailable", "error", err)
+		return nil
+	}
+	return pools
+}
+
+// setReplicaPooler records the pool stats of a replica
+func (h *healthMonitor) setReplicaPooler(replica *sql.DB, stats PoolerStats) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r, ok := h.replicas[replica]; ok {
+		if stats.CollectedAt.IsZero() {
+			stats.CollectedAt = time.Now()
+		}
+		r.status.Pooler = &stats
+	}
+}
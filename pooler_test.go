@@ -0,0 +1,47 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePoolerCollector returns fixed pool stats, or err
+type fakePoolerCollector struct {
+	pools map[string]PoolerStats
+	err   error
+}
+
+func (c *fakePoolerCollector) CollectPoolerStats(context.Context) (map[string]PoolerStats, error) {
+	return c.pools, c.err
+}
+
+func TestHealthCheckPoolerStats(t *testing.T) {
+	collector := &fakePoolerCollector{pools: map[string]PoolerStats{
+		"replica-0": {ClientsActive: 20, ClientsWaiting: 5, ServersActive: 20, Wait: 150 * time.Millisecond},
+		"unknown":   {ClientsWaiting: 1},
+	}}
+	db, primaryMock, replicaMock, _ := newHealthDB(t, WithPoolerStats(collector))
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+
+	pooler := db.GetReplicaStatus()[0].Pooler
+	if pooler == nil || !pooler.Saturated() || pooler.ClientsWaiting != 5 || pooler.Wait != 150*time.Millisecond ||
+		pooler.CollectedAt.IsZero() {
+		t.Fatalf("want the pool stats of the replica, got %+v", pooler)
+	}
+
+	// stats failing to be collected keep the previous ones
+	collector.err = errors.New("connection refused")
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000000")
+
+	db.checkHealth()
+
+	if got := db.GetReplicaStatus()[0].Pooler; got == nil || got.ClientsWaiting != 5 {
+		t.Errorf("want the previous pool stats, got %+v", got)
+	}
+}
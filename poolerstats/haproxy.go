@@ -0,0 +1,100 @@
+package poolerstats
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// HAProxy reads the servers from the "show stat" command of the runtime API of HAProxy. Each session of a TCP
+// proxy holds a server connection, ClientsActive and ServersActive are both the current sessions.
+type HAProxy struct {
+	Network string // "unix" or "tcp", defaults to "unix"
+	Address string // Address of the stats socket, e.g. "/var/run/haproxy.sock" or "127.0.0.1:9999"
+	Backend string // Backend of the replicas, empty for every backend
+	// Replicas maps the servers to the names of the replicas they front, servers missing from it are reported
+	// under their own name
+	Replicas map[string]string
+}
+
+// CollectPoolerStats returns the stats of every server
+func (h *HAProxy) CollectPoolerStats(ctx context.Context) (map[string]dbresolver.PoolerStats, error) {
+	network := h.Network
+	if network == "" {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, h.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the HAProxy runtime API: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(conn, "show stat\n"); err != nil {
+		return nil, fmt.Errorf("failed to show HAProxy stats: %w", err)
+	}
+	return h.parseStats(conn)
+}
+
+// parseStats parses the CSV of "show stat", whose header line starts with "# "
+func (h *HAProxy) parseStats(r io.Reader) (map[string]dbresolver.PoolerStats, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAProxy stats: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "# ")
+	}
+	index := make(map[string]int, len(header))
+	for i, field := range header {
+		index[field] = i
+	}
+	field := func(record []string, name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	now := time.Now()
+	pools := make(map[string]dbresolver.PoolerStats)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return pools, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HAProxy stats: %w", err)
+		}
+		server := field(record, "svname")
+		if server == "" || server == "FRONTEND" || server == "BACKEND" {
+			continue
+		}
+		if h.Backend != "" && field(record, "pxname") != h.Backend {
+			continue
+		}
+		if replica, ok := h.Replicas[server]; ok {
+			server = replica
+		}
+
+		sessions, limit := atoi(field(record, "scur")), atoi(field(record, "slim"))
+		pools[server] = dbresolver.PoolerStats{
+			ClientsActive:  sessions,
+			ClientsWaiting: atoi(field(record, "qcur")),
+			ServersActive:  sessions,
+			ServersIdle:    max(limit-sessions, 0),
+			Wait:           time.Duration(atoi(field(record, "qtime"))) * time.Millisecond,
+			CollectedAt:    now,
+		}
+	}
+}
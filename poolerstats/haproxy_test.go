@@ -0,0 +1,57 @@
+package poolerstats
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+const haproxyStats = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,status,type,qtime
+pg_replicas,FRONTEND,,,12,40,2000,900,OPEN,0,
+pg_replicas,replica1,4,9,10,10,10,500,UP,2,35
+pg_replicas,replica2,0,0,2,8,10,400,UP,2,0
+pg_replicas,BACKEND,4,9,12,18,200,900,UP,1,20
+pg_primary,primary1,0,0,3,5,10,100,UP,2,0
+
+`
+
+func TestHAProxyCollectPoolerStats(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if command, _ := bufio.NewReader(conn).ReadString('\n'); command == "show stat\n" {
+			_, _ = io.WriteString(conn, haproxyStats)
+		}
+	}()
+
+	collector := &HAProxy{Network: "tcp", Address: listener.Addr().String(), Backend: "pg_replicas",
+		Replicas: map[string]string{"replica1": "replica-a"}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pools, err := collector.CollectPoolerStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 {
+		t.Fatalf("want the servers of the backend, got %v", pools)
+	}
+	got := pools["replica-a"]
+	if got.ClientsActive != 10 || got.ClientsWaiting != 4 || got.ServersIdle != 0 || got.Wait != 35*time.Millisecond ||
+		!got.Saturated() {
+		t.Errorf("want the saturated server, got %+v", got)
+	}
+	if got := pools["replica2"]; got.ClientsActive != 2 || got.ServersIdle != 8 || got.Saturated() {
+		t.Errorf("want the unmapped server under its own name, got %+v", got)
+	}
+}
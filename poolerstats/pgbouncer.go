@@ -0,0 +1,87 @@
+// Package poolerstats collects the pool saturation of the poolers and proxies fronting the replicas of a
+// dbresolver.DB, reported in dbresolver.ReplicaStatus.Pooler with dbresolver.WithPoolerStats:
+//
+//	db := dbresolver.New(
+//		dbresolver.WithPrimaryDBs(primary),
+//		dbresolver.WithNamedReplicaDBs(map[string]*sql.DB{"replica-a": replicaA}),
+//		dbresolver.WithHealthCheck(5*time.Second, time.Second),
+//		dbresolver.WithPoolerStats(&poolerstats.PgBouncer{Admin: admin, Replicas: map[string]string{"app": "replica-a"}}),
+//	)
+//
+// Only the standard library is used.
+package poolerstats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+// PgBouncer reads the pools from the SHOW POOLS command of the admin console of pgbouncer. The pools of the
+// users of a database are summed up, keeping the longest wait.
+type PgBouncer struct {
+	// Admin is connected to the admin console, the "pgbouncer" database, with the simple query protocol
+	// (e.g. default_query_exec_mode=simple_protocol with pgx) by a user of stats_users or admin_users
+	Admin *sql.DB
+	// Replicas maps the databases of pgbouncer to the names of the replicas they front, databases missing from
+	// it are reported under their own name
+	Replicas map[string]string
+}
+
+// CollectPoolerStats returns the stats of the pools of every database
+func (p *PgBouncer) CollectPoolerStats(ctx context.Context) (map[string]dbresolver.PoolerStats, error) {
+	rows, err := p.Admin.QueryContext(ctx, "SHOW POOLS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to show pgbouncer pools: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	pools := make(map[string]dbresolver.PoolerStats)
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan pgbouncer pool: %w", err)
+		}
+		pool := make(map[string]string, len(columns))
+		for i, column := range columns {
+			pool[column] = values[i].String
+		}
+		name := pool["database"]
+		if replica, ok := p.Replicas[name]; ok {
+			name = replica
+		}
+
+		stats := pools[name]
+		stats.ClientsActive += atoi(pool["cl_active"])
+		stats.ClientsWaiting += atoi(pool["cl_waiting"])
+		stats.ServersActive += atoi(pool["sv_active"])
+		stats.ServersIdle += atoi(pool["sv_idle"])
+		wait := time.Duration(atoi(pool["maxwait"]))*time.Second + time.Duration(atoi(pool["maxwait_us"]))*time.Microsecond
+		stats.Wait = max(stats.Wait, wait)
+		stats.CollectedAt = now
+		pools[name] = stats
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to show pgbouncer pools: %w", err)
+	}
+	return pools, nil
+}
+
+// atoi parses a counter, 0 when missing or invalid
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
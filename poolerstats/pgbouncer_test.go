@@ -0,0 +1,41 @@
+package poolerstats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPgBouncerCollectPoolerStats(t *testing.T) {
+	admin, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	mock.ExpectQuery("SHOW POOLS").WillReturnRows(sqlmock.NewRows([]string{
+		"database", "user", "cl_active", "cl_waiting", "sv_active", "sv_idle", "maxwait", "maxwait_us", "pool_mode",
+	}).
+		AddRow("app", "web", "20", "3", "20", "0", "1", "250000", "transaction").
+		AddRow("app", "worker", "5", "1", "5", "2", "0", "40000", "transaction").
+		AddRow("pgbouncer", "pgbouncer", "1", "0", "0", "0", "0", "0", "statement"))
+
+	collector := &PgBouncer{Admin: admin, Replicas: map[string]string{"app": "replica-a"}}
+	pools, err := collector.CollectPoolerStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := pools["replica-a"]
+	if got.ClientsActive != 25 || got.ClientsWaiting != 4 || got.ServersActive != 25 || got.ServersIdle != 2 ||
+		got.Wait != 1250*time.Millisecond {
+		t.Errorf("want the pools of the users summed up with the longest wait, got %+v", got)
+	}
+	if _, ok := pools["pgbouncer"]; !ok || len(pools) != 2 {
+		t.Errorf("want the unmapped databases under their own name, got %v", pools)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package promcollector exposes the routing metrics of a dbresolver.DB as Prometheus metrics.
+//
+// It lives in its own module so the core package doesn't depend on the Prometheus client.
+package promcollector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+const namespace = "dbresolver"
+
+// Collector is a prometheus.Collector reading dbresolver.DB.Metrics on every scrape
+type Collector struct {
+	db *dbresolver.DB
+
+	reads           *prometheus.Desc
+	lsnFallbacks    *prometheus.Desc
+	lsnCheckLatency *prometheus.Desc
+	replicaCatchUp  *prometheus.Desc
+	replicaLag      *prometheus.Desc
+
+	openConnections *prometheus.Desc
+	inUse           *prometheus.Desc
+	idle            *prometheus.Desc
+	waitCount       *prometheus.Desc
+	waitDuration    *prometheus.Desc
+}
+
+// NewPrometheusCollector creates a collector for the routing metrics of db.
+// Register it with prometheus.MustRegister.
+func NewPrometheusCollector(db *dbresolver.DB) *Collector {
+	dbLabels := []string{"role", "class", "index", "name"}
+	return &Collector{
+		db: db,
+		reads: prometheus.NewDesc(namespace+"_reads_total",
+			"Reads routed to a replica or the primary.", []string{"target"}, nil),
+		lsnFallbacks: prometheus.NewDesc(namespace+"_lsn_fallbacks_total",
+			"Reads sent to the primary because no replica caught up to the required LSN.", nil, nil),
+		lsnCheckLatency: prometheus.NewDesc(namespace+"_lsn_check_duration_seconds",
+			"Latency of replica replay LSN checks.", nil, nil),
+		replicaCatchUp: prometheus.NewDesc(namespace+"_replica_catchup_duration_seconds",
+			"Time replicas took to replay the LSN captured after a write.", nil, nil),
+		replicaLag: prometheus.NewDesc(namespace+"_replica_lag_bytes",
+			"Replication lag derived from the last observed master and replay LSNs.", []string{"class", "index", "name"}, nil),
+		openConnections: prometheus.NewDesc(namespace+"_pool_open_connections",
+			"Established connections, in use and idle.", dbLabels, nil),
+		inUse: prometheus.NewDesc(namespace+"_pool_in_use_connections",
+			"Connections currently in use.", dbLabels, nil),
+		idle: prometheus.NewDesc(namespace+"_pool_idle_connections",
+			"Idle connections.", dbLabels, nil),
+		waitCount: prometheus.NewDesc(namespace+"_pool_wait_count_total",
+			"Connections waited for.", dbLabels, nil),
+		waitDuration: prometheus.NewDesc(namespace+"_pool_wait_duration_seconds_total",
+			"Time spent waiting for a connection.", dbLabels, nil),
+	}
+}
+
+// Describe sends the descriptors of all metrics
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		c.reads, c.lsnFallbacks, c.lsnCheckLatency, c.replicaCatchUp, c.replicaLag,
+		c.openConnections, c.inUse, c.idle, c.waitCount, c.waitDuration,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect sends the current value of all metrics
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	m := c.db.Metrics()
+
+	ch <- prometheus.MustNewConstMetric(c.reads, prometheus.CounterValue, float64(m.ReplicaReads), "replica")
+	ch <- prometheus.MustNewConstMetric(c.reads, prometheus.CounterValue, float64(m.PrimaryReads), "primary")
+	ch <- prometheus.MustNewConstMetric(c.lsnFallbacks, prometheus.CounterValue, float64(m.LSNFallbacks))
+	ch <- prometheus.MustNewConstHistogram(c.lsnCheckLatency,
+		m.LSNCheckLatency.Count, m.LSNCheckLatency.Sum, m.LSNCheckLatency.Buckets)
+	ch <- prometheus.MustNewConstHistogram(c.replicaCatchUp,
+		m.ReplicaCatchUp.Count, m.ReplicaCatchUp.Sum, m.ReplicaCatchUp.Buckets)
+
+	for _, replica := range m.Replicas {
+		if replica.HasLag {
+			ch <- prometheus.MustNewConstMetric(c.replicaLag, prometheus.GaugeValue,
+				float64(replica.LagBytes), string(replica.Class), strconv.Itoa(replica.Index), replica.Name)
+		}
+	}
+
+	c.collectPool(ch, "primary", m.Primaries)
+	c.collectPool(ch, "replica", m.Replicas)
+}
+
+// collectPool sends the connection pool statistics of the physical databases
+func (c *Collector) collectPool(ch chan<- prometheus.Metric, role string, dbs []dbresolver.PhysicalDBMetrics) {
+	for _, db := range dbs {
+		labels := []string{role, string(db.Class), strconv.Itoa(db.Index), db.Name}
+		ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue,
+			float64(db.Stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(db.Stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(db.Stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(db.Stats.WaitCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue,
+			db.Stats.WaitDuration.Seconds(), labels...)
+	}
+}
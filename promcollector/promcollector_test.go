@@ -0,0 +1,60 @@
+package promcollector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+)
+
+func TestCollector(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbresolver.New(dbresolver.WithPrimaryDBs(primary), dbresolver.WithReplicaDBs(replica))
+	defer db.Close()
+
+	replicaMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err := db.Query("SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	collector := NewPrometheusCollector(db)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	expected := `
+# HELP dbresolver_reads_total Reads routed to a replica or the primary.
+# TYPE dbresolver_reads_total counter
+dbresolver_reads_total{target="primary"} 0
+dbresolver_reads_total{target="replica"} 1
+# HELP dbresolver_lsn_fallbacks_total Reads sent to the primary because no replica caught up to the required LSN.
+# TYPE dbresolver_lsn_fallbacks_total counter
+dbresolver_lsn_fallbacks_total 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"dbresolver_reads_total", "dbresolver_lsn_fallbacks_total"); err != nil {
+		t.Error(err)
+	}
+
+	if n, err := testutil.GatherAndCount(registry, "dbresolver_pool_open_connections"); err != nil || n != 2 {
+		t.Errorf("expected pool stats for both databases, got %d (%v)", n, err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "dbresolver_lsn_check_duration_seconds"); err != nil || n != 1 {
+		t.Errorf("expected the LSN check histogram, got %d (%v)", n, err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "dbresolver_replica_catchup_duration_seconds"); err != nil || n != 1 {
+		t.Errorf("expected the replica catch-up histogram, got %d (%v)", n, err)
+	}
+}
@@ -18,11 +18,14 @@ type QueryTypeChecker interface {
 	Check(query string) QueryType
 }
 
-// DefaultQueryTypeChecker uses regex patterns to detect write queries by identifying SQL DML statements.
+// DefaultQueryTypeChecker uses regex patterns to detect write queries by identifying SQL DML statements,
+// and DDL and utility statements, which standbys reject or which must run on the primary.
 type DefaultQueryTypeChecker struct {
 	// writeRegex matches common SQL write operations at the beginning of the query
 	// or when they contain a RETURNING clause anywhere in the query
 	writeRegex *regexp.Regexp
+	// utilityRegex matches DDL and utility statements, and COPY FROM, at the beginning of the query
+	utilityRegex *regexp.Regexp
 }
 
 // NewDefaultQueryTypeChecker creates a new DefaultQueryTypeChecker with compiled regex
@@ -38,14 +41,20 @@ func NewDefaultQueryTypeChecker() *DefaultQueryTypeChecker {
 	// Uses case-insensitive matching and allows for optional whitespace
 	writePattern := `(?i)^\s*(INSERT|UPDATE|DELETE|MERGE|TRUNCATE|REPLACE)\b|\bRETURNING\b`
 
+	// DDL (CREATE INDEX, ALTER TABLE, ...), privileges, maintenance (VACUUM, ANALYZE, ...), COPY FROM and
+	// other statements failing on a standby, routed to the primary like writes
+	utilityPattern := `(?i)^\s*(CREATE|ALTER|DROP|COMMENT|GRANT|REVOKE|VACUUM|ANALYZE|REINDEX|CLUSTER|REFRESH|LOCK|CALL|DO)\b` +
+		`|^\s*COPY\s+[^(\s][^;]*\bFROM\s+(STDIN|PROGRAM|')`
+
 	return &DefaultQueryTypeChecker{
-		writeRegex: regexp.MustCompile(writePattern),
+		writeRegex:   regexp.MustCompile(writePattern),
+		utilityRegex: regexp.MustCompile(utilityPattern),
 	}
 }
 
 func (c *DefaultQueryTypeChecker) Check(query string) QueryType {
 	// Use the compiled regex to detect write operations
-	if c.writeRegex.MatchString(query) {
+	if c.writeRegex.MatchString(query) || c.utilityRegex.MatchString(query) {
 		return QueryTypeWrite
 	}
 	return QueryTypeUnknown
@@ -1,8 +1,11 @@
 package dbresolver
 
 import (
+	"context"
 	"strings"
 	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 //nolint:funlen // Test function covers many edge cases for query type detection
@@ -76,6 +79,53 @@ func TestDefaultQueryTypeChecker(t *testing.T) {
 			expected: QueryTypeWrite,
 		},
 
+		// DDL and utility statements - routed to the primary like writes
+		{
+			name:     "CREATE INDEX",
+			query:    "CREATE INDEX CONCURRENTLY users_email_idx ON users (email)",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "ALTER TABLE",
+			query:    "alter table users add column active boolean",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "DROP TABLE",
+			query:    "DROP TABLE IF EXISTS sessions",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "VACUUM",
+			query:    "VACUUM (ANALYZE) users",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "ANALYZE",
+			query:    "  ANALYZE users",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "REFRESH MATERIALIZED VIEW",
+			query:    "REFRESH MATERIALIZED VIEW CONCURRENTLY daily_totals",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "GRANT",
+			query:    "GRANT SELECT ON users TO reporting",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "CALL procedure",
+			query:    "CALL archive_orders($1)",
+			expected: QueryTypeWrite,
+		},
+		{
+			name:     "COPY FROM STDIN",
+			query:    `COPY "users" ("id", "name") FROM STDIN`,
+			expected: QueryTypeWrite,
+		},
+
 		// Read queries - should return QueryTypeUnknown (not write operations)
 		{
 			name:     "Simple SELECT",
@@ -112,6 +162,26 @@ func TestDefaultQueryTypeChecker(t *testing.T) {
 			query:    "EXPLAIN SELECT * FROM users",
 			expected: QueryTypeUnknown,
 		},
+		{
+			name:     "EXPLAIN ANALYZE statement",
+			query:    "EXPLAIN ANALYZE SELECT * FROM users",
+			expected: QueryTypeUnknown,
+		},
+		{
+			name:     "COPY query TO STDOUT",
+			query:    "COPY (SELECT * FROM users) TO STDOUT WITH (FORMAT csv)",
+			expected: QueryTypeUnknown,
+		},
+		{
+			name:     "COPY table TO STDOUT",
+			query:    "COPY users TO STDOUT",
+			expected: QueryTypeUnknown,
+		},
+		{
+			name:     "Column named like a DDL keyword",
+			query:    "SELECT created_at, drop_count FROM users",
+			expected: QueryTypeUnknown,
+		},
 		// Edge cases
 		{
 			name:     "Empty query",
@@ -150,6 +220,29 @@ func TestDefaultQueryTypeChecker(t *testing.T) {
 	}
 }
 
+func TestDDLRoutedToPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	ctx := context.Background()
+
+	primaryMock.ExpectExec("CREATE INDEX").WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := db.ExecContext(ctx, "CREATE INDEX users_email_idx ON users (email)"); err != nil {
+		t.Fatal(err)
+	}
+	primaryMock.ExpectQuery("ANALYZE").WillReturnRows(sqlmock.NewRows(nil))
+	rows, err := db.QueryContext(ctx, "ANALYZE VERBOSE users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 // Benchmark the regex-based implementation
 func BenchmarkDefaultQueryTypeChecker(b *testing.B) {
 	checker := NewDefaultQueryTypeChecker()
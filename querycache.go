@@ -0,0 +1,102 @@
+package dbresolver
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithQueryTypeCache remembers the type of the last size distinct queries classified by the QueryTypeChecker,
+// skipping the classification of the queries already seen, e.g. for services running a fixed set of queries at a
+// high rate. The least recently used query is evicted once size queries are cached.
+func WithQueryTypeCache(size int) OptionFunc {
+	return func(opt *Option) {
+		opt.QueryTypeCacheSize = size
+	}
+}
+
+// CachedQueryTypeChecker is a QueryTypeChecker caching the types returned by another checker in a bounded LRU,
+// see WithQueryTypeCache. Checkers whose result doesn't depend on the query text alone must not be cached.
+type CachedQueryTypeChecker struct {
+	checker QueryTypeChecker
+	size    int
+
+	mu      sync.Mutex
+	order   *list.List // of *cachedQueryType, most recently used first
+	queries map[string]*list.Element
+}
+
+type cachedQueryType struct {
+	query     string
+	queryType QueryType
+}
+
+// NewCachedQueryTypeChecker caches the types of up to size queries classified by checker
+func NewCachedQueryTypeChecker(checker QueryTypeChecker, size int) *CachedQueryTypeChecker {
+	return &CachedQueryTypeChecker{
+		checker: checker,
+		size:    max(size, 1),
+		order:   list.New(),
+		queries: make(map[string]*list.Element),
+	}
+}
+
+// Check returns the cached type of query, classifying it with the wrapped checker on a miss
+func (c *CachedQueryTypeChecker) Check(query string) QueryType {
+	c.mu.Lock()
+	if elem, ok := c.queries[query]; ok {
+		c.order.MoveToFront(elem)
+		queryType := elem.Value.(*cachedQueryType).queryType
+		c.mu.Unlock()
+		return queryType
+	}
+	c.mu.Unlock()
+
+	// classified outside the lock, a query racing with itself is classified twice and cached once
+	queryType := c.checker.Check(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.queries[query]; ok {
+		return queryType
+	}
+	c.queries[query] = c.order.PushFront(&cachedQueryType{query: query, queryType: queryType})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.queries, oldest.Value.(*cachedQueryType).query)
+	}
+	return queryType
+}
+
+// Len returns the number of cached queries
+func (c *CachedQueryTypeChecker) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// snapshot returns the cached query types, see DB.State
+func (c *CachedQueryTypeChecker) snapshot() map[string]QueryType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queryTypes := make(map[string]QueryType, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		cached := elem.Value.(*cachedQueryType)
+		queryTypes[cached.query] = cached.queryType
+	}
+	return queryTypes
+}
+
+// restore caches the query types of another process as the least recently used ones, within the cache size
+func (c *CachedQueryTypeChecker) restore(queryTypes map[string]QueryType) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for query, queryType := range queryTypes {
+		if c.order.Len() >= c.size {
+			return
+		}
+		if _, ok := c.queries[query]; !ok {
+			c.queries[query] = c.order.PushBack(&cachedQueryType{query: query, queryType: queryType})
+		}
+	}
+}
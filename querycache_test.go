@@ -0,0 +1,80 @@
+package dbresolver
+
+import (
+	"sync"
+	"testing"
+)
+
+type countingQueryTypeChecker struct {
+	mu     sync.Mutex
+	checks map[string]int
+}
+
+func (c *countingQueryTypeChecker) Check(query string) QueryType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checks == nil {
+		c.checks = make(map[string]int)
+	}
+	c.checks[query]++
+	return NewDefaultQueryTypeChecker().Check(query)
+}
+
+func TestCachedQueryTypeChecker(t *testing.T) {
+	counting := &countingQueryTypeChecker{}
+	checker := NewCachedQueryTypeChecker(counting, 2)
+
+	if got := checker.Check("INSERT INTO users VALUES (1)"); got != QueryTypeWrite {
+		t.Errorf("want the type of the wrapped checker, got %v", got)
+	}
+	checker.Check("INSERT INTO users VALUES (1)")
+	checker.Check("SELECT 1")
+	if counting.checks["INSERT INTO users VALUES (1)"] != 1 {
+		t.Errorf("want a cached query classified once, got %d", counting.checks["INSERT INTO users VALUES (1)"])
+	}
+
+	// SELECT 2 evicts the least recently used SELECT 1, INSERT was used last
+	checker.Check("INSERT INTO users VALUES (1)")
+	checker.Check("SELECT 2")
+	checker.Check("INSERT INTO users VALUES (1)")
+	checker.Check("SELECT 1")
+	if counting.checks["INSERT INTO users VALUES (1)"] != 1 || counting.checks["SELECT 1"] != 2 {
+		t.Errorf("want the least recently used query evicted, got %v", counting.checks)
+	}
+	if checker.Len() != 2 {
+		t.Errorf("want the cache bounded to 2 queries, got %d", checker.Len())
+	}
+}
+
+func TestWithQueryTypeCache(t *testing.T) {
+	primary, _ := newMockDB(t)
+	counting := &countingQueryTypeChecker{}
+	db := New(WithPrimaryDBs(primary), WithQueryTypeCache(10), WithQueryTypeChecker(counting))
+
+	cached, ok := db.queryTypeChecker.(*CachedQueryTypeChecker)
+	if !ok {
+		t.Fatalf("want the checker cached whatever the option order, got %T", db.queryTypeChecker)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				cached.Check("SELECT 1")
+			}
+		})
+	}
+	wg.Wait()
+	if counting.checks["SELECT 1"] > 8 {
+		t.Errorf("want concurrent checks of a query served by the cache, classified %d times", counting.checks["SELECT 1"])
+	}
+}
+
+func BenchmarkCachedQueryTypeChecker(b *testing.B) {
+	checker := NewCachedQueryTypeChecker(NewDefaultQueryTypeChecker(), 100)
+	query := "SELECT * FROM orders WHERE user_id IN (SELECT id FROM users) AND created_at > now() - interval '1 day'"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		checker.Check(query)
+	}
+}
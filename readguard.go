@@ -0,0 +1,110 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+)
+
+// ErrPrimaryReadLimitExceeded is returned for the reads that exceed the primary read limit of a
+// request when the guard has no hook
+var ErrPrimaryReadLimitExceeded = errors.New("dbresolver: request exceeded its primary read limit")
+
+// noPrimaryReadLimit marks an accumulator using the limit configured with WithPrimaryReadGuard
+const noPrimaryReadLimit = math.MinInt64
+
+// ReadAccumulator counts the reads of a single request by the kind of database serving them.
+// The HTTP middleware attaches one to every request; WithReadAccumulator attaches one elsewhere.
+type ReadAccumulator struct {
+	primaryReads atomic.Int64
+	replicaReads atomic.Int64
+	limit        atomic.Int64
+}
+
+// PrimaryReads returns the number of reads of the request served by a primary
+func (a *ReadAccumulator) PrimaryReads() int64 {
+	return a.primaryReads.Load()
+}
+
+// ReplicaReads returns the number of reads of the request served by a replica
+func (a *ReadAccumulator) ReplicaReads() int64 {
+	return a.replicaReads.Load()
+}
+
+// PrimaryReadHook is called for every primary read beyond the limit of a request.
+// Returning an error fails the read with it; returning nil only reports the violation.
+type PrimaryReadHook func(ctx context.Context, primaryReads int64) error
+
+// primaryReadGuard enforces the maximum number of primary reads per request
+type primaryReadGuard struct {
+	limit int64
+	hook  PrimaryReadHook
+}
+
+type readAccumulatorKey struct{}
+
+// WithReadAccumulator returns a context counting the reads made with it.
+// An accumulator already present in ctx is kept.
+func WithReadAccumulator(ctx context.Context) context.Context {
+	if GetReadAccumulator(ctx) != nil {
+		return ctx
+	}
+	acc := &ReadAccumulator{}
+	acc.limit.Store(noPrimaryReadLimit)
+	return context.WithValue(ctx, readAccumulatorKey{}, acc)
+}
+
+// GetReadAccumulator returns the read accumulator of the request, or nil if reads aren't counted
+func GetReadAccumulator(ctx context.Context) *ReadAccumulator {
+	acc, _ := ctx.Value(readAccumulatorKey{}).(*ReadAccumulator)
+	return acc
+}
+
+// WithPrimaryReadLimit overrides the primary read limit of WithPrimaryReadGuard for the request,
+// e.g. 0 on list endpoints that must be served by replicas; a negative limit lifts it.
+// The limit is only enforced when the guard is configured.
+func WithPrimaryReadLimit(ctx context.Context, limit int) context.Context {
+	ctx = WithReadAccumulator(ctx)
+	GetReadAccumulator(ctx).limit.Store(int64(limit))
+	return ctx
+}
+
+// WithPrimaryReadGuard limits the number of reads served by a primary per request, counted by the
+// request's ReadAccumulator. A negative limit only applies to requests setting their own limit
+// with WithPrimaryReadLimit. Reads beyond the limit call hook, or fail with
+// ErrPrimaryReadLimitExceeded if hook is nil.
+func WithPrimaryReadGuard(limit int, hook PrimaryReadHook) OptionFunc {
+	return func(opt *Option) {
+		opt.PrimaryReadGuard = &primaryReadGuard{limit: int64(limit), hook: hook}
+	}
+}
+
+// check counts a read in the accumulator of the request and enforces the primary read limit
+func (g *primaryReadGuard) check(ctx context.Context, primary bool) error {
+	acc := GetReadAccumulator(ctx)
+	if acc == nil {
+		return nil
+	}
+	if !primary {
+		acc.replicaReads.Add(1)
+		return nil
+	}
+
+	reads := acc.primaryReads.Add(1)
+	if g == nil {
+		return nil
+	}
+	limit := acc.limit.Load()
+	if limit == noPrimaryReadLimit {
+		limit = g.limit
+	}
+	if limit < 0 || reads <= limit {
+		return nil
+	}
+
+	if g.hook == nil {
+		return ErrPrimaryReadLimitExceeded
+	}
+	return g.hook(ctx, reads)
+}
@@ -0,0 +1,97 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPrimaryReadGuard(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites}),
+		WithPrimaryReadGuard(1, nil),
+	)
+
+	ctx := WithReadAccumulator(context.Background())
+	forced := WithLSNContext(ctx, &LSNContext{ForceMaster: true})
+
+	replicaMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	primaryMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	if _, err := db.QueryContext(ctx, "SELECT id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	// the first primary read is within the limit
+	if _, err := db.QueryContext(forced, "SELECT id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	// the second one is rejected before reaching the database
+	if _, err := db.QueryContext(forced, "SELECT id FROM orders"); !errors.Is(err, ErrPrimaryReadLimitExceeded) {
+		t.Fatalf("want ErrPrimaryReadLimitExceeded, got %v", err)
+	}
+	var id int
+	if err := db.QueryRowContext(forced, "SELECT id FROM orders").Scan(&id); !errors.Is(err, ErrPrimaryReadLimitExceeded) {
+		t.Fatalf("want ErrPrimaryReadLimitExceeded from QueryRow, got %v", err)
+	}
+
+	acc := GetReadAccumulator(ctx)
+	if acc.PrimaryReads() != 3 || acc.ReplicaReads() != 1 {
+		t.Errorf("want 3 primary and 1 replica reads, got %d and %d", acc.PrimaryReads(), acc.ReplicaReads())
+	}
+
+	// writes are never counted or limited
+	primaryMock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, err := db.ExecContext(forced, "UPDATE orders SET paid = true"); err != nil {
+		t.Fatal(err)
+	}
+
+	// contexts without an accumulator aren't guarded
+	primaryMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	if _, err := db.QueryContext(WithLSNContext(context.Background(), &LSNContext{ForceMaster: true}),
+		"SELECT id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrimaryReadGuardHookAndRequestLimit(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+
+	var reported []int64
+	db := New(WithPrimaryDBs(primary), WithPrimaryReadGuard(-1, func(_ context.Context, primaryReads int64) error {
+		reported = append(reported, primaryReads)
+		return nil
+	}))
+
+	// without replicas every read goes to the primary; only the request with a limit is reported
+	primaryMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	primaryMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if _, err := db.QueryContext(WithReadAccumulator(context.Background()), "SELECT id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.QueryContext(WithPrimaryReadLimit(context.Background(), 0), "SELECT id FROM orders"); err != nil {
+		t.Fatalf("a hook returning nil must not fail the read, got %v", err)
+	}
+	if len(reported) != 1 || reported[0] != 1 {
+		t.Errorf("expected a single report of 1 primary read, got %v", reported)
+	}
+}
+
+func TestHTTPMiddlewareAttachesReadAccumulator(t *testing.T) {
+	middleware := NewHTTPMiddleware(&fixedLSNRouter{}, "", 0, false)
+
+	middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetReadAccumulator(r.Context()) == nil {
+			t.Error("expected a read accumulator on the request context")
+		}
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", http.NoBody))
+}
@@ -0,0 +1,95 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultReadModelPoll = 50 * time.Millisecond
+
+// CheckpointProvider reports the LSN up to which an external read model, such as a search index or an analytics
+// store fed by CDC, has ingested the changes of the primary
+type CheckpointProvider interface {
+	Checkpoint(ctx context.Context) (LSN, error)
+}
+
+// CheckpointProviderFunc adapts a function to CheckpointProvider
+type CheckpointProviderFunc func(ctx context.Context) (LSN, error)
+
+// Checkpoint calls f
+func (f CheckpointProviderFunc) Checkpoint(ctx context.Context) (LSN, error) {
+	return f(ctx)
+}
+
+// ReadModel is an external read model gated by WaitForReadModel
+type ReadModel struct {
+	Name        string // Reported by ReadModelBehind
+	Checkpoints CheckpointProvider
+	MaxWait     time.Duration // Maximum time to wait for the read model to catch up (0 checks once)
+	Poll        time.Duration // Interval between checkpoint checks while waiting (defaults to 50ms)
+}
+
+// ErrReadModelBehind is matched by the errors of WaitForReadModel when the read model hasn't ingested the LSN
+// required by the context
+var ErrReadModelBehind = errors.New("dbresolver: read model hasn't ingested the required LSN")
+
+// ReadModelBehind is returned by WaitForReadModel when the read model is still behind the required LSN
+type ReadModelBehind struct {
+	ReadModel   string
+	RequiredLSN LSN
+	Checkpoint  LSN // Last checkpoint reported by the read model
+}
+
+func (e *ReadModelBehind) Error() string {
+	return fmt.Sprintf("dbresolver: read model %s at checkpoint %s, behind the required LSN %s",
+		e.ReadModel, e.Checkpoint, e.RequiredLSN)
+}
+
+// Is makes ReadModelBehind match ErrReadModelBehind
+func (e *ReadModelBehind) Is(target error) bool {
+	return target == ErrReadModelBehind
+}
+
+// WaitForReadModel extends read-your-writes to an external read model: it waits up to model.MaxWait for the
+// checkpoint of the model to reach the LSN the reads of ctx must observe, the same one carried by the LSN cookie
+// and the causal tokens, captured first if ctx performed a write. It returns nil once the model caught up or when
+// ctx carries no LSN requirement, and a *ReadModelBehind otherwise, upon which the read can be served by Postgres.
+func (db *DB) WaitForReadModel(ctx context.Context, model ReadModel) error {
+	required, err := db.observedLSN(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to capture LSN for read model %s: %w", model.Name, err)
+	}
+	if required.IsZero() {
+		return nil
+	}
+	if model.Poll <= 0 {
+		model.Poll = defaultReadModelPoll
+	}
+
+	deadline := time.Now().Add(model.MaxWait)
+	for {
+		checkpoint, err := model.Checkpoints.Checkpoint(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get checkpoint of read model %s: %w", model.Name, err)
+		}
+		if !checkpoint.LessThan(required) {
+			return nil
+		}
+
+		wait := min(model.Poll, time.Until(deadline))
+		if wait <= 0 {
+			db.log().Debug("read model behind the required LSN", "read_model", model.Name,
+				"checkpoint", checkpoint, "required_lsn", required)
+			return &ReadModelBehind{ReadModel: model.Name, RequiredLSN: required, Checkpoint: checkpoint}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForReadModel(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	var checkpoint atomic.Uint64
+	checkpoint.Store(0x1000)
+	model := ReadModel{
+		Name:        "search",
+		Checkpoints: CheckpointProviderFunc(func(context.Context) (LSN, error) { return LSNFromUint64(checkpoint.Load()), nil }),
+		Poll:        time.Millisecond,
+	}
+
+	if err := db.WaitForReadModel(context.Background(), model); err != nil {
+		t.Errorf("want contexts without LSN requirement let through, got %v", err)
+	}
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x2000}})
+	err := db.WaitForReadModel(ctx, model)
+	var behind *ReadModelBehind
+	if !errors.As(err, &behind) || !errors.Is(err, ErrReadModelBehind) || behind.Checkpoint != (LSN{Lower: 0x1000}) {
+		t.Fatalf("want the read model behind, got %v", err)
+	}
+
+	// the read model ingests the required LSN while waiting
+	model.MaxWait = time.Second
+	time.AfterFunc(10*time.Millisecond, func() { checkpoint.Store(0x2000) })
+	if err := db.WaitForReadModel(ctx, model); err != nil {
+		t.Errorf("want the read model caught up, got %v", err)
+	}
+
+	model.Checkpoints = CheckpointProviderFunc(func(context.Context) (LSN, error) { return LSN{}, errConnReset })
+	if err := db.WaitForReadModel(ctx, model); !errors.Is(err, errConnReset) {
+		t.Errorf("want the checkpoint error returned, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrReadOnlyMode is returned for the writes rejected while the resolver is in read-only mode
+var ErrReadOnlyMode = errors.New("dbresolver: writes are suspended, the resolver is in read-only mode")
+
+// ReasonReadOnlyMode is the route reason of the reads served while the resolver is in read-only mode
+const ReasonReadOnlyMode = "read_only_mode"
+
+// EnterReadOnlyMode suspends the writes, e.g. during a regional failover: until ExitReadOnlyMode, writes,
+// write statements and transactions that aren't read-only fail with ErrReadOnlyMode, and reads are spread
+// over every available node, the primaries included since a former primary may now act as a standby.
+// Reads don't wait for their required LSN, which may never be replayed once the writes are suspended.
+func (db *DB) EnterReadOnlyMode() {
+	if !db.readOnly.Swap(true) {
+		db.log().Warn("read-only mode: writes suspended")
+	}
+}
+
+// ExitReadOnlyMode resumes the writes suspended by EnterReadOnlyMode
+func (db *DB) ExitReadOnlyMode() {
+	if db.readOnly.Swap(false) {
+		db.log().Warn("read-only mode: writes resumed")
+	}
+}
+
+// IsReadOnlyMode reports whether the writes are suspended by EnterReadOnlyMode
+func (db *DB) IsReadOnlyMode() bool {
+	return db.readOnly.Load()
+}
+
+// readOnlyModeDB selects the node serving a read in read-only mode among the available replicas and the
+// primaries that didn't fail their last health check
+func (db *DB) readOnlyModeDB(ctx context.Context, queryType QueryType) *sql.DB {
+	nodes := append([]*sql.DB(nil), db.ReplicaDBs()...)
+	for _, primary := range db.allPrimaries() {
+		if db.health == nil || !db.health.isPrimaryDown(primary) {
+			nodes = append(nodes, primary)
+		}
+	}
+	if len(nodes) == 0 {
+		nodes = db.allPrimaries()
+	}
+
+	curDB := db.loadBalancer.Resolve(nodes)
+	decision := RouteDecision{QueryType: queryType, DB: curDB, Target: physicalDBName(db, curDB), Reason: ReasonReadOnlyMode}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		decision.RequiredLSN = lsnCtx.RequiredLSN
+	}
+	db.hooks.route(decision)
+	db.activity.touch(curDB)
+	return curDB
+}
+
+// selectReadOnlyModeDB is selectDB in read-only mode
+func (db *DB) selectReadOnlyModeDB(ctx context.Context, queryType QueryType, query string) (*sql.DB, error) {
+	if queryType == QueryTypeWrite {
+		return nil, ErrReadOnlyMode
+	}
+	if classDB, reason := db.resourceClassDB(ctx, query); classDB != nil {
+		db.routeClassRead(ctx, queryType, classDB, reason)
+		return classDB, db.recordRead(ctx, classDB)
+	}
+	curDB := db.readOnlyModeDB(ctx, queryType)
+	return curDB, db.recordRead(ctx, curDB)
+}
@@ -0,0 +1,109 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReadOnlyModeRejectsWrites(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+
+	primaryMock.ExpectPrepare("INSERT INTO users")
+	replicaMock.ExpectPrepare("INSERT INTO users")
+	st, err := db.Prepare("INSERT INTO users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.EnterReadOnlyMode()
+	if !db.IsReadOnlyMode() {
+		t.Fatal("want the resolver in read-only mode")
+	}
+	if _, err := db.Exec("INSERT INTO users VALUES (1)"); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode for Exec, got %v", err)
+	}
+	if _, err := db.Query("INSERT INTO users VALUES (1) RETURNING id"); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode for a writing query, got %v", err)
+	}
+	if err := db.QueryRow("DELETE FROM users RETURNING id").Scan(new(int)); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode for a writing row query, got %v", err)
+	}
+	if _, err := st.Exec(); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode for a write statement, got %v", err)
+	}
+	if _, err := db.Begin(); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("want ErrReadOnlyMode for a read-write transaction, got %v", err)
+	}
+
+	db.ExitReadOnlyMode()
+	primaryMock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+	if _, err := db.Exec("INSERT INTO users VALUES (1)"); err != nil {
+		t.Errorf("want writes resumed, got %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadOnlyModeServesReadsFromEveryNode(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	var decisions []RouteDecision
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyLevel(ReadYourWrites),
+		WithRoutingHooks(func(d RouteDecision) { decisions = append(decisions, d) }, nil, nil),
+	)
+	db.EnterReadOnlyMode()
+
+	// the required LSN isn't waited for, and the primary serves reads like a standby
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x3000000}})
+	replicaMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	primaryMock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	for range 2 {
+		var name string
+		if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(decisions) != 2 || decisions[0].DB == decisions[1].DB {
+		t.Fatalf("want the reads spread over the replica and the primary, got %+v", decisions)
+	}
+	if d := decisions[0]; d.Reason != ReasonReadOnlyMode || d.RequiredLSN != (LSN{Lower: 0x3000000}) {
+		t.Errorf("want a read-only mode decision with the required LSN, got %+v", d)
+	}
+
+	primaryMock.ExpectBegin()
+	replicaMock.ExpectBegin()
+	for range 2 {
+		if _, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err != nil {
+			t.Errorf("want read-only transactions started, got %v", err)
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadOnlyModeSkipsPrimaryDown(t *testing.T) {
+	db, primaryMock, replicaMock, _ := newHealthDB(t)
+	primaryMock.ExpectQuery("SELECT pg_current_wal_lsn()").WillReturnError(errors.New("connection refused"))
+	expectReplayLSN(replicaMock, "0/3000000")
+	db.checkHealth()
+
+	db.EnterReadOnlyMode()
+	for range 3 {
+		if target := db.readOnlyModeDB(context.Background(), QueryTypeRead); target != db.allReplicas()[0] {
+			t.Fatalf("want the reads served by the replica while the primary is down, got %s", physicalDBName(db, target))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrReadScopeClosed is returned by the reads of a ReadScope after its Close
+var ErrReadScopeClosed = errors.New("dbresolver: read scope is closed")
+
+// ReadScope runs reads on the single database selected when it was created, see DB.ReadScope
+type ReadScope struct {
+	db     *DB
+	target *sql.DB
+	closed atomic.Bool
+}
+
+// ReadScope selects a database for the reads of ctx once, verifying the LSN of the replica a single time, and
+// returns a scope whose reads all go to it, so the queries of a handler observe the same replica rather than
+// replicas at different replay positions. It is cheaper than a read-only transaction, which holds a connection,
+// but reads don't share a snapshot. Reads fall back to the primary like QueryContext when no replica has caught
+// up; the scope keeps the database even if it is drained or removed meanwhile. Release it with Close.
+func (db *DB) ReadScope(ctx context.Context) (*ReadScope, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	target, err := db.routeDB(ctx, QueryTypeRead)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadScope{db: db, target: target}, nil
+}
+
+// DB returns the database serving the reads of the scope
+func (s *ReadScope) DB() *sql.DB {
+	return s.target
+}
+
+// QueryContext runs a read on the database of the scope
+func (s *ReadScope) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := s.db.recordRead(ctx, s.target); err != nil {
+		return nil, err
+	}
+	return s.target.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a read returning at most one row on the database of the scope
+func (s *ReadScope) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if err := s.checkOpen(); err != nil {
+		return errorRow(ctx, s.target, err)
+	}
+	if err := s.db.recordRead(ctx, s.target); err != nil {
+		return errorRow(ctx, s.target, err)
+	}
+	return s.target.QueryRowContext(ctx, query, args...)
+}
+
+// Close releases the scope, its later reads return ErrReadScopeClosed
+func (s *ReadScope) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func (s *ReadScope) checkOpen() error {
+	if s.closed.Load() {
+		return ErrReadScopeClosed
+	}
+	return s.db.checkOpen()
+}
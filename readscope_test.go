@@ -0,0 +1,51 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReadScope(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica1, mock1 := newMockDB(t)
+	replica2, mock2 := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica1, replica2), WithLoadBalancer(RoundRobinLB),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x1000}})
+
+	// the first replica caught up is verified once, then serves every read of the scope despite the round robin
+	expectReplayLSN(mock1, "0/2000")
+	expectReplayLSN(mock2, "0/2000")
+	scope, err := db.ReadScope(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := map[interface{}]sqlmock.Sqlmock{replica1: mock1, replica2: mock2}[scope.DB()]
+	if mock == nil {
+		t.Fatal("want a replica selected for the scope")
+	}
+	for range 3 {
+		mock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("replica"))
+		var name string
+		if err := scope.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := db.Metrics(); m.ReplicaReads != 3 {
+		t.Errorf("want 3 replica reads, got %d", m.ReplicaReads)
+	}
+
+	if err := scope.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scope.QueryContext(ctx, "SELECT name FROM users"); !errors.Is(err, ErrReadScopeClosed) {
+		t.Errorf("want ErrReadScopeClosed after Close, got %v", err)
+	}
+	// the scope replica was checked once, the other one not at all
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package redisstore provides a Redis backed dbresolver.SessionLSNStore,
+// sharing read-your-writes state between application instances without cookies.
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultKeyPrefix  = "pg_min_lsn:"
+	defaultMaxRetries = 5
+)
+
+// Store is a dbresolver.SessionLSNStore backed by Redis
+type Store struct {
+	client     redis.UniversalClient
+	keyPrefix  string
+	maxRetries int
+}
+
+// Option configures the Store
+type Option func(s *Store)
+
+// WithKeyPrefix sets the prefix prepended to session keys (defaults to "pg_min_lsn:")
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.keyPrefix = prefix
+	}
+}
+
+// WithMaxRetries sets how many times a conflicting concurrent Set is retried (defaults to 5)
+func WithMaxRetries(n int) Option {
+	return func(s *Store) {
+		s.maxRetries = n
+	}
+}
+
+// New creates a Redis session LSN store using the given client
+func New(client redis.UniversalClient, opts ...Option) *Store {
+	s := &Store{
+		client:     client,
+		keyPrefix:  defaultKeyPrefix,
+		maxRetries: defaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get returns the LSN stored for the session key
+func (s *Store) Get(ctx context.Context, sessionKey string) (dbresolver.LSN, bool, error) {
+	value, err := s.client.Get(ctx, s.keyPrefix+sessionKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return dbresolver.LSN{}, false, nil
+	}
+	if err != nil {
+		return dbresolver.LSN{}, false, fmt.Errorf("failed to get session LSN: %w", err)
+	}
+
+	lsn, err := dbresolver.ParseLSN(value)
+	if err != nil {
+		return dbresolver.LSN{}, false, fmt.Errorf("failed to parse session LSN: %w", err)
+	}
+	return lsn, true, nil
+}
+
+// Set stores the LSN for the session key, keeping the stored LSN if it is greater.
+// Concurrent writers are serialized with an optimistic WATCH/MULTI transaction.
+func (s *Store) Set(ctx context.Context, sessionKey string, lsn dbresolver.LSN, ttl time.Duration) error {
+	key := s.keyPrefix + sessionKey
+
+	txf := func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			if stored, perr := dbresolver.ParseLSN(current); perr == nil && stored.GreaterThan(lsn) {
+				lsn = stored
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, lsn.String(), ttl)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < s.maxRetries; i++ {
+		err := s.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set session LSN: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to set session LSN: too many concurrent updates")
+}
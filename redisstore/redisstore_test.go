@@ -0,0 +1,64 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbresolver "github.com/alfari16/go-pgrouter"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return New(client, WithKeyPrefix("test:")), server
+}
+
+func TestStoreGetSet(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+
+	if _, ok, err := store.Get(ctx, "user-1"); ok || err != nil {
+		t.Fatalf("expected no LSN for an unknown session, got ok=%v err=%v", ok, err)
+	}
+
+	want := dbresolver.LSN{Upper: 1, Lower: 0xABCDEF}
+	if err := store.Set(ctx, "user-1", want, time.Minute); err != nil {
+		t.Fatalf("set failed: %s", err)
+	}
+
+	got, ok, err := store.Get(ctx, "user-1")
+	if err != nil || !ok {
+		t.Fatalf("expected stored LSN, got ok=%v err=%v", ok, err)
+	}
+	if got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	if ttl := server.TTL("test:user-1"); ttl != time.Minute {
+		t.Errorf("want TTL %v, got %v", time.Minute, ttl)
+	}
+}
+
+func TestStoreNeverMovesBackwards(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	_ = store.Set(ctx, "user-1", dbresolver.LSN{Lower: 0x2000}, time.Minute)
+	_ = store.Set(ctx, "user-1", dbresolver.LSN{Lower: 0x1000}, time.Minute)
+
+	got, _, _ := store.Get(ctx, "user-1")
+	if got != (dbresolver.LSN{Lower: 0x2000}) {
+		t.Errorf("stored LSN must never move backwards, got %s", got)
+	}
+}
+
+func TestStoreImplementsSessionLSNStore(t *testing.T) {
+	var _ dbresolver.SessionLSNStore = (*Store)(nil)
+}
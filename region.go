@@ -0,0 +1,109 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// Region is a group of replicas sharing a location, in a topology with a single global primary
+type Region struct {
+	Name      string
+	Replicas  []*sql.DB // Replicas streaming from the primary
+	Cascading []*sql.DB // Cascading standbys streaming from the replicas of the region
+}
+
+// WithRegion adds a region to the topology. Its replicas serve reads only when the region is the
+// local region or one of its failover regions, see WithLocalRegion.
+func WithRegion(region Region) OptionFunc {
+	return func(opt *Option) {
+		opt.Regions = append(opt.Regions, region)
+	}
+}
+
+// WithLocalRegion sets the region of the application instance. Reads prefer the replicas of the local
+// region, then its cascading standbys, then the replicas and cascading standbys of the failover regions
+// in order, then the replicas set with WithReplicaDBs. The primary, which may be in another region, is
+// only used for writes, forced primary reads and when none of these replicas is available.
+func WithLocalRegion(name string, failover ...string) OptionFunc {
+	return func(opt *Option) {
+		opt.LocalRegion = name
+		opt.RegionFailover = failover
+	}
+}
+
+// replicaTiers groups replicas by read preference, most preferred first. Empty region tiers are skipped,
+// the replicas set with WithReplicaDBs always form the last tier so AddReplica can extend it.
+func replicaTiers(opt *Option) ([][]*sql.DB, error) {
+	regions := make(map[string]Region, len(opt.Regions))
+	for _, region := range opt.Regions {
+		regions[region.Name] = region
+	}
+
+	if opt.LocalRegion == "" {
+		return nil, fmt.Errorf("regions require a local region, set it with WithLocalRegion")
+	}
+	var tiers [][]*sql.DB
+	for _, name := range append([]string{opt.LocalRegion}, opt.RegionFailover...) {
+		region, ok := regions[name]
+		if !ok {
+			return nil, fmt.Errorf("region %q is not configured, add it with WithRegion", name)
+		}
+		for _, tier := range [][]*sql.DB{region.Replicas, region.Cascading} {
+			if len(tier) > 0 {
+				tiers = append(tiers, tier)
+			}
+		}
+	}
+	return append(tiers, opt.ReplicaDBs), nil
+}
+
+// regionReplicaDBs returns every replica of the regions, including the ones that never serve reads
+func regionReplicaDBs(regions []Region) []*sql.DB {
+	var replicas []*sql.DB
+	for _, region := range regions {
+		replicas = append(replicas, region.Replicas...)
+		replicas = append(replicas, region.Cascading...)
+	}
+	return replicas
+}
+
+// nameRegionReplicas names the unnamed replicas of the regions "<region>-replica-<index>"
+// and "<region>-cascading-<index>"
+func nameRegionReplicas(opt *Option) {
+	if opt.DBNames == nil {
+		opt.DBNames = make(map[*sql.DB]string)
+	}
+	name := func(db *sql.DB, name string) {
+		if _, ok := opt.DBNames[db]; !ok {
+			opt.DBNames[db] = name
+		}
+	}
+	for _, region := range opt.Regions {
+		for i, replica := range region.Replicas {
+			name(replica, region.Name+"-replica-"+strconv.Itoa(i))
+		}
+		for i, standby := range region.Cascading {
+			name(standby, region.Name+"-cascading-"+strconv.Itoa(i))
+		}
+	}
+}
+
+// tieredDBProvider is implemented by providers grouping replicas by read preference
+type tieredDBProvider interface {
+	// replicaTiers returns the available replicas of each tier, most preferred first
+	replicaTiers() [][]*sql.DB
+}
+
+// replicaTiers returns the available replicas of each read preference tier
+func (db *DB) replicaTiers() [][]*sql.DB {
+	readTiers := db.readTiers()
+	if readTiers == nil {
+		return [][]*sql.DB{db.ReplicaDBs()}
+	}
+	tiers := make([][]*sql.DB, 0, len(readTiers))
+	for _, tier := range readTiers {
+		tiers = append(tiers, db.availableReplicas(tier))
+	}
+	return tiers
+}
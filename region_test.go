@@ -0,0 +1,103 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+// newRegionDB returns a DB local to region "eu" failing over to "us", each with
+// one replica and one cascading standby
+func newRegionDB(t *testing.T, opts ...OptionFunc) (db *DB, eu, us Region) {
+	t.Helper()
+
+	primary, _ := newMockDB(t)
+	newDB := func() *sql.DB {
+		db, _ := newMockDB(t)
+		return db
+	}
+	eu = Region{Name: "eu", Replicas: []*sql.DB{newDB()}, Cascading: []*sql.DB{newDB()}}
+	us = Region{Name: "us", Replicas: []*sql.DB{newDB()}, Cascading: []*sql.DB{newDB()}}
+	ap := Region{Name: "ap", Replicas: []*sql.DB{newDB()}}
+
+	db = New(append([]OptionFunc{
+		WithPrimaryDBs(primary),
+		WithRegion(eu),
+		WithRegion(us),
+		WithRegion(ap),
+		WithLocalRegion("eu", "us"),
+		WithReplicaOutagePolicy(ReplicaOutageConfig{Policy: OutageDegradeSilently}),
+	}, opts...)...)
+	return db, eu, us
+}
+
+func TestRegionFailoverOrder(t *testing.T) {
+	db, eu, us := newRegionDB(t)
+
+	want := []*sql.DB{eu.Replicas[0], eu.Cascading[0], us.Replicas[0], us.Cascading[0]}
+	for i, replica := range want {
+		if got := db.ReplicaDBs(); len(got) != 1 || got[0] != replica {
+			t.Fatalf("tier %d: want %s, got %v", i, physicalDBName(db, replica), got)
+		}
+		db.outage.markDown(replica, driver.ErrBadConn, db.replicas)
+	}
+
+	if got := db.ReadOnly(); got != db.primaries[0] {
+		t.Errorf("want primary once every region of the failover order is down, got %s", physicalDBName(db, got))
+	}
+	if len(db.replicas) != 5 {
+		t.Errorf("want every region replica managed by the DB, got %d", len(db.replicas))
+	}
+}
+
+func TestRegionReplicaNames(t *testing.T) {
+	db, eu, us := newRegionDB(t)
+
+	if name := physicalDBName(db, eu.Cascading[0]); name != "eu-cascading-0" {
+		t.Errorf("want eu-cascading-0, got %s", name)
+	}
+	if name := physicalDBName(db, us.Replicas[0]); name != "us-replica-0" {
+		t.Errorf("want us-replica-0, got %s", name)
+	}
+}
+
+func TestRegionCausalReadUsesCaughtUpTier(t *testing.T) {
+	primary, _ := newMockDB(t)
+	local, localMock := newMockDB(t)
+	cascading, cascadingMock := newMockDB(t)
+	expectReplayLSN(localMock, "0/100")
+	expectReplayLSN(cascadingMock, "0/3000060")
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithRegion(Region{Name: "eu", Replicas: []*sql.DB{local}, Cascading: []*sql.DB{cascading}}),
+		WithLocalRegion("eu"),
+		WithCausalConsistencyLevel(ReadYourWrites),
+	)
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x3000060}})
+	got, err := db.queryRouter.RouteQuery(ctx, QueryTypeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != cascading {
+		t.Errorf("want the caught-up cascading standby, got %s", physicalDBName(db, got))
+	}
+}
+
+func TestRegionUnknownLocalRegionPanics(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected New to panic for an unconfigured local region")
+		}
+	}()
+	New(
+		WithPrimaryDBs(primary),
+		WithRegion(Region{Name: "eu", Replicas: []*sql.DB{replica}}),
+		WithLocalRegion("us"),
+	)
+}
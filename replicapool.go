@@ -0,0 +1,193 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// allPrimaries returns the primaries passed to New or set with SetPrimary.
+// The returned slice must not be modified.
+func (db *DB) allPrimaries() []*sql.DB {
+	db.replicasMu.RLock()
+	defer db.replicasMu.RUnlock()
+	return db.primaries
+}
+
+// allReplicas returns every replica passed to New or added since, including the region replicas.
+// The returned slice must not be modified.
+func (db *DB) allReplicas() []*sql.DB {
+	db.replicasMu.RLock()
+	defer db.replicasMu.RUnlock()
+	return db.replicas
+}
+
+// readTiers returns the read preference tiers, nil without a region topology.
+// The returned slices must not be modified.
+func (db *DB) readTiers() [][]*sql.DB {
+	db.replicasMu.RLock()
+	defer db.replicasMu.RUnlock()
+	return db.tiers
+}
+
+// dbNames returns the names given with WithNamedPrimaryDBs, WithNamedReplicaDBs or the regions.
+// The returned map must not be modified.
+func (db *DB) dbNames() map[*sql.DB]string {
+	db.replicasMu.RLock()
+	defer db.replicasMu.RUnlock()
+	return db.names
+}
+
+// AddReplica adds a replica to the read pool at runtime, e.g. to scale read capacity up without a restart.
+// The statements created with Prepare are prepared on the replica before it serves reads; a replica failing
+// to prepare them with a connection error serves them from the primary, like with Prepare. With a region
+// topology, the replica joins the replicas set with WithReplicaDBs. The replica is closed by Close.
+func (db *DB) AddReplica(replica *sql.DB) error {
+	db.membershipMu.Lock()
+	defer db.membershipMu.Unlock()
+
+	if containsDB(db.allReplicas(), replica) || containsDB(db.allPrimaries(), replica) {
+		return fmt.Errorf("database is already part of the resolver")
+	}
+	prepared, err := db.prepareOn(replica, true)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statements on replica: %w", err)
+	}
+
+	db.replicasMu.Lock()
+	db.replicas = append(slices.Clip(db.replicas), replica)
+	if db.tiers != nil {
+		tiers := slices.Clone(db.tiers)
+		last := len(tiers) - 1
+		tiers[last] = append(slices.Clip(tiers[last]), replica)
+		db.tiers = tiers
+	}
+	db.replicasMu.Unlock()
+
+	for s, st := range prepared {
+		s.addReplica(replica, st)
+	}
+	db.errorRatio.track(replica)
+	db.warmUp(replica)
+	db.log().Debug("replica pool: replica added", "db", physicalDBName(db, replica))
+	return nil
+}
+
+// RemoveReplica removes a replica from the read pool at runtime, e.g. to scale read capacity down.
+// Reads stop being routed to it and the statements prepared on it are closed. The replica itself isn't
+// closed, so the queries running on it can complete; the caller closes it once drained.
+func (db *DB) RemoveReplica(replica *sql.DB) error {
+	db.membershipMu.Lock()
+	defer db.membershipMu.Unlock()
+
+	name := physicalDBName(db, replica)
+	db.replicasMu.Lock()
+	i := slices.Index(db.replicas, replica)
+	if i < 0 {
+		db.replicasMu.Unlock()
+		return fmt.Errorf("database is not a replica of the resolver")
+	}
+	db.replicas = slices.Delete(slices.Clone(db.replicas), i, i+1)
+	if db.tiers != nil {
+		tiers := make([][]*sql.DB, len(db.tiers))
+		for j, tier := range db.tiers {
+			tiers[j] = slices.DeleteFunc(slices.Clone(tier), func(d *sql.DB) bool { return d == replica })
+		}
+		db.tiers = tiers
+	}
+	db.replicasMu.Unlock()
+
+	db.stmts.Range(func(key, _ any) bool {
+		key.(*stmt).removeReplica(replica)
+		return true
+	})
+	if db.health != nil {
+		db.health.forget(replica)
+	}
+	if db.outage != nil {
+		db.outage.forget(replica)
+	}
+	if db.roles != nil {
+		db.roles.forget(replica)
+	}
+	db.errorRatio.forget(replica)
+	db.activity.forget(replica)
+	db.metrics.prepareFailures.Delete(replica)
+	db.drain.set(replica, false)
+	db.delay.clear(replica)
+	PurgeChecker(replica)
+	db.log().Debug("replica pool: replica removed", "db", name)
+	return nil
+}
+
+// SetPrimary replaces the primaries with primary at runtime, e.g. to repoint writes to the new leader after
+// a failover instead of the demoted node. The statements created with Prepare are prepared on primary
+// first, failing the replacement on any error. The replaced primaries aren't closed, so the queries and
+// transactions running on them can complete; the caller closes them, or adds them back as replicas.
+// A replica must be removed with RemoveReplica before being set as the primary.
+func (db *DB) SetPrimary(primary *sql.DB) error {
+	db.membershipMu.Lock()
+	defer db.membershipMu.Unlock()
+
+	replaced := db.allPrimaries()
+	if slices.Equal(replaced, []*sql.DB{primary}) {
+		return nil
+	}
+	if db.isReplica(primary) {
+		return fmt.Errorf("database is a replica of the resolver, remove it first")
+	}
+	prepared, err := db.prepareOn(primary, false)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statements on primary: %w", err)
+	}
+
+	db.replicasMu.Lock()
+	db.primaries = []*sql.DB{primary}
+	db.replicasMu.Unlock()
+
+	for s, st := range prepared {
+		s.setPrimary(primary, st)
+	}
+	for _, old := range replaced {
+		if db.health != nil {
+			db.health.forgetPrimary(old)
+		}
+		if db.roles != nil {
+			db.roles.forget(old)
+		}
+	}
+	db.log().Warn("replica pool: primary replaced", "db", physicalDBName(db, primary), "replaced", len(replaced))
+	return nil
+}
+
+// prepareOn prepares the open statements on a database joining the resolver. With connErrFallback, the
+// statements failing to prepare with a connection error are mapped to nil.
+func (db *DB) prepareOn(target *sql.DB, connErrFallback bool) (map[*stmt]*sql.Stmt, error) {
+	prepared := make(map[*stmt]*sql.Stmt)
+	var err error
+	db.stmts.Range(func(key, _ any) bool {
+		s := key.(*stmt)
+		var st *sql.Stmt
+		st, err = target.PrepareContext(context.Background(), s.query)
+		if err != nil {
+			db.metrics.prepareFailed(target)
+		}
+		if connErrFallback && isConnectionError(db.classifier, err) {
+			st, err = nil, nil
+		}
+		if err == nil {
+			prepared[s] = st
+		}
+		return err == nil
+	})
+	if err != nil {
+		for _, st := range prepared {
+			if st != nil {
+				_ = st.Close()
+			}
+		}
+		return nil, err
+	}
+	return prepared, nil
+}
@@ -0,0 +1,226 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAddAndRemoveReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	added, addedMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	db.stmtLoadBalancer = firstLoadBalancer[*sql.Stmt]{}
+
+	primaryMock.ExpectPrepare("SELECT name FROM users").WillBeClosed()
+	replicaMock.ExpectPrepare("SELECT name FROM users").WillBeClosed()
+	st, err := db.Prepare("SELECT name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addedMock.ExpectPrepare("SELECT name FROM users").WillBeClosed()
+	if err := db.AddReplica(added); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddReplica(added); err == nil {
+		t.Error("want an error adding a replica twice")
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 2 || replicas[1] != added {
+		t.Fatalf("want the added replica in the read pool, got %v", replicas)
+	}
+	if name := physicalDBName(db, added); name != "replica-1" {
+		t.Errorf("want the added replica named replica-1, got %s", name)
+	}
+
+	if err := db.RemoveReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("want the statement of the removed replica closed: %v", err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != added {
+		t.Fatalf("want only the added replica in the read pool, got %v", replicas)
+	}
+	if err := db.RemoveReplica(replica); err == nil {
+		t.Error("want an error removing a replica twice")
+	}
+
+	addedMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	var name string
+	if err := st.QueryRow().Scan(&name); err != nil || name != "alice" {
+		t.Fatalf("want the statement served by the added replica, got %q, %v", name, err)
+	}
+
+	_ = st.Close()
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, addedMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestAddReplicaPrepareFailure(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	added, addedMock := newMockDB(t)
+	down, downMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary))
+	primaryMock.ExpectPrepare("SELECT 1")
+	if _, err := db.Prepare("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	addedMock.ExpectPrepare("SELECT 1").WillReturnError(errors.New("syntax error"))
+	if err := db.AddReplica(added); err == nil {
+		t.Error("want the prepare error")
+	}
+	if len(db.ReplicaDBs()) != 0 {
+		t.Error("replica failing to prepare the statements should not be added")
+	}
+
+	downMock.ExpectPrepare("SELECT 1").WillReturnError(errConnReset)
+	if err := db.AddReplica(down); err != nil {
+		t.Fatalf("replica failing with a connection error should be added, got %v", err)
+	}
+	if len(db.ReplicaDBs()) != 1 {
+		t.Error("want the replica added")
+	}
+}
+
+func TestAddReplicaWithRegions(t *testing.T) {
+	primary, _ := newMockDB(t)
+	local, _ := newMockDB(t)
+	added, _ := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithRegion(Region{Name: "eu", Replicas: []*sql.DB{local}}),
+		WithLocalRegion("eu"),
+	)
+	if err := db.AddReplica(added); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != local {
+		t.Errorf("want the local region preferred over the added replica, got %v", replicas)
+	}
+
+	if err := db.RemoveReplica(local); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != added {
+		t.Errorf("want the added replica once the local region is removed, got %v", replicas)
+	}
+}
+
+func TestReplicaMembershipConcurrentReads(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replicas := make([]*sql.DB, 4)
+	for i := range replicas {
+		replicas[i], _ = newMockDB(t)
+	}
+	db := New(WithPrimaryDBs(primary))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			for _, replica := range replicas {
+				_ = db.AddReplica(replica)
+			}
+			for _, replica := range replicas {
+				_ = db.RemoveReplica(replica)
+			}
+		}
+	}()
+	for range 1000 {
+		_ = db.DbSelector(context.Background(), QueryTypeRead)
+	}
+	wg.Wait()
+
+	if len(db.ReplicaDBs()) != 0 {
+		t.Errorf("want every replica removed, got %v", db.ReplicaDBs())
+	}
+}
+
+func TestSetPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	leader, leaderMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	db.stmtLoadBalancer = firstLoadBalancer[*sql.Stmt]{}
+
+	primaryMock.ExpectPrepare("INSERT INTO users").WillBeClosed()
+	replicaMock.ExpectPrepare("INSERT INTO users").WillReturnError(errConnReset)
+	st, err := db.Prepare("INSERT INTO users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.SetPrimary(replica); err == nil {
+		t.Error("want an error setting a replica as the primary")
+	}
+	leaderMock.ExpectPrepare("INSERT INTO users")
+	if err := db.SetPrimary(leader); err != nil {
+		t.Fatal(err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("want the statement of the replaced primary closed: %v", err)
+	}
+	if primaries := db.PrimaryDBs(); len(primaries) != 1 || primaries[0] != leader || db.ReadWrite() != leader {
+		t.Fatalf("want writes routed to the new primary, got %v", primaries)
+	}
+	if name := physicalDBName(db, leader); name != "primary-0" {
+		t.Errorf("want the new primary named primary-0, got %s", name)
+	}
+
+	leaderMock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(1, 1))
+	if _, err := st.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	// the replica failing to prepare the statement falls back to the new primary
+	leaderMock.ExpectQuery("INSERT INTO users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err := st.(*stmt).ROStmt().Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	// the replaced primary can join the replicas
+	primaryMock.ExpectPrepare("INSERT INTO users")
+	if err := db.AddReplica(primary); err != nil {
+		t.Errorf("want the replaced primary added as a replica, got %v", err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, leaderMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSetPrimaryPrepareFailure(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	leader, leaderMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary))
+	primaryMock.ExpectPrepare("SELECT 1")
+	if _, err := db.Prepare("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	leaderMock.ExpectPrepare("SELECT 1").WillReturnError(errConnReset)
+	if err := db.SetPrimary(leader); err == nil {
+		t.Error("want the prepare error of the new primary")
+	}
+	if db.ReadWrite() != primary {
+		t.Error("the primary should not be replaced when the statements fail to prepare")
+	}
+}
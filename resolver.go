@@ -1,5 +1,11 @@
 package dbresolver
 
+import (
+	"database/sql"
+	"slices"
+	"time"
+)
+
 // New will resolve all the passed connection with configurable parameters
 func New(opts ...OptionFunc) *DB {
 	opt := defaultOption()
@@ -12,17 +18,159 @@ func New(opts ...OptionFunc) *DB {
 			"connection with dbresolver.New(dbresolver.WithPrimaryDBs(primaryDB))")
 	}
 
+	var tiers [][]*sql.DB
+	if len(opt.Regions) > 0 {
+		var err error
+		if tiers, err = replicaTiers(opt); err != nil {
+			panic(err.Error())
+		}
+		opt.ReplicaDBs = append(slices.Clip(opt.ReplicaDBs), regionReplicaDBs(opt.Regions)...)
+		nameRegionReplicas(opt)
+	}
+
+	opt.ReplicaDBs = append(slices.Clip(opt.ReplicaDBs), opt.DelayedReplicaDBs...)
+
+	if opt.QueryTypeCacheSize > 0 {
+		opt.QueryTypeChecker = NewCachedQueryTypeChecker(opt.QueryTypeChecker, opt.QueryTypeCacheSize)
+	}
+
+	if opt.DecisionLog != nil {
+		hooks := routingHooks{}
+		if opt.RoutingHooks != nil {
+			hooks = *opt.RoutingHooks
+		}
+		hooks.decisions = opt.DecisionLog
+		opt.RoutingHooks = &hooks
+	}
+	if opt.FallbackRatio != nil {
+		hooks := routingHooks{}
+		if opt.RoutingHooks != nil {
+			hooks = *opt.RoutingHooks
+		}
+		hooks.fallbackRatio = opt.FallbackRatio
+		opt.RoutingHooks = &hooks
+	}
+
 	sqlDB := &DB{
 		primaries:        opt.PrimaryDBs,
 		replicas:         opt.ReplicaDBs,
 		loadBalancer:     opt.DBLB,
 		stmtLoadBalancer: opt.StmtLB,
 		queryTypeChecker: opt.QueryTypeChecker,
+		tokenKeys:        opt.CausalTokenKeys,
+		classReplicas:    opt.ResourceClassReplicas,
+		queryClasses:     opt.QueryResourceClasses,
+		readGuard:        opt.PrimaryReadGuard,
+		logger:           componentLogger(opt.Logger, LogComponentReplicas, opt.LogLevels),
+		hooks:            opt.RoutingHooks,
+		names:            opt.DBNames,
+		assertions:       opt.ConsistencyAssertions,
+		tiers:            tiers,
+		forwarder:        opt.WriteForwarder,
+		leaks:            opt.LeakDetection,
+		retry:            opt.RetryPolicy,
+		partialFailures:  opt.PartialFailureTolerance,
+		classifier:       opt.ErrorClassifier,
+		decisions:        opt.DecisionLog,
+		diagnosis:        opt.Diagnosis,
+		boost:            opt.ReplicaBoost,
+		maintenance:      opt.Maintenance,
+		fallbackRatio:    opt.FallbackRatio,
+		distribution:     opt.ReadDistribution,
+		dualWrite:        opt.DualWrite,
+		capture:          opt.ReadCapture,
+		warmup:           opt.ReplicaWarmUp,
+		txCommitLSN:      opt.TxCommitLSN,
+		copy:             opt.Copy,
+		notifications:    opt.Notifications,
+		stopCh:           make(chan struct{}),
+
+		onLSNTrackingError: opt.LSNTrackingErrorHandler,
+	}
+
+	for _, replica := range opt.DelayedReplicaDBs {
+		sqlDB.delay.set(replica, delayedReplica{})
 	}
 
 	// Initialize query router after SqlDB is created (so it can implement DBProvider)
 	if opt.CCConfig != nil && opt.CCConfig.Enabled && opt.QueryRouter == nil {
-		sqlDB.queryRouter = NewCausalRouter(sqlDB, opt.CCConfig)
+		router := NewCausalRouter(sqlDB, opt.CCConfig)
+		router.tracer = opt.Tracer
+		router.logger = componentLogger(opt.Logger, LogComponentRouter, opt.LogLevels)
+		router.hooks = opt.RoutingHooks
+		router.onTrackingError = opt.LSNTrackingErrorHandler
+		sqlDB.queryRouter = router
+	}
+
+	if opt.OutageConfig != nil {
+		sqlDB.outage = newReplicaOutage(*opt.OutageConfig)
+		sqlDB.outage.classifier = opt.ErrorClassifier
+		if opt.Diagnosis != nil {
+			sqlDB.outage.onEject = func(replica *sql.DB, ejectedAt time.Time, recentErrors []ReplicaError) {
+				sqlDB.diagnoseReplica(replica, EjectedByOutage, ejectedAt, recentErrors)
+			}
+		}
+	}
+
+	if opt.KeepaliveInterval > 0 && len(opt.ReplicaDBs) > 0 {
+		sqlDB.keepaliveInterval = opt.KeepaliveInterval
+		sqlDB.keepaliveTimeout = DefaultCausalConsistencyConfig().Timeout
+		if opt.CCConfig != nil && opt.CCConfig.Timeout > 0 {
+			sqlDB.keepaliveTimeout = opt.CCConfig.Timeout
+		}
+	}
+
+	if opt.ErrorRatioDemotion != nil {
+		replicas := append(append([]*sql.DB(nil), sqlDB.replicas...), sqlDB.resourceClassReplicaDBs()...)
+		sqlDB.errorRatio = newErrorRatioTracker(*opt.ErrorRatioDemotion, replicas)
+		sqlDB.errorRatio.onEvent = opt.OnHealthEvent
+		sqlDB.errorRatio.name = func(replica *sql.DB) string { return physicalDBName(sqlDB, replica) }
+		sqlDB.errorRatio.onDemote = func(replica *sql.DB, demotedAt time.Time, err error) {
+			sqlDB.diagnoseReplica(replica, EjectedByErrorRatio, demotedAt, []ReplicaError{{At: demotedAt, Err: err}})
+		}
+	}
+
+	if opt.HealthCheckInterval > 0 {
+		sqlDB.health = newHealthMonitor(opt)
+	}
+
+	if opt.RoleVerificationInterval > 0 {
+		sqlDB.roles = &roleVerifier{
+			interval:   opt.RoleVerificationInterval,
+			onEvent:    opt.OnHealthEvent,
+			mismatched: make(map[*sql.DB]bool),
+		}
+	}
+
+	if opt.AutoFailover != nil {
+		sqlDB.failover = opt.AutoFailover
+	}
+
+	if opt.ReplicaDiscovery != nil && opt.ReplicaDiscovery.Open == nil {
+		panic("replica discovery requires DiscoveryConfig.Open to open the discovered replicas")
+	}
+
+	// the workers start once every field is set, they read them without synchronization
+	if sqlDB.keepaliveInterval > 0 {
+		sqlDB.goBackground(sqlDB.runKeepalive)
+	}
+	if opt.LeakDetection != nil {
+		sqlDB.goBackground(sqlDB.runLeakDetection)
+	}
+	if sqlDB.health != nil {
+		sqlDB.goBackground(sqlDB.runHealthCheck)
+	}
+	if sqlDB.roles != nil {
+		sqlDB.goBackground(sqlDB.runRoleVerification)
+	}
+	if sqlDB.failover != nil {
+		sqlDB.goBackground(sqlDB.runAutoFailover)
+	}
+	if sqlDB.fallbackRatio != nil {
+		sqlDB.goBackground(sqlDB.runFallbackRatio)
+	}
+	if opt.ReplicaDiscovery != nil {
+		sqlDB.goBackground(newReplicaDiscovery(*opt.ReplicaDiscovery, sqlDB).run)
 	}
 
 	return sqlDB
@@ -3,7 +3,9 @@ package dbresolver_test
 import (
 	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/alfari16/go-pgrouter"
 )
 
@@ -28,3 +30,28 @@ func TestWrapDBWithOneDB(t *testing.T) {
 		t.Errorf("expected %v, got %v", "not nil", db)
 	}
 }
+
+// The background workers start with every option applied, run with -race
+func TestNewStartsWorkersLast(t *testing.T) {
+	primary, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := dbresolver.New(
+		dbresolver.WithPrimaryDBs(primary),
+		dbresolver.WithReplicaDBs(replica),
+		dbresolver.WithReplicaKeepalive(time.Millisecond),
+		dbresolver.WithErrorRatioDemotion(time.Minute, 0.5, 10),
+		dbresolver.WithHealthCheck(time.Millisecond, time.Second),
+		dbresolver.WithRoleVerification(time.Millisecond),
+	)
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Close(); err != nil {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,117 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+)
+
+// ResourceClass names a class of reads, such as vector searches or large aggregates,
+// that must only run on a designated pool of replicas
+type ResourceClass string
+
+// ResourceClassHeavy is the conventional class for memory or CPU intensive reads
+const ResourceClassHeavy ResourceClass = "heavy"
+
+const resourceClassKey contextKey = "resource_class"
+
+// queryResourceClass tags the queries matching pattern with class
+type queryResourceClass struct {
+	class   ResourceClass
+	pattern *regexp.Regexp
+}
+
+// WithResourceClass returns a context whose reads are routed to the replica pool of class.
+// The hint takes precedence over the patterns configured with WithQueryResourceClass.
+func WithResourceClass(ctx context.Context, class ResourceClass) context.Context {
+	return context.WithValue(ctx, resourceClassKey, class)
+}
+
+// GetResourceClass returns the resource class hint of the context, if any
+func GetResourceClass(ctx context.Context) (ResourceClass, bool) {
+	class, ok := ctx.Value(resourceClassKey).(ResourceClass)
+	return class, ok
+}
+
+// WithResourceClassReplicas designates the replicas serving the reads of class, e.g. high-memory
+// replicas for ResourceClassHeavy. They only serve that class and are not used for other reads; the reads
+// of the class fall back to the primary, never to the other replicas.
+func WithResourceClassReplicas(class ResourceClass, replicas ...*sql.DB) OptionFunc {
+	return func(opt *Option) {
+		if opt.ResourceClassReplicas == nil {
+			opt.ResourceClassReplicas = make(map[ResourceClass][]*sql.DB)
+		}
+		opt.ResourceClassReplicas[class] = append(opt.ResourceClassReplicas[class], replicas...)
+	}
+}
+
+// WithQueryResourceClass tags the reads whose query matches any of the patterns with class.
+// Patterns are checked in the order they were configured; the first match wins.
+func WithQueryResourceClass(class ResourceClass, patterns ...*regexp.Regexp) OptionFunc {
+	return func(opt *Option) {
+		for _, pattern := range patterns {
+			opt.QueryResourceClasses = append(opt.QueryResourceClasses, queryResourceClass{class: class, pattern: pattern})
+		}
+	}
+}
+
+// resourceClass returns the class of a read from the context hint or the query patterns
+func (db *DB) resourceClass(ctx context.Context, query string) ResourceClass {
+	if class, ok := GetResourceClass(ctx); ok {
+		return class
+	}
+	for _, qc := range db.queryClasses {
+		if qc.pattern.MatchString(query) {
+			return qc.class
+		}
+	}
+	return ""
+}
+
+// resourceClassDB returns the database of a read of a resource class and the reason it was chosen, or nil
+// if the read has no class with a replica pool and is routed normally. A read of a class is never
+// routed to the general replica pool: it's served by the primary when the master is forced, the pool has
+// no available replica, or no replica of the pool has caught up to the required LSN.
+func (db *DB) resourceClassDB(ctx context.Context, query string) (*sql.DB, string) {
+	if len(db.classReplicas) == 0 {
+		return nil, ""
+	}
+	pool, ok := db.classReplicas[db.resourceClass(ctx, query)]
+	if !ok {
+		return nil, ""
+	}
+
+	lsnCtx := GetLSNContext(ctx)
+	if lsnCtx != nil && lsnCtx.ForceMaster {
+		return db.loadBalancer.Resolve(db.allPrimaries()), ReasonForceMaster
+	}
+	pool = db.availableReplicas(pool)
+	if len(pool) == 0 {
+		return db.loadBalancer.Resolve(db.allPrimaries()), ReasonResourceClassFallback
+	}
+	if router, ok := db.queryRouter.(*CausalRouter); ok && lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero() {
+		if caughtUp, replica := router.caughtUpReplica(ctx, pool, lsnCtx.RequiredLSN); caughtUp {
+			return replica, ReasonResourceClass
+		}
+		return db.loadBalancer.Resolve(db.allPrimaries()), ReasonResourceClassFallback
+	}
+	return db.loadBalancer.Resolve(pool), ReasonResourceClass
+}
+
+// routeClassRead reports the routing of a read of a resource class to the routing hooks and the decision log
+func (db *DB) routeClassRead(ctx context.Context, queryType QueryType, target *sql.DB, reason string) {
+	decision := RouteDecision{QueryType: queryType, DB: target, Target: physicalDBName(db, target), Reason: reason}
+	if lsnCtx := GetLSNContext(ctx); lsnCtx != nil {
+		decision.RequiredLSN = lsnCtx.RequiredLSN
+	}
+	db.hooks.route(decision)
+}
+
+// resourceClassReplicaDBs returns every replica of the resource class pools
+func (db *DB) resourceClassReplicaDBs() []*sql.DB {
+	var replicas []*sql.DB
+	for _, pool := range db.classReplicas {
+		replicas = append(replicas, pool...)
+	}
+	return replicas
+}
@@ -0,0 +1,129 @@
+package dbresolver
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestResourceClassRouting(t *testing.T) {
+	primary, _ := newMockDB(t)
+	oltp, oltpMock := newMockDB(t)
+	highMem, highMemMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(oltp),
+		WithResourceClassReplicas(ResourceClassHeavy, highMem),
+		WithQueryResourceClass(ResourceClassHeavy, regexp.MustCompile(`<->`)),
+	)
+
+	// vector searches match the pattern and go to the high-memory replica
+	highMemMock.ExpectQuery("embedding").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err := db.Query("SELECT id FROM items ORDER BY embedding <-> $1 LIMIT 5", "[1,2,3]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	// a context hint tags an aggregate the patterns don't know about
+	highMemMock.ExpectQuery("SUM").WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	var total int
+	if err := db.QueryRowContext(WithResourceClass(context.Background(), ResourceClassHeavy),
+		"SELECT SUM(amount) FROM orders").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+
+	// regular reads never reach the high-memory replica
+	oltpMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows, err = db.Query("SELECT id FROM orders WHERE id = $1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	if err := highMemMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := oltpMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if m := db.Metrics(); m.ReplicaReads != 3 || len(m.Replicas) != 2 || m.Replicas[1].Class != ResourceClassHeavy {
+		t.Errorf("expected class replica reads and stats in the metrics, got %+v", m)
+	}
+}
+
+func TestResourceClassRoutingHonorsRequiredLSN(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	highMem, highMemMock := newMockDB(t)
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithResourceClassReplicas(ResourceClassHeavy, highMem),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            ReadYourWrites,
+			FallbackToMaster: true,
+			Timeout:          time.Second,
+		}),
+	)
+	ctx := WithResourceClass(WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x3000}}),
+		ResourceClassHeavy)
+
+	// the high-memory replica lags, so the primary serves the read
+	expectReplayLSN(highMemMock, "0/1000")
+	primaryMock.ExpectQuery("SUM").WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT SUM(amount) FROM orders").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+
+	// once caught up, it serves the read
+	expectReplayLSN(highMemMock, "0/3000")
+	highMemMock.ExpectQuery("SUM").WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	if err := db.QueryRowContext(ctx, "SELECT SUM(amount) FROM orders").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := highMemMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResourceClassRoutingNeverUsesGeneralReplicas(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	oltp, oltpMock := newMockDB(t)
+	highMem, _ := newMockDB(t)
+
+	var reasons []string
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(oltp),
+		WithResourceClassReplicas(ResourceClassHeavy, highMem),
+		WithRoutingHooks(func(d RouteDecision) { reasons = append(reasons, d.Reason) }, nil, nil),
+	)
+	db.drain.set(highMem, true) // the only high-memory replica is unavailable
+
+	primaryMock.ExpectQuery("SUM").WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(10))
+	var total int
+	if err := db.QueryRowContext(WithResourceClass(context.Background(), ResourceClassHeavy),
+		"SELECT SUM(amount) FROM orders").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := oltpMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonResourceClassFallback {
+		t.Errorf("want a %s decision, got %v", ReasonResourceClassFallback, reasons)
+	}
+}
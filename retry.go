@@ -0,0 +1,130 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ReasonReadRetried is the fallback reason of the reads retried by the retry policy
+const ReasonReadRetried = "read_retried"
+
+// retryPolicy retries the reads failing with transient errors
+type retryPolicy struct {
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	retryable   []error
+}
+
+// WithRetryPolicy retries the reads failing with a connection or transient error, see WithErrorClassifier,
+// or an error matching one of retryableErrors with errors.Is, up to maxAttempts attempts in total.
+// Each retry runs on another available replica, then on the primary, after waiting backoff(attempt),
+// attempt starting at 1; a nil backoff retries immediately. Reads with an LSN requirement are retried on the primary, and
+// reads of a resource class on the other replicas of its pool first. Writes are never retried.
+func WithRetryPolicy(maxAttempts int, backoff func(attempt int) time.Duration, retryableErrors ...error) OptionFunc {
+	return func(opt *Option) {
+		opt.RetryPolicy = &retryPolicy{maxAttempts: maxAttempts, backoff: backoff, retryable: retryableErrors}
+	}
+}
+
+// ExponentialBackoff returns a backoff doubling from base up to maxDelay
+func ExponentialBackoff(base, maxDelay time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		return min(delay, maxDelay)
+	}
+}
+
+// shouldRetry reports whether err, classified by classifier, is transient
+func (p *retryPolicy) shouldRetry(classifier ErrorClassifier, err error) bool {
+	if class := classifier(err); class == ErrorClassConnection || class == ErrorClassTransient {
+		return true
+	}
+	for _, retryable := range p.retryable {
+		if errors.Is(err, retryable) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait sleeps for the backoff of attempt, returning early with the context's error
+func (p *retryPolicy) wait(ctx context.Context, attempt int) error {
+	if p.backoff == nil {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// runRead runs a read on curDB and, with a retry policy, retries it on other databases while it
+// fails with a transient error
+func (db *DB) runRead(ctx context.Context, query string, curDB *sql.DB, read func(querier) error) (err error) {
+	start := time.Now()
+	err = db.readOn(ctx, curDB, read)
+	db.observeLatency(curDB, time.Since(start), err)
+	db.observeReplica(curDB, err)
+	if db.retry == nil {
+		db.captureRead(ctx, query, curDB, start, err)
+		return err
+	}
+	began, served := start, curDB
+	defer func() { db.captureRead(ctx, query, served, began, err) }()
+
+	tried := []*sql.DB{curDB}
+	for attempt := 1; attempt < db.retry.maxAttempts && err != nil && db.retry.shouldRetry(db.classifier, err); attempt++ {
+		if waitErr := db.retry.wait(ctx, attempt); waitErr != nil {
+			return err
+		}
+		next := db.retryTarget(ctx, query, tried)
+		if readErr := db.recordRead(ctx, next); readErr != nil {
+			return readErr
+		}
+		db.log().Debug("retrying read", "db", physicalDBName(db, next), "attempt", attempt+1, "error", err)
+		db.hooks.fallback(FallbackEvent{QueryType: QueryTypeRead, Reason: ReasonReadRetried, Err: err})
+		db.recordDecision(ctx, QueryTypeRead, next, ReasonReadRetried)
+
+		tried, served = append(tried, next), next
+		start = time.Now()
+		err = db.readOn(ctx, next, read)
+		db.observeLatency(next, time.Since(start), err)
+		db.observeReplica(next, err)
+	}
+	return err
+}
+
+// retryTarget returns the database of the next attempt of a read: an available replica not tried yet,
+// or the primary when none is left
+func (db *DB) retryTarget(ctx context.Context, query string, tried []*sql.DB) *sql.DB {
+	var candidates []*sql.DB
+	lsnCtx := GetLSNContext(ctx)
+	switch {
+	case lsnCtx != nil && (lsnCtx.ForceMaster || !lsnCtx.RequiredLSN.IsZero()):
+		// another replica may not have caught up to the required LSN
+	case db.classReplicas[db.resourceClass(ctx, query)] != nil:
+		candidates = db.availableReplicas(db.classReplicas[db.resourceClass(ctx, query)])
+	default:
+		candidates = db.ReplicaDBs()
+	}
+
+	remaining := make([]*sql.DB, 0, len(candidates))
+	for _, candidate := range candidates {
+		if !containsDB(tried, candidate) {
+			remaining = append(remaining, candidate)
+		}
+	}
+	if len(remaining) == 0 {
+		return db.ReadWrite()
+	}
+	return db.loadBalancer.Resolve(remaining)
+}
@@ -0,0 +1,129 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// errConnReset is a connection error; driver.ErrBadConn can't be used as database/sql retries it itself
+var errConnReset = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+func TestRetryPolicyRetriesOtherReplicaThenPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	first, firstMock := newMockDB(t)
+	second, secondMock := newMockDB(t)
+
+	firstMock.ExpectQuery("SELECT name FROM users").WillReturnError(errConnReset)
+	secondMock.ExpectQuery("SELECT name FROM users").WillReturnError(errConnReset)
+	primaryMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+
+	var retries []FallbackEvent
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(first, second),
+		WithRetryPolicy(3, nil),
+		WithRoutingHooks(nil, func(e FallbackEvent) { retries = append(retries, e) }, nil),
+	)
+	db.loadBalancer = firstLoadBalancer[*sql.DB]{}
+
+	var name string
+	if err := db.QueryRowContext(context.Background(), "SELECT name FROM users").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "alice" {
+		t.Errorf("want alice, got %s", name)
+	}
+	if len(retries) != 2 || retries[0].Reason != ReasonReadRetried {
+		t.Errorf("want 2 retry events, got %+v", retries)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, firstMock, secondMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(errConnReset)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithRetryPolicy(1, nil))
+
+	if _, err := db.QueryContext(context.Background(), "SELECT 1"); !errors.Is(err, errConnReset) {
+		t.Errorf("want the error of the only attempt, got %v", err)
+	}
+}
+
+func TestRetryPolicySkipsPermanentErrors(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	syntaxErr := errors.New("syntax error")
+	replicaMock.ExpectQuery("SELEC 1").WillReturnError(syntaxErr)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithRetryPolicy(3, nil))
+
+	if _, err := db.QueryContext(context.Background(), "SELEC 1"); !errors.Is(err, syntaxErr) {
+		t.Errorf("want the permanent error without retries, got %v", err)
+	}
+}
+
+func TestRetryPolicyCustomRetryableError(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	recoveryConflict := errors.New("canceling statement due to conflict with recovery")
+	replicaMock.ExpectQuery("SELECT 1").WillReturnError(recoveryConflict)
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithRetryPolicy(2, ExponentialBackoff(time.Millisecond, 10*time.Millisecond), recoveryConflict),
+	)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryPolicyLSNRequirementRetriesPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	lagged, laggedMock := newMockDB(t)
+	other, _ := newMockDB(t)
+	laggedMock.ExpectQuery("SELECT 1").WillReturnError(errConnReset)
+	primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(lagged, other), WithRetryPolicy(2, nil))
+	db.loadBalancer = firstLoadBalancer[*sql.DB]{}
+
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x100}})
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("reads with an LSN requirement should be retried on the primary: %s", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w {
+			t.Errorf("attempt %d: want %v, got %v", i+1, w, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+// returningPattern matches the RETURNING clause of a write
+var returningPattern = regexp.MustCompile(`(?i)\bRETURNING\b`)
+
+// returningResult is the sql.Result of ExecReturningID
+type returningResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r returningResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r returningResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// InsertReturningID executes an INSERT on the primary and returns the id of the first row inserted,
+// see ExecReturningID
+func (db *DB) InsertReturningID(ctx context.Context, query string, args ...any) (int64, error) {
+	result, err := db.ExecReturningID(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ExecReturningID executes a write returning a single integer column, e.g. "INSERT ... RETURNING id", and
+// returns a sql.Result whose LastInsertId is the value of the first row and RowsAffected the number of rows,
+// since the PostgreSQL drivers don't implement LastInsertId. " RETURNING id" is appended to a query without
+// RETURNING clause. The write is always routed to the primary, whatever the query type checker classifies it
+// as, and the WAL LSN after it is captured on the same connection for the LSN context of ctx.
+// Writes aren't forwarded by WithWriteForwarder.
+func (db *DB) ExecReturningID(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := db.checkOpen(); err != nil {
+		return nil, err
+	}
+	if !returningPattern.MatchString(query) {
+		query += " RETURNING id"
+	}
+	primary, err := db.selectDB(ctx, QueryTypeWrite, query)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := primary.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := db.applySearchPath(ctx, primary, conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := scanReturningIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	db.captureConnLSN(ctx, conn)
+	return result, nil
+}
+
+// scanReturningIDs counts the rows and keeps the id of the first one, closing rows
+func scanReturningIDs(rows *sql.Rows) (returningResult, error) {
+	defer rows.Close()
+
+	var result returningResult
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return returningResult{}, fmt.Errorf("failed to scan the returned id: %w", err)
+		}
+		if result.rowsAffected == 0 {
+			result.lastInsertID = id
+		}
+		result.rowsAffected++
+	}
+	return result, rows.Err()
+}
+
+// captureConnLSN raises the required LSN of the LSN context of ctx to the current WAL LSN of conn.
+// A failed capture is reported to the fallback hook, the write having succeeded.
+func (db *DB) captureConnLSN(ctx context.Context, conn *sql.Conn) {
+	lsnCtx := GetLSNContext(ctx)
+	if lsnCtx == nil {
+		return
+	}
+	lsnCtx.HasWriteOperation = true
+
+	var lsnStr string
+	err := conn.QueryRowContext(ctx, "SELECT "+PGCurrentWALLSN).Scan(&lsnStr)
+	var lsn LSN
+	if err == nil {
+		lsn, err = ParseLSN(lsnStr)
+	}
+	if err != nil {
+		db.lsnTrackingFailed(ctx, fmt.Errorf("failed to capture LSN of write: %w", err))
+		return
+	}
+	if lsn.GreaterThan(lsnCtx.RequiredLSN) {
+		lsnCtx.RequiredLSN = lsn
+	}
+	db.hooks.lsnUpdate(lsn)
+}
@@ -0,0 +1,74 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestInsertReturningID(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	var lsnUpdates []LSN
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithRoutingHooks(nil, nil, func(lsn LSN) { lsnUpdates = append(lsnUpdates, lsn) }),
+	)
+
+	// a CTE is classified as a read by the query type checker, the helper routes it to the primary anyway
+	query := "WITH u AS (SELECT 1) INSERT INTO users (name) SELECT $1 FROM u"
+	primaryMock.ExpectQuery(regexp.QuoteMeta(query + " RETURNING id")).WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	expectCurrentWALLSN(primaryMock, "0/3000000")
+
+	lsnCtx := &LSNContext{}
+	id, err := db.InsertReturningID(WithLSNContext(context.Background(), lsnCtx), query, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Errorf("want id 42, got %d", id)
+	}
+	if lsnCtx.RequiredLSN != (LSN{Lower: 0x3000000}) || !lsnCtx.HasWriteOperation {
+		t.Errorf("want the LSN of the write captured, got %+v", lsnCtx)
+	}
+	if len(lsnUpdates) != 1 {
+		t.Errorf("want the LSN update hook called, got %v", lsnUpdates)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecReturningID(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	query := "UPDATE users SET active = false WHERE team = $1 RETURNING user_id"
+	primaryMock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3).AddRow(5))
+
+	// without LSN context, the LSN isn't captured
+	result, err := db.ExecReturningID(context.Background(), query, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := result.LastInsertId(); id != 3 {
+		t.Errorf("want the id of the first row, got %d", id)
+	}
+	if n, _ := result.RowsAffected(); n != 2 {
+		t.Errorf("want 2 rows affected, got %d", n)
+	}
+
+	primaryMock.ExpectQuery("INSERT INTO users").WillReturnError(errors.New("duplicate key"))
+	if _, err := db.ExecReturningID(context.Background(), "INSERT INTO users (name) VALUES ('bob')"); err == nil {
+		t.Error("want the write error")
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
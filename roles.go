@@ -0,0 +1,140 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// ErrRoleMismatch is wrapped by the errors of VerifyRoles for the primaries in recovery and the replicas
+// that aren't
+var ErrRoleMismatch = errors.New("dbresolver: database role mismatch")
+
+// roleVerifier rechecks the role of every database and reports its transitions
+type roleVerifier struct {
+	interval time.Duration
+	onEvent  func(HealthEvent)
+
+	mu         sync.Mutex
+	mismatched map[*sql.DB]bool
+}
+
+// WithRoleVerification rechecks every interval that each primary is read/write and each replica is in
+// recovery with pg_is_in_recovery(), e.g. to detect a node whose role flipped after a failover. Mismatches
+// are sent to the health callback as HealthEventRoleMismatch, and HealthEventRoleRestored once resolved.
+// Use VerifyRoles to fail fast on a misconfiguration at startup.
+func WithRoleVerification(interval time.Duration) OptionFunc {
+	return func(opt *Option) {
+		opt.RoleVerificationInterval = interval
+	}
+}
+
+// VerifyRoles checks that each primary is read/write and each replica, including the resource class
+// replicas, is in recovery. The databases in the wrong role are reported as errors wrapping
+// ErrRoleMismatch, combined with the errors of the databases that couldn't be checked.
+func (db *DB) VerifyRoles(ctx context.Context) error {
+	if err := db.checkOpen(); err != nil {
+		return err
+	}
+	var errs error
+	for _, check := range db.checkRoles(ctx) {
+		if check.err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%s: %w", physicalDBName(db, check.db), check.err))
+		}
+	}
+	return errs
+}
+
+// roleCheck is the outcome of the role check of a database, err wrapping ErrRoleMismatch for a mismatch
+type roleCheck struct {
+	db  *sql.DB
+	err error
+}
+
+// checkRoles checks the role of every database concurrently
+func (db *DB) checkRoles(ctx context.Context) []roleCheck {
+	primaries := db.allPrimaries()
+	dbs := append(append(append([]*sql.DB(nil), primaries...), db.allReplicas()...), db.resourceClassReplicaDBs()...)
+	checks := make([]roleCheck, len(dbs))
+	_ = doParallely(len(dbs), func(i int) error {
+		checks[i] = roleCheck{db: dbs[i], err: checkRole(ctx, dbs[i], i < len(primaries))}
+		return nil
+	})
+	return checks
+}
+
+// checkRole checks that target is in recovery, unless it's a primary
+func checkRole(ctx context.Context, target *sql.DB, primary bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultHealthCheckTimeout)
+	defer cancel()
+
+	var inRecovery bool
+	if err := target.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery); err != nil {
+		return fmt.Errorf("failed to check role: %w", err)
+	}
+	switch {
+	case primary && inRecovery:
+		return fmt.Errorf("primary is in recovery: %w", ErrRoleMismatch)
+	case !primary && !inRecovery:
+		return fmt.Errorf("replica is not in recovery: %w", ErrRoleMismatch)
+	}
+	return nil
+}
+
+// runRoleVerification checks the roles right away, then every interval
+func (db *DB) runRoleVerification(stop <-chan struct{}) {
+	ticker := time.NewTicker(db.roles.interval)
+	defer ticker.Stop()
+
+	for {
+		db.recheckRoles()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recheckRoles checks the roles and reports the mismatches appearing and resolved since the last check.
+// Databases that couldn't be checked keep their previous state.
+func (db *DB) recheckRoles() {
+	r := db.roles
+	for _, check := range db.checkRoles(context.Background()) {
+		mismatch := errors.Is(check.err, ErrRoleMismatch)
+		if check.err != nil && !mismatch {
+			continue
+		}
+		r.mu.Lock()
+		was := r.mismatched[check.db]
+		r.mismatched[check.db] = mismatch
+		r.mu.Unlock()
+
+		name := physicalDBName(db, check.db)
+		switch {
+		case mismatch && !was:
+			db.log().Warn("role verification: role mismatch", "db", name, "error", check.err)
+			r.notify(HealthEvent{Type: HealthEventRoleMismatch, DB: name, Err: check.err})
+		case !mismatch && was:
+			r.notify(HealthEvent{Type: HealthEventRoleRestored, DB: name})
+		}
+	}
+}
+
+// forget drops the state of a database removed from the resolver
+func (r *roleVerifier) forget(target *sql.DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.mismatched, target)
+}
+
+func (r *roleVerifier) notify(event HealthEvent) {
+	if r.onEvent != nil {
+		r.onEvent(event)
+	}
+}
@@ -0,0 +1,76 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/multierr"
+)
+
+func expectInRecovery(mock sqlmock.Sqlmock, inRecovery bool) {
+	mock.ExpectQuery("SELECT pg_is_in_recovery()").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_is_in_recovery"}).AddRow(inRecovery))
+}
+
+func TestVerifyRoles(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	down, downMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica, down))
+
+	expectInRecovery(primaryMock, false)
+	expectInRecovery(replicaMock, true)
+	expectInRecovery(downMock, true)
+	if err := db.VerifyRoles(context.Background()); err != nil {
+		t.Fatalf("want the roles verified, got %v", err)
+	}
+
+	// the replica was promoted and the primary demoted, while the last replica is unreachable
+	expectInRecovery(primaryMock, true)
+	expectInRecovery(replicaMock, false)
+	downMock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnError(errConnReset)
+	err := db.VerifyRoles(context.Background())
+	if errs := multierr.Errors(err); len(errs) != 3 {
+		t.Fatalf("want 3 errors, got %v", err)
+	}
+	mismatches := 0
+	for _, err := range multierr.Errors(err) {
+		if errors.Is(err, ErrRoleMismatch) {
+			mismatches++
+		}
+	}
+	if mismatches != 2 {
+		t.Errorf("want the primary and the replica mismatched, got %v", err)
+	}
+}
+
+func TestRoleVerificationEvents(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	var events []HealthEvent
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	db.roles = &roleVerifier{onEvent: func(e HealthEvent) { events = append(events, e) }, mismatched: make(map[*sql.DB]bool)}
+
+	expectInRecovery(primaryMock, false)
+	expectInRecovery(replicaMock, false)
+	expectInRecovery(primaryMock, false)
+	expectInRecovery(replicaMock, false)
+	expectInRecovery(primaryMock, false)
+	replicaMock.ExpectQuery("SELECT pg_is_in_recovery()").WillReturnError(errConnReset)
+	expectInRecovery(primaryMock, false)
+	expectInRecovery(replicaMock, true)
+
+	db.recheckRoles()
+	db.recheckRoles()
+	db.recheckRoles()
+	if len(events) != 1 || events[0].Type != HealthEventRoleMismatch || events[0].DB != "replica-0" {
+		t.Fatalf("want a single mismatch event for replica-0, got %+v", events)
+	}
+	db.recheckRoles()
+	if len(events) != 2 || events[1].Type != HealthEventRoleRestored {
+		t.Errorf("want the role restored, got %+v", events)
+	}
+}
@@ -0,0 +1,47 @@
+package dbresolver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteConsistencyRule sets the consistency level of the requests it matches, see WithRouteConsistency
+type RouteConsistencyRule struct {
+	Method     string // Method of the requests, empty matches any
+	PathPrefix string // Prefix of the URL path of the requests, e.g. "/reports/"; empty matches any
+	Level      CausalConsistencyLevel
+}
+
+// matches reports whether the rule applies to r
+func (rule RouteConsistencyRule) matches(r *http.Request) bool {
+	return (rule.Method == "" || strings.EqualFold(rule.Method, r.Method)) && strings.HasPrefix(r.URL.Path, rule.PathPrefix)
+}
+
+// WithRouteConsistency routes the reads of the requests matching a rule at its level instead of
+// CausalConsistencyConfig.Level, so one global level doesn't send every read of the application to the primary:
+// e.g. NoneCausalConsistency for "/reports/", ReadYourWrites for "/account/" and StrongConsistency for POST requests.
+// The first matching rule applies; requests matching none keep the configured level. Writes of every request
+// still set the LSN cookie.
+func WithRouteConsistency(rules ...RouteConsistencyRule) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.routeLevels = append(m.routeLevels, rules...)
+	}
+}
+
+// routeLevel returns the level of the first rule matching r
+func (m *HTTPMiddleware) routeLevel(r *http.Request) (CausalConsistencyLevel, bool) {
+	for _, rule := range m.routeLevels {
+		if rule.matches(r) {
+			return rule.Level, true
+		}
+	}
+	return 0, false
+}
+
+// level returns the consistency level of the reads of a request, overridden by its LSN context
+func (r *CausalRouter) level(lsnCtx *LSNContext) CausalConsistencyLevel {
+	if lsnCtx != nil && lsnCtx.OverrideLevel {
+		return lsnCtx.Level
+	}
+	return r.config.Level
+}
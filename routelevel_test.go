@@ -0,0 +1,59 @@
+package dbresolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestHTTPMiddlewareRouteConsistency(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	config := &CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithCausalConsistencyConfig(config))
+	middleware := NewHTTPMiddleware(NewCausalRouter(db, config), "", 0, false, WithRouteConsistency(
+		RouteConsistencyRule{PathPrefix: "/reports/", Level: NoneCausalConsistency},
+		RouteConsistencyRule{Method: http.MethodPost, Level: StrongConsistency},
+	))
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var name string
+		if err := db.QueryRowContext(r.Context(), "SELECT name FROM users").Scan(&name); err != nil {
+			t.Errorf("read of %s %s failed: %v", r.Method, r.URL.Path, err)
+		}
+		_, _ = w.Write([]byte(name))
+	}))
+
+	rows := func(name string) *sqlmock.Rows { return sqlmock.NewRows([]string{"name"}).AddRow(name) }
+	for _, tt := range []struct {
+		method, path string
+		expect       func()
+		want         string
+	}{
+		// the cookie LSN is ignored without checking the replica
+		{http.MethodGet, "/reports/daily", func() { replicaMock.ExpectQuery("SELECT name").WillReturnRows(rows("replica")) }, "replica"},
+		// the configured level applies, the lagging replica is skipped
+		{http.MethodGet, "/account/me", func() {
+			expectReplayLSN(replicaMock, "0/100")
+			primaryMock.ExpectQuery("SELECT name").WillReturnRows(rows("primary"))
+		}, "primary"},
+		{http.MethodPost, "/reports/daily", func() { replicaMock.ExpectQuery("SELECT name").WillReturnRows(rows("replica")) }, "replica"},
+		{http.MethodPost, "/orders", func() { primaryMock.ExpectQuery("SELECT name").WillReturnRows(rows("primary")) }, "primary"},
+	} {
+		tt.expect()
+		req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "pg_min_lsn", Value: "0/1000"})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("want %s %s served by the %s, got %q", tt.method, tt.path, tt.want, got)
+		}
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
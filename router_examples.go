@@ -4,23 +4,22 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"math/rand"
-	"time"
+	"math/rand/v2"
+	"sync/atomic"
 )
 
 // RandomRouter implements QueryRouter with random database selection
 // This demonstrates how the QueryRouter interface enables the Open-Closed Principle:
 // We can add new routing strategies without modifying existing code.
+// It is safe for concurrent use.
 type RandomRouter struct {
 	dbProvider DBProvider
-	rand       *rand.Rand
 }
 
 // NewRandomRouter creates a new router that randomly selects databases
 func NewRandomRouter(dbProvider DBProvider) *RandomRouter {
 	return &RandomRouter{
 		dbProvider: dbProvider,
-		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
@@ -40,7 +39,7 @@ func (r *RandomRouter) RouteQuery(_ context.Context, queryType QueryType) (*sql.
 	switch queryType {
 	case QueryTypeWrite:
 		// For writes, randomly select from primaries
-		selected := primaries[r.rand.Intn(len(primaries))]
+		selected := primaries[rand.IntN(len(primaries))]
 		return selected, nil
 
 	case QueryTypeRead:
@@ -48,12 +47,12 @@ func (r *RandomRouter) RouteQuery(_ context.Context, queryType QueryType) (*sql.
 		allDBs := make([]*sql.DB, 0, len(primaries)+len(replicas))
 		allDBs = append(allDBs, primaries...)
 		allDBs = append(allDBs, replicas...)
-		selected := allDBs[r.rand.Intn(len(allDBs))]
+		selected := allDBs[rand.IntN(len(allDBs))]
 		return selected, nil
 
 	default:
 		// Default to primary for unknown query types
-		selected := primaries[r.rand.Intn(len(primaries))]
+		selected := primaries[rand.IntN(len(primaries))]
 		return selected, nil
 	}
 }
@@ -64,19 +63,18 @@ func (r *RandomRouter) UpdateLSNAfterWrite(_ context.Context) (LSN, error) {
 	return LSN{}, nil
 }
 
-// RoundRobinRouter implements QueryRouter with round-robin database selection
+// RoundRobinRouter implements QueryRouter with round-robin database selection.
+// It is safe for concurrent use.
 type RoundRobinRouter struct {
 	dbProvider     DBProvider
-	primariesIndex int
-	replicasIndex  int
+	primariesIndex atomic.Uint64
+	replicasIndex  atomic.Uint64
 }
 
 // NewRoundRobinRouter creates a new router that uses round-robin selection
 func NewRoundRobinRouter(dbProvider DBProvider) *RoundRobinRouter {
 	return &RoundRobinRouter{
-		dbProvider:     dbProvider,
-		primariesIndex: 0,
-		replicasIndex:  0,
+		dbProvider: dbProvider,
 	}
 }
 
@@ -96,30 +94,27 @@ func (r *RoundRobinRouter) RouteQuery(_ context.Context, queryType QueryType) (*
 	switch queryType {
 	case QueryTypeWrite:
 		// For writes, use round-robin on primaries
-		selected := primaries[r.primariesIndex%len(primaries)]
-		r.primariesIndex++
-		return selected, nil
+		return nextRoundRobin(&r.primariesIndex, primaries), nil
 
 	case QueryTypeRead:
 		// For reads, use round-robin on replicas if available, otherwise primaries
 		if len(replicas) > 0 {
-			selected := replicas[r.replicasIndex%len(replicas)]
-			r.replicasIndex++
-			return selected, nil
+			return nextRoundRobin(&r.replicasIndex, replicas), nil
 		}
 		// Fallback to primaries if no replicas
-		selected := primaries[r.primariesIndex%len(primaries)]
-		r.primariesIndex++
-		return selected, nil
+		return nextRoundRobin(&r.primariesIndex, primaries), nil
 
 	default:
 		// Default to primary for unknown query types
-		selected := primaries[r.primariesIndex%len(primaries)]
-		r.primariesIndex++
-		return selected, nil
+		return nextRoundRobin(&r.primariesIndex, primaries), nil
 	}
 }
 
+// nextRoundRobin returns the next of dbs, advancing index atomically
+func nextRoundRobin(index *atomic.Uint64, dbs []*sql.DB) *sql.DB {
+	return dbs[(index.Add(1)-1)%uint64(len(dbs))]
+}
+
 // UpdateLSNAfterWrite is a no-op for RoundRobinRouter since it doesn't track LSN
 func (r *RoundRobinRouter) UpdateLSNAfterWrite(_ context.Context) (LSN, error) {
 	// Round-robin router doesn't track LSN, return zero LSN
@@ -0,0 +1,74 @@
+package dbresolver
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+)
+
+// Savepoint creates the savepoint name in the transaction, see SavepointContext
+func (t *tx) Savepoint(name string) error {
+	return t.SavepointContext(context.Background(), name)
+}
+
+// SavepointContext creates the savepoint name in the transaction, to which RollbackTo undoes the statements run
+// after it without aborting the transaction. The name is quoted, so it's case-sensitive.
+func (t *tx) SavepointContext(ctx context.Context, name string) error {
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to create savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// RollbackTo undoes the statements run after the savepoint name, see RollbackToContext
+func (t *tx) RollbackTo(name string) error {
+	return t.RollbackToContext(context.Background(), name)
+}
+
+// RollbackToContext undoes the statements run after the savepoint name, which is kept, and recovers the
+// transaction from their errors
+func (t *tx) RollbackToContext(ctx context.Context, name string) error {
+	if _, err := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to roll back to savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReleaseSavepoint destroys the savepoint name, see ReleaseSavepointContext
+func (t *tx) ReleaseSavepoint(name string) error {
+	return t.ReleaseSavepointContext(context.Background(), name)
+}
+
+// ReleaseSavepointContext destroys the savepoint name and the savepoints created after it, keeping the effects
+// of their statements
+func (t *tx) ReleaseSavepointContext(ctx context.Context, name string) error {
+	if _, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to release savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// Nested emulates a nested transaction within a savepoint: fn runs with the transaction, and the statements it
+// ran are undone if it returns an error or panics, leaving the outer transaction usable, or kept otherwise.
+// Nested calls nest their savepoints. The error of fn is returned.
+func (t *tx) Nested(ctx context.Context, fn func(tx Tx) error) (err error) {
+	t.savepoints++
+	name := fmt.Sprintf("dbresolver_nested_%d", t.savepoints)
+	if err := t.SavepointContext(ctx, name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = t.RollbackToContext(ctx, name)
+			panic(p)
+		}
+		if err != nil {
+			err = multierr.Append(err, t.RollbackToContext(ctx, name))
+			return
+		}
+		err = t.ReleaseSavepointContext(ctx, name)
+	}()
+	return fn(t)
+}
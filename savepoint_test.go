@@ -0,0 +1,103 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTxSavepoints(t *testing.T) {
+	primary, mock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT "before_items"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO items").WillReturnError(errors.New("duplicate key"))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT "before_items"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT "before_items"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Savepoint("before_items"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO items VALUES (1)"); err == nil {
+		t.Fatal("want the insert to fail")
+	}
+	if err := tx.RollbackTo("before_items"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.ReleaseSavepoint("before_items"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxNested(t *testing.T) {
+	primary, mock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	ctx := context.Background()
+	errInvalid := errors.New("invalid item")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT "dbresolver_nested_1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SAVEPOINT "dbresolver_nested_2"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO items").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT "dbresolver_nested_2"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT "dbresolver_nested_1"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT "dbresolver_nested_3"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT "dbresolver_nested_3"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Nested(ctx, func(tx Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO orders VALUES (1)"); err != nil {
+			return err
+		}
+		// the failed inner transaction is undone, the outer one carries on
+		if err := tx.Nested(ctx, func(tx Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO items VALUES (1)"); err != nil {
+				return err
+			}
+			return errInvalid
+		}); !errors.Is(err, errInvalid) {
+			t.Errorf("want the error of the inner transaction, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("want the panic of the nested transaction propagated")
+			}
+		}()
+		_ = tx.Nested(ctx, func(Tx) error { panic("boom") })
+	}()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,70 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+const searchPathKey contextKey = "search_path"
+
+// searchPath is the list of schemas of a request, see WithSearchPath
+type searchPath []string
+
+// String returns the search_path setting, every schema quoted
+func (p searchPath) String() string {
+	quoted := make([]string, len(p))
+	for i, schema := range p {
+		quoted[i] = `"` + strings.ReplaceAll(schema, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// WithSearchPath returns a context whose queries run with search_path set to schemas, e.g. the schema of a tenant,
+// on the primary and on the replicas alike. Transactions begun with it set the search_path for the transaction
+// only. Queries outside of a transaction set it on the connection they run on; the queries of other contexts
+// reset it on the connections of the databases a search_path was set on.
+// Statements prepared with Prepare and queries issued directly on the physical databases don't apply it.
+func WithSearchPath(ctx context.Context, schemas ...string) context.Context {
+	return context.WithValue(ctx, searchPathKey, searchPath(slices.Clone(schemas)))
+}
+
+// GetSearchPath returns the schemas set with WithSearchPath, if any
+func GetSearchPath(ctx context.Context) ([]string, bool) {
+	path, ok := ctx.Value(searchPathKey).(searchPath)
+	return slices.Clone(path), ok
+}
+
+// usesSearchPath reports whether queries of ctx on target must run on a connection whose search_path is set first
+func (db *DB) usesSearchPath(ctx context.Context, target *sql.DB) bool {
+	if _, ok := ctx.Value(searchPathKey).(searchPath); ok {
+		return true
+	}
+	_, dirty := db.searchPaths.Load(target)
+	return dirty
+}
+
+// applySearchPath sets the search_path of ctx on conn, a connection of target, or resets it when a connection of
+// target may have been left on the search_path of another request
+func (db *DB) applySearchPath(ctx context.Context, target *sql.DB, conn querier) error {
+	if path, ok := ctx.Value(searchPathKey).(searchPath); ok {
+		db.searchPaths.Store(target, struct{}{})
+		_, err := conn.ExecContext(ctx, "SELECT set_config('search_path', $1, false)", path.String())
+		return err
+	}
+	if _, dirty := db.searchPaths.Load(target); dirty {
+		_, err := conn.ExecContext(ctx, "RESET search_path")
+		return err
+	}
+	return nil
+}
+
+// applyTxSearchPath sets the search_path of ctx for the transaction stx on target
+func (db *DB) applyTxSearchPath(ctx context.Context, target *sql.DB, stx *sql.Tx) error {
+	if path, ok := ctx.Value(searchPathKey).(searchPath); ok {
+		_, err := stx.ExecContext(ctx, "SELECT set_config('search_path', $1, true)", path.String())
+		return err
+	}
+	return db.applySearchPath(ctx, target, stx)
+}
@@ -0,0 +1,79 @@
+package dbresolver
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSearchPath(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	tenantCtx := WithSearchPath(context.Background(), "tenant_42", `odd"name`)
+	const want = `"tenant_42", "odd""name"`
+
+	if path, ok := GetSearchPath(tenantCtx); !ok || len(path) != 2 || path[0] != "tenant_42" {
+		t.Errorf("unexpected search path %v", path)
+	}
+
+	sessionSet := regexp.QuoteMeta("SELECT set_config('search_path', $1, false)")
+	replicaMock.ExpectExec(sessionSet).WithArgs(want).WillReturnResult(sqlmock.NewResult(0, 0))
+	replicaMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	var name string
+	if err := db.QueryRowContext(tenantCtx, "SELECT name FROM users").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryMock.ExpectExec(sessionSet).WithArgs(want).WillReturnResult(sqlmock.NewResult(0, 0))
+	primaryMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, err := db.ExecContext(tenantCtx, "UPDATE users SET name = 'bob'"); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(regexp.QuoteMeta("SELECT set_config('search_path', $1, true)")).WithArgs(want).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	primaryMock.ExpectCommit()
+	tx, err := db.BeginTx(tenantCtx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the connections may still be on the tenant search_path
+	replicaMock.ExpectExec("RESET search_path").WillReturnResult(sqlmock.NewResult(0, 0))
+	replicaMock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rows, err := db.QueryContext(context.Background(), "SELECT count(*) FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSearchPathUnusedDatabases(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+
+	replicaMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	var n int
+	if err := db.QueryRowContext(context.Background(), "SELECT 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// selfTestCanary is the canary write of SelfTest: a WAL message that doesn't touch any table
+const selfTestCanary = "SELECT pg_logical_emit_message(false, 'dbresolver_selftest', 'canary')"
+
+// defaultSelfTestTimeout bounds SelfTest when its context has no deadline
+const defaultSelfTestTimeout = 10 * time.Second
+
+// Names of the steps of SelfTest. Replica steps are named "replica:<name>".
+const (
+	SelfTestStepCausalConsistency = "causal_consistency"
+	SelfTestStepCanaryWrite       = "canary_write"
+	SelfTestStepCookieRoundTrip   = "cookie_round_trip"
+	SelfTestStepRouting           = "routing"
+)
+
+// SelfTestStep is the outcome of a step of SelfTest
+type SelfTestStep struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Duration time.Duration `json:"duration"`
+	Detail   string        `json:"detail,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SelfTestReport is the outcome of SelfTest, e.g. to serve as a readiness probe response
+type SelfTestReport struct {
+	OK       bool           `json:"ok"`
+	LSN      string         `json:"lsn,omitempty"` // LSN captured after the canary write
+	Duration time.Duration  `json:"duration"`
+	Steps    []SelfTestStep `json:"steps"`
+}
+
+// SelfTest verifies read-your-writes end to end, e.g. at startup before reporting ready: it performs a canary
+// write on the primary, captures its LSN and round-trips it through the LSN cookie of an in-process
+// HTTPMiddleware, then waits for every replica to catch up and checks that the router routes the reads
+// requiring the LSN to a caught-up database. The canary emits a logical decoding message and doesn't
+// touch any table. The test is bounded by the deadline of ctx, 10s without one.
+func (db *DB) SelfTest(ctx context.Context) *SelfTestReport {
+	start := time.Now()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultSelfTestTimeout)
+		defer cancel()
+	}
+
+	report := &SelfTestReport{OK: true}
+	defer func() { report.Duration = time.Since(start) }()
+
+	router, ok := db.queryRouter.(*CausalRouter)
+	if !ok || !router.IsCausalConsistencyEnabled() {
+		report.add(SelfTestStep{Name: SelfTestStepCausalConsistency, Error: "causal consistency is not enabled"})
+		return report
+	}
+	middleware := NewHTTPMiddleware(router, "", 0, false)
+
+	lsn, cookies := db.selfTestWrite(ctx, middleware, report)
+	if lsn.IsZero() {
+		return report
+	}
+	report.LSN = lsn.String()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		stepStart := time.Now()
+		step := SelfTestStep{Name: SelfTestStepCookieRoundTrip}
+		if lsnCtx := GetLSNContext(r.Context()); lsnCtx == nil || lsnCtx.RequiredLSN != lsn {
+			step.Error = "the LSN cookie didn't carry the LSN of the canary write"
+			report.add(step.done(stepStart))
+			return
+		}
+		step.OK = true
+		report.add(step.done(stepStart))
+
+		db.selfTestReplicas(r.Context(), router, lsn, report)
+		db.selfTestRouting(r.Context(), lsn, report)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	return report
+}
+
+// selfTestWrite performs the canary write through the middleware and returns its LSN, zero on failure,
+// and the cookies of the response
+func (db *DB) selfTestWrite(ctx context.Context, middleware *HTTPMiddleware, report *SelfTestReport) (LSN, []*http.Cookie) {
+	start := time.Now()
+	step := SelfTestStep{Name: SelfTestStepCanaryWrite}
+	var lsn LSN
+	var err error
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+	middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err = db.selfTestCanary(r.Context()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		lsn = GetLSNContext(r.Context()).RequiredLSN
+	})).ServeHTTP(rec, req)
+
+	switch {
+	case err != nil:
+		step.Error = err.Error()
+	case lsn.IsZero():
+		step.Error = "the LSN of the canary write wasn't captured"
+	default:
+		step.OK = true
+		step.Detail = "captured LSN " + lsn.String()
+	}
+	report.add(step.done(start))
+	return lsn, rec.Result().Cookies()
+}
+
+// selfTestCanary runs the canary write on the database chosen by the router, or the write forwarder
+func (db *DB) selfTestCanary(ctx context.Context) error {
+	if db.forwarder != nil {
+		_, err := db.forwardExec(ctx, selfTestCanary)
+		return err
+	}
+	primary, err := db.queryRouter.RouteQuery(ctx, QueryTypeWrite)
+	if err != nil {
+		return fmt.Errorf("failed to route the canary write: %w", err)
+	}
+	if _, err := primary.ExecContext(ctx, selfTestCanary); err != nil {
+		return fmt.Errorf("canary write failed: %w", err)
+	}
+	return nil
+}
+
+// selfTestReplicas waits, concurrently, for every replica to be considered caught up to lsn by the router
+func (db *DB) selfTestReplicas(ctx context.Context, router *CausalRouter, lsn LSN, report *SelfTestReport) {
+	replicas := append(append([]*sql.DB(nil), db.allReplicas()...), db.resourceClassReplicaDBs()...)
+	poll := router.config.ReplicaWaitPoll
+	if poll <= 0 {
+		poll = defaultReplicaWaitPoll
+	}
+
+	steps := make([]SelfTestStep, len(replicas))
+	_ = doParallely(len(replicas), func(i int) error {
+		start := time.Now()
+		step := SelfTestStep{Name: "replica:" + physicalDBName(db, replicas[i])}
+		if err := waitCaughtUp(ctx, router, replicas[i], lsn, poll); err != nil {
+			step.Error = err.Error()
+		} else {
+			step.OK = true
+			step.Detail = "caught up in " + time.Since(start).String()
+		}
+		steps[i] = step.done(start)
+		return nil
+	})
+	for _, step := range steps {
+		report.add(step)
+	}
+}
+
+// waitCaughtUp polls replica until the router considers it caught up to lsn
+func waitCaughtUp(ctx context.Context, router *CausalRouter, replica *sql.DB, lsn LSN, poll time.Duration) error {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		if caughtUp, _ := router.caughtUpReplica(ctx, []*sql.DB{replica}, lsn); caughtUp {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("replica didn't catch up to %s: %w", lsn, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// selfTestRouting checks that a read requiring lsn is routed to the primary or a caught-up replica
+func (db *DB) selfTestRouting(ctx context.Context, lsn LSN, report *SelfTestReport) {
+	start := time.Now()
+	step := SelfTestStep{Name: SelfTestStepRouting}
+	e, err := db.ExplainRoute(ctx, "SELECT 1")
+	switch {
+	case err != nil:
+		step.Error = err.Error()
+	case !e.Primary && (e.TargetLSN == nil || e.TargetLSN.LessThan(lsn)):
+		step.Error = fmt.Sprintf("read requiring %s routed to %s, which hasn't replayed it", lsn, e.Target)
+		if e.TargetLSNErr != nil {
+			step.Error += ": " + e.TargetLSNErr.Error()
+		}
+	default:
+		step.OK = true
+		step.Detail = "routed to " + e.Target + " (" + e.Reason + ")"
+	}
+	report.add(step.done(start))
+}
+
+func (s SelfTestStep) done(start time.Time) SelfTestStep {
+	s.Duration = time.Since(start)
+	return s
+}
+
+func (r *SelfTestReport) add(step SelfTestStep) {
+	r.Steps = append(r.Steps, step)
+	r.OK = r.OK && step.OK
+}
+
+// Err returns an error listing the failed steps of the report, nil if every step succeeded
+func (r *SelfTestReport) Err() error {
+	var err error
+	for _, step := range r.Steps {
+		if !step.OK {
+			err = multierr.Append(err, fmt.Errorf("%s: %s", step.Name, step.Error))
+		}
+	}
+	return err
+}
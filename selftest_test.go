@@ -0,0 +1,81 @@
+package dbresolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSelfTest(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithCausalConsistencyLevel(ReadYourWrites))
+
+	primaryMock.ExpectExec("pg_logical_emit_message").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCurrentWALLSN(primaryMock, "0/3000060")
+	expectReplayLSN(replicaMock, "0/3000000")
+	expectReplayLSN(replicaMock, "0/3000060")
+	expectReplayLSN(replicaMock, "0/3000060")
+	expectReplayLSN(replicaMock, "0/3000060")
+
+	report := db.SelfTest(context.Background())
+	if !report.OK || report.Err() != nil {
+		t.Fatalf("want a successful self-test, got %+v", report)
+	}
+	if report.LSN != "0/3000060" {
+		t.Errorf("want the LSN of the canary write, got %s", report.LSN)
+	}
+	names := []string{SelfTestStepCanaryWrite, SelfTestStepCookieRoundTrip, "replica:replica-0", SelfTestStepRouting}
+	if len(report.Steps) != len(names) {
+		t.Fatalf("want %d steps, got %+v", len(names), report.Steps)
+	}
+	for i, step := range report.Steps {
+		if step.Name != names[i] {
+			t.Errorf("step %d: want %s, got %s", i, names[i], step.Name)
+		}
+	}
+	if detail := report.Steps[3].Detail; detail != "routed to replica-0 ("+ReasonReplicaCaughtUp+")" {
+		t.Errorf("want the read routed to the caught-up replica, got %q", detail)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSelfTestLaggingReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithCausalConsistencyLevel(ReadYourWrites))
+
+	primaryMock.ExpectExec("pg_logical_emit_message").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCurrentWALLSN(primaryMock, "0/3000060")
+	for range 100 {
+		expectReplayLSN(replicaMock, "0/100")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report := db.SelfTest(ctx)
+	if report.OK || report.Err() == nil {
+		t.Fatalf("want the lagging replica reported, got %+v", report)
+	}
+	if step := report.Steps[2]; step.Name != "replica:replica-0" || step.OK || step.Error == "" {
+		t.Errorf("want the replica step failed, got %+v", step)
+	}
+}
+
+func TestSelfTestWithoutCausalConsistency(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	report := db.SelfTest(context.Background())
+	if report.OK || len(report.Steps) != 1 || report.Steps[0].Name != SelfTestStepCausalConsistency {
+		t.Errorf("want the self-test to fail without causal consistency, got %+v", report)
+	}
+}
@@ -0,0 +1,96 @@
+package dbresolver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionLSNStore persists the LSN of the last write per session on the server side.
+// It is used by the HTTP middleware instead of the LSN cookie when clients can't carry cookies reliably.
+type SessionLSNStore interface {
+	// Get returns the LSN stored for the session key; the boolean is false if none is stored
+	Get(ctx context.Context, sessionKey string) (LSN, bool, error)
+	// Set stores the LSN for the session key for the given duration.
+	// Implementations should never replace a stored LSN with a smaller one.
+	Set(ctx context.Context, sessionKey string, lsn LSN, ttl time.Duration) error
+}
+
+// SessionKeyFunc extracts the session key (session ID, user ID, ...) from a request.
+// An empty key disables LSN tracking for the request.
+type SessionKeyFunc func(r *http.Request) string
+
+// SessionKeyFromCookie uses the value of an existing cookie, such as the application's session cookie, as session key
+func SessionKeyFromCookie(cookieName string) SessionKeyFunc {
+	return func(r *http.Request) string {
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			return cookie.Value
+		}
+		return ""
+	}
+}
+
+// SessionKeyFromHeader uses the value of a request header, such as X-User-ID, as session key
+func SessionKeyFromHeader(headerName string) SessionKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(headerName)
+	}
+}
+
+// WithSessionStore switches the middleware from cookie based LSN tracking to a server-side
+// store keyed by the session key returned by keyFunc
+func WithSessionStore(store SessionLSNStore, keyFunc SessionKeyFunc) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.sessionStore = store
+		m.sessionKey = keyFunc
+	}
+}
+
+// MemorySessionLSNStore is an in-process SessionLSNStore.
+// It is only suitable for single instance deployments and tests, since the state isn't shared.
+type MemorySessionLSNStore struct {
+	mu      sync.Mutex
+	entries map[string]memorySessionEntry
+}
+
+type memorySessionEntry struct {
+	lsn       LSN
+	expiresAt time.Time
+}
+
+// NewMemorySessionLSNStore creates an empty in-process session LSN store
+func NewMemorySessionLSNStore() *MemorySessionLSNStore {
+	return &MemorySessionLSNStore{
+		entries: make(map[string]memorySessionEntry),
+	}
+}
+
+// Get returns the LSN stored for the session key if it hasn't expired
+func (s *MemorySessionLSNStore) Get(_ context.Context, sessionKey string) (LSN, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[sessionKey]
+	if !ok {
+		return LSN{}, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, sessionKey)
+		return LSN{}, false, nil
+	}
+	return entry.lsn, true, nil
+}
+
+// Set stores the LSN for the session key, keeping the stored LSN if it is greater
+func (s *MemorySessionLSNStore) Set(_ context.Context, sessionKey string, lsn LSN, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := s.entries[sessionKey]; ok && now.Before(entry.expiresAt) && entry.lsn.GreaterThan(lsn) {
+		lsn = entry.lsn
+	}
+	s.entries[sessionKey] = memorySessionEntry{lsn: lsn, expiresAt: now.Add(ttl)}
+	return nil
+}
@@ -0,0 +1,89 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fixedLSNRouter reports a fixed LSN after every write
+type fixedLSNRouter struct {
+	lsn LSN
+}
+
+func (r *fixedLSNRouter) RouteQuery(_ context.Context, _ QueryType) (*sql.DB, error) {
+	return nil, nil
+}
+
+func (r *fixedLSNRouter) UpdateLSNAfterWrite(_ context.Context) (LSN, error) {
+	return r.lsn, nil
+}
+
+func TestMemorySessionLSNStore(t *testing.T) {
+	store := NewMemorySessionLSNStore()
+	ctx := context.Background()
+
+	if _, ok, _ := store.Get(ctx, "user-1"); ok {
+		t.Fatal("expected no LSN for an unknown session")
+	}
+
+	_ = store.Set(ctx, "user-1", LSN{Lower: 0x2000}, time.Minute)
+	_ = store.Set(ctx, "user-1", LSN{Lower: 0x1000}, time.Minute)
+
+	lsn, ok, err := store.Get(ctx, "user-1")
+	if err != nil || !ok {
+		t.Fatalf("expected stored LSN, got ok=%v err=%v", ok, err)
+	}
+	if lsn != (LSN{Lower: 0x2000}) {
+		t.Errorf("stored LSN must never move backwards, got %s", lsn)
+	}
+
+	_ = store.Set(ctx, "user-2", LSN{Lower: 0x1000}, -time.Second)
+	if _, ok, _ := store.Get(ctx, "user-2"); ok {
+		t.Error("expected expired entry to be ignored")
+	}
+}
+
+func TestHTTPMiddlewareSessionStore(t *testing.T) {
+	store := NewMemorySessionLSNStore()
+	router := &fixedLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}}
+	middleware := NewHTTPMiddleware(router, "", 0, false,
+		WithSessionStore(store, SessionKeyFromHeader("X-User-ID")))
+
+	write := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("POST", "/orders", http.NoBody)
+	req.Header.Set("X-User-ID", "42")
+	rec := httptest.NewRecorder()
+	write.ServeHTTP(rec, req)
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("session store mode must not set LSN cookies")
+	}
+
+	read := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lsnCtx := GetLSNContext(r.Context())
+		if lsnCtx == nil || lsnCtx.RequiredLSN != router.lsn {
+			t.Errorf("expected required LSN %s from the session store, got %+v", router.lsn, lsnCtx)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req = httptest.NewRequest("GET", "/orders", http.NoBody)
+	req.Header.Set("X-User-ID", "42")
+	read.ServeHTTP(httptest.NewRecorder(), req)
+
+	anonymous := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lsnCtx := GetLSNContext(r.Context()); lsnCtx != nil && !lsnCtx.RequiredLSN.IsZero() {
+			t.Error("requests without a session key must not inherit an LSN requirement")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	anonymous.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", http.NoBody))
+}
@@ -0,0 +1,73 @@
+package dbresolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// SessionTracker tracks the LSN of the writes of a long-lived connection, such as a WebSocket or an SSE stream,
+// whose LSN cookie was set once at upgrade and goes stale as the connection writes. Each message of the
+// connection runs its queries with a context derived by Context, and reports its writes with Track, so the reads
+// of the following messages observe them. It is safe for concurrent use by the messages of a connection.
+type SessionTracker struct {
+	tracker LSNTracker
+
+	mu  sync.Mutex
+	lsn LSN
+}
+
+// NewSessionTracker tracks the writes of a connection with tracker, typically the *DB, starting from the LSN
+// required when the connection was established
+func NewSessionTracker(tracker LSNTracker, initial LSN) *SessionTracker {
+	return &SessionTracker{tracker: tracker, lsn: initial}
+}
+
+// NewSessionTracker tracks the writes of the connection upgraded by r, starting from the LSN required by its
+// cookie, with the router of the middleware
+func (m *HTTPMiddleware) NewSessionTracker(r *http.Request) *SessionTracker {
+	var initial LSN
+	if lsnCtx := GetLSNContext(r.Context()); lsnCtx != nil {
+		initial = lsnCtx.RequiredLSN
+	}
+	return NewSessionTracker(m.router, initial)
+}
+
+// Context derives the context of a message of the connection from ctx: its reads observe the writes of the
+// messages tracked before it
+func (s *SessionTracker) Context(ctx context.Context) context.Context {
+	return WithLSNContext(ctx, &LSNContext{RequiredLSN: s.LSN()})
+}
+
+// Track captures the LSN of the primary if the message run with ctx, derived by Context, wrote, and raises the
+// LSN of the connection to it. The LSN of the connection is returned, e.g. to send it to the client as a
+// consistency token.
+func (s *SessionTracker) Track(ctx context.Context) (LSN, error) {
+	if lsnCtx := GetLSNContext(ctx); lsnCtx == nil || !lsnCtx.HasWriteOperation {
+		return s.LSN(), nil
+	}
+	lsn, err := s.tracker.UpdateLSNAfterWrite(ctx)
+	if err != nil {
+		return s.LSN(), fmt.Errorf("failed to capture the LSN of the session write: %w", err)
+	}
+	return s.Observe(lsn), nil
+}
+
+// Observe raises the LSN of the connection to lsn, e.g. the LSN of a write made by another connection of the same
+// user, and returns the LSN of the connection. It is never lowered.
+func (s *SessionTracker) Observe(lsn LSN) LSN {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lsn.GreaterThan(s.lsn) {
+		s.lsn = lsn
+	}
+	return s.lsn
+}
+
+// LSN returns the LSN the reads of the connection must observe
+func (s *SessionTracker) LSN() LSN {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lsn
+}
@@ -0,0 +1,78 @@
+package dbresolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSessionTracker(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	session := NewSessionTracker(db, LSN{Lower: 0x1000})
+	conn := context.Background()
+
+	// a message writing raises the LSN of the connection
+	ctx := session.Context(conn)
+	primaryMock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCurrentWALLSN(primaryMock, "0/2000")
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = $1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if lsn, err := session.Track(ctx); err != nil || lsn != (LSN{Lower: 0x2000}) {
+		t.Fatalf("want the LSN of the write tracked, got %s, %v", lsn, err)
+	}
+
+	// the reads of the next messages observe it, on the primary until the replica caught up
+	for _, read := range []struct {
+		replayed string
+		mock     sqlmock.Sqlmock
+	}{{"0/1000", primaryMock}, {"0/3000", replicaMock}} {
+		ctx := session.Context(conn)
+		expectReplayLSN(replicaMock, read.replayed)
+		read.mock.ExpectQuery("SELECT name").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+		var name string
+		if err := db.QueryRowContext(ctx, "SELECT name FROM users").Scan(&name); err != nil || name != "alice" {
+			t.Errorf("want the read to observe the write, got %q, %v", name, err)
+		}
+		// reads don't move the LSN of the connection
+		if lsn, err := session.Track(ctx); err != nil || lsn != (LSN{Lower: 0x2000}) {
+			t.Errorf("want the LSN of the connection kept, got %s, %v", lsn, err)
+		}
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if lsn := session.Observe(LSN{Lower: 0x100}); lsn != (LSN{Lower: 0x2000}) {
+		t.Errorf("want the LSN of the connection never lowered, got %s", lsn)
+	}
+}
+
+func TestHTTPMiddlewareNewSessionTracker(t *testing.T) {
+	middleware := NewHTTPMiddleware(&fixedLSNRouter{lsn: LSN{Lower: 0x2000}}, "", time.Minute, false)
+	var session *SessionTracker
+	handler := middleware.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		session = middleware.NewSessionTracker(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/ws", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: "pg_min_lsn", Value: "0/1000"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if session.LSN() != (LSN{Lower: 0x1000}) {
+		t.Fatalf("want the session to start from the LSN of the cookie, got %s", session.LSN())
+	}
+
+	ctx := session.Context(context.Background())
+	GetLSNContext(ctx).HasWriteOperation = true
+	if lsn, err := session.Track(ctx); err != nil || lsn != (LSN{Lower: 0x2000}) {
+		t.Errorf("want the write tracked with the router of the middleware, got %s, %v", lsn, err)
+	}
+}
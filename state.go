@@ -0,0 +1,230 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// RoutingStateStore persists the serialized RoutingState of a resolver shared by short-lived processes, such as
+// CGI-style workers or Lambda invocations, see DB.ExportState and DB.ImportState
+type RoutingStateStore interface {
+	// Load returns the last saved state, nil if none is saved
+	Load(ctx context.Context) ([]byte, error)
+	Save(ctx context.Context, state []byte) error
+}
+
+// RoutingState is the warm state of a resolver: the health of the replicas, the LSNs last observed on the
+// databases and the query types cached with WithQueryTypeCache. Databases are identified by their names, see
+// WithNamedReplicaDBs, so the processes sharing a state must name them the same way.
+type RoutingState struct {
+	SavedAt    time.Time            `json:"saved_at"`
+	Databases  []DatabaseState      `json:"databases"`
+	QueryTypes map[string]QueryType `json:"query_types,omitempty"`
+}
+
+// DatabaseState is the warm state of a database, see RoutingState
+type DatabaseState struct {
+	Name       string    `json:"name"`
+	ReplayLSN  string    `json:"replay_lsn,omitempty"` // Last replay LSN observed on a replica
+	ReplayAt   time.Time `json:"replay_at,omitzero"`
+	WALLSN     string    `json:"wal_lsn,omitempty"` // Last current WAL LSN observed on a primary
+	WALAt      time.Time `json:"wal_at,omitzero"`
+	Checked    bool      `json:"checked,omitempty"` // Whether the health monitor checked the replica
+	Healthy    bool      `json:"healthy,omitempty"`
+	Evicted    bool      `json:"evicted,omitempty"`
+	LagBytes   int64     `json:"lag_bytes,omitempty"`
+	CheckedAt  time.Time `json:"checked_at,omitzero"`
+	Failures   int       `json:"failures,omitempty"`
+	ErrorCount int       `json:"error_count,omitempty"`
+}
+
+// State returns the warm state of db, see RoutingState
+func (db *DB) State() RoutingState {
+	state := RoutingState{SavedAt: time.Now()}
+	for _, target := range slices.Concat(db.allPrimaries(), db.allReplicas()) {
+		dbState := DatabaseState{Name: physicalDBName(db, target)}
+		if checker := lookupChecker(target); checker != nil {
+			if lsn, at, ok := checker.CachedReplayLSN(); ok {
+				dbState.ReplayLSN, dbState.ReplayAt = lsn.String(), at
+			}
+			if lsn, at, ok := checker.cachedWALLSN(); ok {
+				dbState.WALLSN, dbState.WALAt = lsn.String(), at
+			}
+		}
+		state.Databases = append(state.Databases, dbState)
+	}
+
+	if db.health != nil {
+		replicas := db.allReplicas()
+		db.health.mu.RLock()
+		for i, replica := range replicas {
+			if r, ok := db.health.replicas[replica]; ok {
+				dbState := &state.Databases[len(state.Databases)-len(replicas)+i]
+				dbState.Checked, dbState.Healthy, dbState.Evicted = true, r.status.IsHealthy, r.status.Evicted
+				dbState.LagBytes, dbState.CheckedAt = r.status.LagBytes, r.status.LastCheck
+				dbState.Failures, dbState.ErrorCount = r.failures, r.status.ErrorCount
+			}
+		}
+		db.health.mu.RUnlock()
+	}
+
+	if cache, ok := db.queryTypeChecker.(*CachedQueryTypeChecker); ok {
+		state.QueryTypes = cache.snapshot()
+	}
+	return state
+}
+
+// Restore warms db up with a state taken by another process with DB.State. The LSNs keep the time they were
+// observed and only replace older ones, the health is only restored on the replicas not checked yet, so the next
+// checks and queries correct a stale state. States older than maxAge are ignored, 0 restores any state.
+func (db *DB) Restore(state RoutingState, maxAge time.Duration) {
+	if maxAge > 0 && time.Since(state.SavedAt) > maxAge {
+		db.log().Debug("dbresolver: ignoring a stale routing state", "savedAt", state.SavedAt)
+		return
+	}
+	byName := make(map[string]*sql.DB)
+	for _, target := range slices.Concat(db.allPrimaries(), db.allReplicas()) {
+		byName[physicalDBName(db, target)] = target
+	}
+	replicas := db.allReplicas()
+	router, _ := db.queryRouter.(*CausalRouter)
+
+	restored := 0
+	for _, dbState := range state.Databases {
+		target, ok := byName[dbState.Name]
+		if !ok {
+			continue
+		}
+		restored++
+		checker := getOrCreateChecker(target, db.lsnQueryTimeout(router))
+		if lsn, err := ParseLSN(dbState.ReplayLSN); err == nil {
+			checker.restoreReplayLSN(lsn, dbState.ReplayAt)
+		}
+		if lsn, err := ParseLSN(dbState.WALLSN); err == nil {
+			checker.restoreWALLSN(lsn, dbState.WALAt)
+		}
+		if db.health != nil && dbState.Checked && containsDB(replicas, target) {
+			db.health.restore(target, dbState)
+		}
+	}
+
+	if cache, ok := db.queryTypeChecker.(*CachedQueryTypeChecker); ok {
+		cache.restore(state.QueryTypes)
+	}
+	db.log().Debug("dbresolver: routing state restored", "databases", restored, "queryTypes", len(state.QueryTypes))
+}
+
+// ExportState saves the warm state of db to store, see DB.State
+func (db *DB) ExportState(ctx context.Context, store RoutingStateStore) error {
+	data, err := json.Marshal(db.State())
+	if err != nil {
+		return fmt.Errorf("failed to encode the routing state: %w", err)
+	}
+	if err := store.Save(ctx, data); err != nil {
+		return fmt.Errorf("failed to save the routing state: %w", err)
+	}
+	return nil
+}
+
+// ImportState warms db up with the state saved to store by another process, see DB.Restore.
+// Nothing is restored when store has no state.
+func (db *DB) ImportState(ctx context.Context, store RoutingStateStore, maxAge time.Duration) error {
+	data, err := store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load the routing state: %w", err)
+	}
+	if data == nil {
+		return nil
+	}
+	var state RoutingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode the routing state: %w", err)
+	}
+	db.Restore(state, maxAge)
+	return nil
+}
+
+// FileRoutingStateStore stores the routing state in a file, e.g. on the /tmp of a Lambda execution environment or
+// a volume shared by the workers of a host. Saves replace the file atomically.
+type FileRoutingStateStore struct {
+	Path string
+}
+
+// Load reads the state file, nil if it doesn't exist
+func (s FileRoutingStateStore) Load(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save writes the state to a temporary file renamed to Path
+func (s FileRoutingStateStore) Save(_ context.Context, state []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(state); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// restore sets the health of a replica never checked by this monitor
+func (h *healthMonitor) restore(replica *sql.DB, state DatabaseState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.replicas[replica]; ok {
+		return
+	}
+	r := &replicaHealth{
+		status: ReplicaStatus{
+			Name:       state.Name,
+			IsHealthy:  state.Healthy,
+			LastCheck:  state.CheckedAt,
+			ErrorCount: state.ErrorCount,
+			LagBytes:   state.LagBytes,
+			Evicted:    state.Evicted,
+		},
+		failures: state.Failures,
+		lagging:  h.maxLag > 0 && state.LagBytes > h.maxLag,
+	}
+	if lsn, err := ParseLSN(state.ReplayLSN); err == nil && state.Healthy {
+		r.status.LastLSN = &lsn
+	}
+	h.replicas[replica] = r
+	if state.Evicted {
+		h.evicted++
+	}
+}
+
+// restoreReplayLSN sets the replay LSN observed at at, unless a later one was observed
+func (c *PGLSNChecker) restoreReplayLSN(lsn LSN, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at.After(c.lastReplayAt) {
+		c.lastReplayLSN, c.lastReplayAt = lsn, at
+	}
+}
+
+// restoreWALLSN sets the current WAL LSN observed at at, unless a later one was observed
+func (c *PGLSNChecker) restoreWALLSN(lsn LSN, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at.After(c.lastWALAt) {
+		c.lastWALLSN, c.lastWALAt = lsn, at
+	}
+}
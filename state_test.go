@@ -0,0 +1,93 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRoutingStateHandoff(t *testing.T) {
+	newResolver := func() (*DB, *sql.DB) {
+		primary, _ := newMockDB(t)
+		replica, _ := newMockDB(t)
+		db := New(WithPrimaryDBs(primary), WithNamedReplicaDBs(map[string]*sql.DB{"east": replica}), WithQueryTypeCache(10))
+		db.health = newHealthMonitor(&Option{EvictAfterFailures: 1})
+		return db, replica
+	}
+	ctx := context.Background()
+	store := FileRoutingStateStore{Path: filepath.Join(t.TempDir(), "state.json")}
+
+	cold, _ := newResolver()
+	if err := cold.ImportState(ctx, store, time.Minute); err != nil {
+		t.Fatalf("want nothing imported from an empty store, got %v", err)
+	}
+
+	warm, warmReplica := newResolver()
+	observedAt := time.Now().Add(-time.Second)
+	getOrCreateChecker(warmReplica, time.Second).restoreReplayLSN(LSN{Upper: 1, Lower: 42}, observedAt)
+	warm.health.updateReplica(warmReplica, "east", LSN{}, 0, errConnReset)
+	warm.queryTypeChecker.Check("INSERT INTO users VALUES (1)")
+	if err := warm.ExportState(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+
+	db, replica := newResolver()
+	if err := db.ImportState(ctx, store, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	lsn, at, ok := lookupChecker(replica).CachedReplayLSN()
+	if !ok || lsn != (LSN{Upper: 1, Lower: 42}) || !at.Equal(observedAt) {
+		t.Errorf("want the replay LSN restored with its observation time, got %s at %v", lsn, at)
+	}
+	if statuses := db.GetReplicaStatus(); len(statuses) != 1 || statuses[0].IsHealthy || !statuses[0].Evicted {
+		t.Errorf("want the evicted replica restored, got %+v", statuses[0])
+	}
+	if available := db.health.available(db.allReplicas()); len(available) != 0 {
+		t.Errorf("want the evicted replica out of the read pool, got %v", available)
+	}
+	if cache := db.queryTypeChecker.(*CachedQueryTypeChecker); cache.Len() != 1 {
+		t.Errorf("want the cached query types restored, got %d", cache.Len())
+	}
+}
+
+func TestRestoreKeepsFresherState(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	getOrCreateChecker(replica, time.Second).restoreReplayLSN(LSN{Upper: 2}, time.Now())
+
+	state := RoutingState{
+		SavedAt:   time.Now(),
+		Databases: []DatabaseState{{Name: "replica-0", ReplayLSN: "1/0", ReplayAt: time.Now().Add(-time.Minute)}},
+	}
+	db.Restore(state, 0)
+	if lsn, _, _ := lookupChecker(replica).CachedReplayLSN(); lsn != (LSN{Upper: 2}) {
+		t.Errorf("want a later observed LSN kept, got %s", lsn)
+	}
+
+	state.SavedAt = time.Now().Add(-time.Hour)
+	state.Databases[0].ReplayAt = time.Now().Add(time.Minute)
+	db.Restore(state, time.Minute)
+	if lsn, _, _ := lookupChecker(replica).CachedReplayLSN(); lsn != (LSN{Upper: 2}) {
+		t.Errorf("want a state older than maxAge ignored, got %s", lsn)
+	}
+}
+
+type failingStateStore struct{}
+
+func (failingStateStore) Load(context.Context) ([]byte, error) { return []byte("{"), nil }
+func (failingStateStore) Save(context.Context, []byte) error   { return errors.New("read-only") }
+
+func TestRoutingStateStoreErrors(t *testing.T) {
+	primary, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+	if err := db.ExportState(context.Background(), failingStateStore{}); err == nil {
+		t.Error("want the save error returned")
+	}
+	if err := db.ImportState(context.Background(), failingStateStore{}, 0); err == nil {
+		t.Error("want the decode error returned")
+	}
+}
@@ -3,6 +3,10 @@ package dbresolver
 import (
 	"context"
 	"database/sql"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/multierr"
 )
@@ -21,20 +25,40 @@ type Stmt interface {
 
 type stmt struct {
 	loadBalancer StmtLoadBalancer
+	writeFlag    bool
+	classifier   ErrorClassifier
+
+	// query and resolver the statement was prepared with, nil for single database statements
+	query    string
+	resolver *DB
+
+	// primary and replica statements, updated as the primary is set and replicas are added or removed
+	mu           sync.RWMutex
 	primaryStmts []*sql.Stmt
 	replicaStmts []*sql.Stmt
-	writeFlag    bool
+	replicaDBs   []*sql.DB // database of each replica statement
 	dbStmt       map[*sql.DB]*sql.Stmt
+	closed       bool
+
+	nextReprepare atomic.Int64 // unix nanoseconds of the next attempt of reprepareFallbacks
 }
 
 // Close closes the statement by concurrently closing all underlying
 // statements concurrently, returning the first non nil error.
 func (s *stmt) Close() error {
-	errPrimaries := doParallely(len(s.primaryStmts), func(i int) error {
-		return s.primaryStmts[i].Close()
+	if s.resolver != nil {
+		s.resolver.stmts.Delete(s)
+	}
+	s.mu.Lock()
+	s.closed = true
+	primaryStmts, replicaStmts := slices.Clone(s.primaryStmts), slices.Clone(s.replicaStmts)
+	s.mu.Unlock()
+
+	errPrimaries := doParallely(len(primaryStmts), func(i int) error {
+		return primaryStmts[i].Close()
 	})
-	errReplicas := doParallely(len(s.replicaStmts), func(i int) error {
-		return s.replicaStmts[i].Close()
+	errReplicas := doParallely(len(replicaStmts), func(i int) error {
+		return replicaStmts[i].Close()
 	})
 
 	return multierr.Combine(errPrimaries, errReplicas)
@@ -51,6 +75,12 @@ func (s *stmt) Exec(args ...interface{}) (sql.Result, error) {
 // and returns a Result summarizing the effect of the statement.
 // Exec uses the master as the underlying physical db.
 func (s *stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if s.readOnlyMode() {
+		return nil, ErrReadOnlyMode
+	}
 	return s.RWStmt().ExecContext(ctx, args...)
 }
 
@@ -65,15 +95,24 @@ func (s *stmt) Query(args ...interface{}) (*sql.Rows, error) {
 // arguments and returns the query results as a *sql.Rows.
 // Query uses the read only DB as the underlying physical db.
 func (s *stmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+	if s.writeFlag && s.readOnlyMode() {
+		return nil, ErrReadOnlyMode
+	}
 	var curStmt *sql.Stmt
 	if s.writeFlag {
 		curStmt = s.RWStmt()
 	} else {
+		s.reprepareFallbacks()
 		curStmt = s.ROStmt()
 	}
 
+	start := time.Now()
 	rows, err := curStmt.QueryContext(ctx, args...)
-	if isDBConnectionError(err) && !s.writeFlag {
+	s.observeLatency(curStmt, time.Since(start), err)
+	if isConnectionError(s.classifier, err) && !s.writeFlag {
 		rows, err = s.RWStmt().QueryContext(ctx, args...)
 	}
 	return rows, err
@@ -96,31 +135,84 @@ func (s *stmt) QueryRow(args ...interface{}) *sql.Row {
 // Otherwise, the *sql.Row's Scan scans the first selected row and discards the rest.
 // QueryRowContext uses the read only DB as the underlying physical db.
 func (s *stmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	if s.checkOpen() != nil {
+		return closedRow(ctx)
+	}
+	if s.writeFlag && s.readOnlyMode() {
+		return errorRow(ctx, s.resolver.ReadWrite(), ErrReadOnlyMode)
+	}
 	var curStmt *sql.Stmt
 	if s.writeFlag {
 		curStmt = s.RWStmt()
 	} else {
+		s.reprepareFallbacks()
 		curStmt = s.ROStmt()
 	}
 
+	start := time.Now()
 	row := curStmt.QueryRowContext(ctx, args...)
-	if isDBConnectionError(row.Err()) && !s.writeFlag {
+	s.observeLatency(curStmt, time.Since(start), row.Err())
+	if isConnectionError(s.classifier, row.Err()) && !s.writeFlag {
 		row = s.RWStmt().QueryRowContext(ctx, args...)
 	}
 	return row
 }
 
+// observeLatency reports the latency of a successful query on curStmt to the load balancer
+func (s *stmt) observeLatency(curStmt *sql.Stmt, latency time.Duration, err error) {
+	if observer, ok := s.loadBalancer.(LatencyObserver[*sql.Stmt]); ok && err == nil {
+		observer.ObserveLatency(curStmt, latency)
+	}
+}
+
+// checkOpen returns ErrResolverClosed once the resolver the statement was prepared with is closed
+func (s *stmt) checkOpen() error {
+	if s.resolver == nil {
+		return nil
+	}
+	return s.resolver.checkOpen()
+}
+
 // ROStmt return the replica statement
 func (s *stmt) ROStmt() *sql.Stmt {
-	totalStmtsConn := len(s.replicaStmts) + len(s.primaryStmts)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	replicaStmts := s.replicaStmts
+	if s.resolver != nil {
+		replicaStmts = s.servingStmtsLocked()
+	}
+	totalStmtsConn := len(replicaStmts) + len(s.primaryStmts)
 	if totalStmtsConn == len(s.primaryStmts) {
 		return s.loadBalancer.Resolve(s.primaryStmts)
 	}
-	return s.loadBalancer.Resolve(s.replicaStmts)
+	return s.loadBalancer.Resolve(replicaStmts)
+}
+
+// servingStmtsLocked returns the replica statements of the replicas routing reads, see DB.ReplicaDBs
+func (s *stmt) servingStmtsLocked() []*sql.Stmt {
+	serving := s.resolver.availableReplicas(s.replicaDBs)
+	if len(serving) == len(s.replicaDBs) {
+		return s.replicaStmts
+	}
+	stmts := make([]*sql.Stmt, 0, len(serving))
+	for i, replica := range s.replicaDBs {
+		if containsDB(serving, replica) {
+			stmts = append(stmts, s.replicaStmts[i])
+		}
+	}
+	return stmts
+}
+
+// readOnlyMode reports whether the resolver of the statement suspended the writes with EnterReadOnlyMode
+func (s *stmt) readOnlyMode() bool {
+	return s.resolver != nil && s.resolver.readOnly.Load()
 }
 
 // RWStmt return the primary statement
 func (s *stmt) RWStmt() *sql.Stmt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.loadBalancer.Resolve(s.primaryStmts)
 }
 
@@ -128,7 +220,9 @@ func (s *stmt) RWStmt() *sql.Stmt {
 // Ihis is needed because sql.Tx.Stmt() requires that the passed *sql.Stmt be from the same database
 // as the transaction.
 func (s *stmt) stmtForDB(db *sql.DB) *sql.Stmt {
+	s.mu.RLock()
 	xsm, ok := s.dbStmt[db]
+	s.mu.RUnlock()
 	if ok {
 		return xsm
 	}
@@ -149,3 +243,117 @@ func newSingleDBStmt(sourceDB *sql.DB, st *sql.Stmt, writeFlag bool) *stmt {
 		writeFlag: writeFlag,
 	}
 }
+
+// addReplica adds the statement prepared on a replica added to the resolver. A nil statement, which
+// failed to prepare with a connection error, is served by the primary like in PrepareContext.
+func (s *stmt) addReplica(replica *sql.DB, st *sql.Stmt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		if st != nil {
+			_ = st.Close()
+		}
+		return
+	}
+	if st == nil {
+		st = s.primaryStmts[0]
+	} else {
+		s.dbStmt[replica] = st
+	}
+	s.replicaStmts = append(s.replicaStmts, st)
+	s.replicaDBs = append(s.replicaDBs, replica)
+}
+
+// removeReplica closes the statement prepared on a replica removed from the resolver
+func (s *stmt) removeReplica(replica *sql.DB) {
+	s.mu.Lock()
+	i := slices.Index(s.replicaDBs, replica)
+	if i < 0 {
+		s.mu.Unlock()
+		return
+	}
+	st := s.replicaStmts[i]
+	s.replicaStmts = slices.Delete(s.replicaStmts, i, i+1)
+	s.replicaDBs = slices.Delete(s.replicaDBs, i, i+1)
+	delete(s.dbStmt, replica)
+	fallback := slices.Contains(s.primaryStmts, st)
+	s.mu.Unlock()
+
+	if !fallback {
+		_ = st.Close()
+	}
+}
+
+// swap rebuilds the statements after DB.Swap: the statements of the databases of primaries and replicas are kept,
+// or taken from prepared, and the others closed. The replicas without a statement are served by the primary.
+func (s *stmt) swap(primaries, replicas []*sql.DB, prepared map[*sql.DB]*sql.Stmt) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		for _, st := range prepared {
+			if st != nil {
+				_ = st.Close()
+			}
+		}
+		return
+	}
+	old := s.dbStmt
+	stmtOn := func(target *sql.DB) *sql.Stmt {
+		if st := prepared[target]; st != nil {
+			return st
+		}
+		return old[target]
+	}
+	dbStmt := make(map[*sql.DB]*sql.Stmt, len(primaries)+len(replicas))
+	primaryStmts := make([]*sql.Stmt, len(primaries))
+	for i, primary := range primaries {
+		primaryStmts[i] = stmtOn(primary)
+		dbStmt[primary] = primaryStmts[i]
+	}
+	replicaStmts := make([]*sql.Stmt, len(replicas))
+	for i, replica := range replicas {
+		if replicaStmts[i] = stmtOn(replica); replicaStmts[i] == nil {
+			replicaStmts[i] = primaryStmts[0]
+		} else {
+			dbStmt[replica] = replicaStmts[i]
+		}
+	}
+	s.primaryStmts, s.replicaStmts, s.replicaDBs, s.dbStmt = primaryStmts, replicaStmts, slices.Clone(replicas), dbStmt
+	s.mu.Unlock()
+
+	for _, st := range old {
+		if st != nil && !slices.Contains(primaryStmts, st) && !slices.Contains(replicaStmts, st) {
+			_ = st.Close()
+		}
+	}
+}
+
+// setPrimary replaces the primary statements with st, prepared on the new primary of the resolver, and
+// closes the replaced ones. The replicas served by the replaced primary statements are served by st.
+func (s *stmt) setPrimary(primary *sql.DB, st *sql.Stmt) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = st.Close()
+		return
+	}
+	replaced := s.primaryStmts
+	for i, replicaStmt := range s.replicaStmts {
+		if slices.Contains(replaced, replicaStmt) {
+			s.replicaStmts[i] = st
+		}
+	}
+	for db, dbStmt := range s.dbStmt {
+		if slices.Contains(replaced, dbStmt) {
+			delete(s.dbStmt, db)
+		}
+	}
+	s.primaryStmts = []*sql.Stmt{st}
+	s.dbStmt[primary] = st
+	s.mu.Unlock()
+
+	for _, old := range replaced {
+		_ = old.Close()
+	}
+}
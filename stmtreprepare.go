@@ -0,0 +1,88 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+)
+
+const (
+	// stmtReprepareInterval is the minimum pause between the attempts to prepare a statement again on the
+	// replicas it failed to prepare on
+	stmtReprepareInterval = 5 * time.Second
+	stmtReprepareTimeout  = 5 * time.Second
+)
+
+// reprepareFallbacks prepares the statement again, in the background, on the replicas serving it from a primary
+// because it failed to prepare on them, e.g. with a connection error while they were added or restarting after a
+// failover. It runs at most once per stmtReprepareInterval, on the reads of the statement, so a replica back
+// serves the statement again without preparing it anew.
+func (s *stmt) reprepareFallbacks() {
+	if s.resolver == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	next := s.nextReprepare.Load()
+	if now < next || !s.nextReprepare.CompareAndSwap(next, now+int64(stmtReprepareInterval)) {
+		return
+	}
+	fallbacks := s.fallbackReplicas()
+	if len(fallbacks) == 0 {
+		return
+	}
+	s.resolver.goBackground(func(stop <-chan struct{}) {
+		ctx, cancel := context.WithTimeout(context.Background(), stmtReprepareTimeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for _, replica := range fallbacks {
+			st, err := replica.PrepareContext(ctx, s.query)
+			if err != nil {
+				s.resolver.metrics.prepareFailed(replica)
+				s.resolver.log().Debug("statement: failed to prepare again on replica",
+					"db", physicalDBName(s.resolver, replica), "error", err)
+				continue
+			}
+			s.replaceFallback(replica, st)
+		}
+	})
+}
+
+// fallbackReplicas returns the replicas whose statement is served by a primary
+func (s *stmt) fallbackReplicas() []*sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil
+	}
+	var fallbacks []*sql.DB
+	for i, replica := range s.replicaDBs {
+		if slices.Contains(s.primaryStmts, s.replicaStmts[i]) {
+			fallbacks = append(fallbacks, replica)
+		}
+	}
+	return fallbacks
+}
+
+// replaceFallback serves the statement from st, prepared again on replica, instead of a primary. st is closed
+// when the statement was closed or replica removed or prepared meanwhile.
+func (s *stmt) replaceFallback(replica *sql.DB, st *sql.Stmt) {
+	s.mu.Lock()
+	i := slices.Index(s.replicaDBs, replica)
+	if s.closed || i < 0 || !slices.Contains(s.primaryStmts, s.replicaStmts[i]) {
+		s.mu.Unlock()
+		_ = st.Close()
+		return
+	}
+	s.replicaStmts[i] = st
+	s.dbStmt[replica] = st
+	s.mu.Unlock()
+	s.resolver.log().Debug("statement: prepared again on replica", "db", physicalDBName(s.resolver, replica))
+}
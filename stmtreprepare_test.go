@@ -0,0 +1,83 @@
+package dbresolver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStmtPreparedAgainOnFallbackReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	defer db.Close()
+
+	// the replica restarting after a failover fails to prepare the statement, served by the primary meanwhile
+	primaryMock.ExpectPrepare("SELECT name FROM users")
+	replicaMock.ExpectPrepare("SELECT name FROM users").WillReturnError(errConnReset)
+	st, err := db.Prepare("SELECT name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, fallbacks := db.statementMetrics(); fallbacks[replica] != 1 {
+		t.Fatalf("want the statement served by the primary, got %v", fallbacks)
+	}
+
+	// the next read prepares it again on the replica, once back
+	replicaMock.ExpectPrepare("SELECT name FROM users")
+	primaryMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	var name string
+	if err := st.QueryRow().Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, fallbacks := db.statementMetrics(); fallbacks[replica] != 0; _, fallbacks = db.statementMetrics() {
+		if time.Now().After(deadline) {
+			t.Fatal("statement not prepared again on the replica")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	replicaMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("bob"))
+	if err := st.QueryRow().Scan(&name); err != nil || name != "bob" {
+		t.Fatalf("want the read served by the replica, got %q, %v", name, err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStmtPreparedAgainAtMostOncePerInterval(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	defer db.Close()
+
+	primaryMock.ExpectPrepare("SELECT 1")
+	replicaMock.ExpectPrepare("SELECT 1").WillReturnError(errConnReset)
+	st, err := db.Prepare("SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica still down fails to prepare it again, not retried by the reads of the next interval
+	replicaMock.ExpectPrepare("SELECT 1").WillReturnError(errConnReset)
+	for range 3 {
+		primaryMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+		rows, err := st.Query()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	db.wg.Wait()
+	if failures := db.metrics.prepareFailuresOf(replica); failures != 2 {
+		t.Errorf("want 2 prepare failures on the replica, got %d", failures)
+	}
+	if _, fallbacks := db.statementMetrics(); fallbacks[replica] != 1 {
+		t.Errorf("want the statement still served by the primary, got %v", fallbacks)
+	}
+}
@@ -0,0 +1,67 @@
+package dbresolver
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultStreamingTokenHeader is the header used by WithStreamingToken when none is given
+const DefaultStreamingTokenHeader = "X-Pg-Consistency-Token"
+
+// WithStreamingToken delivers the consistency token of streaming responses (SSE, chunked) whose headers are
+// flushed before the handler writes, when the LSN cookie can no longer be set: the LSN is captured again
+// once the handler returns, and its token is sent as the HTTP trailer headerName, or recorded in the session
+// store when one is configured. Tokens captured before the headers are flushed are also sent as the
+// response header headerName, and the middleware reads the token from the request header headerName when
+// the request carries no LSN cookie, for clients that can't read cookies or trailers and echo it back.
+// headerName defaults to DefaultStreamingTokenHeader.
+func WithStreamingToken(headerName string) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		if headerName == "" {
+			headerName = DefaultStreamingTokenHeader
+		}
+		m.streamingToken = http.CanonicalHeaderKey(headerName)
+	}
+}
+
+// streamingTokenLSN returns the LSN of the token in the streaming token header of r
+func (m *HTTPMiddleware) streamingTokenLSN(r *http.Request) (LSN, bool) {
+	value := r.Header.Get(m.streamingToken)
+	if value == "" {
+		return LSN{}, false
+	}
+	lsn, err := m.tokenLSN(value)
+	if err != nil {
+		m.log().Debug("HTTPMiddleware: ignoring invalid streaming consistency token", "error", err)
+		return LSN{}, false
+	}
+	return lsn, !lsn.IsZero()
+}
+
+// finishStreaming delivers the LSN of the writes performed after the response headers were written,
+// once the handler returned, in the trailer or the session store
+func (lrw *lsnResponseWriter) finishStreaming() {
+	m := lrw.middleware
+	if !lrw.wroteHeader {
+		// Nothing was written, the headers can still carry the cookie
+		lrw.WriteHeader(http.StatusOK)
+		return
+	}
+	if lrw.statusCode < 200 || lrw.statusCode >= 300 {
+		return
+	}
+	if lsnCtx := GetLSNContext(lrw.ctx); lsnCtx == nil || !lsnCtx.HasWriteOperation {
+		return
+	}
+
+	// The response is complete, the write budget no longer applies
+	lsn, ok := m.captureLSN(lrw.ctx, lrw.sessionKey, time.Time{})
+	if !ok || !lsn.GreaterThan(lrw.persistedLSN) {
+		return
+	}
+	if m.sessionStore != nil {
+		m.persistLSN(lrw.ctx, lrw.ResponseWriter, lrw.sessionKey, lsn)
+		return
+	}
+	lrw.ResponseWriter.Header().Set(http.TrailerPrefix+m.streamingToken, EncodeConsistencyToken(lsn, m.tokenVersion))
+}
@@ -0,0 +1,94 @@
+package dbresolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddlewareStreamingTokenTrailer(t *testing.T) {
+	router := &fixedLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}}
+	middleware := NewHTTPMiddleware(router, "test_lsn", 0, false, WithStreamingToken(""))
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: start\n\n"))
+		w.(http.Flusher).Flush()
+
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		_, _ = w.Write([]byte("event: done\n\n"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/stream", http.NoBody))
+
+	resp := rec.Result()
+	if !rec.Flushed {
+		t.Error("expected Flush to reach the underlying writer")
+	}
+	if len(resp.Cookies()) != 0 {
+		t.Error("expected no cookie once the headers were flushed before the write")
+	}
+	want := EncodeConsistencyToken(router.lsn, TokenVersionLegacy)
+	if got := resp.Trailer.Get(DefaultStreamingTokenHeader); got != want {
+		t.Errorf("expected trailer %q, got %q", want, got)
+	}
+}
+
+func TestHTTPMiddlewareStreamingTokenHeader(t *testing.T) {
+	router := &fixedLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}}
+	middleware := NewHTTPMiddleware(router, "test_lsn", 0, false, WithStreamingToken("X-Token"))
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		_, _ = w.Write([]byte("data: 1\n\n"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/stream", http.NoBody))
+
+	resp := rec.Result()
+	want := EncodeConsistencyToken(router.lsn, TokenVersionLegacy)
+	if got := resp.Header.Get("X-Token"); got != want {
+		t.Errorf("expected header %q, got %q", want, got)
+	}
+	if len(resp.Cookies()) != 1 {
+		t.Errorf("expected the LSN cookie alongside the header, got %d cookies", len(resp.Cookies()))
+	}
+	if len(resp.Trailer) != 0 {
+		t.Errorf("expected no trailer for an LSN already delivered, got %v", resp.Trailer)
+	}
+
+	read := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lsnCtx := GetLSNContext(r.Context()); lsnCtx.RequiredLSN != router.lsn {
+			t.Errorf("expected required LSN %s from the request header, got %s", router.lsn, lsnCtx.RequiredLSN)
+		}
+	}))
+	req := httptest.NewRequest("GET", "/items", http.NoBody)
+	req.Header.Set("X-Token", want)
+	read.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestHTTPMiddlewareStreamingTokenSessionStore(t *testing.T) {
+	store := NewMemorySessionLSNStore()
+	router := &fixedLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}}
+	middleware := NewHTTPMiddleware(router, "", 0, false,
+		WithSessionStore(store, SessionKeyFromHeader("X-User-ID")), WithStreamingToken(""))
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		GetLSNContext(r.Context()).HasWriteOperation = true
+	}))
+
+	req := httptest.NewRequest("POST", "/stream", http.NoBody)
+	req.Header.Set("X-User-ID", "42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if lsn, ok, _ := store.Get(req.Context(), "42"); !ok || lsn != router.lsn {
+		t.Errorf("expected LSN %s in the session store, got %s", router.lsn, lsn)
+	}
+	if len(rec.Result().Trailer) != 0 {
+		t.Error("session store mode must not send trailers")
+	}
+}
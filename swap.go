@@ -0,0 +1,172 @@
+package dbresolver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// swapDrainTimeout bounds the wait for the connections in use on the databases retired by Swap
+	swapDrainTimeout = 30 * time.Second
+	swapDrainPoll    = 100 * time.Millisecond
+)
+
+// Swap replaces the databases serving db by those of next, e.g. to apply a topology read from configuration
+// without building a new resolver and updating every reference to db. next is built with New and only supplies
+// its primaries, replicas, region tiers, delayed replicas and database names; the other options of db are kept.
+// The statements created with Prepare are prepared on the new databases first, failing the swap on any error of a
+// primary. Once swapped, queries are routed to the new databases while the ones running on the replaced databases
+// complete: those are closed in the background once they have no connection in use, or after 30s. The databases
+// part of both resolvers keep serving. next is consumed: it behaves as closed without closing its databases.
+func (db *DB) Swap(next *DB) error {
+	if next == db {
+		return errors.New("dbresolver: cannot swap a resolver with itself")
+	}
+	if err := db.checkOpen(); err != nil {
+		return err
+	}
+	if err := next.checkOpen(); err != nil {
+		return err
+	}
+	db.membershipMu.Lock()
+	defer db.membershipMu.Unlock()
+
+	oldPrimaries, oldReplicas := db.allPrimaries(), db.allReplicas()
+	oldDBs := slices.Concat(oldPrimaries, oldReplicas)
+	primaries, replicas := next.allPrimaries(), next.allReplicas()
+	prepared, err := db.prepareSwap(oldPrimaries, oldReplicas, primaries, replicas)
+	if err != nil {
+		return err
+	}
+
+	next.replicasMu.RLock()
+	tiers, names := next.tiers, next.names
+	next.replicasMu.RUnlock()
+	db.replicasMu.Lock()
+	db.primaries, db.replicas, db.tiers, db.names = primaries, replicas, tiers, names
+	db.replicasMu.Unlock()
+
+	db.stmts.Range(func(key, _ any) bool {
+		s := key.(*stmt)
+		s.swap(primaries, replicas, prepared[s])
+		return true
+	})
+
+	var retired []*sql.DB
+	for _, old := range oldDBs {
+		if !containsDB(primaries, old) && !containsDB(replicas, old) {
+			retired = append(retired, old)
+			db.forgetSwapped(old)
+		}
+	}
+	for _, replica := range replicas {
+		if !containsDB(oldDBs, replica) {
+			db.errorRatio.track(replica)
+		}
+		if delayed, ok := next.delay.get(replica); ok {
+			db.delay.set(replica, delayed)
+		}
+	}
+
+	next.closeOnce.Do(func() {
+		next.closed.Store(true)
+		next.stopBackground()
+	})
+	db.retire(retired)
+	db.log().Warn("dbresolver: topology swapped", "primaries", len(primaries), "replicas", len(replicas), "retired", len(retired))
+	return nil
+}
+
+// prepareSwap prepares the open statements on the databases of primaries and replicas that didn't have the same
+// role before the swap. The statements failing to prepare on a replica with a connection error are mapped to nil,
+// served by the primary.
+func (db *DB) prepareSwap(oldPrimaries, oldReplicas, primaries, replicas []*sql.DB) (map[*stmt]map[*sql.DB]*sql.Stmt, error) {
+	prepared := make(map[*stmt]map[*sql.DB]*sql.Stmt)
+	closePrepared := func() {
+		for _, stmts := range prepared {
+			for _, st := range stmts {
+				if st != nil {
+					_ = st.Close()
+				}
+			}
+		}
+	}
+	for _, target := range slices.Concat(primaries, replicas) {
+		isPrimary := containsDB(primaries, target)
+		if isPrimary && containsDB(oldPrimaries, target) || !isPrimary && containsDB(oldReplicas, target) {
+			continue
+		}
+		stmts, err := db.prepareOn(target, !isPrimary)
+		if err != nil {
+			closePrepared()
+			if isPrimary {
+				return nil, fmt.Errorf("failed to prepare statements on primary: %w", err)
+			}
+			return nil, fmt.Errorf("failed to prepare statements on replica: %w", err)
+		}
+		for s, st := range stmts {
+			if prepared[s] == nil {
+				prepared[s] = make(map[*sql.DB]*sql.Stmt)
+			}
+			prepared[s][target] = st
+		}
+	}
+	return prepared, nil
+}
+
+// forgetSwapped drops the state kept about a database replaced by Swap
+func (db *DB) forgetSwapped(old *sql.DB) {
+	if db.health != nil {
+		db.health.forget(old)
+		db.health.forgetPrimary(old)
+	}
+	if db.outage != nil {
+		db.outage.forget(old)
+	}
+	if db.roles != nil {
+		db.roles.forget(old)
+	}
+	db.errorRatio.forget(old)
+	db.activity.forget(old)
+	db.metrics.prepareFailures.Delete(old)
+	db.drain.set(old, false)
+	db.delay.clear(old)
+	db.searchPaths.Delete(old)
+	PurgeChecker(old)
+}
+
+// retire closes the databases replaced by Swap once their connections in use are released, at the latest after
+// swapDrainTimeout, or right away when the resolver is closed
+func (db *DB) retire(dbs []*sql.DB) {
+	if len(dbs) == 0 {
+		return
+	}
+	closeAll := func() {
+		if err := doParallely(len(dbs), func(i int) error { return dbs[i].Close() }); err != nil {
+			db.log().Warn("dbresolver: failed to close the swapped databases", "error", err)
+		}
+	}
+	started := db.goBackground(func(stop <-chan struct{}) {
+		defer closeAll()
+		deadline := time.NewTimer(swapDrainTimeout)
+		defer deadline.Stop()
+		ticker := time.NewTicker(swapDrainPoll)
+		defer ticker.Stop()
+		for slices.ContainsFunc(dbs, func(old *sql.DB) bool { return old.Stats().InUse > 0 }) {
+			select {
+			case <-stop:
+				return
+			case <-deadline.C:
+				db.log().Warn("dbresolver: closing the swapped databases with connections in use")
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	if !started {
+		closeAll()
+	}
+}
@@ -0,0 +1,80 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSwap(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	newPrimary, newPrimaryMock := newMockDB(t)
+	newReplica, newReplicaMock := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	primaryMock.ExpectPrepare("SELECT name FROM users").WillBeClosed()
+	replicaMock.ExpectPrepare("SELECT name FROM users").WillBeClosed()
+	st, err := db.Prepare("SELECT name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := New(WithPrimaryDBs(newPrimary), WithNamedReplicaDBs(map[string]*sql.DB{"east": newReplica}))
+	newPrimaryMock.ExpectPrepare("SELECT name FROM users")
+	newReplicaMock.ExpectPrepare("SELECT name FROM users")
+	primaryMock.ExpectClose()
+	replicaMock.ExpectClose()
+	if err := db.Swap(next); err != nil {
+		t.Fatal(err)
+	}
+	db.wg.Wait() // the replaced databases are closed in the background
+
+	if got := db.ReadWrite(); got != newPrimary {
+		t.Error("want writes on the new primary")
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 || replicas[0] != newReplica {
+		t.Errorf("want reads on the new replica, got %v", replicas)
+	}
+	if name := physicalDBName(db, newReplica); name != "east" {
+		t.Errorf("want the names of the new databases, got %s", name)
+	}
+	if _, err := next.QueryContext(context.Background(), "SELECT 1"); !errors.Is(err, ErrResolverClosed) {
+		t.Errorf("want the swapped in resolver consumed, got %v", err)
+	}
+
+	newReplicaMock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	var name string
+	if err := st.QueryRow().Scan(&name); err != nil || name != "alice" {
+		t.Fatalf("want the statement served by the new replica, got %q, %v", name, err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock, newPrimaryMock, newReplicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSwapPrepareFailure(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	newPrimary, newPrimaryMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary))
+
+	primaryMock.ExpectPrepare("INSERT INTO users")
+	if _, err := db.Prepare("INSERT INTO users VALUES ($1)"); err != nil {
+		t.Fatal(err)
+	}
+	newPrimaryMock.ExpectPrepare("INSERT INTO users").WillReturnError(errors.New("permission denied"))
+	if err := db.Swap(New(WithPrimaryDBs(newPrimary))); err == nil {
+		t.Fatal("want the swap failed")
+	}
+	if got := db.ReadWrite(); got != primary {
+		t.Error("want the topology kept after a failed swap")
+	}
+	if err := db.Swap(db); err == nil {
+		t.Error("want an error swapping a resolver with itself")
+	}
+}
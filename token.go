@@ -0,0 +1,106 @@
+package dbresolver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TokenVersion identifies the format of a consistency token (the LSN cookie value)
+type TokenVersion int
+
+const (
+	// TokenVersionLegacy is the bare "X/Y" LSN understood by every release
+	TokenVersionLegacy TokenVersion = 0
+	// TokenVersion1 is "v1.X/Y" optionally followed by ".<extension>" fields
+	TokenVersion1 TokenVersion = 1
+	// TokenVersion2 is "v2.X/Y.<signature>", an HMAC signed token produced by SignConsistencyToken
+	TokenVersion2 TokenVersion = 2
+
+	// CurrentTokenVersion is the newest token version this release understands
+	CurrentTokenVersion = TokenVersion2
+)
+
+const tokenFieldSeparator = "."
+
+// ConsistencyToken is a parsed consistency token.
+//
+// Token format rules, which every release must keep so that app instances running different
+// versions can exchange tokens during a rolling deploy:
+//   - a token without a "v<N>." prefix is a legacy bare LSN
+//   - a versioned token is "v<N>.<LSN>[.<extension>...]"; the LSN is always the first field
+//   - extension fields are appended by newer versions and ignored by parsers that don't know them
+//   - tokens with a version newer than CurrentTokenVersion are accepted as long as the LSN field parses
+//   - signed versions (v2 and newer) always carry the signature as the first extension field
+type ConsistencyToken struct {
+	Version    TokenVersion
+	LSN        LSN
+	Extensions []string // Fields this release doesn't interpret, kept for re-encoding
+}
+
+// ParseConsistencyToken parses a legacy or versioned consistency token
+func ParseConsistencyToken(token string) (ConsistencyToken, error) {
+	if token == "" {
+		return ConsistencyToken{}, fmt.Errorf("empty consistency token")
+	}
+
+	version, rest, versioned, err := splitTokenVersion(token)
+	if err != nil {
+		return ConsistencyToken{}, err
+	}
+	if !versioned {
+		lsn, err := ParseLSN(token)
+		if err != nil {
+			return ConsistencyToken{}, fmt.Errorf("invalid legacy consistency token: %w", err)
+		}
+		return ConsistencyToken{Version: TokenVersionLegacy, LSN: lsn}, nil
+	}
+
+	fields := strings.Split(rest, tokenFieldSeparator)
+	lsn, err := ParseLSN(fields[0])
+	if err != nil {
+		return ConsistencyToken{}, fmt.Errorf("invalid v%d consistency token: %w", version, err)
+	}
+
+	parsed := ConsistencyToken{Version: version, LSN: lsn}
+	if len(fields) > 1 {
+		parsed.Extensions = fields[1:]
+	}
+	return parsed, nil
+}
+
+// splitTokenVersion splits the "v<N>." prefix from a versioned token
+func splitTokenVersion(token string) (TokenVersion, string, bool, error) {
+	if !strings.HasPrefix(token, "v") {
+		return TokenVersionLegacy, token, false, nil
+	}
+
+	prefix, rest, found := strings.Cut(token[1:], tokenFieldSeparator)
+	if !found {
+		return 0, "", false, fmt.Errorf("invalid consistency token: %s (missing version separator)", token)
+	}
+
+	version, err := strconv.ParseUint(prefix, 10, 16)
+	if err != nil || version == 0 {
+		return 0, "", false, fmt.Errorf("invalid consistency token version: %s", prefix)
+	}
+	return TokenVersion(version), rest, true, nil
+}
+
+// String encodes the token in its version's format
+func (t ConsistencyToken) String() string {
+	if t.Version == TokenVersionLegacy {
+		return t.LSN.String()
+	}
+
+	fields := append([]string{"v" + strconv.Itoa(int(t.Version)), t.LSN.String()}, t.Extensions...)
+	return strings.Join(fields, tokenFieldSeparator)
+}
+
+// EncodeConsistencyToken encodes the LSN as an unsigned token of the given version.
+// Signed tokens are produced by SignConsistencyToken instead.
+// During a rolling deploy keep writing the oldest version still deployed, and only bump
+// the version once every instance is able to parse it.
+func EncodeConsistencyToken(lsn LSN, version TokenVersion) string {
+	return ConsistencyToken{Version: version, LSN: lsn}.String()
+}
@@ -0,0 +1,98 @@
+package dbresolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseConsistencyToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    ConsistencyToken
+		expectError bool
+	}{
+		{
+			name:     "legacy bare LSN",
+			input:    "1/ABCDEF",
+			expected: ConsistencyToken{Version: TokenVersionLegacy, LSN: LSN{Upper: 1, Lower: 0xABCDEF}},
+		},
+		{
+			name:     "v1 token",
+			input:    "v1.1/ABCDEF",
+			expected: ConsistencyToken{Version: TokenVersion1, LSN: LSN{Upper: 1, Lower: 0xABCDEF}},
+		},
+		{
+			name:  "v1 token with unknown extension",
+			input: "v1.0/3000060.tl2",
+			expected: ConsistencyToken{
+				Version: TokenVersion1, LSN: LSN{Lower: 0x3000060}, Extensions: []string{"tl2"},
+			},
+		},
+		{
+			name:  "newer version keeps the LSN readable",
+			input: "v7.0/3000060.sig.zstd",
+			expected: ConsistencyToken{
+				Version: 7, LSN: LSN{Lower: 0x3000060}, Extensions: []string{"sig", "zstd"},
+			},
+		},
+		{name: "empty token", input: "", expectError: true},
+		{name: "missing version separator", input: "v1", expectError: true},
+		{name: "non numeric version", input: "vx.0/1", expectError: true},
+		{name: "zero version", input: "v0.0/1", expectError: true},
+		{name: "versioned token without LSN", input: "v1.", expectError: true},
+		{name: "versioned token with invalid LSN", input: "v1.zz", expectError: true},
+		{name: "invalid legacy token", input: "garbage", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ParseConsistencyToken(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got %+v", token)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(token, tt.expected) {
+				t.Errorf("want %+v, got %+v", tt.expected, token)
+			}
+			if token.String() != tt.input {
+				t.Errorf("re-encoding mismatch: want %s, got %s", tt.input, token.String())
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareTokenVersion(t *testing.T) {
+	router := &fixedLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}}
+	middleware := NewHTTPMiddleware(router, "test_lsn", 0, false, WithTokenVersion(TokenVersion1))
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", http.NoBody))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != "v1.1/ABCDEF" {
+		t.Fatalf("expected a v1 token cookie, got %+v", cookies)
+	}
+
+	// an instance still on the legacy writer and one on v1 must both be readable
+	for _, value := range []string{"1/ABCDEF", "v1.1/ABCDEF"} {
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "test_lsn", Value: value})
+		lsn, ok := GetLSNFromCookie(req, "test_lsn")
+		if !ok || lsn != router.lsn {
+			t.Errorf("cookie %q: want %s, got %s (ok=%v)", value, router.lsn, lsn, ok)
+		}
+	}
+}
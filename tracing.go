@@ -0,0 +1,140 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+// Tracer starts spans around routing decisions and LSN queries.
+// The oteltracing module provides an OpenTelemetry implementation.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttributes(attrs ...SpanAttribute)
+	RecordError(err error)
+	End()
+}
+
+// SpanAttribute is a key-value pair attached to a span
+type SpanAttribute struct {
+	Key   string
+	Value string
+}
+
+// Span names
+const (
+	SpanRouteQuery          = "dbresolver.RouteQuery"
+	SpanUpdateLSNAfterWrite = "dbresolver.UpdateLSNAfterWrite"
+	SpanCurrentWALLSN       = "dbresolver.GetCurrentWALLSN"
+	SpanLastReplayLSN       = "dbresolver.GetLastReplayLSN"
+)
+
+// Span attribute keys
+const (
+	AttrTargetDB    = "dbresolver.target_db"    // "primary-<index>" or "replica-<index>"
+	AttrQueryType   = "dbresolver.query_type"   // "read", "write" or "unknown"
+	AttrRequiredLSN = "dbresolver.required_lsn" // LSN the read must observe
+	AttrObservedLSN = "dbresolver.observed_lsn" // LSN returned by an LSN query
+	AttrRouteReason = "dbresolver.route_reason" // why the target was chosen, see the Reason constants
+)
+
+// Route reasons reported in the AttrRouteReason attribute
+const (
+	ReasonWrite            = "write"
+	ReasonForceMaster      = "force_master"
+	ReasonReplicaCaughtUp  = "replica_caught_up"
+	ReasonReplicaLagging   = "replica_lagging_fallback"
+	ReasonNoLSNRequirement = "no_lsn_requirement"
+	ReasonNoReplicas       = "no_replicas"
+	ReasonStrongConsistent = "strong_consistency"
+	ReasonDefaultFallback  = "default_fallback"
+
+	ReasonReplicasUnavailable   = "replicas_unavailable"    // every replica is ejected, see WithReplicaOutagePolicy
+	ReasonResourceClass         = "resource_class"          // dedicated replica of the read's resource class
+	ReasonResourceClassFallback = "resource_class_fallback" // no replica of the read's class pool can serve it
+	ReasonRouterError           = "router_error"            // the query router failed, the query was routed without it
+	ReasonReplicaBoost          = "replica_boost"           // boostable read offloaded from a slow primary
+	ReasonLSNCheckSkipped       = "lsn_check_skipped"       // replica check skipped by LSNContext.SkipCheckRate, may be stale
+)
+
+// WithTracer traces routing decisions and LSN queries of the causal router
+func WithTracer(tracer Tracer) OptionFunc {
+	return func(opt *Option) {
+		opt.Tracer = tracer
+	}
+}
+
+type tracerContextKey struct{}
+
+// contextWithTracer makes the LSN checkers called with ctx trace their queries
+func contextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	if tracer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerContextKey{}, tracer)
+}
+
+// startSpan starts a span with the tracer of ctx, or a no-op span if there is none
+func startSpan(ctx context.Context, spanName string) (context.Context, Span) {
+	if tracer, ok := ctx.Value(tracerContextKey{}).(Tracer); ok {
+		return tracer.Start(ctx, spanName)
+	}
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...SpanAttribute) {}
+func (noopSpan) RecordError(error)              {}
+func (noopSpan) End()                           {}
+
+// queryTypeName is the AttrQueryType value of a query type
+func queryTypeName(queryType QueryType) string {
+	switch queryType {
+	case QueryTypeRead:
+		return "read"
+	case QueryTypeWrite:
+		return "write"
+	default:
+		return "unknown"
+	}
+}
+
+// physicalDBName names db by the name given with WithNamedPrimaryDBs or WithNamedReplicaDBs,
+// or else by its role and position in the provider
+func physicalDBName(provider DBProvider, db *sql.DB) string {
+	if resolver, ok := provider.(*DB); ok {
+		if name, ok := resolver.dbNames()[db]; ok {
+			return name
+		}
+	}
+	for i, primary := range provider.PrimaryDBs() {
+		if primary == db {
+			return "primary-" + strconv.Itoa(i)
+		}
+	}
+	var replicas []*sql.DB
+	if resolver, ok := provider.(*DB); ok {
+		// positions passed to New, not among the currently available replicas
+		replicas = resolver.allReplicas()
+		for class, pool := range resolver.classReplicas {
+			for i, replica := range pool {
+				if replica == db {
+					return string(class) + "-replica-" + strconv.Itoa(i)
+				}
+			}
+		}
+	} else {
+		replicas = provider.ReplicaDBs()
+	}
+	for i, replica := range replicas {
+		if replica == db {
+			return "replica-" + strconv.Itoa(i)
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,134 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTracer keeps the spans started through it
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]string
+	err   error
+	ended bool
+}
+
+func (t *recordingTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &recordedSpan{name: spanName, attrs: map[string]string{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...SpanAttribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+func (t *recordingTracer) find(name string) *recordedSpan {
+	for _, span := range t.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestRouteQueryTracing(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	tracer := &recordingTracer{}
+
+	db := New(
+		WithPrimaryDBs(primary),
+		WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{
+			Enabled:          true,
+			Level:            ReadYourWrites,
+			FallbackToMaster: true,
+			Timeout:          time.Second,
+		}),
+		WithTracer(tracer),
+	)
+
+	expectReplayLSN(replicaMock, "0/1000")
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x3000}})
+	if got := db.DbSelector(ctx, QueryTypeRead); got != primary {
+		t.Fatal("expected the lagging replica to be skipped")
+	}
+
+	route := tracer.find(SpanRouteQuery)
+	if route == nil || !route.ended {
+		t.Fatalf("expected an ended %s span, got %+v", SpanRouteQuery, tracer.spans)
+	}
+	expected := map[string]string{
+		AttrQueryType:   "read",
+		AttrRequiredLSN: "0/3000",
+		AttrTargetDB:    "primary-0",
+		AttrRouteReason: ReasonReplicaLagging,
+	}
+	for key, value := range expected {
+		if route.attrs[key] != value {
+			t.Errorf("attribute %s: want %q, got %q", key, value, route.attrs[key])
+		}
+	}
+
+	check := tracer.find(SpanLastReplayLSN)
+	if check == nil || check.attrs[AttrObservedLSN] != "0/1000" {
+		t.Errorf("expected a replay LSN span with the observed LSN, got %+v", check)
+	}
+}
+
+func TestPhysicalDBName(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+	heavy, _ := newMockDB(t)
+	other, _ := newMockDB(t)
+
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithResourceClassReplicas(ResourceClassHeavy, heavy))
+
+	tests := []struct {
+		target *sql.DB
+		want   string
+	}{
+		{target: primary, want: "primary-0"},
+		{target: replica, want: "replica-0"},
+		{target: heavy, want: "heavy-replica-0"},
+		{target: other, want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := physicalDBName(db, tt.target); got != tt.want {
+			t.Errorf("want %s, got %s", tt.want, got)
+		}
+	}
+}
+
+func TestPhysicalDBNameNamed(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, _ := newMockDB(t)
+
+	db := New(
+		WithNamedPrimaryDBs(map[string]*sql.DB{"pg-main": primary}),
+		WithNamedReplicaDBs(map[string]*sql.DB{"pg-replica-a": replica}),
+	)
+	if got := physicalDBName(db, replica); got != "pg-replica-a" {
+		t.Errorf("want pg-replica-a, got %s", got)
+	}
+	m := db.Metrics()
+	if m.Primaries[0].Name != "pg-main" || m.Replicas[0].Name != "pg-replica-a" {
+		t.Errorf("unexpected metric names %s and %s", m.Primaries[0].Name, m.Replicas[0].Name)
+	}
+}
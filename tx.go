@@ -20,30 +20,58 @@ type Tx interface {
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 	Stmt(stmt Stmt) Stmt
 	StmtContext(ctx context.Context, stmt Stmt) Stmt
+	Savepoint(name string) error
+	SavepointContext(ctx context.Context, name string) error
+	RollbackTo(name string) error
+	RollbackToContext(ctx context.Context, name string) error
+	ReleaseSavepoint(name string) error
+	ReleaseSavepointContext(ctx context.Context, name string) error
+	Nested(ctx context.Context, fn func(tx Tx) error) error
+	OnCommit(fn func(ctx context.Context, lsn LSN))
 }
 
 type tx struct {
+	db               *DB
 	sourceDB         *sql.DB
 	tx               *sql.Tx
 	queryTypeChecker QueryTypeChecker
 	writesOccurred   bool
+	readOnly         bool // started with TxOptions.ReadOnly, possibly on a replica: never tracked as a write
+	handle           *leakHandle
+	savepoints       int // savepoints created by Nested, naming the next one
+
+	// ctx started the transaction and carries its LSN context, Commit takes none
+	ctx context.Context
+	// connection held until the transaction ends, nil without WithTxCommitLSN
+	commitConn *sql.Conn
+	// hooks run after a successful Commit, see OnCommit
+	onCommit []func(ctx context.Context, lsn LSN)
 }
 
-// markWriteOperation marks that a write operation has occurred during the transaction
+// markWriteOperation marks that a write operation has occurred during the transaction. The statements of a
+// read-only transaction, like SET LOCAL, write nothing.
 func (t *tx) markWriteOperation(_ context.Context, err error) {
-	if err == nil {
+	if err == nil && !t.readOnly {
 		t.writesOccurred = true
 	}
 }
 
 func (t *tx) Commit() error {
 	err := t.tx.Commit()
+	if err == nil {
+		t.afterCommit()
+	}
+	t.releaseConn()
+	t.handle.release()
 
 	return err
 }
 
 func (t *tx) Rollback() error {
-	return t.tx.Rollback()
+	t.handle.release()
+	err := t.tx.Rollback()
+	t.releaseConn()
+	return err
 }
 
 func (t *tx) Exec(query string, args ...interface{}) (sql.Result, error) {
@@ -0,0 +1,250 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReadOnlyTxRoutedToReplicas(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x2000}})
+	readOnly := &sql.TxOptions{ReadOnly: true}
+
+	// on the replica once it caught up to the LSN of ctx, on the primary before
+	for _, begin := range []struct {
+		replayed string
+		mock     sqlmock.Sqlmock
+	}{{"0/3000", replicaMock}, {"0/1000", primaryMock}} {
+		expectReplayLSN(replicaMock, begin.replayed)
+		begin.mock.ExpectBegin()
+		begin.mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		begin.mock.ExpectCommit()
+
+		tx, err := db.BeginTx(ctx, readOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM orders").Scan(&count); err != nil || count != 3 {
+			t.Errorf("want the report read in the transaction, got %d, %v", count, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Error(err)
+		}
+	}
+	if m := db.Metrics(); m.ReplicaReads != 1 || m.PrimaryReads != 1 {
+		t.Errorf("want each transaction counted as a read, got %d replica and %d primary reads", m.ReplicaReads, m.PrimaryReads)
+	}
+
+	// read-write transactions stay on the primary
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectRollback()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Rollback()
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBeginReplicaTx(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: false}))
+	ctx := WithLSNContext(context.Background(), &LSNContext{RequiredLSN: LSN{Lower: 0x2000}})
+
+	expectReplayLSN(replicaMock, "0/3000")
+	replicaMock.ExpectBegin()
+	replicaMock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	replicaMock.ExpectQuery("SELECT sum").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(42))
+	replicaMock.ExpectCommit()
+	// a read-write request is still read-only
+	tx, err := db.BeginReplicaTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count, sum int
+	if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM orders").Scan(&count); err != nil {
+		t.Error(err)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT sum(total) FROM orders").Scan(&sum); err != nil || count != 3 || sum != 42 {
+		t.Errorf("want both reads served by the replica, got %d, %d, %v", count, sum, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error(err)
+	}
+
+	// without a caught-up replica nor fallback, no transaction starts
+	expectReplayLSN(replicaMock, "0/1000")
+	if _, err := db.BeginReplicaTx(ctx, nil); !errors.Is(err, ErrStaleReadRefused) {
+		t.Errorf("want the transaction refused, got %v", err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReadOnlyTxNotTrackedAsWrite(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	lsnCtx := &LSNContext{RequiredLSN: LSN{Lower: 0x2000}}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	for name, begin := range map[string]func() (Tx, error){
+		"BeginTx":        func() (Tx, error) { return db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}) },
+		"BeginReplicaTx": func() (Tx, error) { return db.BeginReplicaTx(ctx, nil) },
+	} {
+		// the statements of a read-only transaction on a replica, like SET LOCAL, don't capture a WAL LSN on commit
+		expectReplayLSN(replicaMock, "0/3000")
+		replicaMock.ExpectBegin()
+		replicaMock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+		replicaMock.ExpectCommit()
+
+		tx, err := begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var committed LSN
+		tx.OnCommit(func(_ context.Context, lsn LSN) { committed = lsn })
+		if _, err := tx.ExecContext(ctx, "SET LOCAL statement_timeout = 1000"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if !committed.IsZero() {
+			t.Errorf("%s: want no commit LSN, got %s", name, committed)
+		}
+		if lsnCtx.HasWriteOperation || lsnCtx.RequiredLSN != (LSN{Lower: 0x2000}) || lsnCtx.masterDB != nil {
+			t.Errorf("%s: want the LSN context unchanged, got %+v", name, lsnCtx)
+		}
+	}
+	if failures := db.Metrics().LSNTrackingFailures; failures != 0 {
+		t.Errorf("want no LSN capture, got %d failures", failures)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestTxCommitLSN(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithTxCommitLSN())
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	// the LSN is queried on the connection of the transaction once it committed
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec("UPDATE orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectCommit()
+	primaryMock.ExpectQuery(`SELECT pg_current_wal_insert_lsn\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/5000"))
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = 'paid'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !lsnCtx.HasWriteOperation || lsnCtx.RequiredLSN != (LSN{Lower: 0x5000}) {
+		t.Errorf("want the commit LSN required, got %+v", lsnCtx)
+	}
+
+	// transactions rolled back or without writes capture nothing
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec("UPDATE orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectRollback()
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = 'void'"); err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Rollback()
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = tx.Commit()
+	if lsnCtx.RequiredLSN != (LSN{Lower: 0x5000}) {
+		t.Errorf("want the LSN of the first transaction kept, got %s", lsnCtx.RequiredLSN)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxCommitUpdatesCausalLSN(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica),
+		WithCausalConsistencyConfig(&CausalConsistencyConfig{Enabled: true, Level: ReadYourWrites, FallbackToMaster: true}))
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	// a transaction without writes captures nothing
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil || lsnCtx.HasWriteOperation {
+		t.Fatalf("want no write tracked, got %+v, %v", lsnCtx, err)
+	}
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("INSERT INTO orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	primaryMock.ExpectCommit()
+	expectCurrentWALLSN(primaryMock, "0/4000")
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	var id int
+	if err := tx.QueryRowContext(ctx, "INSERT INTO orders DEFAULT VALUES RETURNING id").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !lsnCtx.HasWriteOperation || lsnCtx.RequiredLSN != (LSN{Lower: 0x4000}) {
+		t.Fatalf("want the commit LSN required, got %+v", lsnCtx)
+	}
+
+	// the next reads observe the commit
+	expectReplayLSN(replicaMock, "0/3000")
+	primaryMock.ExpectQuery("SELECT status").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("new"))
+	var status string
+	if err := db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", id).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package dbresolver
+
+import "context"
+
+// OnCommit registers fn to run once the transaction committed, in the order of registration, with the context
+// which started the transaction and the LSN of the commit: replicas which replayed it observe the writes of the
+// transaction, e.g. to publish an event or to set the LSN cookie with SetLSNCookie. The LSN is captured on the
+// connection of the transaction with WithTxCommitLSN, else on the primary right after COMMIT. It is zero when the
+// transaction didn't write, or when its capture failed, reported to the fallback hook. fn doesn't run after a
+// rollback or a failed commit.
+func (t *tx) OnCommit(fn func(ctx context.Context, lsn LSN)) {
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// afterCommit captures the LSN of the committed transaction when it wrote and is tracked, and runs its hooks
+func (t *tx) afterCommit() {
+	var lsn LSN
+	tracked := GetLSNContext(t.ctx) != nil && (t.commitConn != nil || t.db.queryRouter != nil)
+	if t.writesOccurred && (tracked || len(t.onCommit) > 0) {
+		lsn = t.captureCommitLSN()
+	}
+	for _, fn := range t.onCommit {
+		fn(t.ctx, lsn)
+	}
+}
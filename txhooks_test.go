@@ -0,0 +1,72 @@
+package dbresolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTxOnCommit(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, _ := newMockDB(t)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica))
+	lsnCtx := &LSNContext{}
+	ctx := WithLSNContext(context.Background(), lsnCtx)
+
+	var committed []LSN
+	onCommit := func(hookCtx context.Context, lsn LSN) {
+		if GetLSNContext(hookCtx) != lsnCtx {
+			t.Error("want the hook run with the context of the transaction")
+		}
+		committed = append(committed, lsn)
+	}
+
+	// the LSN of the primary is captured right after COMMIT
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectCommit()
+	expectCurrentWALLSN(primaryMock, "0/3000")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.OnCommit(onCommit)
+	tx.OnCommit(onCommit)
+	if _, err := tx.ExecContext(ctx, "INSERT INTO orders VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(committed) != 2 || committed[0] != (LSN{Lower: 0x3000}) || committed[1] != committed[0] {
+		t.Fatalf("want every hook run with the commit LSN, got %v", committed)
+	}
+	if lsnCtx.RequiredLSN != (LSN{Lower: 0x3000}) {
+		t.Errorf("want the commit LSN required, got %s", lsnCtx.RequiredLSN)
+	}
+
+	// hooks don't run after a rollback or a failed commit, and get a zero LSN without writes
+	committed = nil
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectRollback()
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit().WillReturnError(errors.New("serialization failure"))
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	for _, end := range []func(Tx) error{Tx.Rollback, Tx.Commit, Tx.Commit} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.OnCommit(onCommit)
+		_ = end(tx)
+	}
+	if len(committed) != 1 || !committed[0].IsZero() {
+		t.Errorf("want a single hook run without LSN, got %v", committed)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
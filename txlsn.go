@@ -0,0 +1,104 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// WithTxCommitLSN captures the LSN of the transactions that wrote on their own connection, for a precise LSN
+// context: the transaction holds a connection of the primary until it ends, and pg_current_wal_insert_lsn() is
+// queried on it right after COMMIT, before the connection goes back to the pool, rather than on another
+// connection once other writers moved the WAL further. Querying it before COMMIT would precede the commit
+// record, which replicas must replay for the writes to be visible. The required LSN of the LSN context of the
+// context passed to BeginTx is raised to it.
+func WithTxCommitLSN() OptionFunc {
+	return func(opt *Option) {
+		opt.TxCommitLSN = true
+	}
+}
+
+// beginCommitLSNTx starts a transaction on a connection of primary held until the transaction ends, see
+// WithTxCommitLSN
+func (db *DB) beginCommitLSNTx(ctx context.Context, primary *sql.DB, opts *sql.TxOptions) (Tx, error) {
+	conn, err := primary.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := db.applyTxSearchPath(ctx, primary, stx); err != nil {
+		_ = stx.Rollback()
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &tx{
+		db:               db,
+		sourceDB:         primary,
+		tx:               stx,
+		queryTypeChecker: db.queryTypeChecker,
+		handle:           db.trackHandle(HandleTx, primary),
+		ctx:              ctx,
+		commitConn:       conn,
+	}, nil
+}
+
+// releaseConn releases the connection held by the transaction, see WithTxCommitLSN
+func (t *tx) releaseConn() {
+	if t.commitConn != nil {
+		_ = t.commitConn.Close()
+		t.commitConn = nil
+	}
+}
+
+// captureCommitLSN captures the LSN of the committed transaction, on its connection with WithTxCommitLSN, else
+// with the query router for the LSN context of the transaction, like the writes of Exec, or on the primary. The
+// required LSN of its LSN context is raised to it. A failed capture is reported to the fallback hook, the
+// transaction having committed, and returns a zero LSN.
+func (t *tx) captureCommitLSN() LSN {
+	if lsnCtx := GetLSNContext(t.ctx); lsnCtx != nil && t.commitConn == nil && t.db.queryRouter != nil {
+		lsnCtx.HasWriteOperation = true
+		lsnCtx.masterDB = t.sourceDB
+		lsn, err := t.db.queryRouter.UpdateLSNAfterWrite(t.ctx)
+		if err != nil {
+			if _, reported := t.db.queryRouter.(*CausalRouter); !reported {
+				t.db.lsnTrackingFailed(t.ctx, fmt.Errorf("failed to capture LSN of transaction: %w", err))
+			}
+			return LSN{}
+		}
+		if !lsn.IsZero() {
+			return lsn
+		}
+	}
+
+	var (
+		lsn LSN
+		err error
+	)
+	if t.commitConn != nil {
+		var lsnStr string
+		if err = t.commitConn.QueryRowContext(t.ctx, "SELECT "+PGCurrentWALInsertLSN).Scan(&lsnStr); err == nil {
+			lsn, err = ParseLSN(lsnStr)
+		}
+	} else {
+		router, _ := t.db.queryRouter.(*CausalRouter)
+		lsn, err = getOrCreateChecker(t.sourceDB, t.db.lsnQueryTimeout(router)).GetCurrentWALLSN(t.ctx)
+	}
+	if err != nil {
+		t.db.lsnTrackingFailed(t.ctx, fmt.Errorf("failed to capture LSN of transaction: %w", err))
+		return LSN{}
+	}
+
+	if lsnCtx := GetLSNContext(t.ctx); lsnCtx != nil {
+		lsnCtx.HasWriteOperation = true
+		if lsn.GreaterThan(lsnCtx.RequiredLSN) {
+			lsnCtx.RequiredLSN = lsn
+		}
+	}
+	t.db.hooks.lsnUpdate(lsn)
+	return lsn
+}
@@ -0,0 +1,141 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWarmUpConcurrency = 2
+	defaultWarmUpTimeout     = time.Minute
+)
+
+// WarmUpConfig configures WithReplicaWarmUp
+type WarmUpConfig struct {
+	Queries     []string      // Reads run on the replica, e.g. the hottest queries of the application
+	Concurrency int           // Queries run concurrently, defaults to 2
+	Timeout     time.Duration // Bound of the warm-up, after which the replica serves reads anyway; defaults to 1m
+	// OnWarmedUp is called once a replica serves reads again, with the errors of its failed queries
+	OnWarmedUp func(replica string, errs []error)
+}
+
+// WithReplicaWarmUp runs config.Queries on a replica joining the read pool, before it serves reads: replicas added
+// with AddReplica, undrained with UndrainReplica or passing their health check again after failing it. The queries
+// load the pages they read into the buffer cache of the replica, so its first reads don't hit the disk after a
+// restart or maintenance. Failed queries don't hold the replica back.
+func WithReplicaWarmUp(config WarmUpConfig) OptionFunc {
+	return func(opt *Option) {
+		if config.Concurrency <= 0 {
+			config.Concurrency = defaultWarmUpConcurrency
+		}
+		if config.Timeout <= 0 {
+			config.Timeout = defaultWarmUpTimeout
+		}
+		opt.ReplicaWarmUp = &replicaWarmUp{config: config}
+	}
+}
+
+// replicaWarmUp keeps the replicas warming up out of the read pool. Methods are nil-receiver safe.
+type replicaWarmUp struct {
+	config WarmUpConfig
+
+	mu      sync.RWMutex
+	warming map[*sql.DB]struct{}
+}
+
+// begin marks replica as warming up, returning false if it already is
+func (w *replicaWarmUp) begin(replica *sql.DB) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.warming[replica]; ok {
+		return false
+	}
+	if w.warming == nil {
+		w.warming = make(map[*sql.DB]struct{})
+	}
+	w.warming[replica] = struct{}{}
+	return true
+}
+
+func (w *replicaWarmUp) end(replica *sql.DB) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.warming, replica)
+}
+
+// available returns the replicas that aren't warming up
+func (w *replicaWarmUp) available(replicas []*sql.DB) []*sql.DB {
+	if w == nil {
+		return replicas
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if len(w.warming) == 0 {
+		return replicas
+	}
+	serving := make([]*sql.DB, 0, len(replicas))
+	for _, replica := range replicas {
+		if _, warming := w.warming[replica]; !warming {
+			serving = append(serving, replica)
+		}
+	}
+	return serving
+}
+
+// warmUp runs the warm-up queries on a replica joining the read pool in the background
+func (db *DB) warmUp(replica *sql.DB) {
+	w := db.warmup
+	if w == nil || len(w.config.Queries) == 0 || !w.begin(replica) {
+		return
+	}
+	name := physicalDBName(db, replica)
+	started := db.goBackground(func(stop <-chan struct{}) {
+		ctx, cancel := context.WithTimeout(context.Background(), w.config.Timeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		start := time.Now()
+		errs := make([]error, len(w.config.Queries))
+		slots := make(chan struct{}, w.config.Concurrency)
+		var wg sync.WaitGroup
+		for i, query := range w.config.Queries {
+			slots <- struct{}{}
+			wg.Go(func() {
+				defer func() { <-slots }()
+				rows, err := replica.QueryContext(ctx, query)
+				if err == nil {
+					for rows.Next() {
+					}
+					err = rows.Err()
+					_ = rows.Close()
+				}
+				errs[i] = err
+			})
+		}
+		wg.Wait()
+		w.end(replica)
+
+		var failed []error
+		for _, err := range errs {
+			if err != nil {
+				failed = append(failed, err)
+			}
+		}
+		db.log().Debug("replica warm-up: replica serving reads", "db", name, "took", time.Since(start), "failed", len(failed))
+		if w.config.OnWarmedUp != nil {
+			w.config.OnWarmedUp(name, failed)
+		}
+	})
+	if !started {
+		w.end(replica)
+	}
+}
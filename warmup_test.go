@@ -0,0 +1,73 @@
+package dbresolver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaWarmUp(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	warmed := make(chan []error, 1)
+	db := New(WithPrimaryDBs(primary), WithReplicaWarmUp(WarmUpConfig{
+		Queries:     []string{"SELECT id FROM products", "SELECT id FROM categories"},
+		Concurrency: 1,
+		OnWarmedUp:  func(_ string, errs []error) { warmed <- errs },
+	}))
+	defer db.Close()
+
+	replicaMock.ExpectQuery("SELECT id FROM products").WillDelayFor(50 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	replicaMock.ExpectQuery("SELECT id FROM categories").WillReturnError(errors.New("relation does not exist"))
+	if err := db.AddReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 0 {
+		t.Errorf("want the replica out of the read pool while warming up, got %v", replicas)
+	}
+
+	select {
+	case errs := <-warmed:
+		if len(errs) != 1 {
+			t.Errorf("want the failed warm-up query reported, got %v", errs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want the replica warmed up")
+	}
+	if replicas := db.ReplicaDBs(); len(replicas) != 1 {
+		t.Errorf("want the warmed up replica serving reads, got %v", replicas)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplicaWarmUpOnUndrain(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+	warmed := make(chan []error, 1)
+	db := New(WithPrimaryDBs(primary), WithReplicaDBs(replica), WithReplicaWarmUp(WarmUpConfig{
+		Queries:    []string{"SELECT id FROM products"},
+		OnWarmedUp: func(_ string, errs []error) { warmed <- errs },
+	}))
+	defer db.Close()
+
+	replicaMock.ExpectQuery("SELECT id FROM products").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if err := db.DrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UndrainReplica(replica); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case errs := <-warmed:
+		if len(errs) != 0 {
+			t.Error(errs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want the undrained replica warmed up")
+	}
+}
@@ -0,0 +1,106 @@
+package dbresolver
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// defaultAsyncCaptureTimeout bounds an LSN capture running after the response was sent
+const defaultAsyncCaptureTimeout = 5 * time.Second
+
+// writeBudget decides whether the post-write LSN capture fits in the remaining request budget
+type writeBudget struct {
+	budget       time.Duration // per-request budget, measured from the moment the middleware is entered
+	asyncTimeout time.Duration
+	lastCapture  atomic.Int64 // duration of the last LSN capture, the estimate for the next one
+}
+
+// WithWriteLatencyBudget bounds how long a write request may spend capturing its LSN.
+// The remaining budget is the time left until the request context's deadline or until budget
+// elapsed since the middleware was entered, whichever is earlier; a non-positive budget only uses
+// the context deadline. When the last observed capture took longer than the remaining budget,
+// the capture is skipped for the response and run in the background instead, updating the
+// session store (see WithSessionStore) but never the response cookie. Without a session store
+// the skipped LSN is not tracked and the following reads fall back to the default routing.
+func WithWriteLatencyBudget(budget time.Duration) MiddlewareOption {
+	return func(m *HTTPMiddleware) {
+		m.writeBudget = &writeBudget{budget: budget, asyncTimeout: defaultAsyncCaptureTimeout}
+	}
+}
+
+// deadline returns the point in time the request budget runs out, zero if unbounded
+func (b *writeBudget) deadline(ctx context.Context, start time.Time) time.Time {
+	var deadline time.Time
+	if b.budget > 0 {
+		deadline = start.Add(b.budget)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	return deadline
+}
+
+// fits reports whether a capture is expected to finish before the deadline
+func (b *writeBudget) fits(deadline time.Time) bool {
+	if deadline.IsZero() {
+		return true
+	}
+	return time.Until(deadline) > time.Duration(b.lastCapture.Load())
+}
+
+// observe records the duration of a capture that started at start
+func (b *writeBudget) observe(start time.Time) {
+	b.lastCapture.Store(int64(time.Since(start)))
+}
+
+// captureLSN runs the post-write LSN capture synchronously when it fits in the request budget.
+// Otherwise the capture is scheduled in the background and false is returned.
+func (m *HTTPMiddleware) captureLSN(ctx context.Context, sessionKey string, deadline time.Time) (LSN, bool) {
+	if m.writeBudget == nil {
+		lsn, err := m.router.UpdateLSNAfterWrite(ctx)
+		return lsn, err == nil
+	}
+
+	if !m.writeBudget.fits(deadline) {
+		m.log().Debug("HTTPMiddleware: LSN capture exceeds the remaining write budget, capturing asynchronously",
+			"estimate", time.Duration(m.writeBudget.lastCapture.Load()))
+		m.captureLSNAsync(ctx, sessionKey)
+		return LSN{}, false
+	}
+
+	start := time.Now()
+	lsn, err := m.router.UpdateLSNAfterWrite(ctx)
+	m.writeBudget.observe(start)
+	return lsn, err == nil
+}
+
+// captureLSNAsync captures the LSN after the response was sent and records it in the session store.
+// The capture runs with its own copy of the LSN context, since the handler may still use the original one.
+func (m *HTTPMiddleware) captureLSNAsync(ctx context.Context, sessionKey string) {
+	if m.sessionStore == nil || sessionKey == "" {
+		return
+	}
+
+	lsnCtx := *GetLSNContext(ctx)
+	ctx = contextWithLSN(context.WithoutCancel(ctx), &lsnCtx)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, m.writeBudget.asyncTimeout)
+		defer cancel()
+
+		start := time.Now()
+		lsn, err := m.router.UpdateLSNAfterWrite(ctx)
+		m.writeBudget.observe(start)
+		if err != nil {
+			m.log().Debug("HTTPMiddleware: async LSN capture failed", "error", err)
+			return
+		}
+		if lsn.IsZero() {
+			return
+		}
+		if err := m.sessionStore.Set(ctx, sessionKey, lsn, m.tokenMaxAge()); err != nil {
+			m.log().Debug("HTTPMiddleware: failed to store async LSN in session store", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package dbresolver
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowLSNRouter reports a fixed LSN after every write, taking delay to do so
+type slowLSNRouter struct {
+	lsn   LSN
+	delay time.Duration
+}
+
+func (r *slowLSNRouter) RouteQuery(_ context.Context, _ QueryType) (*sql.DB, error) {
+	return nil, nil
+}
+
+func (r *slowLSNRouter) UpdateLSNAfterWrite(ctx context.Context) (LSN, error) {
+	select {
+	case <-time.After(r.delay):
+		return r.lsn, nil
+	case <-ctx.Done():
+		return LSN{}, ctx.Err()
+	}
+}
+
+func TestHTTPMiddlewareWriteLatencyBudget(t *testing.T) {
+	store := NewMemorySessionLSNStore()
+	router := &slowLSNRouter{lsn: LSN{Upper: 1, Lower: 0xABCDEF}, delay: 100 * time.Millisecond}
+	middleware := NewHTTPMiddleware(router, "", 0, false,
+		WithSessionStore(store, SessionKeyFromHeader("X-User-ID")),
+		WithWriteLatencyBudget(50*time.Millisecond))
+
+	write := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func(user string) time.Duration {
+		req := httptest.NewRequest("POST", "/orders", http.NoBody)
+		req.Header.Set("X-User-ID", user)
+		start := time.Now()
+		write.ServeHTTP(httptest.NewRecorder(), req)
+		return time.Since(start)
+	}
+
+	// without an estimate yet, the first capture runs synchronously
+	serve("1")
+	if lsn, ok, _ := store.Get(context.Background(), "1"); !ok || lsn != router.lsn {
+		t.Fatalf("expected synchronous capture to store %s, got %s (ok=%v)", router.lsn, lsn, ok)
+	}
+
+	// the last capture exceeded the budget, so the next one must not delay the response
+	if elapsed := serve("2"); elapsed >= router.delay {
+		t.Errorf("write response was delayed by the LSN capture: %s", elapsed)
+	}
+	if _, ok, _ := store.Get(context.Background(), "2"); ok {
+		t.Error("async capture must not complete before the response")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if lsn, ok, _ := store.Get(context.Background(), "2"); ok {
+			if lsn != router.lsn {
+				t.Errorf("want %s, got %s", router.lsn, lsn)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("async LSN capture never updated the session store")
+}
+
+func TestHTTPMiddlewareWriteLatencyBudgetCookieMode(t *testing.T) {
+	router := &slowLSNRouter{lsn: LSN{Upper: 1}, delay: 20 * time.Millisecond}
+	middleware := NewHTTPMiddleware(router, "test_lsn", 0, false, WithWriteLatencyBudget(time.Second))
+
+	handler := middleware.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetLSNContext(r.Context()).HasWriteOperation = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// a capture within the budget still sets the cookie
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", http.NoBody))
+	if len(rec.Result().Cookies()) != 1 {
+		t.Fatalf("expected LSN cookie when the capture fits the budget, got %+v", rec.Result().Cookies())
+	}
+
+	// an already expired request deadline skips the capture, and the cookie with it
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", http.NoBody).WithContext(ctx))
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected no LSN cookie once the budget is exhausted, got %+v", rec.Result().Cookies())
+	}
+}